@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/alibaba/higress/ingress/kube/common"
@@ -32,8 +33,10 @@ import (
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
 	kubecontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
 	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/schema/collection"
 	"istio.io/istio/pkg/config/schema/collections"
@@ -43,6 +46,8 @@ import (
 	"istio.io/pkg/env"
 	"istio.io/pkg/ledger"
 	"istio.io/pkg/log"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 
@@ -85,21 +90,25 @@ type RegistryOptions struct {
 }
 
 type ServerArgs struct {
-	Debug                bool
-	MeshId               string
-	RegionId             string
-	NativeIstio          bool
-	HttpAddress          string
-	GrpcAddress          string
-	IngressClass         string
-	EnableStatus         bool
-	WatchNamespace       string
-	GrpcKeepAliveOptions *keepalive.Options
-	XdsOptions           XdsOptions
-	RegistryOptions      RegistryOptions
-	KeepStaleWhenEmpty   bool
-	GatewaySelectorKey   string
-	GatewaySelectorValue string
+	Debug                 bool
+	MeshId                string
+	RegionId              string
+	NativeIstio           bool
+	HttpAddress           string
+	GrpcAddress           string
+	IngressClass          string
+	EnableStatus          bool
+	WatchNamespace        string
+	GrpcKeepAliveOptions  *keepalive.Options
+	XdsOptions            XdsOptions
+	RegistryOptions       RegistryOptions
+	KeepStaleWhenEmpty    bool
+	GatewaySelectorKey    string
+	GatewaySelectorValue  string
+	EnableGatewayAPI      bool
+	EnableErrorAnnotation bool
+	// The service of controller, through which gateways fetch the generic secrets by sds.
+	ControllerService string
 }
 
 type readinessProbe func() (bool, error)
@@ -114,6 +123,7 @@ type Server struct {
 	httpMux          *http.ServeMux
 	grpcServer       *grpc.Server
 	xdsServer        *xds.DiscoveryServer
+	gatewayPods      cache.SharedIndexInformer
 	server           server.Instance
 	readinessProbes  map[string]readinessProbe
 }
@@ -201,23 +211,47 @@ func (s *Server) initRegistryEventHandlers() error {
 	return nil
 }
 
+// sdsCluster returns the cluster of the controller service, whose port is the same as the grpc address.
+func (s *Server) sdsCluster(namespace string) (string, error) {
+	_, portStr, err := net.SplitHostPort(s.GrpcAddress)
+	if err != nil {
+		return "", err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", err
+	}
+	hostname := fmt.Sprintf("%s.%s.svc.%s", s.ControllerService, namespace, s.environment.DomainSuffix)
+	return model.BuildSubsetKey(model.TrafficDirectionOutbound, "", host.Name(hostname), port), nil
+}
+
 func (s *Server) initConfigController() error {
 	ns := PodNamespace
 	options := common.Options{
-		Enable:               true,
-		ClusterId:            string(s.RegistryOptions.KubeOptions.ClusterID),
-		IngressClass:         s.IngressClass,
-		WatchNamespace:       s.WatchNamespace,
-		EnableStatus:         s.EnableStatus,
-		SystemNamespace:      ns,
-		GatewaySelectorKey:   s.GatewaySelectorKey,
-		GatewaySelectorValue: s.GatewaySelectorValue,
+		Enable:                true,
+		ClusterId:             string(s.RegistryOptions.KubeOptions.ClusterID),
+		IngressClass:          s.IngressClass,
+		WatchNamespace:        s.WatchNamespace,
+		EnableStatus:          s.EnableStatus,
+		SystemNamespace:       ns,
+		GatewaySelectorKey:    s.GatewaySelectorKey,
+		GatewaySelectorValue:  s.GatewaySelectorValue,
+		KeepStaleWhenEmpty:    s.KeepStaleWhenEmpty,
+		EnableGatewayAPI:      s.EnableGatewayAPI,
+		EnableErrorAnnotation: s.EnableErrorAnnotation,
 	}
 	if options.ClusterId == "Kubernetes" {
 		options.ClusterId = ""
 	}
+	sdsCluster, err := s.sdsCluster(ns)
+	if err != nil {
+		return err
+	}
+	options.SdsCluster = sdsCluster
 	ingressConfig := ingressconfig.NewIngressConfig(s.kubeClient, s.xdsServer, ns, options.ClusterId)
 	ingressController := ingressConfig.AddLocalCluster(options)
+	// Istio sds only serves the tls certificates, so the generic secrets referred by ingresses are served here.
+	s.xdsServer.McpGenerators[v3.SecretType] = &mcp.SecretGenerator{Server: s.xdsServer, Store: ingressConfig}
 	s.configStores = append(s.configStores, ingressConfig)
 	// Wrap the config controller with a cache.
 	aggregateConfigController, err := configaggregate.MakeCache(s.configStores)
@@ -317,6 +351,8 @@ func (s *Server) initXdsServer() error {
 	s.xdsServer.McpGenerators[gvk.EnvoyFilter.String()] = &mcp.EnvoyFilterGenerator{Server: s.xdsServer}
 	s.xdsServer.McpGenerators[gvk.Gateway.String()] = &mcp.GatewayGenerator{Server: s.xdsServer}
 	s.xdsServer.McpGenerators[gvk.VirtualService.String()] = &mcp.VirtualServiceGenerator{Server: s.xdsServer}
+	s.xdsServer.McpGenerators[gvk.ServiceEntry.String()] = &mcp.ServiceEntryGenerator{Server: s.xdsServer}
+	s.xdsServer.StatusReporter = &mcp.DisconnectReporter{Server: s.xdsServer}
 	s.xdsServer.ProxyNeedsPush = func(proxy *model.Proxy, req *model.PushRequest) bool {
 		return true
 	}
@@ -335,12 +371,33 @@ func (s *Server) initGrpcServer() error {
 		prometheus.UnaryServerInterceptor,
 	}
 	grpcOptions := istiogrpc.ServerOptions(s.GrpcKeepAliveOptions, interceptors...)
+	grpcOptions = append(grpcOptions, grpc.ChainStreamInterceptor(s.gatewayAuthorizer().StreamInterceptor()))
 	s.grpcServer = grpc.NewServer(grpcOptions...)
 	s.xdsServer.Register(s.grpcServer)
 	reflection.Register(s.grpcServer)
 	return nil
 }
 
+// gatewayAuthorizer returns the authorizer only allowing the gateway pods, which run within the same namespace
+// as controller, to fetch the generic secrets served by sds.
+func (s *Server) gatewayAuthorizer() *mcp.GatewayAuthorizer {
+	factory := informers.NewSharedInformerFactoryWithOptions(s.kubeClient.Kube(), 0, informers.WithNamespace(PodNamespace))
+	pods := factory.Core().V1().Pods()
+	s.gatewayPods = pods.Informer()
+	s.server.RunComponent(func(stop <-chan struct{}) error {
+		factory.Start(stop)
+		return nil
+	})
+	selector := labels.Everything()
+	if s.GatewaySelectorKey != "" {
+		selector = labels.SelectorFromSet(map[string]string{s.GatewaySelectorKey: s.GatewaySelectorValue})
+	}
+	return &mcp.GatewayAuthorizer{
+		Pods:     pods.Lister().Pods(PodNamespace),
+		Selector: selector,
+	}
+}
+
 func (s *Server) initKubeClient() error {
 	if s.kubeClient != nil {
 		// Already initialized by startup arguments
@@ -388,6 +445,9 @@ func (s *Server) cachesSynced() bool {
 	if !s.configController.HasSynced() {
 		return false
 	}
+	if !s.gatewayPods.HasSynced() {
+		return false
+	}
 	return true
 }
 
@@ -0,0 +1,1802 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/config/common/matcher/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	ratelimitpb "github.com/envoyproxy/go-control-plane/envoy/config/ratelimit/v3"
+	routepb "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	matchingpb "github.com/envoyproxy/go-control-plane/envoy/extensions/common/matching/v3"
+	ratelimitcommon "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	brotlipb "github.com/envoyproxy/go-control-plane/envoy/extensions/compression/brotli/compressor/v3"
+	gzippb "github.com/envoyproxy/go-control-plane/envoy/extensions/compression/gzip/compressor/v3"
+	skipaction "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/common/matcher/action/v3"
+	bufferpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/buffer/v3"
+	compressorpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/compressor/v3"
+	extauthz "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	jwtauthn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	localratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+	luapb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	oauth2 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/oauth2/v3alpha"
+	ratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ratelimit/v3"
+	httppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	matcherpb "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	metadatapb "github.com/envoyproxy/go-control-plane/envoy/type/metadata/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schema/gvk"
+
+	"github.com/alibaba/higress/ingress/kube/annotations"
+	"github.com/alibaba/higress/ingress/kube/common"
+	"github.com/alibaba/higress/ingress/kube/util"
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	extAuthFilterPrefix = "higress.ext_authz."
+	extAuthSigninFilter = "higress.ext_auth_signin"
+	// The filter after ext_authz filters, which marks the requests allowed by auth services.
+	extAuthAuthorizedFilter = "higress.ext_auth_authorized"
+
+	digestAuthFilter = "higress.digest_auth"
+
+	jwtProviderPrefix = "higress.jwt."
+	jwksFetchTimeout  = 5 * time.Second
+	jwksCacheDuration = 5 * time.Minute
+	// The provider without any key, which rejects all requests of the routes with invalid jwt annotations.
+	jwtInvalidProvider = jwtProviderPrefix + "invalid"
+
+	oidcFilterPrefix       = "higress.oidc."
+	oidcCookieDomainFilter = "higress.oidc_cookie_domain"
+	oidcSignoutPath        = "/signout"
+	oidcTokenTimeout       = 5 * time.Second
+
+	rateLimitFilterPrefix = "higress.ratelimit."
+	rateLimitDomain       = "higress"
+	rateLimitTimeout      = 100 * time.Millisecond
+	// The stage of ratelimit filters ranges from 0 to 10, and the last one is used by local rate limits.
+	maxRateLimitStage = 10
+	rateLimitRouteKey = "route"
+	anonymousConsumer = "anonymous"
+	// The lua filter sets the limit of route into the dynamic metadata, which overrides the limit of descriptors.
+	rateLimitLimitFilter    = "higress.ratelimit_limit"
+	rateLimitLimitNamespace = "higress.ratelimit"
+	rateLimitLimitKey       = "limit"
+
+	localRateLimitFilter     = "higress.local_ratelimit"
+	localRateLimitBodyFilter = "higress.local_ratelimit_body"
+	localRateLimitStatPrefix = "http_local_rate_limiter"
+	localRateLimitStage      = maxRateLimitStage
+	// The header marks the responses of limited requests, and is removed before sent to downstream.
+	localRateLimitHeader = "x-higress-local-ratelimit"
+	// The descriptor key of header_value_match rate limit actions.
+	headerMatchDescriptorKey = "header_match"
+	// The descriptor key of remote_address rate limit actions.
+	remoteAddressDescriptorKey = "remote_address"
+
+	bodySizeFilter   = "higress.body_size"
+	bodyBufferFilter = "higress.body_buffer"
+	// The buffer filter requires a limit, while it is disabled on routes without body size.
+	defaultMaxRequestBytes = 1 << 20
+
+	compressorFilterPrefix = "higress.compressor."
+	compressionMarkFilter  = "higress.compression_mark"
+	// The header carries the compressor of route from the mark filter to compressor filters,
+	// and is removed before sent to upstream.
+	compressionHeader = "x-higress-compression"
+
+	rateLimitLimitLuaCode = `function envoy_on_request(request_handle)
+  request_handle:streamInfo():dynamicMetadata():set("higress.ratelimit", "limit", {requests_per_unit = {{requests_per_unit}}, unit = "{{unit}}"})
+end
+`
+
+	// Set the domain of session cookies issued by the oauth2 filters, according to the host of request.
+	oidcCookieDomainLuaCode = `local domains = {{domains}}
+
+function envoy_on_request(request_handle)
+  local host = string.gsub(request_handle:headers():get(":authority") or "", ":%d+$", "")
+  local domain = domains[host]
+  if domain == nil then
+    domain = domains[string.gsub(host, "^[^.]+", "*")]
+  end
+  if domain ~= nil then
+    request_handle:streamInfo():dynamicMetadata():set("higress.oidc", "cookie_domain", domain)
+  end
+end
+
+function envoy_on_response(response_handle)
+  local metadata = response_handle:streamInfo():dynamicMetadata():get("higress.oidc")
+  if metadata == nil or metadata["cookie_domain"] == nil then
+    return
+  end
+  local cookies = {}
+  for key, value in pairs(response_handle:headers()) do
+    if key == "set-cookie" then
+      table.insert(cookies, value)
+    end
+  end
+  if #cookies == 0 then
+    return
+  end
+  response_handle:headers():remove("set-cookie")
+  for _, cookie in ipairs(cookies) do
+    if string.find(cookie, "^BearerToken=") or string.find(cookie, "^OauthHMAC=") or string.find(cookie, "^OauthExpires=") then
+      cookie = cookie .. "; Domain=" .. metadata["cookie_domain"]
+    end
+    response_handle:headers():add("set-cookie", cookie)
+  end
+end
+`
+
+	// Reject the request whose content length exceeds the limit before its body is read.
+	bodySizeLuaCode = `function envoy_on_request(request_handle)
+  local length = tonumber(request_handle:headers():get("content-length"))
+  if length ~= nil and length > {{limit}} then
+    request_handle:respond({[":status"] = "413"}, "Payload Too Large")
+  end
+end
+`
+
+	// The lua filter requires inline code, and the real code is set per route.
+	noopLuaCode = "function envoy_on_request(request_handle) end"
+
+	// Replace the body of responses limited by the local rate limit filter.
+	localRateLimitBodyLuaCode = `function envoy_on_response(response_handle)
+  local headers = response_handle:headers()
+  if headers:get({{header}}) == nil then
+    return
+  end
+  headers:remove({{header}})
+  headers:replace("content-type", "text/plain; charset=utf-8")
+  response_handle:body():setBytes({{body}})
+end
+`
+
+	// Mark the request with the compressor of route, and the mark from downstream is removed by default.
+	compressionMarkLuaCode = `function envoy_on_request(request_handle)
+  request_handle:headers():remove({{header}})
+end
+`
+
+	compressionRouteLuaCode = `function envoy_on_request(request_handle)
+  request_handle:headers():replace({{header}}, {{compressor}})
+end
+`
+
+	// Only the requests passing the ext_authz filter reach the authorized filter, so a 401 response
+	// without the mark is the denial of auth service rather than the one of upstream.
+	extAuthAuthorizedLuaCode = `function envoy_on_request(request_handle)
+  request_handle:streamInfo():dynamicMetadata():set("higress.ext_auth", "authorized", "true")
+end
+`
+
+	// Redirect to the sign-in url with the original request url when auth service denies the request.
+	extAuthSigninLuaCode = `function envoy_on_request(request_handle)
+  local headers = request_handle:headers()
+  local url = headers:get(":scheme") .. "://" .. headers:get(":authority") .. headers:get(":path")
+  request_handle:streamInfo():dynamicMetadata():set("higress.ext_auth", "request_url", url)
+end
+
+function envoy_on_response(response_handle)
+  if response_handle:headers():get(":status") ~= "401" then
+    return
+  end
+  local metadata = response_handle:streamInfo():dynamicMetadata():get("higress.ext_auth")
+  if metadata ~= nil and metadata["authorized"] ~= nil then
+    return
+  end
+  local location = {{signin}}
+  local param = {{param}}
+  if param ~= "" and metadata ~= nil and metadata["request_url"] ~= nil then
+    local encoded = string.gsub(metadata["request_url"], "([^%w%-%.%_%~])", function(c)
+      return string.format("%%%02X", string.byte(c))
+    end)
+    location = location .. {{separator}} .. param .. "=" .. encoded
+  end
+  response_handle:headers():replace(":status", "302")
+  response_handle:headers():replace("location", location)
+end
+`
+
+	// Digest auth of RFC 7616 with MD5, the nonce is the timestamp signed by HMAC-MD5 with the shared key.
+	// The gateways keep no state of nonces, so a captured request could be replayed until its nonce expires.
+	// The signature covers the address of client to reject the replays from other clients, and nonces expire
+	// soon, while clients get a fresh nonce from the stale challenge without prompting the user again.
+	// Errors of script reject the request instead of skipping the auth.
+	digestAuthLuaCode = `local bit = require("bit")
+local band, bor, bxor, bnot = bit.band, bit.bor, bit.bxor, bit.bnot
+local lshift, rshift, rol, tobit = bit.lshift, bit.rshift, bit.rol, bit.tobit
+
+local realm = {{realm}}
+local credentials = {{credentials}}
+local nonce_key = {{nonce_key}}
+local nonce_max_age = {{nonce_max_age}}
+
+local K = {
+  0xd76aa478, 0xe8c7b756, 0x242070db, 0xc1bdceee, 0xf57c0faf, 0x4787c62a, 0xa8304613, 0xfd469501,
+  0x698098d8, 0x8b44f7af, 0xffff5bb1, 0x895cd7be, 0x6b901122, 0xfd987193, 0xa679438e, 0x49b40821,
+  0xf61e2562, 0xc040b340, 0x265e5a51, 0xe9b6c7aa, 0xd62f105d, 0x02441453, 0xd8a1e681, 0xe7d3fbc8,
+  0x21e1cde6, 0xc33707d6, 0xf4d50d87, 0x455a14ed, 0xa9e3e905, 0xfcefa3f8, 0x676f02d9, 0x8d2a4c8a,
+  0xfffa3942, 0x8771f681, 0x6d9d6122, 0xfde5380c, 0xa4beea44, 0x4bdecfa9, 0xf6bb4b60, 0xbebfbc70,
+  0x289b7ec6, 0xeaa127fa, 0xd4ef3085, 0x04881d05, 0xd9d4d039, 0xe6db99e5, 0x1fa27cf8, 0xc4ac5665,
+  0xf4292244, 0x432aff97, 0xab9423a7, 0xfc93a039, 0x655b59c3, 0x8f0ccc92, 0xffeff47d, 0x85845dd1,
+  0x6fa87e4f, 0xfe2ce6e0, 0xa3014314, 0x4e0811a1, 0xf7537e82, 0xbd3af235, 0x2ad7d2bb, 0xeb86d391,
+}
+
+local S = {}
+local shifts = {{7, 12, 17, 22}, {5, 9, 14, 20}, {4, 11, 16, 23}, {6, 10, 15, 21}}
+for i = 0, 63 do
+  S[i] = shifts[math.floor(i / 16) + 1][i % 4 + 1]
+end
+
+local function le_hex(x)
+  return string.format("%02x%02x%02x%02x", band(x, 0xff), band(rshift(x, 8), 0xff),
+    band(rshift(x, 16), 0xff), band(rshift(x, 24), 0xff))
+end
+
+local function md5(message)
+  local length = #message
+  local bits = length * 8
+  message = message .. "\128" .. string.rep("\0", (55 - length) % 64) ..
+    string.char(band(bits, 0xff), band(rshift(bits, 8), 0xff), band(rshift(bits, 16), 0xff),
+      band(rshift(bits, 24), 0xff), 0, 0, 0, 0)
+
+  local a0, b0, c0, d0 = 0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476
+  for chunk = 1, #message, 64 do
+    local M = {}
+    for i = 0, 15 do
+      local b1, b2, b3, b4 = string.byte(message, chunk + i * 4, chunk + i * 4 + 3)
+      M[i] = bor(b1, lshift(b2, 8), lshift(b3, 16), lshift(b4, 24))
+    end
+    local a, b, c, d = a0, b0, c0, d0
+    for i = 0, 63 do
+      local f, g
+      if i < 16 then
+        f, g = bor(band(b, c), band(bnot(b), d)), i
+      elseif i < 32 then
+        f, g = bor(band(d, b), band(bnot(d), c)), (5 * i + 1) % 16
+      elseif i < 48 then
+        f, g = bxor(b, c, d), (3 * i + 5) % 16
+      else
+        f, g = bxor(c, bor(b, bnot(d))), (7 * i) % 16
+      end
+      a, d, c, b = d, c, b, tobit(b + rol(tobit(a + f + K[i + 1] + M[g]), S[i]))
+    end
+    a0, b0, c0, d0 = tobit(a0 + a), tobit(b0 + b), tobit(c0 + c), tobit(d0 + d)
+  end
+  return le_hex(a0) .. le_hex(b0) .. le_hex(c0) .. le_hex(d0)
+end
+
+local function to_binary(hex)
+  return (string.gsub(hex, "..", function(byte)
+    return string.char(tonumber(byte, 16))
+  end))
+end
+
+local function hmac_md5(key, message)
+  if #key > 64 then
+    key = to_binary(md5(key))
+  end
+  key = key .. string.rep("\0", 64 - #key)
+  local inner = string.gsub(key, ".", function(c) return string.char(bxor(string.byte(c), 0x36)) end)
+  local outer = string.gsub(key, ".", function(c) return string.char(bxor(string.byte(c), 0x5c)) end)
+  return md5(outer .. to_binary(md5(inner .. message)))
+end
+
+local function parse_params(value)
+  local params = {}
+  local pos = 1
+  while true do
+    local key, start = string.match(value, "^[%s,]*([%w_-]+)%s*=%s*()", pos)
+    if key == nil then
+      return params
+    end
+    local param
+    if string.sub(value, start, start) == '"' then
+      local quote = string.find(value, '"', start + 1, true)
+      if quote == nil then
+        return nil
+      end
+      param, pos = string.sub(value, start + 1, quote - 1), quote + 1
+    else
+      param, pos = string.match(value, "^([^,%s]*)()", start)
+    end
+    params[string.lower(key)] = param
+  end
+end
+
+local function client_address(stream_info)
+  local address = stream_info:downstreamDirectRemoteAddress()
+  return string.match(address, "^%[?(.-)%]?:%d+$") or address
+end
+
+local function sign(timestamp, client)
+  return hmac_md5(nonce_key, timestamp .. " " .. client)
+end
+
+local function challenge(stale, client)
+  local timestamp = tostring(os.time())
+  local nonce = timestamp .. "." .. sign(timestamp, client)
+  local value = 'Digest realm="' .. realm .. '", qop="auth", algorithm=MD5, nonce="' .. nonce .. '"'
+  if stale then
+    value = value .. ", stale=true"
+  end
+  return 401, value
+end
+
+local function authenticate(request_handle)
+  if nonce_key == "" then
+    return 503
+  end
+  local headers = request_handle:headers()
+  local client = client_address(request_handle:streamInfo())
+  local authorization = headers:get("authorization")
+  if authorization == nil or string.lower(string.sub(authorization, 1, 7)) ~= "digest " then
+    return challenge(false, client)
+  end
+  local params = parse_params(string.sub(authorization, 8))
+  local ha1 = params and params["username"] and credentials[params["username"]]
+  if ha1 == nil or params["realm"] ~= realm or params["uri"] ~= headers:get(":path") or params["response"] == nil then
+    return challenge(false, client)
+  end
+  local timestamp, signature = string.match(params["nonce"] or "", "^(%d+)%.(%x+)$")
+  if timestamp == nil or signature ~= sign(timestamp, client) then
+    return challenge(false, client)
+  end
+
+  local ha2 = md5(headers:get(":method") .. ":" .. params["uri"])
+  local expected
+  if params["qop"] == nil then
+    expected = md5(ha1 .. ":" .. params["nonce"] .. ":" .. ha2)
+  elseif params["qop"] == "auth" and params["nc"] ~= nil and params["cnonce"] ~= nil then
+    expected = md5(table.concat({ha1, params["nonce"], params["nc"], params["cnonce"], "auth", ha2}, ":"))
+  else
+    return challenge(false, client)
+  end
+  if string.lower(params["response"]) ~= expected then
+    return challenge(false, client)
+  end
+  if os.time() - tonumber(timestamp) > nonce_max_age then
+    return challenge(true, client)
+  end
+  return nil
+end
+
+function envoy_on_request(request_handle)
+  local ok, status, challenge_value = pcall(authenticate, request_handle)
+  if not ok then
+    request_handle:logErr("digest auth error: " .. tostring(status))
+    status, challenge_value = 500, nil
+  end
+  if status == nil then
+    return
+  end
+  local headers = {[":status"] = tostring(status)}
+  if challenge_value ~= nil then
+    headers["www-authenticate"] = challenge_value
+  end
+  request_handle:respond(headers, "")
+end
+`
+)
+
+// constructExtAuthEnvoyFilter generates one ext_authz filter for each auth service. Filters are disabled
+// on all routes by default, and only enabled on the routes configured with the auth service.
+func constructExtAuthEnvoyFilter(routes []*common.WrapperHTTPRoute, namespace string) (*config.Config, error) {
+	filters := map[string]*annotations.ExtAuthConfig{}
+	var routePatches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	var signin bool
+	for _, route := range routes {
+		extAuth := route.WrapperConfig.AnnotationsConfig.ExtAuth
+		name := extAuthFilterName(extAuth)
+		filters[name] = extAuth
+
+		perFilterConfigs := map[string]proto.Message{
+			name: &extauthz.ExtAuthzPerRoute{
+				Override: &extauthz.ExtAuthzPerRoute_CheckSettings{
+					CheckSettings: &extauthz.CheckSettings{},
+				},
+			},
+		}
+		if extAuth.SigninURL != "" {
+			signin = true
+			perFilterConfigs[extAuthSigninFilter] = &luapb.LuaPerRoute{
+				Override: &luapb.LuaPerRoute_SourceCode{
+					SourceCode: &corev3.DataSource{
+						Specifier: &corev3.DataSource_InlineString{
+							InlineString: extAuthSigninCode(extAuth),
+						},
+					},
+				},
+			}
+			perFilterConfigs[extAuthAuthorizedFilter] = &luapb.LuaPerRoute{
+				Override: &luapb.LuaPerRoute_SourceCode{
+					SourceCode: &corev3.DataSource{
+						Specifier: &corev3.DataSource_InlineString{
+							InlineString: extAuthAuthorizedLuaCode,
+						},
+					},
+				},
+			}
+		}
+		patch, err := routePatch(route.HTTPRoute.Name, perFilterConfigs)
+		if err != nil {
+			return nil, err
+		}
+		routePatches = append(routePatches, patch)
+	}
+
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Filters are inserted after cors one by one, so the one inserted later comes first.
+	var patches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	disabledConfigs := map[string]proto.Message{}
+	if signin {
+		patch, err := httpFilterPatch(extAuthAuthorizedFilter, &luapb.Lua{InlineCode: noopLuaCode})
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patch)
+		disabledConfigs[extAuthAuthorizedFilter] = &luapb.LuaPerRoute{
+			Override: &luapb.LuaPerRoute_Disabled{Disabled: true},
+		}
+	}
+	for idx := len(names) - 1; idx >= 0; idx-- {
+		patch, err := httpFilterPatch(names[idx], extAuthzFilter(filters[names[idx]]))
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patch)
+		disabledConfigs[names[idx]] = &extauthz.ExtAuthzPerRoute{
+			Override: &extauthz.ExtAuthzPerRoute_Disabled{Disabled: true},
+		}
+	}
+	if signin {
+		patch, err := httpFilterPatch(extAuthSigninFilter, &luapb.Lua{InlineCode: noopLuaCode})
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patch)
+		disabledConfigs[extAuthSigninFilter] = &luapb.LuaPerRoute{
+			Override: &luapb.LuaPerRoute_Disabled{Disabled: true},
+		}
+	}
+
+	// Patches of the same kind are applied in order, so disable filters on all routes first.
+	disabledPatch, err := routePatch("", disabledConfigs)
+	if err != nil {
+		return nil, err
+	}
+	patches = append(patches, disabledPatch)
+	patches = append(patches, routePatches...)
+
+	return &config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.EnvoyFilter,
+			Name:             common.CreateConvertedName(constants.IstioIngressGatewayName, "ext-auth"),
+			Namespace:        namespace,
+		},
+		Spec: &networking.EnvoyFilter{
+			ConfigPatches: patches,
+		},
+	}, nil
+}
+
+// extAuthFilterName returns the same name for the same auth service, whose configs must be the same as well.
+func extAuthFilterName(extAuth *annotations.ExtAuthConfig) string {
+	key := strings.Join([]string{
+		extAuth.URL,
+		extAuth.Method,
+		extAuth.Timeout.String(),
+		strings.Join(extAuth.RequestHeaders, ","),
+		strings.Join(extAuth.ResponseHeaders, ","),
+	}, "|")
+	hash := md5.Sum([]byte(key))
+	return extAuthFilterPrefix + hex.EncodeToString(hash[:])[:8]
+}
+
+func extAuthzFilter(extAuth *annotations.ExtAuthConfig) *extauthz.ExtAuthz {
+	service := &extauthz.HttpService{
+		ServerUri: &corev3.HttpUri{
+			Uri: extAuth.URL,
+			HttpUpstreamType: &corev3.HttpUri_Cluster{
+				Cluster: model.BuildSubsetKey(model.TrafficDirectionOutbound, "", host.Name(extAuth.ServiceHost), int(extAuth.ServicePort)),
+			},
+			Timeout: durationpb.New(extAuth.Timeout),
+		},
+		PathPrefix: extAuth.PathPrefix,
+	}
+	if len(extAuth.RequestHeaders) > 0 || extAuth.Method != "" {
+		service.AuthorizationRequest = &extauthz.AuthorizationRequest{}
+	}
+	if len(extAuth.RequestHeaders) > 0 {
+		service.AuthorizationRequest.AllowedHeaders = headerMatchers(extAuth.RequestHeaders)
+	}
+	// The auth request copies the method of the original request, unless it is overridden here.
+	if extAuth.Method != "" {
+		service.AuthorizationRequest.HeadersToAdd = []*corev3.HeaderValue{
+			{Key: ":method", Value: extAuth.Method},
+		}
+	}
+	if len(extAuth.ResponseHeaders) > 0 {
+		service.AuthorizationResponse = &extauthz.AuthorizationResponse{
+			AllowedUpstreamHeaders: headerMatchers(extAuth.ResponseHeaders),
+		}
+	}
+
+	return &extauthz.ExtAuthz{
+		Services: &extauthz.ExtAuthz_HttpService{
+			HttpService: service,
+		},
+		TransportApiVersion: corev3.ApiVersion_V3,
+	}
+}
+
+func extAuthSigninCode(extAuth *annotations.ExtAuthConfig) string {
+	separator := "?"
+	if strings.Contains(extAuth.SigninURL, "?") {
+		separator = "&"
+	}
+	return strings.NewReplacer(
+		"{{signin}}", strconv.Quote(extAuth.SigninURL),
+		"{{param}}", strconv.Quote(extAuth.SigninRedirectParam),
+		"{{separator}}", strconv.Quote(separator),
+	).Replace(extAuthSigninLuaCode)
+}
+
+// constructDigestAuthEnvoyFilter generates one lua script for each digest auth rule, and the script of rule
+// is only enabled on the routes of the rule.
+func constructDigestAuthEnvoyFilter(rules *common.DigestAuthRules, namespace string) (*config.Config, error) {
+	sourceCodes := map[string]*corev3.DataSource{}
+	var routePatches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	for idx, rule := range rules.Rules {
+		name := "rule-" + strconv.Itoa(idx)
+		sourceCodes[name] = &corev3.DataSource{
+			Specifier: &corev3.DataSource_InlineString{
+				InlineString: digestAuthCode(rule, rules.NonceKey, rules.NonceMaxAge),
+			},
+		}
+		for _, route := range rule.MatchRoute {
+			patch, err := routePatch(route, map[string]proto.Message{
+				digestAuthFilter: &luapb.LuaPerRoute{
+					Override: &luapb.LuaPerRoute_Name{Name: name},
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+			routePatches = append(routePatches, patch)
+		}
+	}
+
+	filterPatch, err := httpFilterPatch(digestAuthFilter, &luapb.Lua{
+		InlineCode:  noopLuaCode,
+		SourceCodes: sourceCodes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Patches of the same kind are applied in order, so disable the filter on all routes first.
+	disabledPatch, err := routePatch("", map[string]proto.Message{
+		digestAuthFilter: &luapb.LuaPerRoute{
+			Override: &luapb.LuaPerRoute_Disabled{Disabled: true},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.EnvoyFilter,
+			Name:             common.CreateConvertedName(constants.IstioIngressGatewayName, "digest-auth"),
+			Namespace:        namespace,
+		},
+		Spec: &networking.EnvoyFilter{
+			ConfigPatches: append([]*networking.EnvoyFilter_EnvoyConfigObjectPatch{filterPatch, disabledPatch}, routePatches...),
+		},
+	}, nil
+}
+
+func digestAuthCode(rule *common.DigestRule, nonceKey string, nonceMaxAge int64) string {
+	var credentials []string
+	for _, credential := range rule.Credentials {
+		idx := strings.LastIndex(credential, ":")
+		if idx < 0 {
+			continue
+		}
+		credentials = append(credentials, fmt.Sprintf("[%s] = %s", luaString(credential[:idx]), luaString(credential[idx+1:])))
+	}
+	return strings.NewReplacer(
+		"{{realm}}", luaString(rule.Realm),
+		"{{credentials}}", "{"+strings.Join(credentials, ", ")+"}",
+		"{{nonce_key}}", luaString(nonceKey),
+		"{{nonce_max_age}}", strconv.FormatInt(nonceMaxAge, 10),
+	).Replace(digestAuthLuaCode)
+}
+
+// luaString quotes the string as a lua literal, where the bytes except printable ascii are escaped in decimal.
+func luaString(s string) string {
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x20 && c < 0x7f && c != '"' && c != '\\' {
+			builder.WriteByte(c)
+		} else {
+			fmt.Fprintf(&builder, "\\%03d", c)
+		}
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
+
+func headerMatchers(headers []string) *matcherpb.ListStringMatcher {
+	matchers := &matcherpb.ListStringMatcher{}
+	for _, header := range headers {
+		matchers.Patterns = append(matchers.Patterns, &matcherpb.StringMatcher{
+			MatchPattern: &matcherpb.StringMatcher_Exact{
+				Exact: header,
+			},
+			IgnoreCase: true,
+		})
+	}
+	return matchers
+}
+
+// httpFilterPatch inserts the http filter after the cors filter of gateways.
+func httpFilterPatch(name string, filter proto.Message) (*networking.EnvoyFilter_EnvoyConfigObjectPatch, error) {
+	filterAny, err := anypb.New(filter)
+	if err != nil {
+		return nil, err
+	}
+	typedConfig := &httppb.HttpFilter{
+		Name: name,
+		ConfigType: &httppb.HttpFilter_TypedConfig{
+			TypedConfig: filterAny,
+		},
+	}
+	gogoTypedConfig, err := util.MessageToGoGoStruct(typedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &networking.EnvoyFilter_EnvoyConfigObjectPatch{
+		ApplyTo: networking.EnvoyFilter_HTTP_FILTER,
+		Match: &networking.EnvoyFilter_EnvoyConfigObjectMatch{
+			Context: networking.EnvoyFilter_GATEWAY,
+			ObjectTypes: &networking.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+				Listener: &networking.EnvoyFilter_ListenerMatch{
+					FilterChain: &networking.EnvoyFilter_ListenerMatch_FilterChainMatch{
+						Filter: &networking.EnvoyFilter_ListenerMatch_FilterMatch{
+							Name: "envoy.filters.network.http_connection_manager",
+							SubFilter: &networking.EnvoyFilter_ListenerMatch_SubFilterMatch{
+								Name: "envoy.filters.http.cors",
+							},
+						},
+					},
+				},
+			},
+		},
+		Patch: &networking.EnvoyFilter_Patch{
+			Operation: networking.EnvoyFilter_Patch_INSERT_AFTER,
+			Value:     gogoTypedConfig,
+		},
+	}, nil
+}
+
+// routePatch merges the per filter configs into the route of gateways, all routes are patched if the name is empty.
+func routePatch(routeName string, perFilterConfigs map[string]proto.Message) (*networking.EnvoyFilter_EnvoyConfigObjectPatch, error) {
+	typedPerFilterConfig, err := toTypedPerFilterConfig(perFilterConfigs)
+	if err != nil {
+		return nil, err
+	}
+	return routeMergePatch(routeName, &routepb.Route{
+		TypedPerFilterConfig: typedPerFilterConfig,
+	})
+}
+
+func toTypedPerFilterConfig(perFilterConfigs map[string]proto.Message) (map[string]*anypb.Any, error) {
+	typedPerFilterConfig := map[string]*anypb.Any{}
+	for name, perFilterConfig := range perFilterConfigs {
+		configAny, err := anypb.New(perFilterConfig)
+		if err != nil {
+			return nil, err
+		}
+		typedPerFilterConfig[name] = configAny
+	}
+	return typedPerFilterConfig, nil
+}
+
+// routeMergePatch merges the partial route into the route of gateways, all routes are patched if the name is empty.
+func routeMergePatch(routeName string, route *routepb.Route) (*networking.EnvoyFilter_EnvoyConfigObjectPatch, error) {
+	gogoValue, err := util.MessageToGoGoStruct(route)
+	if err != nil {
+		return nil, err
+	}
+
+	match := &networking.EnvoyFilter_EnvoyConfigObjectMatch{
+		Context: networking.EnvoyFilter_GATEWAY,
+	}
+	if routeName != "" {
+		match.ObjectTypes = &networking.EnvoyFilter_EnvoyConfigObjectMatch_RouteConfiguration{
+			RouteConfiguration: &networking.EnvoyFilter_RouteConfigurationMatch{
+				Vhost: &networking.EnvoyFilter_RouteConfigurationMatch_VirtualHostMatch{
+					Route: &networking.EnvoyFilter_RouteConfigurationMatch_RouteMatch{
+						Name: routeName,
+					},
+				},
+			},
+		}
+	}
+
+	return &networking.EnvoyFilter_EnvoyConfigObjectPatch{
+		ApplyTo: networking.EnvoyFilter_HTTP_ROUTE,
+		Match:   match,
+		Patch: &networking.EnvoyFilter_Patch{
+			Operation: networking.EnvoyFilter_Patch_MERGE,
+			Value:     gogoValue,
+		},
+	}, nil
+}
+
+// constructJwtAuthnEnvoyFilter generates the jwt authn filter with one provider for each issuer and jwks.
+// The requirement of each route is keyed by the route name, and routes without requirement are not verified.
+func constructJwtAuthnEnvoyFilter(routes []*common.WrapperHTTPRoute, namespace string) (*config.Config, error) {
+	jwtAuthn := &jwtauthn.JwtAuthentication{
+		Providers:      map[string]*jwtauthn.JwtProvider{},
+		RequirementMap: map[string]*jwtauthn.JwtRequirement{},
+	}
+	var routePatches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	for _, route := range routes {
+		jwt := route.WrapperConfig.AnnotationsConfig.Jwt
+		name := jwtInvalidProvider
+		if jwt.Invalid {
+			jwtAuthn.Providers[name] = &jwtauthn.JwtProvider{
+				JwksSourceSpecifier: &jwtauthn.JwtProvider_LocalJwks{
+					LocalJwks: &corev3.DataSource{
+						Specifier: &corev3.DataSource_InlineString{
+							InlineString: `{"keys":[]}`,
+						},
+					},
+				},
+			}
+		} else {
+			name = jwtProviderName(jwt)
+			jwtAuthn.Providers[name] = jwtProvider(jwt)
+		}
+
+		requirement := &jwtauthn.JwtRequirement{
+			RequiresType: &jwtauthn.JwtRequirement_ProviderName{
+				ProviderName: name,
+			},
+		}
+		if jwt.Optional {
+			requirement = &jwtauthn.JwtRequirement{
+				RequiresType: &jwtauthn.JwtRequirement_RequiresAny{
+					RequiresAny: &jwtauthn.JwtRequirementOrList{
+						Requirements: []*jwtauthn.JwtRequirement{
+							requirement,
+							{
+								RequiresType: &jwtauthn.JwtRequirement_AllowMissing{
+									AllowMissing: &emptypb.Empty{},
+								},
+							},
+						},
+					},
+				},
+			}
+		}
+		jwtAuthn.RequirementMap[route.HTTPRoute.Name] = requirement
+
+		patch, err := routePatch(route.HTTPRoute.Name, map[string]proto.Message{
+			annotations.JwtAuthnFilterName: &jwtauthn.PerRouteConfig{
+				RequirementSpecifier: &jwtauthn.PerRouteConfig_RequirementName{
+					RequirementName: route.HTTPRoute.Name,
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		routePatches = append(routePatches, patch)
+	}
+
+	filterPatch, err := httpFilterPatch(annotations.JwtAuthnFilterName, jwtAuthn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.EnvoyFilter,
+			Name:             common.CreateConvertedName(constants.IstioIngressGatewayName, "jwt-authn"),
+			Namespace:        namespace,
+		},
+		Spec: &networking.EnvoyFilter{
+			ConfigPatches: append([]*networking.EnvoyFilter_EnvoyConfigObjectPatch{filterPatch}, routePatches...),
+		},
+	}, nil
+}
+
+// jwtProviderName returns the same name for the same issuer, audiences and jwks.
+func jwtProviderName(jwt *annotations.JwtConfig) string {
+	key := strings.Join([]string{
+		jwt.Issuer,
+		strings.Join(jwt.Audiences, ","),
+		jwt.Jwks,
+		jwt.JwksURI,
+	}, "|")
+	hash := md5.Sum([]byte(key))
+	return jwtProviderPrefix + hex.EncodeToString(hash[:])[:8]
+}
+
+func jwtProvider(jwt *annotations.JwtConfig) *jwtauthn.JwtProvider {
+	provider := &jwtauthn.JwtProvider{
+		Issuer:    jwt.Issuer,
+		Audiences: jwt.Audiences,
+		// Keep the token for upstream, like the ingress without jwt.
+		Forward:           true,
+		PayloadInMetadata: annotations.JwtPayloadMetadataKey,
+	}
+	if jwt.Jwks != "" {
+		provider.JwksSourceSpecifier = &jwtauthn.JwtProvider_LocalJwks{
+			LocalJwks: &corev3.DataSource{
+				Specifier: &corev3.DataSource_InlineString{
+					InlineString: jwt.Jwks,
+				},
+			},
+		}
+	} else {
+		provider.JwksSourceSpecifier = &jwtauthn.JwtProvider_RemoteJwks{
+			RemoteJwks: &jwtauthn.RemoteJwks{
+				HttpUri: &corev3.HttpUri{
+					Uri: jwt.JwksURI,
+					HttpUpstreamType: &corev3.HttpUri_Cluster{
+						Cluster: model.BuildSubsetKey(model.TrafficDirectionOutbound, "", host.Name(jwt.JwksServiceHost), int(jwt.JwksServicePort)),
+					},
+					Timeout: durationpb.New(jwksFetchTimeout),
+				},
+				CacheDuration: durationpb.New(jwksCacheDuration),
+			},
+		}
+	}
+	return provider
+}
+
+// constructOidcEnvoyFilter generates one oauth2 filter for each host, since the filter doesn't support per route config.
+// Requests of other hosts pass through the filter. The client secrets and the hmac secret are fetched by sds
+// from the cluster of controller.
+func constructOidcEnvoyFilter(hostConfigs map[string]*annotations.OidcConfig, hmacSecret, sdsCluster, namespace string) (*config.Config, error) {
+	hosts := make([]string, 0, len(hostConfigs))
+	for host := range hostConfigs {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	// Filters are inserted after cors one by one, so the one inserted later comes first.
+	var patches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	var cookieDomains []string
+	for idx := len(hosts) - 1; idx >= 0; idx-- {
+		oidc := hostConfigs[hosts[idx]]
+		patch, err := httpFilterPatch(oidcFilterPrefix+hosts[idx], oauth2Filter(hosts[idx], oidc, hmacSecret, sdsCluster))
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patch)
+		if oidc.CookieDomain != "" {
+			cookieDomains = append(cookieDomains, fmt.Sprintf("[%s] = %s", strconv.Quote(hosts[idx]), strconv.Quote(oidc.CookieDomain)))
+		}
+	}
+	// The cookie domain filter must be ahead of oauth2 filters to handle their responses.
+	if len(cookieDomains) > 0 {
+		sort.Strings(cookieDomains)
+		code := strings.Replace(oidcCookieDomainLuaCode, "{{domains}}", "{"+strings.Join(cookieDomains, ", ")+"}", 1)
+		patch, err := httpFilterPatch(oidcCookieDomainFilter, &luapb.Lua{InlineCode: code})
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patch)
+	}
+
+	return &config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.EnvoyFilter,
+			Name:             common.CreateConvertedName(constants.IstioIngressGatewayName, "oidc"),
+			Namespace:        namespace,
+		},
+		Spec: &networking.EnvoyFilter{
+			ConfigPatches: patches,
+		},
+	}, nil
+}
+
+func oauth2Filter(domain string, oidc *annotations.OidcConfig, hmacSecret, sdsCluster string) *oauth2.OAuth2 {
+	sdsConfig := &corev3.ConfigSource{
+		ConfigSourceSpecifier: &corev3.ConfigSource_ApiConfigSource{
+			ApiConfigSource: &corev3.ApiConfigSource{
+				ApiType:             corev3.ApiConfigSource_GRPC,
+				TransportApiVersion: corev3.ApiVersion_V3,
+				GrpcServices: []*corev3.GrpcService{
+					{
+						TargetSpecifier: &corev3.GrpcService_EnvoyGrpc_{
+							EnvoyGrpc: &corev3.GrpcService_EnvoyGrpc{
+								ClusterName: sdsCluster,
+							},
+						},
+					},
+				},
+			},
+		},
+		// Wait for the secrets without timeout, otherwise the filter would work with empty secrets.
+		InitialFetchTimeout: durationpb.New(0),
+		ResourceApiVersion:  corev3.ApiVersion_V3,
+	}
+	oauth2Config := &oauth2.OAuth2Config{
+		TokenEndpoint: &corev3.HttpUri{
+			Uri: oidc.TokenEndpoint,
+			HttpUpstreamType: &corev3.HttpUri_Cluster{
+				Cluster: model.BuildSubsetKey(model.TrafficDirectionOutbound, "", host.Name(oidc.TokenServiceHost), int(oidc.TokenServicePort)),
+			},
+			Timeout: durationpb.New(oidcTokenTimeout),
+		},
+		AuthorizationEndpoint: oidc.AuthorizationEndpoint,
+		Credentials: &oauth2.OAuth2Credentials{
+			ClientId: oidc.ClientId,
+			TokenSecret: &tlsv3.SdsSecretConfig{
+				Name:      oidcClientSecret(oidc).Name(),
+				SdsConfig: sdsConfig,
+			},
+			TokenFormation: &oauth2.OAuth2Credentials_HmacSecret{
+				HmacSecret: &tlsv3.SdsSecretConfig{
+					Name:      hmacSecret,
+					SdsConfig: sdsConfig,
+				},
+			},
+		},
+		RedirectUri:         "%REQ(x-forwarded-proto)%://%REQ(:authority)%" + oidc.RedirectPath,
+		RedirectPathMatcher: pathMatcher(oidc.RedirectPath),
+		SignoutPath:         pathMatcher(oidcSignoutPath),
+		ForwardBearerToken:  true,
+		AuthScopes:          oidc.Scopes,
+	}
+	if domain != "*" {
+		oauth2Config.PassThroughMatcher = []*routepb.HeaderMatcher{
+			{
+				Name: ":authority",
+				HeaderMatchSpecifier: &routepb.HeaderMatcher_SafeRegexMatch{
+					SafeRegexMatch: &matcherpb.RegexMatcher{
+						EngineType: &matcherpb.RegexMatcher_GoogleRe2{
+							GoogleRe2: &matcherpb.RegexMatcher_GoogleRE2{},
+						},
+						Regex: authorityRegex(domain),
+					},
+				},
+				InvertMatch: true,
+			},
+		}
+	}
+	return &oauth2.OAuth2{
+		Config: oauth2Config,
+	}
+}
+
+// authorityRegex matches the host with an optional port, and the wildcard matches one label.
+func authorityRegex(domain string) string {
+	if strings.HasPrefix(domain, "*.") {
+		return `^[^.]+` + regexp.QuoteMeta(domain[1:]) + `(:\d+)?$`
+	}
+	return "^" + regexp.QuoteMeta(domain) + `(:\d+)?$`
+}
+
+func pathMatcher(path string) *matcherpb.PathMatcher {
+	return &matcherpb.PathMatcher{
+		Rule: &matcherpb.PathMatcher_Path{
+			Path: &matcherpb.StringMatcher{
+				MatchPattern: &matcherpb.StringMatcher_Exact{
+					Exact: path,
+				},
+			},
+		},
+	}
+}
+
+// constructGlobalRateLimitEnvoyFilter generates one ratelimit filter for each rate limit service, and the rate limits
+// of routes. Each filter works on its own stage, so routes only call the service configured for them.
+// The limit of route is sent along with the descriptor as the limit override of rls protocol, so the service
+// doesn't need any config about the routes.
+func constructGlobalRateLimitEnvoyFilter(routes []*common.WrapperHTTPRoute, namespace string) (*config.Config, error) {
+	services := map[string]*annotations.GlobalRateLimitConfig{}
+	for _, route := range routes {
+		global := route.WrapperConfig.AnnotationsConfig.GlobalRateLimit
+		services[rateLimitServiceCluster(global)] = global
+	}
+	clusters := make([]string, 0, len(services))
+	for cluster := range services {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+
+	stages := map[string]uint32{}
+	var patches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	for idx := len(clusters) - 1; idx >= 0; idx-- {
+		if idx >= localRateLimitStage {
+			IngressLog.Errorf("Too many global rate limit services, ignore service %s", clusters[idx])
+			continue
+		}
+		stages[clusters[idx]] = uint32(idx)
+		patch, err := httpFilterPatch(rateLimitFilterPrefix+strconv.Itoa(idx), rateLimitFilter(clusters[idx], uint32(idx)))
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patch)
+	}
+
+	sourceCodes := map[string]*corev3.DataSource{}
+	var routePatches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	for _, route := range routes {
+		global := route.WrapperConfig.AnnotationsConfig.GlobalRateLimit
+		stage, exist := stages[rateLimitServiceCluster(global)]
+		if !exist {
+			continue
+		}
+		// Routes with the same limit share the source code.
+		name := fmt.Sprintf("%d/%s", global.RequestsPerUnit, global.Unit)
+		sourceCodes[name] = &corev3.DataSource{
+			Specifier: &corev3.DataSource_InlineString{
+				InlineString: rateLimitLimitCode(global),
+			},
+		}
+		typedPerFilterConfig, err := toTypedPerFilterConfig(map[string]proto.Message{
+			rateLimitLimitFilter: &luapb.LuaPerRoute{
+				Override: &luapb.LuaPerRoute_Name{Name: name},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		patch, err := routeMergePatch(route.HTTPRoute.Name, &routepb.Route{
+			Action: &routepb.Route_Route{
+				Route: &routepb.RouteAction{
+					RateLimits: []*routepb.RateLimit{
+						{
+							Stage:   wrapperspb.UInt32(stage),
+							Actions: rateLimitActions(route.HTTPRoute.Name, global),
+							Limit:   rateLimitOverride(),
+						},
+					},
+				},
+			},
+			TypedPerFilterConfig: typedPerFilterConfig,
+		})
+		if err != nil {
+			return nil, err
+		}
+		routePatches = append(routePatches, patch)
+	}
+
+	// The limit filter is inserted after the ratelimit filters, so it comes first.
+	limitPatch, err := httpFilterPatch(rateLimitLimitFilter, &luapb.Lua{
+		InlineCode:  noopLuaCode,
+		SourceCodes: sourceCodes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Patches of the same kind are applied in order, so disable the filter on all routes first.
+	disabledPatch, err := routePatch("", map[string]proto.Message{
+		rateLimitLimitFilter: &luapb.LuaPerRoute{
+			Override: &luapb.LuaPerRoute_Disabled{Disabled: true},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	patches = append(patches, limitPatch, disabledPatch)
+
+	return &config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.EnvoyFilter,
+			Name:             common.CreateConvertedName(constants.IstioIngressGatewayName, "global-rate-limit"),
+			Namespace:        namespace,
+		},
+		Spec: &networking.EnvoyFilter{
+			ConfigPatches: append(patches, routePatches...),
+		},
+	}, nil
+}
+
+func rateLimitLimitCode(global *annotations.GlobalRateLimitConfig) string {
+	unit := typev3.RateLimitUnit_SECOND
+	if global.Unit == annotations.RateLimitUnitMinute {
+		unit = typev3.RateLimitUnit_MINUTE
+	}
+	return strings.NewReplacer(
+		"{{requests_per_unit}}", strconv.FormatUint(uint64(global.RequestsPerUnit), 10),
+		"{{unit}}", unit.String(),
+	).Replace(rateLimitLimitLuaCode)
+}
+
+// rateLimitOverride reads the limit set by the limit filter from the dynamic metadata.
+func rateLimitOverride() *routepb.RateLimit_Override {
+	return &routepb.RateLimit_Override{
+		OverrideSpecifier: &routepb.RateLimit_Override_DynamicMetadata_{
+			DynamicMetadata: &routepb.RateLimit_Override_DynamicMetadata{
+				MetadataKey: &metadatapb.MetadataKey{
+					Key: rateLimitLimitNamespace,
+					Path: []*metadatapb.MetadataKey_PathSegment{
+						{
+							Segment: &metadatapb.MetadataKey_PathSegment_Key{
+								Key: rateLimitLimitKey,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func rateLimitServiceCluster(global *annotations.GlobalRateLimitConfig) string {
+	return model.BuildSubsetKey(model.TrafficDirectionOutbound, "", host.Name(global.ServiceHost), int(global.ServicePort))
+}
+
+func rateLimitFilter(cluster string, stage uint32) *ratelimit.RateLimit {
+	return &ratelimit.RateLimit{
+		Domain: rateLimitDomain,
+		Stage:  stage,
+		// Requests are rejected if the rate limit service is unavailable, otherwise the limits silently stop working.
+		FailureModeDeny: true,
+		Timeout:         durationpb.New(rateLimitTimeout),
+		RateLimitService: &ratelimitpb.RateLimitServiceConfig{
+			GrpcService: &corev3.GrpcService{
+				TargetSpecifier: &corev3.GrpcService_EnvoyGrpc_{
+					EnvoyGrpc: &corev3.GrpcService_EnvoyGrpc{
+						ClusterName: cluster,
+					},
+				},
+			},
+			TransportApiVersion: corev3.ApiVersion_V3,
+		},
+	}
+}
+
+// rateLimitActions generates the descriptor of route, which begins with the route name, such as
+// [("route", "foo"), ("remote_address", "10.0.0.1")]. The rate limit service counts requests by the whole
+// descriptor, and rejects them when the count exceeds the limit override of descriptor.
+func rateLimitActions(routeName string, global *annotations.GlobalRateLimitConfig) []*routepb.RateLimit_Action {
+	actions := []*routepb.RateLimit_Action{
+		genericKeyAction(rateLimitRouteKey, routeName),
+	}
+	for _, key := range global.Keys {
+		switch key.Type {
+		case annotations.RateLimitByRemoteAddr:
+			actions = append(actions, &routepb.RateLimit_Action{
+				ActionSpecifier: &routepb.RateLimit_Action_RemoteAddress_{
+					RemoteAddress: &routepb.RateLimit_Action_RemoteAddress{},
+				},
+			})
+		case annotations.RateLimitByHeader, annotations.RateLimitByPath:
+			header, descriptorKey := key.Name, key.Name
+			if key.Type == annotations.RateLimitByPath {
+				header, descriptorKey = ":path", "path"
+			}
+			// Requests without the header share the same count, rather than skip the rate limit.
+			actions = append(actions, &routepb.RateLimit_Action{
+				ActionSpecifier: &routepb.RateLimit_Action_RequestHeaders_{
+					RequestHeaders: &routepb.RateLimit_Action_RequestHeaders{
+						HeaderName:    header,
+						DescriptorKey: descriptorKey,
+						SkipIfAbsent:  true,
+					},
+				},
+			})
+		case annotations.RateLimitByConsumer:
+			actions = append(actions, &routepb.RateLimit_Action{
+				ActionSpecifier: &routepb.RateLimit_Action_Metadata{
+					Metadata: &routepb.RateLimit_Action_MetaData{
+						DescriptorKey: annotations.RateLimitByConsumer,
+						MetadataKey: &metadatapb.MetadataKey{
+							Key: annotations.JwtAuthnFilterName,
+							Path: []*metadatapb.MetadataKey_PathSegment{
+								{
+									Segment: &metadatapb.MetadataKey_PathSegment_Key{
+										Key: annotations.JwtPayloadMetadataKey,
+									},
+								},
+								{
+									Segment: &metadatapb.MetadataKey_PathSegment_Key{
+										Key: key.Name,
+									},
+								},
+							},
+						},
+						DefaultValue: anonymousConsumer,
+						Source:       routepb.RateLimit_Action_MetaData_DYNAMIC,
+					},
+				},
+			})
+		}
+	}
+	return actions
+}
+
+func genericKeyAction(key, value string) *routepb.RateLimit_Action {
+	return &routepb.RateLimit_Action{
+		ActionSpecifier: &routepb.RateLimit_Action_GenericKey_{
+			GenericKey: &routepb.RateLimit_Action_GenericKey{
+				DescriptorKey:   key,
+				DescriptorValue: value,
+			},
+		},
+	}
+}
+
+// constructLocalRateLimitEnvoyFilter generates the local ratelimit filter for the local rate limits not supported by
+// the route filter. The filter is disabled by default, and enabled by the config of each route.
+func constructLocalRateLimitEnvoyFilter(routes []*common.WrapperHTTPRoute, namespace string) (*config.Config, error) {
+	var routePatches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	var body bool
+	for _, route := range routes {
+		local := route.WrapperConfig.AnnotationsConfig.LocalRateLimit
+		perFilterConfigs := map[string]proto.Message{
+			localRateLimitFilter: localRateLimitPerRoute(local),
+		}
+		if local.Body != "" {
+			body = true
+			perFilterConfigs[localRateLimitBodyFilter] = &luapb.LuaPerRoute{
+				Override: &luapb.LuaPerRoute_SourceCode{
+					SourceCode: &corev3.DataSource{
+						Specifier: &corev3.DataSource_InlineString{
+							InlineString: strings.NewReplacer(
+								"{{header}}", strconv.Quote(localRateLimitHeader),
+								"{{body}}", strconv.Quote(local.Body),
+							).Replace(localRateLimitBodyLuaCode),
+						},
+					},
+				},
+			}
+		}
+
+		typedPerFilterConfig, err := toTypedPerFilterConfig(perFilterConfigs)
+		if err != nil {
+			return nil, err
+		}
+		partialRoute := &routepb.Route{
+			TypedPerFilterConfig: typedPerFilterConfig,
+		}
+		if local.Key != nil {
+			partialRoute.Action = &routepb.Route_Route{
+				Route: &routepb.RouteAction{
+					RateLimits: localRateLimits(local.Key),
+				},
+			}
+		}
+		patch, err := routeMergePatch(route.HTTPRoute.Name, partialRoute)
+		if err != nil {
+			return nil, err
+		}
+		routePatches = append(routePatches, patch)
+	}
+
+	var patches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	patch, err := httpFilterPatch(localRateLimitFilter, &localratelimit.LocalRateLimit{
+		StatPrefix: localRateLimitStatPrefix,
+	})
+	if err != nil {
+		return nil, err
+	}
+	patches = append(patches, patch)
+	// The body filter comes before the local ratelimit filter, so it can see the limited responses.
+	if body {
+		patch, err = httpFilterPatch(localRateLimitBodyFilter, &luapb.Lua{InlineCode: noopLuaCode})
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patch)
+		patch, err = routePatch("", map[string]proto.Message{
+			localRateLimitBodyFilter: &luapb.LuaPerRoute{
+				Override: &luapb.LuaPerRoute_Disabled{Disabled: true},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patch)
+	}
+	patches = append(patches, routePatches...)
+
+	return &config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.EnvoyFilter,
+			Name:             common.CreateConvertedName(constants.IstioIngressGatewayName, "local-rate-limit"),
+			Namespace:        namespace,
+		},
+		Spec: &networking.EnvoyFilter{
+			ConfigPatches: patches,
+		},
+	}, nil
+}
+
+func localRateLimitPerRoute(local *annotations.LocalRateLimitConfig) *localratelimit.LocalRateLimit {
+	fillInterval := durationpb.New(time.Duration(local.FillInterval.Seconds) * time.Second)
+	perRoute := &localratelimit.LocalRateLimit{
+		StatPrefix: localRateLimitStatPrefix,
+		Status: &typev3.HttpStatus{
+			Code: typev3.StatusCode(local.GetStatusCode()),
+		},
+		TokenBucket: &typev3.TokenBucket{
+			MaxTokens:     local.MaxTokens,
+			TokensPerFill: wrapperspb.UInt32(local.TokensPerFill),
+			FillInterval:  fillInterval,
+		},
+		FilterEnabled:  fullRuntimeFraction("local_rate_limit_enabled"),
+		FilterEnforced: fullRuntimeFraction("local_rate_limit_enforced"),
+		Stage:          localRateLimitStage,
+	}
+	if local.Body != "" {
+		perRoute.ResponseHeadersToAdd = []*corev3.HeaderValueOption{
+			{
+				Header: &corev3.HeaderValue{
+					Key:   localRateLimitHeader,
+					Value: "true",
+				},
+			},
+		}
+	}
+	if local.Key != nil {
+		descriptorKey := headerMatchDescriptorKey
+		if local.Key.RemoteAddress {
+			descriptorKey = remoteAddressDescriptorKey
+		}
+		for _, bucket := range local.Key.Buckets {
+			perRoute.Descriptors = append(perRoute.Descriptors, &ratelimitcommon.LocalRateLimitDescriptor{
+				Entries: []*ratelimitcommon.RateLimitDescriptor_Entry{
+					{
+						Key:   descriptorKey,
+						Value: bucket.Value,
+					},
+				},
+				TokenBucket: &typev3.TokenBucket{
+					MaxTokens:     bucket.MaxTokens,
+					TokensPerFill: wrapperspb.UInt32(bucket.TokensPerFill),
+					FillInterval:  fillInterval,
+				},
+			})
+		}
+	}
+	return perRoute
+}
+
+// localRateLimits generates one rate limit for each bucket, whose descriptor is [("header_match", value)]
+// if the key of request matches the value of bucket. The remote address is limited by one rate limit,
+// whose descriptor is [("remote_address", address)], and only the addresses of buckets are limited.
+func localRateLimits(key *annotations.LocalRateLimitKey) []*routepb.RateLimit {
+	if key.RemoteAddress {
+		return []*routepb.RateLimit{
+			{
+				Stage: wrapperspb.UInt32(localRateLimitStage),
+				Actions: []*routepb.RateLimit_Action{
+					{
+						ActionSpecifier: &routepb.RateLimit_Action_RemoteAddress_{
+							RemoteAddress: &routepb.RateLimit_Action_RemoteAddress{},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	var rateLimits []*routepb.RateLimit
+	for _, bucket := range key.Buckets {
+		matcher := &routepb.HeaderMatcher{
+			Name: key.Header,
+			HeaderMatchSpecifier: &routepb.HeaderMatcher_StringMatch{
+				StringMatch: &matcherpb.StringMatcher{
+					MatchPattern: &matcherpb.StringMatcher_Exact{
+						Exact: bucket.Value,
+					},
+				},
+			},
+		}
+		if key.QueryParam != "" {
+			matcher = &routepb.HeaderMatcher{
+				Name: ":path",
+				HeaderMatchSpecifier: &routepb.HeaderMatcher_SafeRegexMatch{
+					SafeRegexMatch: &matcherpb.RegexMatcher{
+						EngineType: &matcherpb.RegexMatcher_GoogleRe2{
+							GoogleRe2: &matcherpb.RegexMatcher_GoogleRE2{},
+						},
+						Regex: queryParamRegex(key.QueryParam, bucket.Value),
+					},
+				},
+			}
+		}
+		rateLimits = append(rateLimits, &routepb.RateLimit{
+			Stage: wrapperspb.UInt32(localRateLimitStage),
+			Actions: []*routepb.RateLimit_Action{
+				{
+					ActionSpecifier: &routepb.RateLimit_Action_HeaderValueMatch_{
+						HeaderValueMatch: &routepb.RateLimit_Action_HeaderValueMatch{
+							DescriptorValue: bucket.Value,
+							Headers:         []*routepb.HeaderMatcher{matcher},
+						},
+					},
+				},
+			},
+		})
+	}
+	return rateLimits
+}
+
+// constructProxyTimeoutEnvoyFilter sets the idle timeout of routes, which is the closest to the read and send timeouts
+// of nginx. It is merged into the route action, so the timeout and retries configured by annotations are kept.
+func constructProxyTimeoutEnvoyFilter(routes []*common.WrapperHTTPRoute, namespace string) (*config.Config, error) {
+	var patches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	for _, route := range routes {
+		idleTimeout := route.WrapperConfig.AnnotationsConfig.Timeout.IdleTimeout()
+		patch, err := routeMergePatch(route.HTTPRoute.Name, &routepb.Route{
+			Action: &routepb.Route_Route{
+				Route: &routepb.RouteAction{
+					IdleTimeout: durationpb.New(idleTimeout),
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patch)
+	}
+
+	return &config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.EnvoyFilter,
+			Name:             common.CreateConvertedName(constants.IstioIngressGatewayName, "proxy-timeout"),
+			Namespace:        namespace,
+		},
+		Spec: &networking.EnvoyFilter{
+			ConfigPatches: patches,
+		},
+	}, nil
+}
+
+// constructBodySizeEnvoyFilter limits the request body of routes. The lua filter responds 413 if the content length of
+// request is too large, without buffering the body. The body without content length can only be limited by the buffer
+// filter, which buffers the whole body in memory, so it is enabled only on the routes whose limit doesn't exceed their
+// buffer bytes. Both filters are disabled on all routes by default, and enabled on the routes keyed by name.
+func constructBodySizeEnvoyFilter(routeBodySizes map[string]*annotations.BodySize, namespace string) (*config.Config, error) {
+	routeNames := make([]string, 0, len(routeBodySizes))
+	for routeName := range routeBodySizes {
+		routeNames = append(routeNames, routeName)
+	}
+	sort.Strings(routeNames)
+
+	sourceCodes := map[string]*corev3.DataSource{}
+	var routePatches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	for _, routeName := range routeNames {
+		bodySize := routeBodySizes[routeName]
+		// Routes with the same limit share the source code.
+		name := strconv.FormatUint(uint64(bodySize.MaxRequestBytes), 10)
+		sourceCodes[name] = &corev3.DataSource{
+			Specifier: &corev3.DataSource_InlineString{
+				InlineString: strings.ReplaceAll(bodySizeLuaCode, "{{limit}}", name),
+			},
+		}
+		perFilterConfigs := map[string]proto.Message{
+			bodySizeFilter: &luapb.LuaPerRoute{
+				Override: &luapb.LuaPerRoute_Name{Name: name},
+			},
+		}
+		if bodySize.MaxRequestBytes <= bodySize.BufferBytes {
+			perFilterConfigs[bodyBufferFilter] = &bufferpb.BufferPerRoute{
+				Override: &bufferpb.BufferPerRoute_Buffer{
+					Buffer: &bufferpb.Buffer{
+						MaxRequestBytes: wrapperspb.UInt32(bodySize.MaxRequestBytes),
+					},
+				},
+			}
+		}
+		patch, err := routePatch(routeName, perFilterConfigs)
+		if err != nil {
+			return nil, err
+		}
+		routePatches = append(routePatches, patch)
+	}
+
+	var patches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	patch, err := httpFilterPatch(bodyBufferFilter, &bufferpb.Buffer{
+		MaxRequestBytes: wrapperspb.UInt32(defaultMaxRequestBytes),
+	})
+	if err != nil {
+		return nil, err
+	}
+	patches = append(patches, patch)
+	// The lua filter is inserted after the buffer filter, so it comes first.
+	patch, err = httpFilterPatch(bodySizeFilter, &luapb.Lua{
+		InlineCode:  noopLuaCode,
+		SourceCodes: sourceCodes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	patches = append(patches, patch)
+	// Patches of the same kind are applied in order, so disable the filters on all routes first.
+	patch, err = routePatch("", map[string]proto.Message{
+		bodySizeFilter: &luapb.LuaPerRoute{
+			Override: &luapb.LuaPerRoute_Disabled{Disabled: true},
+		},
+		bodyBufferFilter: &bufferpb.BufferPerRoute{
+			Override: &bufferpb.BufferPerRoute_Disabled{Disabled: true},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	patches = append(patches, patch)
+
+	return &config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.EnvoyFilter,
+			Name:             common.CreateConvertedName(constants.IstioIngressGatewayName, "body-size"),
+			Namespace:        namespace,
+		},
+		Spec: &networking.EnvoyFilter{
+			ConfigPatches: append(patches, routePatches...),
+		},
+	}, nil
+}
+
+// constructCompressionEnvoyFilter generates the compressor filters for each compression config, one per algorithm.
+// Envoy 1.20 can't configure compressors per route, so each compressor is skipped unless the request is marked with
+// it by the lua filter of route, and routes without compression are not marked.
+func constructCompressionEnvoyFilter(routes []*common.WrapperHTTPRoute, namespace string) (*config.Config, error) {
+	compressors := map[string]*annotations.CompressionConfig{}
+	var routePatches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	for _, route := range routes {
+		compression := route.WrapperConfig.AnnotationsConfig.Compression
+		name := compressorFilterName(compression)
+		compressors[name] = compression
+
+		typedPerFilterConfig, err := toTypedPerFilterConfig(map[string]proto.Message{
+			compressionMarkFilter: &luapb.LuaPerRoute{
+				Override: &luapb.LuaPerRoute_SourceCode{
+					SourceCode: &corev3.DataSource{
+						Specifier: &corev3.DataSource_InlineString{
+							InlineString: strings.NewReplacer(
+								"{{header}}", strconv.Quote(compressionHeader),
+								"{{compressor}}", strconv.Quote(name),
+							).Replace(compressionRouteLuaCode),
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		patch, err := routeMergePatch(route.HTTPRoute.Name, &routepb.Route{
+			TypedPerFilterConfig:   typedPerFilterConfig,
+			RequestHeadersToRemove: []string{compressionHeader},
+		})
+		if err != nil {
+			return nil, err
+		}
+		routePatches = append(routePatches, patch)
+	}
+
+	names := make([]string, 0, len(compressors))
+	for name := range compressors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Filters are inserted after cors one by one, so the one inserted later comes first. The algorithms of
+	// one compression come in order of preference, and the mark filter comes before all compressors.
+	var patches []*networking.EnvoyFilter_EnvoyConfigObjectPatch
+	for idx := len(names) - 1; idx >= 0; idx-- {
+		compression := compressors[names[idx]]
+		for algorithmIdx := len(compression.Algorithms) - 1; algorithmIdx >= 0; algorithmIdx-- {
+			algorithm := compression.Algorithms[algorithmIdx]
+			filter, err := compressorFilter(names[idx], algorithm, compression)
+			if err != nil {
+				return nil, err
+			}
+			patch, err := httpFilterPatch(names[idx]+"."+algorithm, filter)
+			if err != nil {
+				return nil, err
+			}
+			patches = append(patches, patch)
+		}
+	}
+	patch, err := httpFilterPatch(compressionMarkFilter, &luapb.Lua{
+		InlineCode: strings.ReplaceAll(compressionMarkLuaCode, "{{header}}", strconv.Quote(compressionHeader)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	patches = append(patches, patch)
+	patches = append(patches, routePatches...)
+
+	return &config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.EnvoyFilter,
+			Name:             common.CreateConvertedName(constants.IstioIngressGatewayName, "compression"),
+			Namespace:        namespace,
+		},
+		Spec: &networking.EnvoyFilter{
+			ConfigPatches: patches,
+		},
+	}, nil
+}
+
+func compressorFilterName(compression *annotations.CompressionConfig) string {
+	// The unset level differs from level 0, which is a valid quality of brotli.
+	level := ""
+	if compression.Level != nil {
+		level = strconv.FormatUint(uint64(*compression.Level), 10)
+	}
+	key := strings.Join([]string{
+		strings.Join(compression.Algorithms, ","),
+		strconv.FormatUint(uint64(compression.MinLength), 10),
+		strings.Join(compression.ContentTypes, ","),
+		level,
+	}, "|")
+	hash := md5.Sum([]byte(key))
+	return compressorFilterPrefix + hex.EncodeToString(hash[:])[:8]
+}
+
+// compressorFilter wraps the compressor with the matcher, which skips the compressor if the request isn't marked with it.
+// The compressor negotiates with accept-encoding of request and adds vary of response, and leaves the encoded responses.
+func compressorFilter(name, algorithm string, compression *annotations.CompressionConfig) (*matchingpb.ExtensionWithMatcher, error) {
+	var library *corev3.TypedExtensionConfig
+	switch algorithm {
+	case annotations.GzipCompression:
+		gzip := &gzippb.Gzip{}
+		if compression.Level != nil {
+			gzip.CompressionLevel = gzippb.Gzip_CompressionLevel(*compression.Level)
+		}
+		libraryAny, err := anypb.New(gzip)
+		if err != nil {
+			return nil, err
+		}
+		library = &corev3.TypedExtensionConfig{
+			Name:        "envoy.compression.gzip.compressor",
+			TypedConfig: libraryAny,
+		}
+	case annotations.BrotliCompression:
+		brotli := &brotlipb.Brotli{}
+		if compression.Level != nil {
+			brotli.Quality = wrapperspb.UInt32(*compression.Level)
+		}
+		libraryAny, err := anypb.New(brotli)
+		if err != nil {
+			return nil, err
+		}
+		library = &corev3.TypedExtensionConfig{
+			Name:        "envoy.compression.brotli.compressor",
+			TypedConfig: libraryAny,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm %s", algorithm)
+	}
+
+	commonConfig := &compressorpb.Compressor_CommonDirectionConfig{
+		ContentType: compression.ContentTypes,
+	}
+	if compression.MinLength > 0 {
+		commonConfig.MinContentLength = wrapperspb.UInt32(compression.MinLength)
+	}
+	compressorAny, err := anypb.New(&compressorpb.Compressor{
+		CompressorLibrary: library,
+		ResponseDirectionConfig: &compressorpb.Compressor_ResponseDirectionConfig{
+			CommonConfig: commonConfig,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inputAny, err := anypb.New(&matcherpb.HttpRequestHeaderMatchInput{HeaderName: compressionHeader})
+	if err != nil {
+		return nil, err
+	}
+	skipAny, err := anypb.New(&skipaction.SkipFilter{})
+	if err != nil {
+		return nil, err
+	}
+	return &matchingpb.ExtensionWithMatcher{
+		Matcher: &matcherv3.Matcher{
+			MatcherType: &matcherv3.Matcher_MatcherList_{
+				MatcherList: &matcherv3.Matcher_MatcherList{
+					Matchers: []*matcherv3.Matcher_MatcherList_FieldMatcher{
+						{
+							Predicate: &matcherv3.Matcher_MatcherList_Predicate{
+								MatchType: &matcherv3.Matcher_MatcherList_Predicate_NotMatcher{
+									NotMatcher: &matcherv3.Matcher_MatcherList_Predicate{
+										MatchType: &matcherv3.Matcher_MatcherList_Predicate_SinglePredicate_{
+											SinglePredicate: &matcherv3.Matcher_MatcherList_Predicate_SinglePredicate{
+												Input: &corev3.TypedExtensionConfig{
+													Name:        "request-headers",
+													TypedConfig: inputAny,
+												},
+												Matcher: &matcherv3.Matcher_MatcherList_Predicate_SinglePredicate_ValueMatch{
+													ValueMatch: &matcherpb.StringMatcher{
+														MatchPattern: &matcherpb.StringMatcher_Exact{Exact: name},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+							OnMatch: &matcherv3.Matcher_OnMatch{
+								OnMatch: &matcherv3.Matcher_OnMatch_Action{
+									Action: &corev3.TypedExtensionConfig{
+										Name:        "skip",
+										TypedConfig: skipAny,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		ExtensionConfig: &corev3.TypedExtensionConfig{
+			Name:        "envoy.filters.http.compressor",
+			TypedConfig: compressorAny,
+		},
+	}, nil
+}
+
+// queryParamRegex matches the path with the query parameter of value.
+func queryParamRegex(name, value string) string {
+	return `^[^?]*\?(.*&)?` + regexp.QuoteMeta(url.QueryEscape(name)) + "=" + regexp.QuoteMeta(url.QueryEscape(value)) + `(&.*)?$`
+}
+
+func fullRuntimeFraction(runtimeKey string) *corev3.RuntimeFractionalPercent {
+	return &corev3.RuntimeFractionalPercent{
+		DefaultValue: &typev3.FractionalPercent{
+			Numerator:   100,
+			Denominator: typev3.FractionalPercent_HUNDRED,
+		},
+		RuntimeKey: runtimeKey,
+	}
+}
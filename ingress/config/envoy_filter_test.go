@@ -0,0 +1,943 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	routepb "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	matchingpb "github.com/envoyproxy/go-control-plane/envoy/extensions/common/matching/v3"
+	ratelimitcommon "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	gzippb "github.com/envoyproxy/go-control-plane/envoy/extensions/compression/gzip/compressor/v3"
+	bufferpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/buffer/v3"
+	compressorpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/compressor/v3"
+	extauthz "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	jwtauthn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	localratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+	luapb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	oauth2 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/oauth2/v3alpha"
+	ratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ratelimit/v3"
+	httppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/xds"
+
+	"github.com/alibaba/higress/ingress/kube/annotations"
+	"github.com/alibaba/higress/ingress/kube/common"
+	"github.com/alibaba/higress/ingress/kube/util"
+)
+
+func TestConstructExtAuthEnvoyFilter(t *testing.T) {
+	extAuth := &annotations.ExtAuthConfig{
+		URL:                 "http://auth.default.svc.cluster.local/verify",
+		ServiceHost:         "auth.default.svc.cluster.local",
+		ServicePort:         80,
+		PathPrefix:          "/verify",
+		Method:              "POST",
+		Timeout:             time.Second,
+		ResponseHeaders:     []string{"X-User"},
+		SigninURL:           "https://login.example.com/signin",
+		SigninRedirectParam: "rd",
+	}
+	routes := []*common.WrapperHTTPRoute{
+		{
+			HTTPRoute: &networking.HTTPRoute{Name: "route"},
+			WrapperConfig: &common.WrapperConfig{
+				AnnotationsConfig: &annotations.Ingress{ExtAuth: extAuth},
+			},
+		},
+	}
+
+	config, err := constructExtAuthEnvoyFilter(routes, "")
+	if err != nil {
+		t.Fatalf("construct error %v", err)
+	}
+	envoyFilter := config.Spec.(*networking.EnvoyFilter)
+	// Authorized filter, ext_authz filter, sign-in filter, the disabling patch and the route patch.
+	assert.Equal(t, 5, len(envoyFilter.ConfigPatches))
+
+	// The authorized filter is inserted first, so it comes after the ext_authz filter.
+	pb, err := xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_FILTER, envoyFilter.ConfigPatches[0].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	assert.Equal(t, extAuthAuthorizedFilter, pb.(*httppb.HttpFilter).Name)
+
+	filterName := extAuthFilterName(extAuth)
+	pb, err = xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_FILTER, envoyFilter.ConfigPatches[1].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	filter := pb.(*httppb.HttpFilter)
+	assert.Equal(t, filterName, filter.Name)
+	authz := &extauthz.ExtAuthz{}
+	if err = filter.GetTypedConfig().UnmarshalTo(authz); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	assert.Equal(t, "outbound|80||auth.default.svc.cluster.local", authz.GetHttpService().GetServerUri().GetCluster())
+	assert.Equal(t, "/verify", authz.GetHttpService().GetPathPrefix())
+	assert.Equal(t, ":method", authz.GetHttpService().GetAuthorizationRequest().GetHeadersToAdd()[0].GetKey())
+	assert.Equal(t, "POST", authz.GetHttpService().GetAuthorizationRequest().GetHeadersToAdd()[0].GetValue())
+
+	pb, err = xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_ROUTE, envoyFilter.ConfigPatches[3].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	perRoute := &extauthz.ExtAuthzPerRoute{}
+	if err = pb.(*routepb.Route).TypedPerFilterConfig[filterName].UnmarshalTo(perRoute); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	assert.True(t, perRoute.GetDisabled())
+
+	assert.Equal(t, "route", envoyFilter.ConfigPatches[4].Match.GetRouteConfiguration().GetVhost().GetRoute().GetName())
+	pb, err = xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_ROUTE, envoyFilter.ConfigPatches[4].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	perRoute = &extauthz.ExtAuthzPerRoute{}
+	if err = pb.(*routepb.Route).TypedPerFilterConfig[filterName].UnmarshalTo(perRoute); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	assert.NotNil(t, perRoute.GetCheckSettings())
+	luaPerRoute := &luapb.LuaPerRoute{}
+	if err = pb.(*routepb.Route).TypedPerFilterConfig[extAuthAuthorizedFilter].UnmarshalTo(luaPerRoute); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	assert.Equal(t, extAuthAuthorizedLuaCode, luaPerRoute.GetSourceCode().GetInlineString())
+}
+
+func TestConstructDigestAuthEnvoyFilter(t *testing.T) {
+	rules := &common.DigestAuthRules{
+		Rules: []*common.DigestRule{
+			{
+				Realm:       "test",
+				MatchRoute:  []string{"route-a", "route-b"},
+				Credentials: []string{"user:29203e70de091917e404308db1d37ebc"},
+			},
+		},
+		NonceKey:    "0123456789abcdef",
+		NonceMaxAge: 300,
+	}
+
+	config, err := constructDigestAuthEnvoyFilter(rules, "")
+	if err != nil {
+		t.Fatalf("construct error %v", err)
+	}
+	envoyFilter := config.Spec.(*networking.EnvoyFilter)
+	// Lua filter, the disabling patch and the patches of two routes.
+	assert.Equal(t, 4, len(envoyFilter.ConfigPatches))
+
+	pb, err := xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_FILTER, envoyFilter.ConfigPatches[0].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	filter := pb.(*httppb.HttpFilter)
+	assert.Equal(t, digestAuthFilter, filter.Name)
+	lua := &luapb.Lua{}
+	if err = filter.GetTypedConfig().UnmarshalTo(lua); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	code := lua.SourceCodes["rule-0"].GetInlineString()
+	assert.Contains(t, code, `local realm = "test"`)
+	assert.Contains(t, code, `local credentials = {["user"] = "29203e70de091917e404308db1d37ebc"}`)
+	assert.Contains(t, code, `local nonce_key = "0123456789abcdef"`)
+	assert.Contains(t, code, `local nonce_max_age = 300`)
+	assert.Contains(t, code, `hmac_md5(nonce_key, timestamp .. " " .. client)`)
+
+	for idx, route := range []string{"route-a", "route-b"} {
+		patch := envoyFilter.ConfigPatches[idx+2]
+		assert.Equal(t, route, patch.Match.GetRouteConfiguration().GetVhost().GetRoute().GetName())
+		pb, err = xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_ROUTE, patch.Patch.Value, false)
+		if err != nil {
+			t.Fatalf("build object error %v", err)
+		}
+		perRoute := &luapb.LuaPerRoute{}
+		if err = pb.(*routepb.Route).TypedPerFilterConfig[digestAuthFilter].UnmarshalTo(perRoute); err != nil {
+			t.Fatalf("unmarshal error %v", err)
+		}
+		assert.Equal(t, "rule-0", perRoute.GetName())
+	}
+}
+
+func TestLuaString(t *testing.T) {
+	assert.Equal(t, `"user"`, luaString("user"))
+	assert.Equal(t, `"a\034b\092c\010"`, luaString("a\"b\\c\n"))
+	assert.Equal(t, `"\228\189\160"`, luaString("你"))
+}
+
+func TestConstructJwtAuthnEnvoyFilter(t *testing.T) {
+	jwt := &annotations.JwtConfig{
+		Issuer:          "https://issuer.example.com",
+		JwksURI:         "http://jwks.default.svc.cluster.local/jwks.json",
+		JwksServiceHost: "jwks.default.svc.cluster.local",
+		JwksServicePort: 80,
+		Optional:        true,
+	}
+	routes := []*common.WrapperHTTPRoute{
+		{
+			HTTPRoute: &networking.HTTPRoute{Name: "route"},
+			WrapperConfig: &common.WrapperConfig{
+				AnnotationsConfig: &annotations.Ingress{Jwt: jwt},
+			},
+		},
+		{
+			HTTPRoute: &networking.HTTPRoute{Name: "invalid"},
+			WrapperConfig: &common.WrapperConfig{
+				AnnotationsConfig: &annotations.Ingress{Jwt: &annotations.JwtConfig{Invalid: true}},
+			},
+		},
+	}
+
+	config, err := constructJwtAuthnEnvoyFilter(routes, "")
+	if err != nil {
+		t.Fatalf("construct error %v", err)
+	}
+	envoyFilter := config.Spec.(*networking.EnvoyFilter)
+	assert.Equal(t, 3, len(envoyFilter.ConfigPatches))
+
+	pb, err := xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_FILTER, envoyFilter.ConfigPatches[0].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	filter := pb.(*httppb.HttpFilter)
+	assert.Equal(t, annotations.JwtAuthnFilterName, filter.Name)
+	jwtAuthn := &jwtauthn.JwtAuthentication{}
+	if err = filter.GetTypedConfig().UnmarshalTo(jwtAuthn); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	provider := jwtAuthn.Providers[jwtProviderName(jwt)]
+	assert.Equal(t, "outbound|80||jwks.default.svc.cluster.local", provider.GetRemoteJwks().GetHttpUri().GetCluster())
+	assert.Equal(t, 2, len(jwtAuthn.RequirementMap["route"].GetRequiresAny().GetRequirements()))
+	// Routes with invalid jwt annotations require the jwt verified by no key.
+	assert.Equal(t, jwtInvalidProvider, jwtAuthn.RequirementMap["invalid"].GetProviderName())
+	assert.Equal(t, `{"keys":[]}`, jwtAuthn.Providers[jwtInvalidProvider].GetLocalJwks().GetInlineString())
+
+	pb, err = xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_ROUTE, envoyFilter.ConfigPatches[1].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	perRoute := &jwtauthn.PerRouteConfig{}
+	if err = pb.(*routepb.Route).TypedPerFilterConfig[annotations.JwtAuthnFilterName].UnmarshalTo(perRoute); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	assert.Equal(t, "route", perRoute.GetRequirementName())
+}
+
+func TestConstructOidcEnvoyFilter(t *testing.T) {
+	hostConfigs := map[string]*annotations.OidcConfig{
+		"a.example.com": {
+			ClientId: "a",
+			ClientSecret: util.ClusterNamespacedName{
+				NamespacedName: model.NamespacedName{
+					Namespace: "default",
+					Name:      "a",
+				},
+				ClusterId: "cluster",
+			},
+			AuthorizationEndpoint: "https://idp.example.com/authorize",
+			TokenEndpoint:         "https://idp.example.com/token",
+			TokenServiceHost:      "idp.example.com",
+			TokenServicePort:      443,
+			TokenServiceTLS:       true,
+			RedirectPath:          "/oauth2/callback",
+			Scopes:                []string{"openid"},
+			CookieDomain:          "example.com",
+		},
+		"*.foo.com": {
+			ClientId: "foo",
+			ClientSecret: util.ClusterNamespacedName{
+				NamespacedName: model.NamespacedName{
+					Namespace: "default",
+					Name:      "foo",
+				},
+				ClusterId: "cluster",
+			},
+			AuthorizationEndpoint: "https://idp.example.com/authorize",
+			TokenEndpoint:         "https://idp.example.com/token",
+			TokenServiceHost:      "idp.example.com",
+			TokenServicePort:      443,
+			TokenServiceTLS:       true,
+			RedirectPath:          "/oauth2/callback",
+			Scopes:                []string{"openid"},
+		},
+	}
+
+	hmacSecret := "higress-generic://cluster/higress-system/higress-oidc/hmac-secret"
+	sdsCluster := "outbound|15051||higress-controller.higress-system.svc.cluster.local"
+	config, err := constructOidcEnvoyFilter(hostConfigs, hmacSecret, sdsCluster, "")
+	if err != nil {
+		t.Fatalf("construct error %v", err)
+	}
+	envoyFilter := config.Spec.(*networking.EnvoyFilter)
+	// Two oauth2 filters, and the cookie domain filter.
+	assert.Equal(t, 3, len(envoyFilter.ConfigPatches))
+
+	pb, err := xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_FILTER, envoyFilter.ConfigPatches[0].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	filter := pb.(*httppb.HttpFilter)
+	assert.Equal(t, "higress.oidc.a.example.com", filter.Name)
+	oauth := &oauth2.OAuth2{}
+	if err = filter.GetTypedConfig().UnmarshalTo(oauth); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	assert.Equal(t, "outbound|443||idp.example.com", oauth.Config.GetTokenEndpoint().GetCluster())
+	credentials := oauth.Config.GetCredentials()
+	assert.Equal(t, "higress-generic://cluster/default/a/client-secret", credentials.GetTokenSecret().GetName())
+	assert.Equal(t, hmacSecret, credentials.GetHmacSecret().GetName())
+	sdsService := credentials.GetHmacSecret().GetSdsConfig().GetApiConfigSource().GetGrpcServices()[0]
+	assert.Equal(t, sdsCluster, sdsService.GetEnvoyGrpc().GetClusterName())
+	assert.Equal(t, `^a\.example\.com(:\d+)?$`, oauth.Config.GetPassThroughMatcher()[0].GetSafeRegexMatch().GetRegex())
+
+	pb, err = xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_FILTER, envoyFilter.ConfigPatches[2].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	assert.Equal(t, oidcCookieDomainFilter, pb.(*httppb.HttpFilter).Name)
+}
+
+func TestAuthorityRegex(t *testing.T) {
+	testCases := []struct {
+		host    string
+		match   []string
+		noMatch []string
+	}{
+		{
+			host:    "a.example.com",
+			match:   []string{"a.example.com", "a.example.com:8080"},
+			noMatch: []string{"b.example.com", "aaexample.com", "a.example.com.cn"},
+		},
+		{
+			host:    "*.example.com",
+			match:   []string{"a.example.com", "b.example.com:443"},
+			noMatch: []string{"example.com", "a.b.example.com"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run("", func(t *testing.T) {
+			re := regexp.MustCompile(authorityRegex(testCase.host))
+			for _, authority := range testCase.match {
+				assert.True(t, re.MatchString(authority), authority)
+			}
+			for _, authority := range testCase.noMatch {
+				assert.False(t, re.MatchString(authority), authority)
+			}
+		})
+	}
+}
+
+// fakeRateLimitService counts requests by the whole descriptor within the window of its limit override, like the
+// rate limit service of envoy. Descriptors without limit override are not limited, since the service has no config.
+type fakeRateLimitService struct {
+	rlsv3.UnimplementedRateLimitServiceServer
+
+	// The time of requests is fixed, so the counts never cross the boundary of windows.
+	now    time.Time
+	mutex  sync.Mutex
+	counts map[string]uint32
+}
+
+func (f *fakeRateLimitService) ShouldRateLimit(_ context.Context, request *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	response := &rlsv3.RateLimitResponse{
+		OverallCode: rlsv3.RateLimitResponse_OK,
+	}
+	for _, descriptor := range request.Descriptors {
+		limit := descriptor.GetLimit()
+		if limit == nil {
+			continue
+		}
+		var unit time.Duration
+		switch limit.Unit {
+		case typev3.RateLimitUnit_SECOND:
+			unit = time.Second
+		case typev3.RateLimitUnit_MINUTE:
+			unit = time.Minute
+		default:
+			return nil, fmt.Errorf("unsupported unit %s", limit.Unit)
+		}
+
+		var entries []string
+		for _, entry := range descriptor.Entries {
+			entries = append(entries, entry.Key+"="+entry.Value)
+		}
+		key := fmt.Sprintf("%s|%s|%d", request.Domain, strings.Join(entries, ","), f.now.Truncate(unit).Unix())
+		f.counts[key] += request.HitsAddend
+		if f.counts[key] > limit.RequestsPerUnit {
+			response.OverallCode = rlsv3.RateLimitResponse_OVER_LIMIT
+		}
+	}
+	return response, nil
+}
+
+type fakeRequest struct {
+	remoteAddr string
+	headers    map[string]string
+	consumer   string
+}
+
+var rateLimitLimitRegex = regexp.MustCompile(`set\("([^"]+)", "([^"]+)", \{requests_per_unit = (\d+), unit = "([A-Z]+)"\}\)`)
+
+// limitOverride runs the limit filter of route like envoy, and returns the limit override read from the dynamic
+// metadata by the rate limit of route.
+func limitOverride(t *testing.T, rateLimit *routepb.RateLimit, code string) *ratelimitcommon.RateLimitDescriptor_RateLimitOverride {
+	match := rateLimitLimitRegex.FindStringSubmatch(code)
+	if match == nil {
+		t.Fatalf("limit code %s is invalid", code)
+	}
+	metadataKey := rateLimit.GetLimit().GetDynamicMetadata().GetMetadataKey()
+	if metadataKey.GetKey() != match[1] || len(metadataKey.GetPath()) != 1 || metadataKey.GetPath()[0].GetKey() != match[2] {
+		t.Fatalf("limit override %v doesn't read the metadata set by code %s", metadataKey, code)
+	}
+	requests, _ := strconv.ParseUint(match[3], 10, 32)
+	unit, exist := typev3.RateLimitUnit_value[match[4]]
+	if !exist {
+		t.Fatalf("unit of code %s is invalid", code)
+	}
+	return &ratelimitcommon.RateLimitDescriptor_RateLimitOverride{
+		RequestsPerUnit: uint32(requests),
+		Unit:            typev3.RateLimitUnit(unit),
+	}
+}
+
+// buildDescriptor generates the descriptor from actions like envoy, and returns nil if any value is missing.
+func buildDescriptor(actions []*routepb.RateLimit_Action, request fakeRequest) *ratelimitcommon.RateLimitDescriptor {
+	descriptor := &ratelimitcommon.RateLimitDescriptor{}
+	for _, action := range actions {
+		var key, value string
+		switch {
+		case action.GetGenericKey() != nil:
+			key, value = action.GetGenericKey().DescriptorKey, action.GetGenericKey().DescriptorValue
+		case action.GetRemoteAddress() != nil:
+			key, value = "remote_address", request.remoteAddr
+		case action.GetRequestHeaders() != nil:
+			header, exist := request.headers[action.GetRequestHeaders().HeaderName]
+			if !exist {
+				if action.GetRequestHeaders().SkipIfAbsent {
+					continue
+				}
+				return nil
+			}
+			key, value = action.GetRequestHeaders().DescriptorKey, header
+		case action.GetMetadata() != nil:
+			key, value = action.GetMetadata().DescriptorKey, request.consumer
+			if value == "" {
+				value = action.GetMetadata().DefaultValue
+			}
+		}
+		if value == "" {
+			return nil
+		}
+		descriptor.Entries = append(descriptor.Entries, &ratelimitcommon.RateLimitDescriptor_Entry{
+			Key:   key,
+			Value: value,
+		})
+	}
+	return descriptor
+}
+
+func TestConstructGlobalRateLimitEnvoyFilter(t *testing.T) {
+	routes := []*common.WrapperHTTPRoute{
+		{
+			HTTPRoute: &networking.HTTPRoute{Name: "foo"},
+			WrapperConfig: &common.WrapperConfig{
+				AnnotationsConfig: &annotations.Ingress{
+					GlobalRateLimit: &annotations.GlobalRateLimitConfig{
+						ServiceHost:     "rls.default.svc.cluster.local",
+						ServicePort:     8081,
+						RequestsPerUnit: 2,
+						Unit:            annotations.RateLimitUnitMinute,
+						Keys: []annotations.RateLimitKey{
+							{Type: annotations.RateLimitByRemoteAddr},
+						},
+					},
+				},
+			},
+		},
+		{
+			HTTPRoute: &networking.HTTPRoute{Name: "bar"},
+			WrapperConfig: &common.WrapperConfig{
+				AnnotationsConfig: &annotations.Ingress{
+					GlobalRateLimit: &annotations.GlobalRateLimitConfig{
+						ServiceHost:     "rls.higress-system.svc.cluster.local",
+						ServicePort:     8081,
+						RequestsPerUnit: 1,
+						Unit:            annotations.RateLimitUnitSecond,
+						Keys: []annotations.RateLimitKey{
+							{Type: annotations.RateLimitByHeader, Name: "x-user-id"},
+							{Type: annotations.RateLimitByPath},
+							{Type: annotations.RateLimitByConsumer, Name: "sub"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	config, err := constructGlobalRateLimitEnvoyFilter(routes, "")
+	if err != nil {
+		t.Fatalf("construct error %v", err)
+	}
+	envoyFilter := config.Spec.(*networking.EnvoyFilter)
+	// Two ratelimit filters, the limit filter, the disabled limit filter and the rate limits of two routes.
+	assert.Equal(t, 6, len(envoyFilter.ConfigPatches))
+
+	// The filter of the last service is inserted first.
+	for idx, cluster := range []string{
+		"outbound|8081||rls.higress-system.svc.cluster.local",
+		"outbound|8081||rls.default.svc.cluster.local",
+	} {
+		pb, err := xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_FILTER, envoyFilter.ConfigPatches[idx].Patch.Value, false)
+		if err != nil {
+			t.Fatalf("build object error %v", err)
+		}
+		rateLimit := &ratelimit.RateLimit{}
+		if err = pb.(*httppb.HttpFilter).GetTypedConfig().UnmarshalTo(rateLimit); err != nil {
+			t.Fatalf("unmarshal error %v", err)
+		}
+		assert.Equal(t, rateLimitDomain, rateLimit.Domain)
+		assert.Equal(t, uint32(1-idx), rateLimit.Stage)
+		assert.True(t, rateLimit.FailureModeDeny)
+		assert.Equal(t, cluster, rateLimit.GetRateLimitService().GetGrpcService().GetEnvoyGrpc().GetClusterName())
+	}
+
+	// The limit filter is inserted last, so it sets the limits before the ratelimit filters run.
+	pb, err := xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_FILTER, envoyFilter.ConfigPatches[2].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	assert.Equal(t, rateLimitLimitFilter, pb.(*httppb.HttpFilter).Name)
+	lua := &luapb.Lua{}
+	if err = pb.(*httppb.HttpFilter).GetTypedConfig().UnmarshalTo(lua); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	assert.Equal(t, 2, len(lua.SourceCodes))
+
+	var rateLimits []*routepb.RateLimit
+	var overrides []*ratelimitcommon.RateLimitDescriptor_RateLimitOverride
+	for _, patch := range envoyFilter.ConfigPatches[4:] {
+		pb, err := xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_ROUTE, patch.Patch.Value, false)
+		if err != nil {
+			t.Fatalf("build object error %v", err)
+		}
+		route := pb.(*routepb.Route)
+		routeRateLimits := route.GetRoute().GetRateLimits()
+		assert.Equal(t, 1, len(routeRateLimits))
+		perRoute := &luapb.LuaPerRoute{}
+		if err = route.TypedPerFilterConfig[rateLimitLimitFilter].UnmarshalTo(perRoute); err != nil {
+			t.Fatalf("unmarshal error %v", err)
+		}
+		code, exist := lua.SourceCodes[perRoute.GetName()]
+		if !exist {
+			t.Fatalf("source code %s is missing", perRoute.GetName())
+		}
+		rateLimits = append(rateLimits, routeRateLimits[0])
+		overrides = append(overrides, limitOverride(t, routeRateLimits[0], code.GetInlineString()))
+	}
+	assert.Equal(t, uint32(0), rateLimits[0].GetStage().GetValue())
+	assert.Equal(t, uint32(1), rateLimits[1].GetStage().GetValue())
+	assert.Equal(t, uint32(2), overrides[0].RequestsPerUnit)
+	assert.Equal(t, typev3.RateLimitUnit_MINUTE, overrides[0].Unit)
+	assert.Equal(t, uint32(1), overrides[1].RequestsPerUnit)
+	assert.Equal(t, typev3.RateLimitUnit_SECOND, overrides[1].Unit)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error %v", err)
+	}
+	server := grpc.NewServer()
+	rlsv3.RegisterRateLimitServiceServer(server, &fakeRateLimitService{
+		now:    time.Date(2022, 1, 1, 0, 0, 30, 0, time.UTC),
+		counts: map[string]uint32{},
+	})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("dial error %v", err)
+	}
+	defer conn.Close()
+	client := rlsv3.NewRateLimitServiceClient(conn)
+
+	testCases := []struct {
+		route   int
+		request fakeRequest
+		expect  rlsv3.RateLimitResponse_Code
+	}{
+		{
+			route:   0,
+			request: fakeRequest{remoteAddr: "10.0.0.1"},
+			expect:  rlsv3.RateLimitResponse_OK,
+		},
+		{
+			route:   0,
+			request: fakeRequest{remoteAddr: "10.0.0.1"},
+			expect:  rlsv3.RateLimitResponse_OK,
+		},
+		{
+			route:   0,
+			request: fakeRequest{remoteAddr: "10.0.0.1"},
+			expect:  rlsv3.RateLimitResponse_OVER_LIMIT,
+		},
+		{
+			route:   0,
+			request: fakeRequest{remoteAddr: "10.0.0.2"},
+			expect:  rlsv3.RateLimitResponse_OK,
+		},
+		{
+			route: 1,
+			request: fakeRequest{
+				headers: map[string]string{"x-user-id": "a", ":path": "/bar"},
+			},
+			expect: rlsv3.RateLimitResponse_OK,
+		},
+		{
+			route: 1,
+			request: fakeRequest{
+				headers:  map[string]string{"x-user-id": "a", ":path": "/bar"},
+				consumer: "alice",
+			},
+			expect: rlsv3.RateLimitResponse_OK,
+		},
+		{
+			route: 1,
+			request: fakeRequest{
+				headers: map[string]string{"x-user-id": "a", ":path": "/bar"},
+			},
+			expect: rlsv3.RateLimitResponse_OVER_LIMIT,
+		},
+		{
+			route: 1,
+			request: fakeRequest{
+				headers: map[string]string{":path": "/bar"},
+			},
+			expect: rlsv3.RateLimitResponse_OK,
+		},
+	}
+
+	for _, testCase := range testCases {
+		descriptor := buildDescriptor(rateLimits[testCase.route].Actions, testCase.request)
+		if descriptor == nil {
+			t.Fatalf("descriptor of request %v is missing", testCase.request)
+		}
+		descriptor.Limit = overrides[testCase.route]
+		response, err := client.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+			Domain:      rateLimitDomain,
+			Descriptors: []*ratelimitcommon.RateLimitDescriptor{descriptor},
+			HitsAddend:  1,
+		})
+		if err != nil {
+			t.Fatalf("should rate limit error %v", err)
+		}
+		assert.Equal(t, testCase.expect, response.OverallCode)
+	}
+}
+
+func TestConstructLocalRateLimitEnvoyFilter(t *testing.T) {
+	routes := []*common.WrapperHTTPRoute{
+		{
+			HTTPRoute: &networking.HTTPRoute{Name: "route"},
+			WrapperConfig: &common.WrapperConfig{
+				AnnotationsConfig: &annotations.Ingress{
+					LocalRateLimit: &annotations.LocalRateLimitConfig{
+						TokensPerFill: 10,
+						MaxTokens:     50,
+						FillInterval:  &types.Duration{Seconds: 60},
+						StatusCode:    429,
+						Body:          "too many requests",
+						Key: &annotations.LocalRateLimitKey{
+							QueryParam: "tenant",
+							Buckets: []annotations.LocalRateLimitBucket{
+								{Value: "a", TokensPerFill: 1, MaxTokens: 5},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	config, err := constructLocalRateLimitEnvoyFilter(routes, "")
+	if err != nil {
+		t.Fatalf("construct error %v", err)
+	}
+	envoyFilter := config.Spec.(*networking.EnvoyFilter)
+	// The local ratelimit filter, the body filter and its disabled config, and the route config.
+	assert.Equal(t, 4, len(envoyFilter.ConfigPatches))
+
+	pb, err := xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_ROUTE, envoyFilter.ConfigPatches[3].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	route := pb.(*routepb.Route)
+	perRoute := &localratelimit.LocalRateLimit{}
+	if err = route.TypedPerFilterConfig[localRateLimitFilter].UnmarshalTo(perRoute); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	assert.Equal(t, uint32(429), uint32(perRoute.GetStatus().GetCode()))
+	assert.Equal(t, uint32(50), perRoute.GetTokenBucket().GetMaxTokens())
+	assert.Equal(t, 60*time.Second, perRoute.GetTokenBucket().GetFillInterval().AsDuration())
+	assert.Equal(t, uint32(localRateLimitStage), perRoute.Stage)
+	assert.Equal(t, localRateLimitHeader, perRoute.GetResponseHeadersToAdd()[0].GetHeader().GetKey())
+	assert.Equal(t, 1, len(perRoute.Descriptors))
+	assert.Equal(t, "a", perRoute.Descriptors[0].Entries[0].Value)
+	assert.Equal(t, uint32(5), perRoute.Descriptors[0].GetTokenBucket().GetMaxTokens())
+	_, exist := route.TypedPerFilterConfig[localRateLimitBodyFilter]
+	assert.True(t, exist)
+
+	rateLimits := route.GetRoute().GetRateLimits()
+	assert.Equal(t, 1, len(rateLimits))
+	assert.Equal(t, uint32(localRateLimitStage), rateLimits[0].GetStage().GetValue())
+	headerValueMatch := rateLimits[0].Actions[0].GetHeaderValueMatch()
+	assert.Equal(t, "a", headerValueMatch.DescriptorValue)
+	assert.Equal(t, ":path", headerValueMatch.Headers[0].Name)
+}
+
+func TestLocalRateLimitByRemoteAddress(t *testing.T) {
+	local := &annotations.LocalRateLimitConfig{
+		TokensPerFill: 10,
+		MaxTokens:     50,
+		FillInterval:  &types.Duration{Seconds: 60},
+		Key: &annotations.LocalRateLimitKey{
+			RemoteAddress: true,
+			Buckets: []annotations.LocalRateLimitBucket{
+				{Value: "10.0.0.1", TokensPerFill: 1, MaxTokens: 5},
+				{Value: "10.0.0.2", TokensPerFill: 2, MaxTokens: 10},
+			},
+		},
+	}
+
+	perRoute := localRateLimitPerRoute(local)
+	assert.Equal(t, 2, len(perRoute.Descriptors))
+	assert.Equal(t, remoteAddressDescriptorKey, perRoute.Descriptors[1].Entries[0].Key)
+	assert.Equal(t, "10.0.0.2", perRoute.Descriptors[1].Entries[0].Value)
+
+	rateLimits := localRateLimits(local.Key)
+	assert.Equal(t, 1, len(rateLimits))
+	assert.NotNil(t, rateLimits[0].Actions[0].GetRemoteAddress())
+}
+
+func TestConstructProxyTimeoutEnvoyFilter(t *testing.T) {
+	annotationsConfig := &annotations.Ingress{}
+	err := annotations.NewAnnotationHandlerManager().Parse(annotations.Annotations{
+		"nginx.ingress.kubernetes.io/proxy-read-timeout": "30",
+		"nginx.ingress.kubernetes.io/proxy-send-timeout": "500ms",
+	}, annotationsConfig, nil)
+	if err != nil {
+		t.Fatalf("parse error %v", err)
+	}
+	routes := []*common.WrapperHTTPRoute{
+		{
+			HTTPRoute: &networking.HTTPRoute{Name: "route"},
+			WrapperConfig: &common.WrapperConfig{
+				AnnotationsConfig: annotationsConfig,
+			},
+		},
+	}
+
+	config, err := constructProxyTimeoutEnvoyFilter(routes, "")
+	if err != nil {
+		t.Fatalf("construct error %v", err)
+	}
+	envoyFilter := config.Spec.(*networking.EnvoyFilter)
+	assert.Equal(t, 1, len(envoyFilter.ConfigPatches))
+	assert.Equal(t, "route", envoyFilter.ConfigPatches[0].Match.GetRouteConfiguration().GetVhost().GetRoute().GetName())
+
+	pb, err := xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_ROUTE, envoyFilter.ConfigPatches[0].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	route := pb.(*routepb.Route)
+	assert.Equal(t, 30*time.Second, route.GetRoute().GetIdleTimeout().AsDuration())
+}
+
+func TestConstructBodySizeEnvoyFilter(t *testing.T) {
+	config, err := constructBodySizeEnvoyFilter(map[string]*annotations.BodySize{
+		"b": {MaxRequestBytes: 1024, BufferBytes: 1 << 20},
+		"a": {MaxRequestBytes: 8 << 20, BufferBytes: 1 << 20},
+	}, "")
+	if err != nil {
+		t.Fatalf("construct error %v", err)
+	}
+	envoyFilter := config.Spec.(*networking.EnvoyFilter)
+	// The buffer and lua filters, their disabled config on all routes, and the config of two routes.
+	assert.Equal(t, 5, len(envoyFilter.ConfigPatches))
+
+	pb, err := xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_FILTER, envoyFilter.ConfigPatches[1].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	lua := &luapb.Lua{}
+	if err = pb.(*httppb.HttpFilter).GetTypedConfig().UnmarshalTo(lua); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	assert.Equal(t, 2, len(lua.SourceCodes))
+	assert.Contains(t, lua.SourceCodes["1024"].GetInlineString(), "length > 1024")
+
+	perRoutes := func(idx int) (*luapb.LuaPerRoute, *bufferpb.BufferPerRoute) {
+		pb, err := xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_ROUTE, envoyFilter.ConfigPatches[idx].Patch.Value, false)
+		if err != nil {
+			t.Fatalf("build object error %v", err)
+		}
+		typedPerFilterConfig := pb.(*routepb.Route).TypedPerFilterConfig
+		luaPerRoute := &luapb.LuaPerRoute{}
+		if err = typedPerFilterConfig[bodySizeFilter].UnmarshalTo(luaPerRoute); err != nil {
+			t.Fatalf("unmarshal error %v", err)
+		}
+		if typedPerFilterConfig[bodyBufferFilter] == nil {
+			return luaPerRoute, nil
+		}
+		bufferPerRoute := &bufferpb.BufferPerRoute{}
+		if err = typedPerFilterConfig[bodyBufferFilter].UnmarshalTo(bufferPerRoute); err != nil {
+			t.Fatalf("unmarshal error %v", err)
+		}
+		return luaPerRoute, bufferPerRoute
+	}
+
+	assert.Equal(t, "", envoyFilter.ConfigPatches[2].Match.GetRouteConfiguration().GetVhost().GetRoute().GetName())
+	luaPerRoute, bufferPerRoute := perRoutes(2)
+	assert.True(t, luaPerRoute.GetDisabled())
+	assert.True(t, bufferPerRoute.GetDisabled())
+
+	// The limit of route a exceeds its buffer bytes, so only the content length is checked.
+	assert.Equal(t, "a", envoyFilter.ConfigPatches[3].Match.GetRouteConfiguration().GetVhost().GetRoute().GetName())
+	luaPerRoute, bufferPerRoute = perRoutes(3)
+	assert.Equal(t, "8388608", luaPerRoute.GetName())
+	assert.Nil(t, bufferPerRoute)
+
+	assert.Equal(t, "b", envoyFilter.ConfigPatches[4].Match.GetRouteConfiguration().GetVhost().GetRoute().GetName())
+	luaPerRoute, bufferPerRoute = perRoutes(4)
+	assert.Equal(t, "1024", luaPerRoute.GetName())
+	assert.Equal(t, uint32(1024), bufferPerRoute.GetBuffer().GetMaxRequestBytes().GetValue())
+}
+
+func TestConstructCompressionEnvoyFilter(t *testing.T) {
+	level := uint32(5)
+	compression := &annotations.CompressionConfig{
+		Algorithms:   []string{annotations.BrotliCompression, annotations.GzipCompression},
+		MinLength:    1024,
+		ContentTypes: []string{"application/json"},
+		Level:        &level,
+	}
+	routes := []*common.WrapperHTTPRoute{
+		{
+			HTTPRoute: &networking.HTTPRoute{Name: "a"},
+			WrapperConfig: &common.WrapperConfig{
+				AnnotationsConfig: &annotations.Ingress{Compression: compression},
+			},
+		},
+		{
+			HTTPRoute: &networking.HTTPRoute{Name: "b"},
+			WrapperConfig: &common.WrapperConfig{
+				AnnotationsConfig: &annotations.Ingress{Compression: compression},
+			},
+		},
+	}
+
+	config, err := constructCompressionEnvoyFilter(routes, "")
+	if err != nil {
+		t.Fatalf("construct error %v", err)
+	}
+	envoyFilter := config.Spec.(*networking.EnvoyFilter)
+	// The gzip and brotli compressors, the mark filter, and the config of two routes.
+	assert.Equal(t, 5, len(envoyFilter.ConfigPatches))
+
+	name := compressorFilterName(compression)
+	pb, err := xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_FILTER, envoyFilter.ConfigPatches[0].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	httpFilter := pb.(*httppb.HttpFilter)
+	// The brotli compressor is preferred, so it is inserted later.
+	assert.Equal(t, name+"."+annotations.GzipCompression, httpFilter.Name)
+	withMatcher := &matchingpb.ExtensionWithMatcher{}
+	if err = httpFilter.GetTypedConfig().UnmarshalTo(withMatcher); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	predicate := withMatcher.GetMatcher().GetMatcherList().GetMatchers()[0].GetPredicate()
+	assert.Equal(t, name, predicate.GetNotMatcher().GetSinglePredicate().GetValueMatch().GetExact())
+	compressor := &compressorpb.Compressor{}
+	if err = withMatcher.GetExtensionConfig().GetTypedConfig().UnmarshalTo(compressor); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	commonConfig := compressor.GetResponseDirectionConfig().GetCommonConfig()
+	assert.Equal(t, uint32(1024), commonConfig.GetMinContentLength().GetValue())
+	assert.Equal(t, []string{"application/json"}, commonConfig.GetContentType())
+	gzip := &gzippb.Gzip{}
+	if err = compressor.GetCompressorLibrary().GetTypedConfig().UnmarshalTo(gzip); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	assert.Equal(t, gzippb.Gzip_COMPRESSION_LEVEL_5, gzip.CompressionLevel)
+
+	pb, err = xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_FILTER, envoyFilter.ConfigPatches[2].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	assert.Equal(t, compressionMarkFilter, pb.(*httppb.HttpFilter).Name)
+
+	assert.Equal(t, "a", envoyFilter.ConfigPatches[3].Match.GetRouteConfiguration().GetVhost().GetRoute().GetName())
+	pb, err = xds.BuildXDSObjectFromStruct(networking.EnvoyFilter_HTTP_ROUTE, envoyFilter.ConfigPatches[3].Patch.Value, false)
+	if err != nil {
+		t.Fatalf("build object error %v", err)
+	}
+	route := pb.(*routepb.Route)
+	assert.Equal(t, []string{compressionHeader}, route.RequestHeadersToRemove)
+	perRoute := &luapb.LuaPerRoute{}
+	if err = route.TypedPerFilterConfig[compressionMarkFilter].UnmarshalTo(perRoute); err != nil {
+		t.Fatalf("unmarshal error %v", err)
+	}
+	assert.Contains(t, perRoute.GetSourceCode().GetInlineString(), strconv.Quote(name))
+}
+
+func TestQueryParamRegex(t *testing.T) {
+	testCases := []struct {
+		name   string
+		value  string
+		path   string
+		expect bool
+	}{
+		{name: "tenant", value: "a", path: "/foo?tenant=a", expect: true},
+		{name: "tenant", value: "a", path: "/foo?id=1&tenant=a&x=y", expect: true},
+		{name: "tenant", value: "a", path: "/foo?tenant=ab", expect: false},
+		{name: "tenant", value: "a", path: "/foo?mytenant=a", expect: false},
+		{name: "tenant", value: "a", path: "/tenant=a", expect: false},
+		{name: "tenant", value: "a b", path: "/foo?tenant=a+b", expect: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run("", func(t *testing.T) {
+			regex := regexp.MustCompile(queryParamRegex(testCase.name, testCase.value))
+			assert.Equal(t, testCase.expect, regex.MatchString(testCase.path))
+		})
+	}
+}
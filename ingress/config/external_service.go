@@ -0,0 +1,217 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/gvk"
+
+	"github.com/alibaba/higress/ingress/kube/common"
+	"github.com/alibaba/higress/ingress/kube/util"
+)
+
+// externalService is a service called by envoy filters directly, such as the auth service. The clusters of
+// services outside the mesh are not built by istio unless they are exported as service entries.
+type externalService struct {
+	host string
+	port uint32
+	// The istio protocol name of port.
+	protocol string
+	tls      bool
+	// The path of ca certificates verifying the service if called over tls, defaults to the system roots.
+	caCertificates string
+}
+
+// systemCACertificates is the path of the system roots within the gateway image.
+const systemCACertificates = "/etc/ssl/certs/ca-certificates.crt"
+
+// isMeshHost returns whether the host is the FQDN of kubernetes service, whose clusters are always built.
+func isMeshHost(host string) bool {
+	return strings.HasSuffix(host, ".svc."+util.DefaultDomainSuffix)
+}
+
+// externalServices returns the services outside the mesh referred by the annotations of ingresses.
+func externalServices(configs []common.WrapperConfig) []externalService {
+	services := map[string]externalService{}
+	add := func(service externalService) {
+		if service.host == "" || isMeshHost(service.host) {
+			return
+		}
+		key := service.host + ":" + strconv.Itoa(int(service.port))
+		// The ca certificates set by the ingress found first are kept.
+		if existing, exist := services[key]; exist && existing.caCertificates != "" {
+			return
+		}
+		services[key] = service
+	}
+
+	for _, cfg := range configs {
+		annotationsConfig := cfg.AnnotationsConfig
+		if annotationsConfig == nil {
+			continue
+		}
+		caCertificates := ""
+		if annotationsConfig.ExternalTLS != nil {
+			caCertificates = annotationsConfig.ExternalTLS.CACertificates
+		}
+		if extAuth := annotationsConfig.ExtAuth; extAuth != nil && !extAuth.Invalid {
+			add(externalService{
+				host:           extAuth.ServiceHost,
+				port:           extAuth.ServicePort,
+				protocol:       "HTTP",
+				tls:            extAuth.ServiceTLS,
+				caCertificates: caCertificates,
+			})
+		}
+		if jwt := annotationsConfig.Jwt; jwt != nil && jwt.JwksURI != "" {
+			add(externalService{
+				host:           jwt.JwksServiceHost,
+				port:           jwt.JwksServicePort,
+				protocol:       "HTTP",
+				tls:            jwt.JwksServiceTLS,
+				caCertificates: caCertificates,
+			})
+		}
+		if oidc := annotationsConfig.Oidc; oidc != nil && !oidc.Invalid {
+			add(externalService{
+				host:           oidc.TokenServiceHost,
+				port:           oidc.TokenServicePort,
+				protocol:       "HTTP",
+				tls:            oidc.TokenServiceTLS,
+				caCertificates: caCertificates,
+			})
+		}
+		if global := annotationsConfig.GlobalRateLimit; global != nil {
+			add(externalService{
+				host:     global.ServiceHost,
+				port:     global.ServicePort,
+				protocol: "GRPC",
+			})
+		}
+	}
+
+	out := make([]externalService, 0, len(services))
+	for _, service := range services {
+		out = append(out, service)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].host != out[j].host {
+			return out[i].host < out[j].host
+		}
+		return out[i].port < out[j].port
+	})
+	return out
+}
+
+func externalServiceName(host string) string {
+	return common.CreateConvertedName(constants.IstioIngressGatewayName, "external", common.CleanHost(host))
+}
+
+// convertExternalServiceEntries exports each external host as a service entry, skipping the hosts
+// which already come from registries.
+func convertExternalServiceEntries(services []externalService, namespace string, existing []config.Config) []config.Config {
+	existingHosts := map[string]bool{}
+	for _, se := range existing {
+		for _, host := range se.Spec.(*networking.ServiceEntry).Hosts {
+			existingHosts[host] = true
+		}
+	}
+
+	var out []config.Config
+	serviceEntries := map[string]*networking.ServiceEntry{}
+	for _, service := range services {
+		if existingHosts[service.host] {
+			continue
+		}
+		se, exist := serviceEntries[service.host]
+		if !exist {
+			se = &networking.ServiceEntry{
+				Hosts:      []string{service.host},
+				Location:   networking.ServiceEntry_MESH_EXTERNAL,
+				Resolution: networking.ServiceEntry_DNS,
+			}
+			if net.ParseIP(service.host) != nil {
+				se.Resolution = networking.ServiceEntry_STATIC
+				se.Endpoints = []*networking.WorkloadEntry{{Address: service.host}}
+			}
+			serviceEntries[service.host] = se
+			out = append(out, config.Config{
+				Meta: config.Meta{
+					GroupVersionKind: gvk.ServiceEntry,
+					Name:             externalServiceName(service.host),
+					Namespace:        namespace,
+				},
+				Spec: se,
+			})
+		}
+		se.Ports = append(se.Ports, &networking.Port{
+			Number:   service.port,
+			Protocol: service.protocol,
+			Name:     strings.ToLower(service.protocol) + "-" + strconv.Itoa(int(service.port)),
+		})
+	}
+	return out
+}
+
+// convertExternalDestinationRules originates tls to the ports of external hosts called over tls, and verifies
+// the certificates of hosts, since the auth services receive the credentials of requests.
+func convertExternalDestinationRules(services []externalService, namespace string) []config.Config {
+	var out []config.Config
+	destinationRules := map[string]*networking.DestinationRule{}
+	for _, service := range services {
+		if !service.tls {
+			continue
+		}
+		dr, exist := destinationRules[service.host]
+		if !exist {
+			dr = &networking.DestinationRule{
+				Host:          service.host,
+				TrafficPolicy: &networking.TrafficPolicy{},
+			}
+			destinationRules[service.host] = dr
+			out = append(out, config.Config{
+				Meta: config.Meta{
+					GroupVersionKind: gvk.DestinationRule,
+					Name:             externalServiceName(service.host),
+					Namespace:        namespace,
+				},
+				Spec: dr,
+			})
+		}
+		tls := &networking.ClientTLSSettings{
+			Mode:           networking.ClientTLSSettings_SIMPLE,
+			CaCertificates: service.caCertificates,
+		}
+		if tls.CaCertificates == "" {
+			tls.CaCertificates = systemCACertificates
+		}
+		if net.ParseIP(service.host) == nil {
+			tls.Sni = service.host
+			tls.SubjectAltNames = []string{service.host}
+		}
+		dr.TrafficPolicy.PortLevelSettings = append(dr.TrafficPolicy.PortLevelSettings, &networking.TrafficPolicy_PortTrafficPolicy{
+			Port: &networking.PortSelector{Number: service.port},
+			Tls:  tls,
+		})
+	}
+	return out
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config"
+
+	"github.com/alibaba/higress/ingress/kube/annotations"
+	"github.com/alibaba/higress/ingress/kube/common"
+)
+
+func TestExternalServices(t *testing.T) {
+	configs := []common.WrapperConfig{
+		{
+			AnnotationsConfig: &annotations.Ingress{
+				ExtAuth: &annotations.ExtAuthConfig{
+					ServiceHost: "auth.example.com",
+					ServicePort: 443,
+					ServiceTLS:  true,
+				},
+			},
+		},
+		{
+			AnnotationsConfig: &annotations.Ingress{
+				ExtAuth: &annotations.ExtAuthConfig{
+					ServiceHost: "auth.default.svc.cluster.local",
+					ServicePort: 80,
+				},
+			},
+		},
+		{
+			AnnotationsConfig: &annotations.Ingress{
+				ExtAuth: &annotations.ExtAuthConfig{
+					ServiceHost: "auth.example.com",
+					ServicePort: 8080,
+				},
+			},
+		},
+		{
+			AnnotationsConfig: &annotations.Ingress{
+				ExtAuth: &annotations.ExtAuthConfig{
+					ServiceHost: "10.0.0.1",
+					ServicePort: 443,
+					ServiceTLS:  true,
+				},
+			},
+		},
+		{
+			AnnotationsConfig: &annotations.Ingress{},
+		},
+	}
+
+	services := externalServices(configs)
+	assert.Equal(t, []externalService{
+		{host: "10.0.0.1", port: 443, protocol: "HTTP", tls: true},
+		{host: "auth.example.com", port: 443, protocol: "HTTP", tls: true},
+		{host: "auth.example.com", port: 8080, protocol: "HTTP"},
+	}, services)
+
+	registry := config.Config{
+		Spec: &networking.ServiceEntry{Hosts: []string{"10.0.0.1"}},
+	}
+	serviceEntries := convertExternalServiceEntries(services, "higress-system", []config.Config{registry})
+	assert.Equal(t, 1, len(serviceEntries))
+	assert.Equal(t, "istio-autogenerated-k8s-ingress-external-auth-example-com", serviceEntries[0].Name)
+	se := serviceEntries[0].Spec.(*networking.ServiceEntry)
+	assert.Equal(t, []string{"auth.example.com"}, se.Hosts)
+	assert.Equal(t, networking.ServiceEntry_MESH_EXTERNAL, se.Location)
+	assert.Equal(t, networking.ServiceEntry_DNS, se.Resolution)
+	assert.Equal(t, []*networking.Port{
+		{Number: 443, Protocol: "HTTP", Name: "http-443"},
+		{Number: 8080, Protocol: "HTTP", Name: "http-8080"},
+	}, se.Ports)
+
+	serviceEntries = convertExternalServiceEntries(services[:1], "higress-system", nil)
+	se = serviceEntries[0].Spec.(*networking.ServiceEntry)
+	assert.Equal(t, networking.ServiceEntry_STATIC, se.Resolution)
+	assert.Equal(t, "10.0.0.1", se.Endpoints[0].Address)
+
+	destinationRules := convertExternalDestinationRules(services, "higress-system")
+	assert.Equal(t, 2, len(destinationRules))
+	dr := destinationRules[0].Spec.(*networking.DestinationRule)
+	assert.Equal(t, "10.0.0.1", dr.Host)
+	assert.Equal(t, "", dr.TrafficPolicy.PortLevelSettings[0].Tls.Sni)
+	dr = destinationRules[1].Spec.(*networking.DestinationRule)
+	assert.Equal(t, "auth.example.com", dr.Host)
+	assert.Equal(t, 1, len(dr.TrafficPolicy.PortLevelSettings))
+	assert.Equal(t, uint32(443), dr.TrafficPolicy.PortLevelSettings[0].Port.Number)
+	assert.Equal(t, networking.ClientTLSSettings_SIMPLE, dr.TrafficPolicy.PortLevelSettings[0].Tls.Mode)
+	assert.Equal(t, "auth.example.com", dr.TrafficPolicy.PortLevelSettings[0].Tls.Sni)
+	assert.Equal(t, []string{"auth.example.com"}, dr.TrafficPolicy.PortLevelSettings[0].Tls.SubjectAltNames)
+	assert.Equal(t, "/etc/ssl/certs/ca-certificates.crt", dr.TrafficPolicy.PortLevelSettings[0].Tls.CaCertificates)
+}
+
+func TestExternalServicesCACertificates(t *testing.T) {
+	configs := []common.WrapperConfig{
+		{
+			AnnotationsConfig: &annotations.Ingress{
+				ExtAuth: &annotations.ExtAuthConfig{
+					ServiceHost: "auth.example.com",
+					ServicePort: 443,
+					ServiceTLS:  true,
+				},
+			},
+		},
+		{
+			AnnotationsConfig: &annotations.Ingress{
+				ExtAuth: &annotations.ExtAuthConfig{
+					ServiceHost: "auth.example.com",
+					ServicePort: 443,
+					ServiceTLS:  true,
+				},
+				ExternalTLS: &annotations.ExternalTLSConfig{
+					CACertificates: "/etc/certs/ca.crt",
+				},
+			},
+		},
+		{
+			AnnotationsConfig: &annotations.Ingress{
+				ExtAuth: &annotations.ExtAuthConfig{
+					ServiceHost: "auth.example.com",
+					ServicePort: 443,
+					ServiceTLS:  true,
+				},
+				ExternalTLS: &annotations.ExternalTLSConfig{
+					CACertificates: "/etc/other/ca.crt",
+				},
+			},
+		},
+	}
+
+	services := externalServices(configs)
+	assert.Equal(t, []externalService{
+		{host: "auth.example.com", port: 443, protocol: "HTTP", tls: true, caCertificates: "/etc/certs/ca.crt"},
+	}, services)
+
+	drs := convertExternalDestinationRules(services, "higress-system")
+	tls := drs[0].Spec.(*networking.DestinationRule).TrafficPolicy.PortLevelSettings[0].Tls
+	assert.Equal(t, "/etc/certs/ca.crt", tls.CaCertificates)
+}
+
+func TestExternalServicesOfJwt(t *testing.T) {
+	configs := []common.WrapperConfig{
+		{
+			AnnotationsConfig: &annotations.Ingress{
+				Jwt: &annotations.JwtConfig{
+					JwksURI:         "https://issuer.example.com/jwks.json",
+					JwksServiceHost: "issuer.example.com",
+					JwksServicePort: 443,
+					JwksServiceTLS:  true,
+				},
+			},
+		},
+		{
+			AnnotationsConfig: &annotations.Ingress{
+				Jwt: &annotations.JwtConfig{
+					JwksURI:         "http://jwks.default.svc.cluster.local/jwks.json",
+					JwksServiceHost: "jwks.default.svc.cluster.local",
+					JwksServicePort: 80,
+				},
+			},
+		},
+		{
+			AnnotationsConfig: &annotations.Ingress{
+				Jwt: &annotations.JwtConfig{Invalid: true},
+			},
+		},
+	}
+
+	assert.Equal(t, []externalService{
+		{host: "issuer.example.com", port: 443, protocol: "HTTP", tls: true},
+	}, externalServices(configs))
+}
+
+func TestExternalServicesOfOidc(t *testing.T) {
+	configs := []common.WrapperConfig{
+		{
+			AnnotationsConfig: &annotations.Ingress{
+				Oidc: &annotations.OidcConfig{
+					TokenEndpoint:    "https://idp.example.com/token",
+					TokenServiceHost: "idp.example.com",
+					TokenServicePort: 443,
+					TokenServiceTLS:  true,
+				},
+			},
+		},
+		{
+			AnnotationsConfig: &annotations.Ingress{
+				Oidc: &annotations.OidcConfig{
+					TokenEndpoint:    "http://keycloak.default.svc.cluster.local:8080/token",
+					TokenServiceHost: "keycloak.default.svc.cluster.local",
+					TokenServicePort: 8080,
+				},
+			},
+		},
+	}
+
+	services := externalServices(configs)
+	assert.Equal(t, []externalService{
+		{host: "idp.example.com", port: 443, protocol: "HTTP", tls: true},
+	}, services)
+
+	drs := convertExternalDestinationRules(services, "higress-system")
+	assert.Equal(t, 1, len(drs))
+	dr := drs[0].Spec.(*networking.DestinationRule)
+	assert.Equal(t, "idp.example.com", dr.Host)
+	assert.Equal(t, networking.ClientTLSSettings_SIMPLE, dr.TrafficPolicy.PortLevelSettings[0].Tls.Mode)
+}
+
+func TestExternalServicesOfGlobalRateLimit(t *testing.T) {
+	configs := []common.WrapperConfig{
+		{
+			AnnotationsConfig: &annotations.Ingress{
+				GlobalRateLimit: &annotations.GlobalRateLimitConfig{
+					ServiceHost: "rls.example.com",
+					ServicePort: 8081,
+				},
+			},
+		},
+		{
+			AnnotationsConfig: &annotations.Ingress{
+				GlobalRateLimit: &annotations.GlobalRateLimitConfig{
+					ServiceHost: "rls.higress-system.svc.cluster.local",
+					ServicePort: 8081,
+				},
+			},
+		},
+	}
+
+	services := externalServices(configs)
+	assert.Equal(t, []externalService{
+		{host: "rls.example.com", port: 8081, protocol: "GRPC"},
+	}, services)
+
+	serviceEntries := convertExternalServiceEntries(services, "higress-system", nil)
+	assert.Equal(t, 1, len(serviceEntries))
+	assert.Equal(t, []*networking.Port{
+		{Number: 8081, Protocol: "GRPC", Name: "grpc-8081"},
+	}, serviceEntries[0].Spec.(*networking.ServiceEntry).Ports)
+	assert.Equal(t, 0, len(convertExternalDestinationRules(services, "higress-system")))
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"istio.io/istio/pilot/pkg/model"
+
+	"github.com/alibaba/higress/ingress/kube/annotations"
+	"github.com/alibaba/higress/ingress/kube/util"
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	genericSecretPrefix = "higress-generic://"
+
+	// The secret within the namespace of controller holds the key signing the session cookies of oidc,
+	// so the sessions issued by a gateway can be verified by the others.
+	oidcHmacSecretName = "higress-oidc"
+	oidcHmacSecretKey  = "hmac-secret"
+	oidcHmacMinSize    = 16
+)
+
+// genericSecret refers to the data of a key within a secret, which is served to gateways as a generic secret by sds.
+type genericSecret struct {
+	util.ClusterNamespacedName
+	Key string
+	// The data shorter than it is not served.
+	MinSize int
+}
+
+// Name is the name of sds resource.
+func (s genericSecret) Name() string {
+	return genericSecretPrefix + s.ClusterNamespacedName.String() + "/" + s.Key
+}
+
+func oidcClientSecret(oidc *annotations.OidcConfig) genericSecret {
+	return genericSecret{
+		ClusterNamespacedName: oidc.ClientSecret,
+		Key:                   annotations.OidcClientSecretKey,
+		MinSize:               1,
+	}
+}
+
+func (m *IngressConfig) oidcHmacSecret() genericSecret {
+	return genericSecret{
+		ClusterNamespacedName: util.ClusterNamespacedName{
+			NamespacedName: model.NamespacedName{
+				Namespace: m.namespace,
+				Name:      oidcHmacSecretName,
+			},
+			ClusterId: m.localClusterId,
+		},
+		Key:     oidcHmacSecretKey,
+		MinSize: oidcHmacMinSize,
+	}
+}
+
+// GetGenericSecret returns the data of generic secret by the name of sds resource. Only the secrets referred by
+// the converted envoy filters are returned.
+func (m *IngressConfig) GetGenericSecret(name string) ([]byte, bool) {
+	m.mutex.RLock()
+	secret, exist := m.genericSecrets[name]
+	ingressController := m.remoteIngressControllers[secret.ClusterId]
+	m.mutex.RUnlock()
+	if !exist || ingressController == nil {
+		return nil, false
+	}
+
+	kubeSecret, err := ingressController.SecretLister().Secrets(secret.Namespace).Get(secret.Name)
+	if err != nil {
+		IngressLog.Errorf("Get generic secret %s error %v", name, err)
+		return nil, false
+	}
+	data := kubeSecret.Data[secret.Key]
+	if len(data) < secret.MinSize {
+		IngressLog.Errorf("The %s of secret %s must have at least %d bytes", secret.Key, secret.NamespacedName, secret.MinSize)
+		return nil, false
+	}
+	return data, true
+}
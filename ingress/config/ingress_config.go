@@ -16,8 +16,13 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
@@ -39,11 +44,26 @@ import (
 
 	"github.com/alibaba/higress/ingress/kube/annotations"
 	"github.com/alibaba/higress/ingress/kube/common"
+	"github.com/alibaba/higress/ingress/kube/gateway"
 	"github.com/alibaba/higress/ingress/kube/ingress"
 	"github.com/alibaba/higress/ingress/kube/ingressv1"
+	"github.com/alibaba/higress/ingress/kube/mcpbridge"
+	mcpbridgekube "github.com/alibaba/higress/ingress/kube/mcpbridge/kube"
 	secretkube "github.com/alibaba/higress/ingress/kube/secret/kube"
 	"github.com/alibaba/higress/ingress/kube/util"
 	. "github.com/alibaba/higress/ingress/log"
+	"github.com/alibaba/higress/registry/reconcile"
+)
+
+const (
+	// digestAuthNonceMaxAge is how long a nonce of digest auth stays valid. It bounds the window of replaying
+	// a captured request from the same client, and clients renew the expired nonce without prompting users.
+	digestAuthNonceMaxAge = 30 * time.Second
+
+	// The secret within the namespace of controller holds the key signing the nonces of digest auth,
+	// so the nonces issued by a gateway can be verified by the others, even after the controller restarts.
+	digestAuthNonceSecretName = "higress-digest-auth"
+	digestAuthNonceSecretKey  = "nonce-key"
 )
 
 var (
@@ -59,27 +79,53 @@ type IngressConfig struct {
 	ingressRouteCache  model.IngressRouteCollection
 	ingressDomainCache model.IngressDomainCollection
 
+	// The translation errors reported to users by the status syncer of each cluster.
+	ingressRouteErrors  []common.IngressError
+	ingressDomainErrors []common.IngressError
+
 	localKubeClient kube.Client
 
 	virtualServiceHandlers  []model.EventHandler
 	gatewayHandlers         []model.EventHandler
 	destinationRuleHandlers []model.EventHandler
 	envoyFilterHandlers     []model.EventHandler
+	serviceEntryHandlers    []model.EventHandler
 	watchErrorHandler       cache.WatchErrorHandler
 
 	cachedEnvoyFilters []config.Config
 
+	// The generic secrets referred by the cached envoy filters, key: the name of sds resource.
+	genericSecrets map[string]genericSecret
+
+	translationCache *translationCache
+
 	watchedSecretSet sets.Set
 
+	mcpbridgeOnce sync.Once
+
+	gatewayOnce sync.Once
+
 	XDSUpdater model.XDSUpdater
 
 	annotationHandler annotations.AnnotationHandler
 
+	mcpbridgeController mcpbridge.Controller
+
+	gatewayController gateway.Controller
+
+	RegistryReconciler *reconcile.Reconciler
+
 	globalGatewayName string
 
 	namespace string
 
 	clusterId string
+
+	// The cluster id of the ingress controller watching the local cluster.
+	localClusterId string
+
+	// The cluster through which gateways fetch the generic secrets.
+	sdsCluster string
 }
 
 func NewIngressConfig(localKubeClient kube.Client, XDSUpdater model.XDSUpdater, namespace, clusterId string) *IngressConfig {
@@ -96,13 +142,15 @@ func NewIngressConfig(localKubeClient kube.Client, XDSUpdater model.XDSUpdater,
 			common.CreateConvertedName(clusterId, "global"),
 		watchedSecretSet: sets.NewSet(),
 		namespace:        namespace,
+		translationCache: newTranslationCache(),
 	}
 }
 
 func (m *IngressConfig) RegisterEventHandler(kind config.GroupVersionKind, f model.EventHandler) {
 	IngressLog.Infof("register resource %v", kind)
 	if kind != gvk.VirtualService && kind != gvk.Gateway &&
-		kind != gvk.DestinationRule && kind != gvk.EnvoyFilter {
+		kind != gvk.DestinationRule && kind != gvk.EnvoyFilter &&
+		kind != gvk.ServiceEntry {
 		return
 	}
 
@@ -118,17 +166,32 @@ func (m *IngressConfig) RegisterEventHandler(kind config.GroupVersionKind, f mod
 
 	case gvk.EnvoyFilter:
 		m.envoyFilterHandlers = append(m.envoyFilterHandlers, f)
+
+	case gvk.ServiceEntry:
+		// Service entries come from registries, and the external services referred by ingresses.
+		m.serviceEntryHandlers = append(m.serviceEntryHandlers, f)
 	}
 
 	for _, remoteIngressController := range m.remoteIngressControllers {
 		remoteIngressController.RegisterEventHandler(kind, f)
 	}
+	if m.gatewayController != nil {
+		m.gatewayController.RegisterEventHandler(kind, f)
+	}
 }
 
 func (m *IngressConfig) AddLocalCluster(options common.Options) common.IngressController {
 	secretController := secretkube.NewController(m.localKubeClient, options)
 	secretController.AddEventHandler(m.ReflectSecretChanges)
 
+	m.RegistryReconciler = reconcile.NewReconciler(m.notifyServiceEntryChanges, options.KeepStaleWhenEmpty)
+	m.mcpbridgeController = mcpbridgekube.NewController(m.localKubeClient, options)
+	m.mcpbridgeController.AddEventHandler(m.AddOrUpdateMcpBridge)
+
+	if options.EnableGatewayAPI {
+		m.gatewayController = gateway.NewController(m.localKubeClient, options)
+	}
+
 	var ingressController common.IngressController
 	v1 := common.V1Available(m.localKubeClient)
 	if !v1 {
@@ -137,6 +200,8 @@ func (m *IngressConfig) AddLocalCluster(options common.Options) common.IngressCo
 		ingressController = ingressv1.NewController(m.localKubeClient, m.localKubeClient, options, secretController)
 	}
 
+	m.localClusterId = options.ClusterId
+	m.sdsCluster = options.SdsCluster
 	m.remoteIngressControllers[options.ClusterId] = ingressController
 	return ingressController
 }
@@ -154,10 +219,30 @@ func (m *IngressConfig) InitializeCluster(ingressController common.IngressContro
 	for _, handler := range m.envoyFilterHandlers {
 		ingressController.RegisterEventHandler(gvk.EnvoyFilter, handler)
 	}
+	for _, handler := range m.serviceEntryHandlers {
+		ingressController.RegisterEventHandler(gvk.ServiceEntry, handler)
+	}
 
 	_ = ingressController.SetWatchErrorHandler(m.watchErrorHandler)
 
 	go ingressController.Run(stop)
+
+	if m.mcpbridgeController != nil {
+		m.mcpbridgeOnce.Do(func() {
+			go m.mcpbridgeController.Run(stop)
+			go func() {
+				<-stop
+				m.RegistryReconciler.Stop()
+			}()
+		})
+	}
+
+	if m.gatewayController != nil {
+		m.gatewayOnce.Do(func() {
+			_ = m.gatewayController.SetWatchErrorHandler(m.watchErrorHandler)
+			go m.gatewayController.Run(stop)
+		})
+	}
 	return nil
 }
 
@@ -165,7 +250,8 @@ func (m *IngressConfig) List(typ config.GroupVersionKind, namespace string) ([]c
 	if typ != gvk.Gateway &&
 		typ != gvk.VirtualService &&
 		typ != gvk.DestinationRule &&
-		typ != gvk.EnvoyFilter {
+		typ != gvk.EnvoyFilter &&
+		typ != gvk.ServiceEntry {
 		return nil, common.ErrUnsupportedOp
 	}
 
@@ -182,27 +268,52 @@ func (m *IngressConfig) List(typ config.GroupVersionKind, namespace string) ([]c
 		return m.cachedEnvoyFilters, nil
 	}
 
+	if typ == gvk.ServiceEntry {
+		serviceEntries := m.convertServiceEntry()
+		wrapperConfigs := m.createWrapperConfigs(m.listIngresses())
+		serviceEntries = append(serviceEntries, convertExternalServiceEntries(externalServices(wrapperConfigs), m.namespace, serviceEntries)...)
+		IngressLog.Infof("resource type %s, configs number %d", typ, len(serviceEntries))
+		return serviceEntries, nil
+	}
+
+	out := m.convertIngresses(typ, m.listIngresses())
+	// Configs converted from Gateway API are appended after the ones of ingresses.
+	if m.gatewayController != nil {
+		out = append(out, m.gatewayController.List(typ)...)
+	}
+	return out, nil
+}
+
+// listIngresses returns the ingresses of all clusters.
+func (m *IngressConfig) listIngresses() []config.Config {
 	var configs []config.Config
 	m.mutex.RLock()
 	for _, ingressController := range m.remoteIngressControllers {
 		configs = append(configs, ingressController.List()...)
 	}
 	m.mutex.RUnlock()
+	return configs
+}
 
+// convertIngresses translates ingresses into the configs of the given type, reusing the cached
+// results of the ingresses and hosts which are not changed.
+func (m *IngressConfig) convertIngresses(typ config.GroupVersionKind, configs []config.Config) []config.Config {
 	common.SortIngressByCreationTime(configs)
 	wrapperConfigs := m.createWrapperConfigs(configs)
 
 	IngressLog.Infof("resource type %s, configs number %d", typ, len(wrapperConfigs))
+	cache := m.translationCache
 	switch typ {
 	case gvk.Gateway:
-		return m.convertGateways(wrapperConfigs), nil
+		return m.extractGateways(cache.gateways.convert(wrapperConfigs, cache.version, m.buildGateways))
 	case gvk.VirtualService:
-		return m.convertVirtualService(wrapperConfigs), nil
+		return m.extractVirtualServices(cache.virtualServices.convert(wrapperConfigs, cache.version, m.buildVirtualServices))
 	case gvk.DestinationRule:
-		return m.convertDestinationRule(wrapperConfigs), nil
+		out := m.convertDestinationRule(wrapperConfigs)
+		return append(out, convertExternalDestinationRules(externalServices(wrapperConfigs), m.namespace)...)
 	}
 
-	return nil, nil
+	return nil
 }
 
 func (m *IngressConfig) createWrapperConfigs(configs []config.Config) []common.WrapperConfig {
@@ -217,37 +328,75 @@ func (m *IngressConfig) createWrapperConfigs(configs []config.Config) []common.W
 		clusterServiceListers[clusterId] = controller.ServiceLister()
 	}
 	m.mutex.RUnlock()
-	globalContext := &annotations.GlobalContext{
-		WatchedSecrets:      sets.NewSet(),
-		ClusterSecretLister: clusterSecretListers,
-		ClusterServiceList:  clusterServiceListers,
-	}
+	watchedSecrets := sets.NewSet()
+	keys := sets.NewSet()
 
 	for idx := range configs {
 		rawConfig := configs[idx]
-		annotationsConfig := &annotations.Ingress{
-			Meta: annotations.Meta{
-				Namespace:    rawConfig.Namespace,
-				Name:         rawConfig.Name,
-				RawClusterId: common.GetRawClusterId(rawConfig.Annotations),
-				ClusterId:    common.GetClusterId(rawConfig.Annotations),
-			},
+		key := ingressKey(&rawConfig)
+		keys.Insert(key)
+
+		clusterId := common.GetClusterId(rawConfig.Annotations)
+		serviceLister := clusterServiceListers[clusterId]
+
+		// Only parse annotations again when the ingress or the services it refers to change.
+		entry := m.translationCache.getIngress(key, rawConfig.ResourceVersion, serviceLister)
+		if entry == nil {
+			globalContext := &annotations.GlobalContext{
+				WatchedSecrets:      sets.NewSet(),
+				WatchedServices:     sets.NewSet(ingressServices(&rawConfig)...),
+				ClusterSecretLister: clusterSecretListers,
+				ClusterServiceList:  clusterServiceListers,
+			}
+			annotationsConfig := &annotations.Ingress{
+				Meta: annotations.Meta{
+					Namespace:    rawConfig.Namespace,
+					Name:         rawConfig.Name,
+					RawClusterId: common.GetRawClusterId(rawConfig.Annotations),
+					ClusterId:    clusterId,
+				},
+			}
+			if err := m.annotationHandler.Parse(rawConfig.Annotations, annotationsConfig, globalContext); err != nil {
+				common.IncrementInvalidIngress(annotationsConfig.ClusterId, common.InvalidAnnotation)
+			}
+			entry = &ingressEntry{
+				resourceVersion:   rawConfig.ResourceVersion,
+				annotationsConfig: annotationsConfig,
+				watchedSecrets:    globalContext.WatchedSecrets,
+				watchedServices:   globalContext.WatchedServices,
+				servicesVersion:   servicesVersion(serviceLister, globalContext.WatchedServices),
+			}
+			m.translationCache.setIngress(key, entry)
 		}
-		_ = m.annotationHandler.Parse(rawConfig.Annotations, annotationsConfig, globalContext)
+
+		for secret := range entry.watchedSecrets {
+			watchedSecrets.Insert(secret)
+		}
+		// Converting may replace the fields of the annotations config, so use a copy to keep the cached one intact.
+		// The nested configs are shared with the cached one, which must be treated as read-only.
+		annotationsConfig := *entry.annotationsConfig
 		wrapperConfigs = append(wrapperConfigs, common.WrapperConfig{
 			Config:            &rawConfig,
-			AnnotationsConfig: annotationsConfig,
+			AnnotationsConfig: &annotationsConfig,
 		})
 	}
+	m.translationCache.retainIngresses(keys)
+	// Subscribe the nonce key of digest auth and the hmac secret of oidc, so the rotation of keys will trigger a push.
+	watchedSecrets.Insert(m.digestAuthNonceSecret().String())
+	watchedSecrets.Insert(m.oidcHmacSecret().String())
 
 	m.mutex.Lock()
-	m.watchedSecretSet = globalContext.WatchedSecrets
+	m.watchedSecretSet = watchedSecrets
 	m.mutex.Unlock()
 
 	return wrapperConfigs
 }
 
 func (m *IngressConfig) convertGateways(configs []common.WrapperConfig) []config.Config {
+	return m.extractGateways(m.buildGateways(configs))
+}
+
+func (m *IngressConfig) buildGateways(configs []common.WrapperConfig) map[string]*hostOutput {
 	convertOptions := common.ConvertOptions{
 		IngressDomainCache: common.NewIngressDomainCache(),
 		Gateways:           map[string]*common.WrapperGateway{},
@@ -272,14 +421,25 @@ func (m *IngressConfig) convertGateways(configs []common.WrapperConfig) []config
 		m.annotationHandler.ApplyGateway(wrapperGateway.Gateway, wrapperGateway.WrapperConfig.AnnotationsConfig)
 	}
 
-	m.mutex.Lock()
-	m.ingressDomainCache = convertOptions.IngressDomainCache.Extract()
-	m.mutex.Unlock()
+	outputs := map[string]*hostOutput{}
+	domains := convertOptions.IngressDomainCache.Extract()
+	for _, domain := range domains.Valid {
+		output := outputOf(outputs, domain.Host)
+		output.domains.Valid = append(output.domains.Valid, domain)
+	}
+	for _, domain := range domains.Invalid {
+		output := outputOf(outputs, domain.Host)
+		output.domains.Invalid = append(output.domains.Invalid, domain)
+	}
+	for _, ingressError := range convertOptions.IngressDomainCache.Errors {
+		output := outputOf(outputs, ingressError.Host)
+		output.errors = append(output.errors, ingressError)
+	}
 
-	out := make([]config.Config, 0, len(convertOptions.Gateways))
 	for _, gateway := range convertOptions.Gateways {
 		cleanHost := common.CleanHost(gateway.Host)
-		out = append(out, config.Config{
+		output := outputOf(outputs, gateway.Host)
+		output.configs = append(output.configs, config.Config{
 			Meta: config.Meta{
 				GroupVersionKind: gvk.Gateway,
 				Name:             common.CreateConvertedName(constants.IstioIngressGatewayName, cleanHost),
@@ -292,10 +452,36 @@ func (m *IngressConfig) convertGateways(configs []common.WrapperConfig) []config
 			Spec: gateway.Gateway,
 		})
 	}
+	return outputs
+}
+
+func (m *IngressConfig) extractGateways(outputs map[string]*hostOutput) []config.Config {
+	var out []config.Config
+	domainCollection := model.IngressDomainCollection{}
+	var ingressErrors []common.IngressError
+	for _, host := range sortedHosts(outputs) {
+		output := outputs[host]
+		out = append(out, output.configs...)
+		domainCollection.Valid = append(domainCollection.Valid, output.domains.Valid...)
+		domainCollection.Invalid = append(domainCollection.Invalid, output.domains.Invalid...)
+		ingressErrors = append(ingressErrors, output.errors...)
+	}
+
+	m.mutex.Lock()
+	m.ingressDomainCache = domainCollection
+	m.ingressDomainErrors = ingressErrors
+	m.mutex.Unlock()
+
+	m.reportIngressErrors()
+
 	return out
 }
 
 func (m *IngressConfig) convertVirtualService(configs []common.WrapperConfig) []config.Config {
+	return m.extractVirtualServices(m.buildVirtualServices(configs))
+}
+
+func (m *IngressConfig) buildVirtualServices(configs []common.WrapperConfig) map[string]*hostOutput {
 	convertOptions := common.ConvertOptions{
 		HostAndPath2Ingress: map[string]*config.Config{},
 		IngressRouteCache:   common.NewIngressRouteCache(),
@@ -328,6 +514,7 @@ func (m *IngressConfig) convertVirtualService(configs []common.WrapperConfig) []
 	// Apply canary ingress
 	if len(configs) > len(convertOptions.CanaryIngresses) {
 		m.applyCanaryIngresses(&convertOptions)
+		applyStickyCanaries(&convertOptions)
 	}
 
 	// Normalize weighted cluster to make sure the sum of weight is 100.
@@ -365,16 +552,28 @@ func (m *IngressConfig) convertVirtualService(configs []common.WrapperConfig) []
 	// Apply internal active redirect for error page.
 	m.applyInternalActiveRedirect(&convertOptions)
 
-	m.mutex.Lock()
-	m.ingressRouteCache = convertOptions.IngressRouteCache.Extract()
-	m.mutex.Unlock()
+	outputs := map[string]*hostOutput{}
+	routeCollection := convertOptions.IngressRouteCache.Extract()
+	for _, route := range routeCollection.Valid {
+		output := outputOf(outputs, route.Host)
+		output.routes.Valid = append(output.routes.Valid, route)
+	}
+	for _, route := range routeCollection.Invalid {
+		output := outputOf(outputs, route.Host)
+		output.routes.Invalid = append(output.routes.Invalid, route)
+	}
+	for _, ingressError := range convertOptions.IngressRouteCache.Errors() {
+		output := outputOf(outputs, ingressError.Host)
+		output.errors = append(output.errors, ingressError)
+	}
 
 	// Convert http route to virtual service
-	out := make([]config.Config, 0, len(convertOptions.HTTPRoutes))
 	for host, routes := range convertOptions.HTTPRoutes {
 		if len(routes) == 0 {
 			continue
 		}
+		output := outputOf(outputs, host)
+		output.httpRoutes = routes
 
 		cleanHost := common.CleanHost(host)
 		// namespace/name, name format: (istio cluster id)-host
@@ -391,13 +590,16 @@ func (m *IngressConfig) convertVirtualService(configs []common.WrapperConfig) []
 		}
 		vs := wrapperVS.VirtualService
 		vs.Gateways = gateways
+		if bodySize := wrapperVS.WrapperConfig.AnnotationsConfig.BodySize; bodySize != nil {
+			output.bodySize = bodySize.Domain
+		}
 
 		for _, route := range routes {
 			vs.Http = append(vs.Http, route.HTTPRoute)
 		}
 
 		firstRoute := routes[0]
-		out = append(out, config.Config{
+		output.configs = append(output.configs, config.Config{
 			Meta: config.Meta{
 				GroupVersionKind: gvk.VirtualService,
 				Name:             common.CreateConvertedName(constants.IstioIngressGatewayName, firstRoute.WrapperConfig.Config.Namespace, firstRoute.WrapperConfig.Config.Name, cleanHost),
@@ -409,16 +611,67 @@ func (m *IngressConfig) convertVirtualService(configs []common.WrapperConfig) []
 			Spec: vs,
 		})
 	}
+	return outputs
+}
+
+func (m *IngressConfig) extractVirtualServices(outputs map[string]*hostOutput) []config.Config {
+	var out []config.Config
+	routeCollection := model.IngressRouteCollection{}
+	httpRoutes := map[string][]*common.WrapperHTTPRoute{}
+	hostBodySizes := map[string]*annotations.BodySize{}
+	var ingressErrors []common.IngressError
+	for _, host := range sortedHosts(outputs) {
+		output := outputs[host]
+		out = append(out, output.configs...)
+		routeCollection.Valid = append(routeCollection.Valid, output.routes.Valid...)
+		routeCollection.Invalid = append(routeCollection.Invalid, output.routes.Invalid...)
+		ingressErrors = append(ingressErrors, output.errors...)
+		if len(output.httpRoutes) > 0 {
+			httpRoutes[host] = output.httpRoutes
+		}
+		if output.bodySize != nil {
+			hostBodySizes[host] = output.bodySize
+		}
+	}
+
+	m.mutex.Lock()
+	m.ingressRouteCache = routeCollection
+	m.ingressRouteErrors = ingressErrors
+	m.mutex.Unlock()
+
+	m.reportIngressErrors()
 
 	// We generate some specific envoy filter here to avoid duplicated computation.
-	m.convertEnvoyFilter(&convertOptions)
+	m.convertEnvoyFilter(&common.ConvertOptions{
+		HTTPRoutes: httpRoutes,
+	}, hostBodySizes)
 
 	return out
 }
 
-func (m *IngressConfig) convertEnvoyFilter(convertOptions *common.ConvertOptions) {
+// reportIngressErrors dispatches the translation errors to the ingress controller of each cluster.
+func (m *IngressConfig) reportIngressErrors() {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	clusterErrors := map[string][]common.IngressError{}
+	for _, ingressError := range m.ingressDomainErrors {
+		clusterErrors[ingressError.ClusterId] = append(clusterErrors[ingressError.ClusterId], ingressError)
+	}
+	for _, ingressError := range m.ingressRouteErrors {
+		clusterErrors[ingressError.ClusterId] = append(clusterErrors[ingressError.ClusterId], ingressError)
+	}
+	for clusterId, ingressController := range m.remoteIngressControllers {
+		ingressController.SetIngressErrors(clusterErrors[clusterId])
+	}
+}
+
+// convertEnvoyFilter generates the envoy filters of routes, and the body size of host is applied on the routes
+// of host without their own.
+func (m *IngressConfig) convertEnvoyFilter(convertOptions *common.ConvertOptions, hostBodySizes map[string]*annotations.BodySize) {
 	var envoyFilters []config.Config
 	mappings := map[string]*common.Rule{}
+	digestMappings := map[string]*common.DigestRule{}
 
 	for _, routes := range convertOptions.HTTPRoutes {
 		for _, route := range routes {
@@ -432,6 +685,19 @@ func (m *IngressConfig) convertEnvoyFilter(convertOptions *common.ConvertOptions
 			}
 
 			key := auth.AuthSecret.String() + "/" + auth.AuthRealm
+			if auth.AuthType == annotations.DigestAuthType {
+				if rule, exist := digestMappings[key]; !exist {
+					digestMappings[key] = &common.DigestRule{
+						Realm:       auth.AuthRealm,
+						MatchRoute:  []string{route.HTTPRoute.Name},
+						Credentials: auth.Credentials,
+					}
+				} else {
+					rule.MatchRoute = append(rule.MatchRoute, route.HTTPRoute.Name)
+				}
+				continue
+			}
+
 			if rule, exist := mappings[key]; !exist {
 				mappings[key] = &common.Rule{
 					Realm:       auth.AuthRealm,
@@ -460,10 +726,174 @@ func (m *IngressConfig) convertEnvoyFilter(convertOptions *common.ConvertOptions
 		}
 	}
 
+	IngressLog.Infof("Found %d number of digest auth", len(digestMappings))
+	if len(digestMappings) > 0 {
+		// Without the nonce key, the filter rejects all requests of digest auth routes.
+		nonceKey, err := m.digestAuthNonceKey()
+		if err != nil {
+			IngressLog.Errorf("Get digest auth nonce key error %v, requests of digest auth routes are rejected", err)
+		}
+		rules := &common.DigestAuthRules{
+			NonceKey:    nonceKey,
+			NonceMaxAge: int64(digestAuthNonceMaxAge.Seconds()),
+		}
+		keys := make([]string, 0, len(digestMappings))
+		for key := range digestMappings {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			rules.Rules = append(rules.Rules, digestMappings[key])
+		}
+
+		digestAuth, err := constructDigestAuthEnvoyFilter(rules, m.namespace)
+		if err != nil {
+			IngressLog.Errorf("Construct digest auth filter error %v", err)
+		} else {
+			envoyFilters = append(envoyFilters, *digestAuth)
+		}
+	}
+
+	var extAuthRoutes, jwtRoutes, localRateLimitRoutes, globalRateLimitRoutes, proxyTimeoutRoutes []*common.WrapperHTTPRoute
+	var compressionRoutes []*common.WrapperHTTPRoute
+	oidcHosts := map[string]*annotations.OidcConfig{}
+	genericSecrets := map[string]genericSecret{}
+	routeBodySizes := map[string]*annotations.BodySize{}
+	hosts := make([]string, 0, len(convertOptions.HTTPRoutes))
+	for host := range convertOptions.HTTPRoutes {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		hostBodySize := hostBodySizes[host]
+		for _, route := range convertOptions.HTTPRoutes[host] {
+			if strings.HasSuffix(route.HTTPRoute.Name, "app-root") {
+				continue
+			}
+			if bodySize := route.WrapperConfig.AnnotationsConfig.BodySize.Limit(hostBodySize); bodySize != nil && bodySize.MaxRequestBytes > 0 {
+				routeBodySizes[route.HTTPRoute.Name] = bodySize
+			}
+			if extAuth := route.WrapperConfig.AnnotationsConfig.ExtAuth; extAuth != nil && !extAuth.Invalid {
+				extAuthRoutes = append(extAuthRoutes, route)
+			}
+			if route.WrapperConfig.AnnotationsConfig.Jwt != nil {
+				jwtRoutes = append(jwtRoutes, route)
+			}
+			if local := route.WrapperConfig.AnnotationsConfig.LocalRateLimit; local != nil && local.NeedEnvoyFilter() {
+				localRateLimitRoutes = append(localRateLimitRoutes, route)
+			}
+			if route.WrapperConfig.AnnotationsConfig.GlobalRateLimit != nil {
+				globalRateLimitRoutes = append(globalRateLimitRoutes, route)
+			}
+			if route.WrapperConfig.AnnotationsConfig.Timeout.IdleTimeout() > 0 {
+				proxyTimeoutRoutes = append(proxyTimeoutRoutes, route)
+			}
+			if route.WrapperConfig.AnnotationsConfig.Compression != nil {
+				compressionRoutes = append(compressionRoutes, route)
+			}
+			if oidc := route.WrapperConfig.AnnotationsConfig.Oidc; oidc != nil && !oidc.Invalid {
+				if _, exist := oidcHosts[host]; !exist {
+					oidcHosts[host] = oidc
+				} else if !reflect.DeepEqual(oidcHosts[host], oidc) {
+					IngressLog.Warnf("Oidc of host %s within ingress %s/%s conflicts with others, ignore it.",
+						host, route.WrapperConfig.Config.Namespace, route.WrapperConfig.Config.Name)
+				}
+			}
+		}
+	}
+
+	IngressLog.Infof("Found %d number of routes with ext auth", len(extAuthRoutes))
+	if len(extAuthRoutes) > 0 {
+		extAuth, err := constructExtAuthEnvoyFilter(extAuthRoutes, m.namespace)
+		if err != nil {
+			IngressLog.Errorf("Construct ext auth filter error %v", err)
+		} else {
+			envoyFilters = append(envoyFilters, *extAuth)
+		}
+	}
+
+	IngressLog.Infof("Found %d number of routes with jwt", len(jwtRoutes))
+	if len(jwtRoutes) > 0 {
+		jwtAuthn, err := constructJwtAuthnEnvoyFilter(jwtRoutes, m.namespace)
+		if err != nil {
+			IngressLog.Errorf("Construct jwt authn filter error %v", err)
+		} else {
+			envoyFilters = append(envoyFilters, *jwtAuthn)
+		}
+	}
+
+	IngressLog.Infof("Found %d number of hosts with oidc", len(oidcHosts))
+	if len(oidcHosts) > 0 {
+		// The oauth2 filters wait for the secrets served by controller, and never work without them.
+		hmacSecret := m.oidcHmacSecret()
+		oidc, err := constructOidcEnvoyFilter(oidcHosts, hmacSecret.Name(), m.sdsCluster, m.namespace)
+		if err != nil {
+			IngressLog.Errorf("Construct oidc filter error %v", err)
+		} else {
+			envoyFilters = append(envoyFilters, *oidc)
+			genericSecrets[hmacSecret.Name()] = hmacSecret
+			for _, oidcConfig := range oidcHosts {
+				clientSecret := oidcClientSecret(oidcConfig)
+				genericSecrets[clientSecret.Name()] = clientSecret
+			}
+		}
+	}
+
+	IngressLog.Infof("Found %d number of routes with local rate limit by key or body", len(localRateLimitRoutes))
+	if len(localRateLimitRoutes) > 0 {
+		localRateLimit, err := constructLocalRateLimitEnvoyFilter(localRateLimitRoutes, m.namespace)
+		if err != nil {
+			IngressLog.Errorf("Construct local rate limit filter error %v", err)
+		} else {
+			envoyFilters = append(envoyFilters, *localRateLimit)
+		}
+	}
+
+	IngressLog.Infof("Found %d number of routes with global rate limit", len(globalRateLimitRoutes))
+	if len(globalRateLimitRoutes) > 0 {
+		globalRateLimit, err := constructGlobalRateLimitEnvoyFilter(globalRateLimitRoutes, m.namespace)
+		if err != nil {
+			IngressLog.Errorf("Construct global rate limit filter error %v", err)
+		} else {
+			envoyFilters = append(envoyFilters, *globalRateLimit)
+		}
+	}
+
+	IngressLog.Infof("Found %d number of routes with proxy read or send timeout", len(proxyTimeoutRoutes))
+	if len(proxyTimeoutRoutes) > 0 {
+		proxyTimeout, err := constructProxyTimeoutEnvoyFilter(proxyTimeoutRoutes, m.namespace)
+		if err != nil {
+			IngressLog.Errorf("Construct proxy timeout filter error %v", err)
+		} else {
+			envoyFilters = append(envoyFilters, *proxyTimeout)
+		}
+	}
+
+	IngressLog.Infof("Found %d number of routes with body size", len(routeBodySizes))
+	if len(routeBodySizes) > 0 {
+		bodySize, err := constructBodySizeEnvoyFilter(routeBodySizes, m.namespace)
+		if err != nil {
+			IngressLog.Errorf("Construct body size filter error %v", err)
+		} else {
+			envoyFilters = append(envoyFilters, *bodySize)
+		}
+	}
+
+	IngressLog.Infof("Found %d number of routes with compression", len(compressionRoutes))
+	if len(compressionRoutes) > 0 {
+		compression, err := constructCompressionEnvoyFilter(compressionRoutes, m.namespace)
+		if err != nil {
+			IngressLog.Errorf("Construct compression filter error %v", err)
+		} else {
+			envoyFilters = append(envoyFilters, *compression)
+		}
+	}
+
 	// TODO Support other envoy filters
 
 	m.mutex.Lock()
 	m.cachedEnvoyFilters = envoyFilters
+	m.genericSecrets = genericSecrets
 	m.mutex.Unlock()
 }
 
@@ -487,10 +917,23 @@ func (m *IngressConfig) convertDestinationRule(configs []common.WrapperConfig) [
 		}
 	}
 
+	// Convert destination from mirror service within ingress, no matter whether ingress has traffic policy.
+	for idx := range configs {
+		cfg := configs[idx]
+		clusterId := common.GetClusterId(cfg.Config.Annotations)
+		m.mutex.RLock()
+		ingressController := m.remoteIngressControllers[clusterId]
+		m.mutex.RUnlock()
+		if ingressController == nil {
+			continue
+		}
+		common.AddMirrorTrafficPolicy(&convertOptions, &cfg)
+	}
+
 	IngressLog.Debugf("traffic policy number %d", len(convertOptions.Service2TrafficPolicy))
 
 	for _, wrapperTrafficPolicy := range convertOptions.Service2TrafficPolicy {
-		m.annotationHandler.ApplyTrafficPolicy(wrapperTrafficPolicy.TrafficPolicy, wrapperTrafficPolicy.WrapperConfig.AnnotationsConfig)
+		m.annotationHandler.ApplyTrafficPolicy(wrapperTrafficPolicy.TrafficPolicy, trafficPolicyAnnotations(wrapperTrafficPolicy))
 	}
 
 	// Merge multi-port traffic policy per service into one destination rule.
@@ -531,6 +974,49 @@ func (m *IngressConfig) convertDestinationRule(configs []common.WrapperConfig) [
 	return out
 }
 
+// trafficPolicyAnnotations returns the annotations applied on the traffic policy of service. The circuit
+// breakers of all ingresses sharing the service are merged, while the other policies come from the earliest one.
+func trafficPolicyAnnotations(wrapperTrafficPolicy *common.WrapperTrafficPolicy) *annotations.Ingress {
+	annotationsConfig := wrapperTrafficPolicy.WrapperConfig.AnnotationsConfig
+	if len(wrapperTrafficPolicy.SharedWrapperConfigs) == 0 {
+		return annotationsConfig
+	}
+
+	circuitBreakers := []*annotations.CircuitBreakerConfig{annotationsConfig.CircuitBreaker}
+	for _, shared := range wrapperTrafficPolicy.SharedWrapperConfigs {
+		circuitBreakers = append(circuitBreakers, shared.AnnotationsConfig.CircuitBreaker)
+	}
+
+	// Copy to avoid modifying the annotations of ingress.
+	merged := *annotationsConfig
+	merged.CircuitBreaker = annotations.MergeCircuitBreaker(circuitBreakers...)
+	return &merged
+}
+
+func (m *IngressConfig) convertServiceEntry() []config.Config {
+	if m.RegistryReconciler == nil {
+		return nil
+	}
+
+	serviceEntryWrappers := m.RegistryReconciler.GetAllServiceEntryWrapper()
+	out := make([]config.Config, 0, len(serviceEntryWrappers))
+	for _, sew := range serviceEntryWrappers {
+		out = append(out, config.Config{
+			Meta: config.Meta{
+				GroupVersionKind:  gvk.ServiceEntry,
+				Name:              common.CreateConvertedName(constants.IstioIngressGatewayName, common.CleanHost(sew.ServiceName)),
+				Namespace:         m.namespace,
+				CreationTimestamp: sew.GetCreateTime(),
+				Annotations: map[string]string{
+					common.RegistryTypeAnnotation: sew.RegistryType,
+				},
+			},
+			Spec: sew.ServiceEntry,
+		})
+	}
+	return out
+}
+
 func (m *IngressConfig) applyAppRoot(convertOptions *common.ConvertOptions) {
 	for host, wrapVS := range convertOptions.VirtualServices {
 		if wrapVS.AppRoot != "" {
@@ -627,6 +1113,7 @@ func (m *IngressConfig) ReflectSecretChanges(clusterNamespacedName util.ClusterN
 	m.mutex.RUnlock()
 
 	if hit {
+		m.translationCache.invalidateSecret(clusterNamespacedName.String())
 		push := func(kind config.GroupVersionKind) {
 			m.XDSUpdater.ConfigUpdate(&model.PushRequest{
 				Full: true,
@@ -643,6 +1130,26 @@ func (m *IngressConfig) ReflectSecretChanges(clusterNamespacedName util.ClusterN
 	}
 }
 
+// AddOrUpdateMcpBridge reconciles registries whenever any McpBridge within system namespace changes.
+func (m *IngressConfig) AddOrUpdateMcpBridge(clusterNamespacedName util.ClusterNamespacedName) {
+	IngressLog.Infof("mcpbridge %s changed, reconcile registries", clusterNamespacedName.NamespacedName)
+	m.RegistryReconciler.Reconcile(m.mcpbridgeController.List())
+}
+
+func (m *IngressConfig) notifyServiceEntryChanges() {
+	metadata := config.Meta{
+		Name:             "mcpbridge-serviceentry",
+		Namespace:        m.namespace,
+		GroupVersionKind: gvk.ServiceEntry,
+		// Set this label so that we do not compare configs and just push.
+		Labels: map[string]string{constants.AlwaysPushLabel: "true"},
+	}
+
+	for _, f := range m.serviceEntryHandlers {
+		f(config.Config{Meta: metadata}, config.Config{Meta: metadata}, model.EventUpdate)
+	}
+}
+
 func normalizeWeightedCluster(cache *common.IngressRouteCache, route *common.WrapperHTTPRoute) {
 	if len(route.HTTPRoute.Route) == 1 {
 		route.HTTPRoute.Route[0].Weight = 100
@@ -668,8 +1175,9 @@ func normalizeWeightedCluster(cache *common.IngressRouteCache, route *common.Wra
 			continue
 		}
 
-		weight := float32(routeDestination.Weight) / float32(weightTotal)
-		routeDestination.Weight = int32(weight * 100)
+		if weightTotal > 0 {
+			routeDestination.Weight = routeDestination.Weight * 100 / weightTotal
+		}
 
 		sum += routeDestination.Weight
 	}
@@ -688,6 +1196,8 @@ func (m *IngressConfig) applyCanaryIngresses(convertOptions *common.ConvertOptio
 	}
 
 	IngressLog.Infof("Found %d number of canary ingresses.", len(convertOptions.CanaryIngresses))
+	// The canaries of higher priority are inserted first, and then take precedence over others of the same path.
+	common.SortCanaryIngresses(convertOptions.CanaryIngresses)
 	for _, cfg := range convertOptions.CanaryIngresses {
 		clusterId := common.GetClusterId(cfg.Config.Annotations)
 		m.mutex.RLock()
@@ -702,6 +1212,36 @@ func (m *IngressConfig) applyCanaryIngresses(convertOptions *common.ConvertOptio
 	}
 }
 
+// applyStickyCanaries inserts the sticky routes before the weighted canary routes of which
+// the affinity canary behavior is sticky.
+func applyStickyCanaries(convertOptions *common.ConvertOptions) {
+	for host, routes := range convertOptions.HTTPRoutes {
+		var result []*common.WrapperHTTPRoute
+		for _, route := range routes {
+			// Only the route merged with weight canaries
+			if route.WeightTotal > 0 {
+				stickyRoutes := annotations.ApplyStickyCanary(route.HTTPRoute, route.WrapperConfig.AnnotationsConfig)
+				for _, stickyRoute := range stickyRoutes {
+					wrapperStickyRoute := &common.WrapperHTTPRoute{
+						HTTPRoute:      stickyRoute,
+						WrapperConfig:  route.WrapperConfig,
+						RawClusterId:   route.RawClusterId,
+						ClusterId:      route.ClusterId,
+						ClusterName:    route.ClusterName,
+						Host:           route.Host,
+						OriginPath:     route.OriginPath,
+						OriginPathType: route.OriginPathType,
+					}
+					result = append(result, wrapperStickyRoute)
+					convertOptions.IngressRouteCache.NewAndAdd(wrapperStickyRoute)
+				}
+			}
+			result = append(result, route)
+		}
+		convertOptions.HTTPRoutes[host] = result
+	}
+}
+
 func constructBasicAuthEnvoyFilter(rules *common.BasicAuthRules, namespace string) (*config.Config, error) {
 	rulesStr, err := json.Marshal(rules)
 	if err != nil {
@@ -785,6 +1325,38 @@ func constructBasicAuthEnvoyFilter(rules *common.BasicAuthRules, namespace strin
 	}, nil
 }
 
+func (m *IngressConfig) digestAuthNonceSecret() util.ClusterNamespacedName {
+	return util.ClusterNamespacedName{
+		NamespacedName: model.NamespacedName{
+			Namespace: m.namespace,
+			Name:      digestAuthNonceSecretName,
+		},
+		ClusterId: m.localClusterId,
+	}
+}
+
+// digestAuthNonceKey returns the key signing the nonces of digest auth, which is read from the secret
+// within the namespace of controller.
+func (m *IngressConfig) digestAuthNonceKey() (string, error) {
+	secret := m.digestAuthNonceSecret()
+	m.mutex.RLock()
+	ingressController := m.remoteIngressControllers[secret.ClusterId]
+	m.mutex.RUnlock()
+	if ingressController == nil {
+		return "", errors.New("local cluster is not initialized")
+	}
+
+	nonceSecret, err := ingressController.SecretLister().Secrets(secret.Namespace).Get(secret.Name)
+	if err != nil {
+		return "", err
+	}
+	key := nonceSecret.Data[digestAuthNonceSecretKey]
+	if len(key) < 16 {
+		return "", fmt.Errorf("the %s of secret %s must have at least 16 bytes", digestAuthNonceSecretKey, secret.NamespacedName)
+	}
+	return string(key), nil
+}
+
 func (m *IngressConfig) Run(<-chan struct{}) {}
 
 func (m *IngressConfig) HasSynced() bool {
@@ -796,6 +1368,14 @@ func (m *IngressConfig) HasSynced() bool {
 		}
 	}
 
+	if m.mcpbridgeController != nil && !m.mcpbridgeController.HasSynced() {
+		return false
+	}
+
+	if m.gatewayController != nil && !m.gatewayController.HasSynced() {
+		return false
+	}
+
 	IngressLog.Info("Ingress config controller synced.")
 	return true
 }
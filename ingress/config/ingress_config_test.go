@@ -15,13 +15,18 @@
 package config
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	httppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/protobuf/proto"
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/config/xds"
 	"istio.io/istio/pkg/kube"
@@ -108,6 +113,69 @@ func TestNormalizeWeightedCluster(t *testing.T) {
 	}
 }
 
+func TestNormalizeWeightedClusterWithMultipleCanaries(t *testing.T) {
+	route := &common.WrapperHTTPRoute{
+		HTTPRoute: &networking.HTTPRoute{
+			Route: []*networking.HTTPRouteDestination{
+				{
+					Weight: 0,
+				},
+				{
+					Weight: 10,
+				},
+				{
+					Weight: 5,
+				},
+			},
+		},
+		WeightTotal: 100,
+	}
+
+	normalizeWeightedCluster(nil, route)
+	var actual []int32
+	for _, routeDestination := range route.HTTPRoute.Route {
+		actual = append(actual, routeDestination.Weight)
+	}
+	assert.Equal(t, []int32{85, 10, 5}, actual)
+}
+
+func TestTrafficPolicyAnnotations(t *testing.T) {
+	first := &common.WrapperConfig{
+		AnnotationsConfig: &annotations.Ingress{
+			LoadBalance: &annotations.LoadBalanceConfig{},
+			CircuitBreaker: &annotations.CircuitBreakerConfig{
+				MaxConnections: 100,
+			},
+		},
+	}
+	second := &common.WrapperConfig{
+		AnnotationsConfig: &annotations.Ingress{
+			CircuitBreaker: &annotations.CircuitBreakerConfig{
+				MaxConnections:     200,
+				MaxPendingRequests: 10,
+			},
+		},
+	}
+
+	wrapperTrafficPolicy := &common.WrapperTrafficPolicy{
+		WrapperConfig: first,
+	}
+	assert.Equal(t, first.AnnotationsConfig, trafficPolicyAnnotations(wrapperTrafficPolicy))
+
+	wrapperTrafficPolicy.AddSharedWrapperConfig(first)
+	wrapperTrafficPolicy.AddSharedWrapperConfig(second)
+	wrapperTrafficPolicy.AddSharedWrapperConfig(second)
+	assert.Equal(t, []*common.WrapperConfig{second}, wrapperTrafficPolicy.SharedWrapperConfigs)
+
+	merged := trafficPolicyAnnotations(wrapperTrafficPolicy)
+	assert.Equal(t, first.AnnotationsConfig.LoadBalance, merged.LoadBalance)
+	assert.Equal(t, &annotations.CircuitBreakerConfig{
+		MaxConnections:     100,
+		MaxPendingRequests: 10,
+	}, merged.CircuitBreaker)
+	assert.Equal(t, int32(0), first.AnnotationsConfig.CircuitBreaker.MaxPendingRequests)
+}
+
 func TestConvertGatewaysForIngress(t *testing.T) {
 	fake := kube.NewFakeClient()
 	v1Beta1Options := common.Options{
@@ -611,3 +679,186 @@ func TestConstructBasicAuthEnvoyFilter(t *testing.T) {
 	target := proto.Clone(pb).(*httppb.HttpFilter)
 	t.Log(target)
 }
+
+func buildIngressesForBenchmark(num int) []config.Config {
+	pathType := ingress.PathTypePrefix
+	creationTime := time.Now()
+	configs := make([]config.Config, 0, num)
+	for i := 0; i < num; i++ {
+		configs = append(configs, config.Config{
+			Meta: config.Meta{
+				Name:              fmt.Sprintf("ingress-%d", i),
+				Namespace:         "wakanda",
+				ResourceVersion:   "1",
+				CreationTimestamp: creationTime.Add(time.Duration(i) * time.Second),
+				Annotations: map[string]string{
+					common.ClusterIdAnnotation: "ingress-v1",
+				},
+			},
+			Spec: ingress.IngressSpec{
+				Rules: []ingress.IngressRule{
+					{
+						Host: fmt.Sprintf("foo-%d.com", i),
+						IngressRuleValue: ingress.IngressRuleValue{
+							HTTP: &ingress.HTTPIngressRuleValue{
+								Paths: []ingress.HTTPIngressPath{
+									{
+										Path:     "/",
+										PathType: &pathType,
+										Backend: ingress.IngressBackend{
+											Service: &ingress.IngressServiceBackend{
+												Name: "foo",
+												Port: ingress.ServiceBackendPort{
+													Number: 80,
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+	return configs
+}
+
+func newIngressConfigForBenchmark() *IngressConfig {
+	fake := kube.NewFakeClient()
+	options := common.Options{
+		Enable:       true,
+		ClusterId:    "ingress-v1",
+		RawClusterId: "ingress-v1__",
+	}
+	m := NewIngressConfig(fake, nil, "wakanda", "gw-123-istio")
+	m.remoteIngressControllers = map[string]common.IngressController{
+		"ingress-v1": controllerv1.NewController(fake, fake, options, nil),
+	}
+	return m
+}
+
+func translateIngresses(m *IngressConfig, configs []config.Config) {
+	for _, typ := range []config.GroupVersionKind{gvk.Gateway, gvk.VirtualService, gvk.DestinationRule} {
+		m.convertIngresses(typ, configs)
+	}
+}
+
+func BenchmarkTranslateIngresses(b *testing.B) {
+	const num = 5000
+
+	b.Run("full", func(b *testing.B) {
+		m := newIngressConfigForBenchmark()
+		configs := buildIngressesForBenchmark(num)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// Drop the cache to translate all ingresses, like what happens without the cache.
+			m.translationCache = newTranslationCache()
+			translateIngresses(m, configs)
+		}
+	})
+
+	b.Run("incremental", func(b *testing.B) {
+		m := newIngressConfigForBenchmark()
+		configs := buildIngressesForBenchmark(num)
+		translateIngresses(m, configs)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// Only one ingress changes between pushes.
+			configs[i%num].ResourceVersion = strconv.Itoa(i + 2)
+			translateIngresses(m, configs)
+		}
+	})
+}
+
+func TestHostBodySizeOfEnvoyFilter(t *testing.T) {
+	fake := kube.NewFakeClient()
+	options := common.Options{
+		Enable:       true,
+		ClusterId:    "ingress-v1",
+		RawClusterId: "ingress-v1__",
+	}
+	m := NewIngressConfig(fake, nil, "wakanda", "gw-123-istio")
+	m.remoteIngressControllers = map[string]common.IngressController{
+		"ingress-v1": controllerv1.NewController(fake, fake, options, nil),
+	}
+
+	pathType := ingress.PathTypePrefix
+	newWrapper := func(name, path string, bodySize *annotations.BodySizeConfig) common.WrapperConfig {
+		return common.WrapperConfig{
+			Config: &config.Config{
+				Meta: config.Meta{
+					Name:      name,
+					Namespace: "wakanda",
+					Annotations: map[string]string{
+						common.ClusterIdAnnotation: "ingress-v1",
+					},
+				},
+				Spec: ingress.IngressSpec{
+					Rules: []ingress.IngressRule{
+						{
+							Host: "test.com",
+							IngressRuleValue: ingress.IngressRuleValue{
+								HTTP: &ingress.HTTPIngressRuleValue{
+									Paths: []ingress.HTTPIngressPath{
+										{
+											Path:     path,
+											PathType: &pathType,
+											Backend: ingress.IngressBackend{
+												Service: &ingress.IngressServiceBackend{
+													Name: name,
+													Port: ingress.ServiceBackendPort{
+														Number: 80,
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			AnnotationsConfig: &annotations.Ingress{
+				BodySize: bodySize,
+			},
+		}
+	}
+
+	// The domain body size is set by the second ingress of host, and applied on the routes of both.
+	configs := []common.WrapperConfig{
+		newWrapper("a", "/a", nil),
+		newWrapper("b", "/b", &annotations.BodySizeConfig{
+			Domain: &annotations.BodySize{MaxRequestBytes: 1024},
+		}),
+		newWrapper("c", "/c", &annotations.BodySizeConfig{
+			Route: &annotations.BodySize{},
+		}),
+	}
+	m.extractVirtualServices(m.buildVirtualServices(configs))
+
+	var bodySize *config.Config
+	for idx := range m.cachedEnvoyFilters {
+		if m.cachedEnvoyFilters[idx].Name == common.CreateConvertedName(constants.IstioIngressGatewayName, "body-size") {
+			bodySize = &m.cachedEnvoyFilters[idx]
+		}
+	}
+	if bodySize == nil {
+		t.Fatal("body size envoy filter should be generated")
+	}
+	// The buffer and lua filters, their disabled config on all routes, and the config of routes a and b,
+	// while route c is unlimited by its own body size.
+	patches := bodySize.Spec.(*networking.EnvoyFilter).ConfigPatches
+	assert.Equal(t, 5, len(patches))
+	var routeNames []string
+	for _, patch := range patches[3:] {
+		routeNames = append(routeNames, patch.Match.GetRouteConfiguration().GetVhost().GetRoute().GetName())
+	}
+	for idx, prefix := range []string{"wakanda-a-", "wakanda-b-"} {
+		if !strings.HasPrefix(routeNames[idx], prefix) {
+			t.Fatalf("Unexpected route %s, expect prefix %s", routeNames[idx], prefix)
+		}
+	}
+}
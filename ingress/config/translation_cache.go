@@ -0,0 +1,355 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config"
+	ingress "k8s.io/api/networking/v1"
+	ingressv1beta1 "k8s.io/api/networking/v1beta1"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+
+	"github.com/alibaba/higress/ingress/kube/annotations"
+	"github.com/alibaba/higress/ingress/kube/common"
+	"github.com/alibaba/higress/ingress/kube/util"
+)
+
+// ingressEntry is the parsed annotations of an ingress, which is reused until the resource version
+// of the ingress or any referred service changes.
+type ingressEntry struct {
+	resourceVersion   string
+	annotationsConfig *annotations.Ingress
+	// secret key is cluster/namespace/name
+	watchedSecrets sets.Set
+	// service key is namespace/name, including backends and services referred by annotations
+	watchedServices sets.Set
+	servicesVersion string
+}
+
+// version identifies the translation of the ingress, both of the ingress and the referred services are considered.
+func (e *ingressEntry) version() string {
+	return e.resourceVersion + "/" + e.servicesVersion
+}
+
+// hostOutput is the converted result of a host.
+type hostOutput struct {
+	configs []config.Config
+	domains model.IngressDomainCollection
+	routes  model.IngressRouteCollection
+	errors  []common.IngressError
+	// Used to generate envoy filters which aggregate routes of all hosts.
+	httpRoutes []*common.WrapperHTTPRoute
+	// The body size of host, which is applied on the routes without their own.
+	bodySize *annotations.BodySize
+}
+
+// hostCache keeps the converted result per host for one kind of output, so that only the hosts
+// touched by the changed ingresses are converted again.
+type hostCache struct {
+	mutex sync.Mutex
+	// key: cluster id/namespace/name, value: version converted last time
+	versions map[string]string
+	// key: cluster id/namespace/name, value: hosts touched last time
+	hosts map[string][]string
+	// key: host
+	outputs map[string]*hostOutput
+}
+
+// convert returns the outputs of all hosts. The configs should be sorted by creation time, and
+// only the ones touching the changed hosts are passed to build. An ingress whose version is empty
+// is always considered to be changed.
+func (h *hostCache) convert(configs []common.WrapperConfig, versionOf func(key string) string,
+	build func([]common.WrapperConfig) map[string]*hostOutput) map[string]*hostOutput {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	full := h.outputs == nil
+	dirtyHosts := sets.NewSet()
+	versions := make(map[string]string, len(configs))
+	hosts := make(map[string][]string, len(configs))
+	for idx := range configs {
+		cfg := configs[idx].Config
+		key := ingressKey(cfg)
+		version := versionOf(key)
+		versions[key] = version
+		hosts[key] = ingressHosts(cfg)
+
+		preVersion, exist := h.versions[key]
+		if exist && preVersion == version && version != "" {
+			continue
+		}
+		dirtyHosts.Insert(h.hosts[key]...)
+		dirtyHosts.Insert(hosts[key]...)
+	}
+	for key, preHosts := range h.hosts {
+		if _, exist := versions[key]; !exist {
+			dirtyHosts.Insert(preHosts...)
+		}
+	}
+	h.versions = versions
+	h.hosts = hosts
+
+	if full {
+		h.outputs = build(configs)
+		return h.snapshot()
+	}
+	if len(dirtyHosts) == 0 {
+		return h.snapshot()
+	}
+
+	var touched []common.WrapperConfig
+	for idx := range configs {
+		for _, host := range hosts[ingressKey(configs[idx].Config)] {
+			if dirtyHosts.Contains(host) {
+				touched = append(touched, configs[idx])
+				break
+			}
+		}
+	}
+
+	outputs := build(touched)
+	for host := range dirtyHosts {
+		if output, exist := outputs[host]; exist {
+			h.outputs[host] = output
+		} else {
+			delete(h.outputs, host)
+		}
+	}
+	return h.snapshot()
+}
+
+// invalidate makes the ingress be converted again next time.
+func (h *hostCache) invalidate(key string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.versions, key)
+}
+
+func (h *hostCache) snapshot() map[string]*hostOutput {
+	out := make(map[string]*hostOutput, len(h.outputs))
+	for host, output := range h.outputs {
+		out[host] = output
+	}
+	return out
+}
+
+// translationCache caches the translation of ingresses keyed by cluster id/namespace/name and resource version.
+type translationCache struct {
+	mutex sync.RWMutex
+	// key: cluster id/namespace/name
+	ingresses map[string]*ingressEntry
+
+	gateways        hostCache
+	virtualServices hostCache
+}
+
+func newTranslationCache() *translationCache {
+	return &translationCache{
+		ingresses: map[string]*ingressEntry{},
+	}
+}
+
+// getIngress returns the cached entry if neither the resource version of the ingress nor the
+// referred services are changed.
+func (t *translationCache) getIngress(key, resourceVersion string, serviceLister listersv1.ServiceLister) *ingressEntry {
+	if resourceVersion == "" {
+		return nil
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	entry, exist := t.ingresses[key]
+	if !exist || entry.resourceVersion != resourceVersion ||
+		entry.servicesVersion != servicesVersion(serviceLister, entry.watchedServices) {
+		return nil
+	}
+	return entry
+}
+
+// version returns the version of the cached ingress, or empty if it is not cached.
+func (t *translationCache) version(key string) string {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	entry, exist := t.ingresses[key]
+	if !exist {
+		return ""
+	}
+	return entry.version()
+}
+
+func (t *translationCache) setIngress(key string, entry *ingressEntry) {
+	if entry.resourceVersion == "" {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.ingresses[key] = entry
+}
+
+// retainIngresses removes the entries of deleted ingresses.
+func (t *translationCache) retainIngresses(keys sets.Set) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for key := range t.ingresses {
+		if !keys.Contains(key) {
+			delete(t.ingresses, key)
+		}
+	}
+}
+
+// invalidateSecret makes the ingresses referring to the secret be translated again next time.
+func (t *translationCache) invalidateSecret(secret string) {
+	t.mutex.Lock()
+	var keys []string
+	for key, entry := range t.ingresses {
+		if entry.watchedSecrets.Contains(secret) {
+			keys = append(keys, key)
+			delete(t.ingresses, key)
+		}
+	}
+	t.mutex.Unlock()
+
+	for _, key := range keys {
+		t.gateways.invalidate(key)
+		t.virtualServices.invalidate(key)
+	}
+}
+
+func ingressKey(cfg *config.Config) string {
+	return common.GetClusterId(cfg.Annotations) + "/" + cfg.Namespace + "/" + cfg.Name
+}
+
+// ingressHosts returns the hosts whose outputs may be affected by the ingress.
+func ingressHosts(cfg *config.Config) []string {
+	var hosts []string
+	switch spec := cfg.Spec.(type) {
+	case ingress.IngressSpec:
+		for _, rule := range spec.Rules {
+			hosts = append(hosts, rule.Host)
+		}
+		for _, tls := range spec.TLS {
+			hosts = append(hosts, tls.Hosts...)
+		}
+		// Spec default backend applies to the default host as well.
+		if spec.DefaultBackend != nil {
+			hosts = append(hosts, common.DefaultHost)
+		}
+	case ingressv1beta1.IngressSpec:
+		for _, rule := range spec.Rules {
+			hosts = append(hosts, rule.Host)
+		}
+		for _, tls := range spec.TLS {
+			hosts = append(hosts, tls.Hosts...)
+		}
+		if spec.Backend != nil {
+			hosts = append(hosts, common.DefaultHost)
+		}
+	}
+	return hosts
+}
+
+// ingressServices returns the keys of the backend services of the ingress, which is namespace/name.
+func ingressServices(cfg *config.Config) []string {
+	var services []string
+	switch spec := cfg.Spec.(type) {
+	case ingress.IngressSpec:
+		backends := []*ingress.IngressBackend{spec.DefaultBackend}
+		for _, rule := range spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for idx := range rule.HTTP.Paths {
+				backends = append(backends, &rule.HTTP.Paths[idx].Backend)
+			}
+		}
+		for _, backend := range backends {
+			if backend != nil && backend.Service != nil {
+				services = append(services, cfg.Namespace+"/"+backend.Service.Name)
+			}
+		}
+	case ingressv1beta1.IngressSpec:
+		backends := []*ingressv1beta1.IngressBackend{spec.Backend}
+		for _, rule := range spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for idx := range rule.HTTP.Paths {
+				backends = append(backends, &rule.HTTP.Paths[idx].Backend)
+			}
+		}
+		for _, backend := range backends {
+			if backend != nil && backend.ServiceName != "" {
+				services = append(services, cfg.Namespace+"/"+backend.ServiceName)
+			}
+		}
+	}
+	return services
+}
+
+// servicesVersion joins the resource versions of the services, the version of a missing service is empty.
+// So that creating, updating or deleting any of them changes the result.
+func servicesVersion(serviceLister listersv1.ServiceLister, services sets.Set) string {
+	if len(services) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(services))
+	for key := range services {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	versions := make([]string, 0, len(keys))
+	for _, key := range keys {
+		var version string
+		if serviceLister != nil {
+			namespacedName := util.SplitNamespacedName(key)
+			service, err := serviceLister.Services(namespacedName.Namespace).Get(namespacedName.Name)
+			if err == nil {
+				version = service.ResourceVersion
+			}
+		}
+		versions = append(versions, key+"="+version)
+	}
+	return strings.Join(versions, ",")
+}
+
+func outputOf(outputs map[string]*hostOutput, host string) *hostOutput {
+	output, exist := outputs[host]
+	if !exist {
+		output = &hostOutput{}
+		outputs[host] = output
+	}
+	return output
+}
+
+func sortedHosts(outputs map[string]*hostOutput) []string {
+	hosts := make([]string, 0, len(outputs))
+	for host := range outputs {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
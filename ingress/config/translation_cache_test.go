@@ -0,0 +1,323 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	ingress "k8s.io/api/networking/v1"
+	ingressv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/alibaba/higress/ingress/kube/annotations"
+	"github.com/alibaba/higress/ingress/kube/common"
+)
+
+func buildWrapperConfig(name, resourceVersion string, hosts ...string) common.WrapperConfig {
+	var rules []ingress.IngressRule
+	for _, host := range hosts {
+		rules = append(rules, ingress.IngressRule{Host: host})
+	}
+	return common.WrapperConfig{
+		Config: &config.Config{
+			Meta: config.Meta{
+				Name:            name,
+				Namespace:       "wakanda",
+				ResourceVersion: resourceVersion,
+			},
+			Spec: ingress.IngressSpec{
+				Rules: rules,
+			},
+		},
+		AnnotationsConfig: &annotations.Ingress{},
+	}
+}
+
+func TestHostCacheConvert(t *testing.T) {
+	var built []string
+	build := func(configs []common.WrapperConfig) map[string]*hostOutput {
+		built = nil
+		outputs := map[string]*hostOutput{}
+		for _, cfg := range configs {
+			built = append(built, cfg.Config.Name)
+			for _, host := range ingressHosts(cfg.Config) {
+				output := outputOf(outputs, host)
+				output.configs = append(output.configs, *cfg.Config)
+			}
+		}
+		return outputs
+	}
+
+	testCases := []struct {
+		name        string
+		input       []common.WrapperConfig
+		expectBuilt []string
+		expectHosts []string
+	}{
+		{
+			name: "first time",
+			input: []common.WrapperConfig{
+				buildWrapperConfig("foo", "1", "a.com"),
+				buildWrapperConfig("bar", "1", "a.com", "b.com"),
+				buildWrapperConfig("baz", "1", "c.com"),
+			},
+			expectBuilt: []string{"foo", "bar", "baz"},
+			expectHosts: []string{"a.com", "b.com", "c.com"},
+		},
+		{
+			name: "nothing changed",
+			input: []common.WrapperConfig{
+				buildWrapperConfig("foo", "1", "a.com"),
+				buildWrapperConfig("bar", "1", "a.com", "b.com"),
+				buildWrapperConfig("baz", "1", "c.com"),
+			},
+			expectHosts: []string{"a.com", "b.com", "c.com"},
+		},
+		{
+			name: "only convert ingresses touching the changed hosts",
+			input: []common.WrapperConfig{
+				buildWrapperConfig("foo", "2", "a.com"),
+				buildWrapperConfig("bar", "1", "a.com", "b.com"),
+				buildWrapperConfig("baz", "1", "c.com"),
+			},
+			expectBuilt: []string{"foo", "bar"},
+			expectHosts: []string{"a.com", "b.com", "c.com"},
+		},
+		{
+			name: "hosts removed from ingress",
+			input: []common.WrapperConfig{
+				buildWrapperConfig("foo", "2", "a.com"),
+				buildWrapperConfig("bar", "2", "a.com"),
+				buildWrapperConfig("baz", "1", "c.com"),
+			},
+			expectBuilt: []string{"foo", "bar"},
+			expectHosts: []string{"a.com", "c.com"},
+		},
+		{
+			name: "ingress deleted",
+			input: []common.WrapperConfig{
+				buildWrapperConfig("foo", "2", "a.com"),
+				buildWrapperConfig("bar", "2", "a.com"),
+			},
+			expectHosts: []string{"a.com"},
+		},
+		{
+			name: "ingress without resource version",
+			input: []common.WrapperConfig{
+				buildWrapperConfig("foo", "2", "a.com"),
+				buildWrapperConfig("bar", "2", "a.com"),
+				buildWrapperConfig("baz", "", "c.com"),
+			},
+			expectBuilt: []string{"baz"},
+			expectHosts: []string{"a.com", "c.com"},
+		},
+	}
+
+	cache := &hostCache{}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			built = nil
+			versions := map[string]string{}
+			for _, cfg := range testCase.input {
+				versions[ingressKey(cfg.Config)] = cfg.Config.ResourceVersion
+			}
+			versionOf := func(key string) string {
+				return versions[key]
+			}
+			outputs := cache.convert(testCase.input, versionOf, build)
+			if !reflect.DeepEqual(testCase.expectBuilt, built) {
+				t.Fatalf("Should be equal, expect built %v, actual %v", testCase.expectBuilt, built)
+			}
+			if !reflect.DeepEqual(testCase.expectHosts, sortedHosts(outputs)) {
+				t.Fatalf("Should be equal, expect hosts %v, actual %v", testCase.expectHosts, sortedHosts(outputs))
+			}
+		})
+	}
+}
+
+func TestTranslationCacheInvalidateSecret(t *testing.T) {
+	cache := newTranslationCache()
+	cache.setIngress("ingress-v1/wakanda/foo", &ingressEntry{
+		resourceVersion:   "1",
+		annotationsConfig: &annotations.Ingress{},
+		watchedSecrets:    sets.NewSet("ingress-v1/wakanda/auth"),
+	})
+	cache.setIngress("ingress-v1/wakanda/bar", &ingressEntry{
+		resourceVersion:   "1",
+		annotationsConfig: &annotations.Ingress{},
+		watchedSecrets:    sets.NewSet(),
+	})
+	cache.setIngress("ingress-v1/wakanda/baz", &ingressEntry{
+		annotationsConfig: &annotations.Ingress{},
+		watchedSecrets:    sets.NewSet(),
+	})
+
+	if cache.getIngress("ingress-v1/wakanda/foo", "2", nil) != nil {
+		t.Fatalf("Should parse again if resource version changes")
+	}
+	if cache.getIngress("ingress-v1/wakanda/baz", "", nil) != nil {
+		t.Fatalf("Should not cache ingress without resource version")
+	}
+
+	cache.invalidateSecret("ingress-v1/wakanda/auth")
+	if cache.getIngress("ingress-v1/wakanda/foo", "1", nil) != nil {
+		t.Fatalf("Should parse again if the watched secret changes")
+	}
+	if cache.getIngress("ingress-v1/wakanda/bar", "1", nil) == nil {
+		t.Fatalf("Should keep ingress not referring to the secret")
+	}
+
+	cache.retainIngresses(sets.NewSet())
+	if cache.getIngress("ingress-v1/wakanda/bar", "1", nil) != nil {
+		t.Fatalf("Should remove deleted ingress")
+	}
+}
+
+func TestTranslationCacheServiceChanges(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	serviceLister := listersv1.NewServiceLister(indexer)
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "foo",
+			Namespace:       "wakanda",
+			ResourceVersion: "1",
+		},
+	}
+	_ = indexer.Add(service)
+
+	translationCache := newTranslationCache()
+	services := sets.NewSet("wakanda/foo", "wakanda/mirror")
+	translationCache.setIngress("ingress-v1/wakanda/foo", &ingressEntry{
+		resourceVersion:   "1",
+		annotationsConfig: &annotations.Ingress{},
+		watchedSecrets:    sets.NewSet(),
+		watchedServices:   services,
+		servicesVersion:   servicesVersion(serviceLister, services),
+	})
+	version := translationCache.version("ingress-v1/wakanda/foo")
+	if translationCache.getIngress("ingress-v1/wakanda/foo", "1", serviceLister) == nil {
+		t.Fatalf("Should reuse the entry if nothing changes")
+	}
+
+	updated := service.DeepCopy()
+	updated.ResourceVersion = "2"
+	_ = indexer.Update(updated)
+	if translationCache.getIngress("ingress-v1/wakanda/foo", "1", serviceLister) != nil {
+		t.Fatalf("Should parse again if the backend service changes")
+	}
+
+	_ = indexer.Add(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "mirror",
+			Namespace:       "wakanda",
+			ResourceVersion: "3",
+		},
+	})
+	newVersion := servicesVersion(serviceLister, services)
+	if newVersion == servicesVersion(nil, services) || newVersion == version {
+		t.Fatalf("Should change the version once the missing service is created")
+	}
+}
+
+func TestIngressServices(t *testing.T) {
+	cfg := &config.Config{
+		Meta: config.Meta{Namespace: "wakanda"},
+		Spec: ingress.IngressSpec{
+			DefaultBackend: &ingress.IngressBackend{
+				Service: &ingress.IngressServiceBackend{Name: "default"},
+			},
+			Rules: []ingress.IngressRule{
+				{
+					Host: "foo.com",
+					IngressRuleValue: ingress.IngressRuleValue{
+						HTTP: &ingress.HTTPIngressRuleValue{
+							Paths: []ingress.HTTPIngressPath{
+								{Backend: ingress.IngressBackend{Service: &ingress.IngressServiceBackend{Name: "foo"}}},
+								{Backend: ingress.IngressBackend{Resource: &v1.TypedLocalObjectReference{Name: "bucket"}}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	expect := []string{"wakanda/default", "wakanda/foo"}
+	if services := ingressServices(cfg); !reflect.DeepEqual(expect, services) {
+		t.Fatalf("Should be equal, expect %v, actual %v", expect, services)
+	}
+}
+
+func TestIngressHosts(t *testing.T) {
+	testCases := []struct {
+		input  *config.Config
+		expect []string
+	}{
+		{
+			input: &config.Config{
+				Spec: ingress.IngressSpec{
+					DefaultBackend: &ingress.IngressBackend{},
+					Rules: []ingress.IngressRule{
+						{Host: "foo.com"},
+						{Host: "bar.com"},
+					},
+				},
+			},
+			expect: []string{"*", "bar.com", "foo.com"},
+		},
+		{
+			input: &config.Config{
+				Spec: ingressv1beta1.IngressSpec{
+					Rules: []ingressv1beta1.IngressRule{
+						{Host: "foo.com"},
+					},
+				},
+			},
+			expect: []string{"foo.com"},
+		},
+		{
+			input: &config.Config{
+				Spec: ingress.IngressSpec{
+					TLS: []ingress.IngressTLS{
+						{Hosts: []string{"foo.com", "tls.com"}},
+					},
+					Rules: []ingress.IngressRule{
+						{Host: "foo.com"},
+					},
+				},
+			},
+			expect: []string{"foo.com", "foo.com", "tls.com"},
+		},
+		{
+			input: &config.Config{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run("", func(t *testing.T) {
+			hosts := ingressHosts(testCase.input)
+			sort.Strings(hosts)
+			if !reflect.DeepEqual(testCase.expect, hosts) {
+				t.Fatalf("Should be equal, expect %v, actual %v", testCase.expect, hosts)
+			}
+		})
+	}
+}
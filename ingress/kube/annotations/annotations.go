@@ -15,6 +15,7 @@
 package annotations
 
 import (
+	"github.com/hashicorp/go-multierror"
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/util/sets"
 	listersv1 "k8s.io/client-go/listers/core/v1"
@@ -24,6 +25,9 @@ type GlobalContext struct {
 	// secret key is cluster/namespace/name
 	WatchedSecrets sets.Set
 
+	// service key is namespace/name, within the cluster of the ingress
+	WatchedServices sets.Set
+
 	ClusterSecretLister map[string]listersv1.SecretLister
 
 	ClusterServiceList map[string]listersv1.ServiceLister
@@ -54,6 +58,8 @@ type Ingress struct {
 
 	IPAccessControl *IPAccessControlConfig
 
+	BodySize *BodySizeConfig
+
 	HeaderControl *HeaderControlConfig
 
 	Timeout *TimeoutConfig
@@ -62,11 +68,33 @@ type Ingress struct {
 
 	LoadBalance *LoadBalanceConfig
 
-	localRateLimit *localRateLimitConfig
+	CircuitBreaker *CircuitBreakerConfig
+
+	UpstreamKeepalive *UpstreamKeepaliveConfig
+
+	LocalRateLimit *LocalRateLimitConfig
+
+	GlobalRateLimit *GlobalRateLimitConfig
 
 	Fallback *FallbackConfig
 
+	Mirror *MirrorConfig
+
+	Fault *FaultConfig
+
+	Compression *CompressionConfig
+
 	Auth *AuthConfig
+
+	ExtAuth *ExtAuthConfig
+
+	Jwt *JwtConfig
+
+	Oidc *OidcConfig
+
+	ExternalTLS *ExternalTLSConfig
+
+	Destination *DestinationConfig
 }
 
 func (i *Ingress) NeedRegexMatch() bool {
@@ -85,15 +113,17 @@ func (i *Ingress) IsCanary() bool {
 	return i.Canary.Enabled
 }
 
-// CanaryKind return byHeader, byWeight
+// CanaryKind return byHeader, byWeight.
+// Both are true when the requests not matched by header can fall through to weight.
 func (i *Ingress) CanaryKind() (bool, bool) {
 	if !i.IsCanary() {
 		return false, false
 	}
 
-	// first header, cookie
-	if i.Canary.Header != "" || i.Canary.Cookie != "" {
-		return true, false
+	// first header, cookie, query, source range
+	if i.Canary.Header != "" || i.Canary.Cookie != "" || i.Canary.Query != "" ||
+		len(i.Canary.SourceRanges) > 0 {
+		return true, i.Canary.Weight > 0
 	}
 
 	// then weight
@@ -102,22 +132,43 @@ func (i *Ingress) CanaryKind() (bool, bool) {
 
 func (i *Ingress) NeedTrafficPolicy() bool {
 	return i.UpstreamTLS != nil ||
-		i.LoadBalance != nil
+		i.LoadBalance != nil ||
+		i.CircuitBreaker != nil ||
+		i.UpstreamKeepalive != nil ||
+		i.Timeout.NeedConnectTimeout()
 }
 
+// MergeHostBodySizeIfNotExist never modifies the existing body size config, which may be shared
+// with the cached translation, but replaces it with a merged copy.
+func (i *Ingress) MergeHostBodySizeIfNotExist(bs *BodySizeConfig) {
+	if i.BodySize != nil && i.BodySize.Domain != nil {
+		return
+	}
+
+	if bs != nil && bs.Domain != nil {
+		merged := BodySizeConfig{}
+		if i.BodySize != nil {
+			merged = *i.BodySize
+		}
+		merged.Domain = bs.Domain
+		i.BodySize = &merged
+	}
+}
+
+// MergeHostIPAccessControlIfNotExist never modifies the existing ip access control config, which may be
+// shared with the cached translation, but replaces it with a merged copy.
 func (i *Ingress) MergeHostIPAccessControlIfNotExist(ac *IPAccessControlConfig) {
 	if i.IPAccessControl != nil && i.IPAccessControl.Domain != nil {
 		return
 	}
 
 	if ac != nil && ac.Domain != nil {
-		if i.IPAccessControl == nil {
-			i.IPAccessControl = &IPAccessControlConfig{
-				Domain: ac.Domain,
-			}
-		} else {
-			i.IPAccessControl.Domain = ac.Domain
+		merged := IPAccessControlConfig{}
+		if i.IPAccessControl != nil {
+			merged = *i.IPAccessControl
 		}
+		merged.Domain = ac.Domain
+		i.IPAccessControl = &merged
 	}
 }
 
@@ -147,13 +198,25 @@ func NewAnnotationHandlerManager() AnnotationHandler {
 			rewrite{},
 			upstreamTLS{},
 			ipAccessControl{},
+			bodySize{},
 			headerControl{},
 			timeout{},
 			retry{},
 			loadBalance{},
+			circuitBreaker{},
+			upstreamKeepalive{},
 			localRateLimit{},
+			globalRateLimit{},
 			fallback{},
+			mirror{},
+			fault{},
+			compressor{},
 			auth{},
+			extAuth{},
+			jwt{},
+			oidc{},
+			externalTLS{},
+			destination{},
 		},
 		gatewayHandlers: []GatewayHandler{
 			downstreamTLS{},
@@ -171,20 +234,33 @@ func NewAnnotationHandlerManager() AnnotationHandler {
 			retry{},
 			localRateLimit{},
 			fallback{},
+			mirror{},
+			fault{},
+			jwt{},
+			// The auth handlers come after fault, so that the rejection on invalid annotations overrides the fault injection.
+			extAuth{},
+			oidc{},
 		},
 		trafficPolicyHandlers: []TrafficPolicyHandler{
 			upstreamTLS{},
 			loadBalance{},
+			timeout{},
+			upstreamKeepalive{},
+			circuitBreaker{},
 		},
 	}
 }
 
+// Parse returns the errors of parsers, while the valid annotations are still parsed.
 func (h *AnnotationHandlerManager) Parse(annotations Annotations, config *Ingress, globalContext *GlobalContext) error {
+	var errs error
 	for _, parser := range h.parsers {
-		_ = parser.Parse(annotations, config, globalContext)
+		if err := parser.Parse(annotations, config, globalContext); err != nil {
+			errs = multierror.Append(errs, err)
+		}
 	}
 
-	return nil
+	return errs
 }
 
 func (h *AnnotationHandlerManager) ApplyGateway(gateway *networking.Gateway, config *Ingress) {
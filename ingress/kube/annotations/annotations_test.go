@@ -149,6 +149,37 @@ func TestCanaryKind(t *testing.T) {
 			byHeader: false,
 			byWeight: true,
 		},
+		{
+			input: &Ingress{
+				Canary: &CanaryConfig{
+					Enabled: true,
+					Query:   "test",
+				},
+			},
+			byHeader: true,
+			byWeight: false,
+		},
+		{
+			input: &Ingress{
+				Canary: &CanaryConfig{
+					Enabled:      true,
+					SourceRanges: []string{"10.0.0.0/8"},
+				},
+			},
+			byHeader: true,
+			byWeight: false,
+		},
+		{
+			input: &Ingress{
+				Canary: &CanaryConfig{
+					Enabled: true,
+					Header:  "test",
+					Weight:  20,
+				},
+			},
+			byHeader: true,
+			byWeight: true,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -180,3 +211,46 @@ func TestNeedTrafficPolicy(t *testing.T) {
 		t.Fatal("should be true")
 	}
 }
+
+func TestMergeHostBodySizeIfNotExist(t *testing.T) {
+	host := &BodySize{MaxRequestBytes: 1024}
+	config := &Ingress{
+		BodySize: &BodySizeConfig{
+			Route: &BodySize{},
+		},
+	}
+	config.MergeHostBodySizeIfNotExist(&BodySizeConfig{Domain: host})
+	if config.BodySize.Domain != host || config.BodySize.Route == nil {
+		t.Fatal("host body size should be merged")
+	}
+
+	config.MergeHostBodySizeIfNotExist(&BodySizeConfig{Domain: &BodySize{MaxRequestBytes: 10}})
+	if config.BodySize.Domain != host {
+		t.Fatal("host body size should not be overridden")
+	}
+
+	cached := &BodySizeConfig{Route: &BodySize{}}
+	copied := Ingress{BodySize: cached}
+	copied.MergeHostBodySizeIfNotExist(&BodySizeConfig{Domain: host})
+	if cached.Domain != nil || copied.BodySize.Domain != host {
+		t.Fatal("the shared body size config should not be modified")
+	}
+}
+
+func TestMergeHostIPAccessControlIfNotExist(t *testing.T) {
+	host := &IPAccessControl{isWhite: true, remoteIp: []string{"1.1.1.1"}}
+	cached := &IPAccessControlConfig{Route: &IPAccessControl{}}
+	config := Ingress{IPAccessControl: cached}
+	config.MergeHostIPAccessControlIfNotExist(&IPAccessControlConfig{Domain: host})
+	if config.IPAccessControl.Domain != host || config.IPAccessControl.Route != cached.Route {
+		t.Fatal("host ip access control should be merged")
+	}
+	if cached.Domain != nil {
+		t.Fatal("the shared ip access control config should not be modified")
+	}
+
+	config.MergeHostIPAccessControlIfNotExist(&IPAccessControlConfig{Domain: &IPAccessControl{}})
+	if config.IPAccessControl.Domain != host {
+		t.Fatal("host ip access control should not be overridden")
+	}
+}
@@ -16,6 +16,8 @@ package annotations
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -32,9 +34,12 @@ const (
 	authSecretTypeAnn = "auth-secret-type"
 
 	defaultAuthType = "basic"
+	DigestAuthType  = "digest"
 	authFileKey     = "auth"
 )
 
+var ha1Regex = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
 type authSecretType string
 
 const (
@@ -68,10 +73,11 @@ func (a auth) Parse(annotations Annotations, config *Ingress, globalContext *Glo
 		IngressLog.Errorf("Parse auth type error %v within ingress %/%s", err, config.Namespace, config.Name)
 		return nil
 	}
-	if authType != defaultAuthType {
+	if authType != defaultAuthType && authType != DigestAuthType {
 		IngressLog.Errorf("Auth type %s within ingress %/%s is not supported yet.", authType, config.Namespace, config.Name)
 		return nil
 	}
+	authConfig.AuthType = authType
 
 	secretName, _ := annotations.ParseStringASAP(authSecretAnn)
 	namespaced := util.SplitNamespacedName(secretName)
@@ -114,6 +120,23 @@ func (a auth) Parse(annotations Annotations, config *Ingress, globalContext *Glo
 			namespaced.String(), config.Namespace, config.Name)
 		return nil
 	}
+	if authType == DigestAuthType {
+		if secretType != authFileAuthSecretType {
+			IngressLog.Errorf("Digest auth within ingress %s/%s only supports the auth-file secret type",
+				config.Namespace, config.Name)
+			return nil
+		}
+		realm, credentials, err := convertDigestCredentials(authConfig.AuthRealm, authSecret)
+		if err != nil {
+			IngressLog.Errorf("Parse digest auth secret fail within ingress %s/%s, err %v", config.Namespace, config.Name, err)
+			return nil
+		}
+		authConfig.AuthRealm = realm
+		authConfig.Credentials = credentials
+		config.Auth = authConfig
+		return nil
+	}
+
 	credentials, err := convertCredentials(secretType, authSecret)
 	if err != nil {
 		IngressLog.Errorf("Parse auth secret fail, err %v", err)
@@ -149,6 +172,43 @@ func convertCredentials(secretType authSecretType, secret *corev1.Secret) ([]str
 	return result, nil
 }
 
+// convertDigestCredentials converts the htdigest file within secret into user:HA1 credentials.
+// Only the lines of the given realm are kept, and the realm of the first line is used if it is empty.
+func convertDigestCredentials(realm string, secret *corev1.Secret) (string, []string, error) {
+	users, exist := secret.Data[authFileKey]
+	if !exist {
+		return "", nil, errors.New("the auth file type must has auth key in secret data")
+	}
+
+	var result []string
+	for _, line := range strings.Split(string(users), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// The format of htdigest is realm:user:HA1, where HA1 is md5(user:realm:password).
+		parts := strings.Split(line, ":")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || !ha1Regex.MatchString(parts[2]) {
+			return "", nil, fmt.Errorf("invalid htdigest line %q", line)
+		}
+		if realm == "" {
+			realm = parts[0]
+		}
+		if parts[0] != realm {
+			continue
+		}
+		result = append(result, parts[1]+":"+strings.ToLower(parts[2]))
+	}
+	if len(result) == 0 {
+		return "", nil, fmt.Errorf("no credentials of realm %q", realm)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+
+	return realm, result, nil
+}
+
 func needAuthConfig(annotations Annotations) bool {
 	return annotations.HasASAP(authType) &&
 		annotations.HasASAP(authSecretAnn)
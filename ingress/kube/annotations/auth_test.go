@@ -150,6 +150,52 @@ func TestAuthParse(t *testing.T) {
 			},
 			watchedSecret: "cluster/default/bar",
 		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(authType):    DigestAuthType,
+				buildNginxAnnotationKey(authRealm):   "test",
+				buildMSEAnnotationKey(authSecretAnn): "foo/bar",
+			},
+			secret: &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "bar",
+					Namespace: "foo",
+				},
+				Data: map[string][]byte{
+					"auth": []byte("test:B:fc99ea43d3207e60d3440032160bfb7e\nother:C:05f0cacecb3588ff5f0282f6d3d41339\ntest:A:29203e70de091917e404308db1d37ebc\n"),
+				},
+			},
+			expect: &AuthConfig{
+				AuthType:  DigestAuthType,
+				AuthRealm: "test",
+				AuthSecret: util.ClusterNamespacedName{
+					NamespacedName: model.NamespacedName{
+						Namespace: "foo",
+						Name:      "bar",
+					},
+					ClusterId: "cluster",
+				},
+				Credentials: []string{"A:29203e70de091917e404308db1d37ebc", "B:fc99ea43d3207e60d3440032160bfb7e"},
+			},
+			watchedSecret: "cluster/foo/bar",
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(authType):          DigestAuthType,
+				buildMSEAnnotationKey(authSecretAnn):       "foo/bar",
+				buildNginxAnnotationKey(authSecretTypeAnn): string(authMapAuthSecretType),
+			},
+			secret: &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "bar",
+					Namespace: "foo",
+				},
+				Data: map[string][]byte{
+					"A": []byte("29203e70de091917e404308db1d37ebc"),
+				},
+			},
+			watchedSecret: "cluster/foo/bar",
+		},
 	}
 
 	for _, inputCase := range inputCases {
@@ -178,6 +224,64 @@ func TestAuthParse(t *testing.T) {
 	}
 }
 
+func TestConvertDigestCredentials(t *testing.T) {
+	inputCases := []struct {
+		realm       string
+		data        string
+		expectRealm string
+		expect      []string
+		expectErr   bool
+	}{
+		{
+			data:        "test:A:29203e70de091917e404308db1d37ebc\n\ntest:B:FC99EA43D3207E60D3440032160BFB7E",
+			expectRealm: "test",
+			expect:      []string{"A:29203e70de091917e404308db1d37ebc", "B:fc99ea43d3207e60d3440032160bfb7e"},
+		},
+		{
+			realm:       "other",
+			data:        "test:A:29203e70de091917e404308db1d37ebc\nother:C:05f0cacecb3588ff5f0282f6d3d41339",
+			expectRealm: "other",
+			expect:      []string{"C:05f0cacecb3588ff5f0282f6d3d41339"},
+		},
+		{
+			realm:     "missing",
+			data:      "test:A:29203e70de091917e404308db1d37ebc",
+			expectErr: true,
+		},
+		{
+			data:      "A:a",
+			expectErr: true,
+		},
+		{
+			data:      "test:A:not-a-md5-hash",
+			expectErr: true,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			secret := &v1.Secret{
+				Data: map[string][]byte{
+					authFileKey: []byte(inputCase.data),
+				},
+			}
+			realm, credentials, err := convertDigestCredentials(inputCase.realm, secret)
+			if inputCase.expectErr {
+				if err == nil {
+					t.Fatal("Should be error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if realm != inputCase.expectRealm || !reflect.DeepEqual(inputCase.expect, credentials) {
+				t.Fatalf("Should be equal.")
+			}
+		})
+	}
+}
+
 func initGlobalContext(secret *v1.Secret) (*GlobalContext, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -0,0 +1,122 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"fmt"
+	"math"
+
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	domainProxyBodySize  = "domain-proxy-body-size"
+	proxyBodySize        = "proxy-body-size"
+	clientBodyBufferSize = "client-body-buffer-size"
+
+	defaultClientBodyBufferSize = 1 << 20
+)
+
+var _ Parser = bodySize{}
+
+// BodySize is the max bytes of request body, and zero means unlimited. The limit is checked against
+// the content length of request, while the body without content length has to be buffered to be limited,
+// which is done only if the limit doesn't exceed the buffer bytes.
+type BodySize struct {
+	MaxRequestBytes uint32
+	BufferBytes     uint32
+}
+
+type BodySizeConfig struct {
+	Domain *BodySize
+	Route  *BodySize
+	// BufferBytes is the max bytes of request body buffered in memory, and zero means the default.
+	BufferBytes uint32
+}
+
+type bodySize struct{}
+
+// Parse returns error if any body size annotation is invalid, and the body size is not limited in this case.
+func (b bodySize) Parse(annotations Annotations, config *Ingress, _ *GlobalContext) error {
+	if !needBodySizeConfig(annotations) {
+		return nil
+	}
+
+	bodySizeConfig := &BodySizeConfig{}
+	sizes := []struct {
+		key   string
+		parse func(string) (string, error)
+		set   func(uint32)
+	}{
+		{domainProxyBodySize, annotations.ParseStringForMSE, func(value uint32) {
+			bodySizeConfig.Domain = &BodySize{MaxRequestBytes: value}
+		}},
+		{proxyBodySize, annotations.ParseStringASAP, func(value uint32) {
+			bodySizeConfig.Route = &BodySize{MaxRequestBytes: value}
+		}},
+		{clientBodyBufferSize, annotations.ParseStringASAP, func(value uint32) {
+			bodySizeConfig.BufferBytes = value
+		}},
+	}
+	for _, item := range sizes {
+		raw, err := item.parse(item.key)
+		if err != nil {
+			continue
+		}
+		value, err := parseNginxSize(raw)
+		if err == nil && value > math.MaxUint32 {
+			err = fmt.Errorf("size %q exceeds 4g", raw)
+		}
+		if err != nil {
+			IngressLog.Errorf("Body size %s within ingress %s/%s is invalid, %v", item.key, config.Namespace, config.Name, err)
+			return fmt.Errorf("invalid %s within ingress %s/%s: %v", item.key, config.Namespace, config.Name, err)
+		}
+		item.set(uint32(value))
+	}
+
+	if bodySizeConfig.Domain != nil || bodySizeConfig.Route != nil || bodySizeConfig.BufferBytes > 0 {
+		config.BodySize = bodySizeConfig
+	}
+	return nil
+}
+
+// Limit returns the body size of route, which falls back to the body size of host,
+// and the body is buffered up to the buffer bytes of route.
+func (b *BodySizeConfig) Limit(host *BodySize) *BodySize {
+	limit := host
+	bufferBytes := uint32(defaultClientBodyBufferSize)
+	if b != nil {
+		if b.Route != nil {
+			limit = b.Route
+		}
+		if b.BufferBytes > 0 {
+			bufferBytes = b.BufferBytes
+		}
+	}
+	if limit == nil {
+		return nil
+	}
+
+	return &BodySize{
+		MaxRequestBytes: limit.MaxRequestBytes,
+		BufferBytes:     bufferBytes,
+	}
+}
+
+func needBodySizeConfig(annotations Annotations) bool {
+	return annotations.HasMSE(domainProxyBodySize) ||
+		annotations.HasASAP(proxyBodySize) ||
+		annotations.HasASAP(clientBodyBufferSize)
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBodySizeParse(t *testing.T) {
+	bodySize := bodySize{}
+	inputCases := []struct {
+		input     map[string]string
+		expect    *BodySizeConfig
+		expectErr bool
+	}{
+		{},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(clientBodyBufferSize): "16k",
+			},
+			expect: &BodySizeConfig{
+				BufferBytes: 16 << 10,
+			},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(proxyBodySize): "8m",
+			},
+			expect: &BodySizeConfig{
+				Route: &BodySize{
+					MaxRequestBytes: 8 << 20,
+				},
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(domainProxyBodySize): "1g",
+				buildNginxAnnotationKey(proxyBodySize):     "0",
+			},
+			expect: &BodySizeConfig{
+				Domain: &BodySize{
+					MaxRequestBytes: 1 << 30,
+				},
+				Route: &BodySize{},
+			},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(proxyBodySize): "8mb",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(proxyBodySize):        "8m",
+				buildNginxAnnotationKey(clientBodyBufferSize): "16x",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(domainProxyBodySize): "4g",
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			config := &Ingress{}
+			err := bodySize.Parse(inputCase.input, config, nil)
+			if (err != nil) != inputCase.expectErr {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(inputCase.expect, config.BodySize) {
+				t.Fatalf("Should be equal")
+			}
+		})
+	}
+}
+
+func TestBodySizeLimit(t *testing.T) {
+	host := &BodySize{MaxRequestBytes: 1024}
+	inputCases := []struct {
+		config *BodySizeConfig
+		host   *BodySize
+		expect *BodySize
+	}{
+		{},
+		{
+			host: host,
+			expect: &BodySize{
+				MaxRequestBytes: 1024,
+				BufferBytes:     defaultClientBodyBufferSize,
+			},
+		},
+		{
+			config: &BodySizeConfig{
+				Domain:      &BodySize{MaxRequestBytes: 10},
+				BufferBytes: 512,
+			},
+			host: host,
+			expect: &BodySize{
+				MaxRequestBytes: 1024,
+				BufferBytes:     512,
+			},
+		},
+		{
+			config: &BodySizeConfig{
+				Route: &BodySize{},
+			},
+			host: host,
+			expect: &BodySize{
+				BufferBytes: defaultClientBodyBufferSize,
+			},
+		},
+		{
+			config: &BodySizeConfig{
+				BufferBytes: 512,
+			},
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			if actual := inputCase.config.Limit(inputCase.host); !reflect.DeepEqual(inputCase.expect, actual) {
+				t.Fatalf("Should be %v, but actual is %v", inputCase.expect, actual)
+			}
+		})
+	}
+}
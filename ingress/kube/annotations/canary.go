@@ -15,7 +15,16 @@
 package annotations
 
 import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
 	networking "istio.io/api/networking/v1alpha3"
+
+	. "github.com/alibaba/higress/ingress/log"
 )
 
 const (
@@ -24,10 +33,22 @@ const (
 	canaryByHeaderValue   = "canary-by-header-value"
 	canaryByHeaderPattern = "canary-by-header-pattern"
 	canaryByCookie        = "canary-by-cookie"
+	canaryByQuery         = "canary-by-query"
+	canaryByQueryValue    = "canary-by-query-value"
+	canaryBySourceRange   = "canary-by-source-range"
 	canaryWeight          = "canary-weight"
 	canaryWeightTotal     = "canary-weight-total"
+	canaryPriority        = "canary-priority"
 
 	defaultCanaryWeightTotal = 100
+
+	// The gateway appends the address of the downstream peer to the end of
+	// x-forwarded-for, which is present even when the peer is a private address.
+	sourceAddressHeader = "x-forwarded-for"
+
+	stickyCanaryCookieSuffix = "-canary"
+
+	cookieHeader = "cookie"
 )
 
 var _ Parser = &canary{}
@@ -38,8 +59,13 @@ type CanaryConfig struct {
 	HeaderValue   string
 	HeaderPattern string
 	Cookie        string
+	Query         string
+	QueryValue    string
+	SourceRanges  []string
 	Weight        int
 	WeightTotal   int
+	// Priority orders the canaries of the same path, the higher is applied first.
+	Priority int
 }
 
 type canary struct{}
@@ -69,25 +95,44 @@ func (c canary) Parse(annotations Annotations, config *Ingress, _ *GlobalContext
 	if headerValue, err := annotations.ParseStringASAP(canaryByHeaderValue); err == nil &&
 		headerValue != "" {
 		canaryConfig.HeaderValue = headerValue
-		return nil
-	}
-
-	if headerPattern, err := annotations.ParseStringASAP(canaryByHeaderPattern); err == nil &&
+	} else if headerPattern, err := annotations.ParseStringASAP(canaryByHeaderPattern); err == nil &&
 		headerPattern != "" {
 		canaryConfig.HeaderPattern = headerPattern
-		return nil
 	}
 
 	if cookie, err := annotations.ParseStringASAP(canaryByCookie); err == nil &&
 		cookie != "" {
 		canaryConfig.Cookie = cookie
-		return nil
+		// Both conditions would match the cookie header, the one by cookie takes over.
+		if strings.EqualFold(canaryConfig.Header, cookieHeader) {
+			return fmt.Errorf("invalid canary within ingress %s/%s: %s conflicts with %s",
+				config.Namespace, config.Name, canaryByHeader, canaryByCookie)
+		}
+	}
+
+	if query, err := annotations.ParseStringForMSE(canaryByQuery); err == nil &&
+		query != "" {
+		canaryConfig.Query = query
+		canaryConfig.QueryValue, _ = annotations.ParseStringForMSE(canaryByQueryValue)
+	}
+
+	if rawRanges, err := annotations.ParseStringForMSE(canaryBySourceRange); err == nil {
+		for _, rawRange := range splitBySeparator(rawRanges, ",") {
+			sourceRange, err := parseIPv4Range(rawRange)
+			if err != nil {
+				IngressLog.Errorf("Canary source range %s within ingress %s/%s is invalid, err: %v",
+					rawRange, config.Namespace, config.Name, err)
+				continue
+			}
+			canaryConfig.SourceRanges = append(canaryConfig.SourceRanges, sourceRange)
+		}
 	}
 
 	canaryConfig.Weight, _ = annotations.ParseIntASAP(canaryWeight)
 	if weightTotal, err := annotations.ParseIntASAP(canaryWeightTotal); err == nil && weightTotal > 0 {
 		canaryConfig.WeightTotal = weightTotal
 	}
+	canaryConfig.Priority, _ = annotations.ParseIntForMSE(canaryPriority)
 
 	return nil
 }
@@ -132,39 +177,62 @@ func ApplyByHeader(canary, route *networking.HTTPRoute, canaryIngress *Ingress)
 	// Assign temp copied canary route destination
 	canary.Route = temp.Route
 
-	// Modified match base on by header
+	// Modified match base on by header, cookie, query and source range.
+	// All of them are combined with AND in the same match.
+	headers := map[string]*networking.StringMatch{}
 	if canaryConfig.Header != "" {
-		canary.Match[0].Headers = map[string]*networking.StringMatch{
-			canaryConfig.Header: {
-				MatchType: &networking.StringMatch_Exact{
-					Exact: "always",
-				},
+		headers[canaryConfig.Header] = &networking.StringMatch{
+			MatchType: &networking.StringMatch_Exact{
+				Exact: "always",
 			},
 		}
 		if canaryConfig.HeaderValue != "" {
-			canary.Match[0].Headers = map[string]*networking.StringMatch{
-				canaryConfig.Header: {
-					MatchType: &networking.StringMatch_Regex{
-						Regex: "always|" + canaryConfig.HeaderValue,
-					},
+			headers[canaryConfig.Header] = &networking.StringMatch{
+				MatchType: &networking.StringMatch_Regex{
+					Regex: "always|" + canaryConfig.HeaderValue,
 				},
 			}
 		} else if canaryConfig.HeaderPattern != "" {
-			canary.Match[0].Headers = map[string]*networking.StringMatch{
-				canaryConfig.Header: {
-					MatchType: &networking.StringMatch_Regex{
-						Regex: canaryConfig.HeaderPattern,
-					},
+			headers[canaryConfig.Header] = &networking.StringMatch{
+				MatchType: &networking.StringMatch_Regex{
+					Regex: canaryConfig.HeaderPattern,
 				},
 			}
 		}
-	} else if canaryConfig.Cookie != "" {
-		canary.Match[0].Headers = map[string]*networking.StringMatch{
-			"cookie": {
+	}
+	if canaryConfig.Cookie != "" {
+		headers[cookieHeader] = &networking.StringMatch{
+			MatchType: &networking.StringMatch_Regex{
+				Regex: "^(.\\*?;)?(" + canaryConfig.Cookie + "=always)(;.\\*)?$",
+			},
+		}
+	}
+	if len(canaryConfig.SourceRanges) > 0 {
+		headers[sourceAddressHeader] = &networking.StringMatch{
+			MatchType: &networking.StringMatch_Regex{
+				Regex: sourceRangesRegex(canaryConfig.SourceRanges),
+			},
+		}
+	}
+	if len(headers) > 0 {
+		canary.Match[0].Headers = headers
+	}
+
+	if canaryConfig.Query != "" {
+		queryMatch := &networking.StringMatch{
+			MatchType: &networking.StringMatch_Exact{
+				Exact: "always",
+			},
+		}
+		if canaryConfig.QueryValue != "" {
+			queryMatch = &networking.StringMatch{
 				MatchType: &networking.StringMatch_Regex{
-					Regex: "^(.\\*?;)?(" + canaryConfig.Cookie + "=always)(;.\\*)?$",
+					Regex: "always|" + canaryConfig.QueryValue,
 				},
-			},
+			}
+		}
+		canary.Match[0].QueryParams = map[string]*networking.StringMatch{
+			canaryConfig.Query: queryMatch,
 		}
 	}
 
@@ -180,6 +248,181 @@ func ApplyByHeader(canary, route *networking.HTTPRoute, canaryIngress *Ingress)
 		route.Route[0].FallbackClusters...)
 }
 
+// ApplyStickyCanary pins the users to the destination chosen by weight at the first time,
+// if the affinity canary behavior of normal ingress is sticky. Each destination of the weighted
+// route sets a cookie identifying itself, and the returned routes, which must precede the weighted
+// route, send the requests carrying the cookie to the same destination.
+func ApplyStickyCanary(route *networking.HTTPRoute, config *Ingress) []*networking.HTTPRoute {
+	if len(route.Route) < 2 || len(route.Match) == 0 || config.LoadBalance == nil ||
+		config.LoadBalance.cookie == nil || !config.LoadBalance.cookie.canarySticky {
+		return nil
+	}
+
+	cookie := config.LoadBalance.cookie
+	name := cookie.name + stickyCanaryCookieSuffix
+	var stickyRoutes []*networking.HTTPRoute
+	for _, destination := range route.Route {
+		value := stickyCanaryValue(destination.Destination)
+
+		if destination.Headers == nil {
+			destination.Headers = &networking.Headers{}
+		}
+		if destination.Headers.Response == nil {
+			destination.Headers.Response = &networking.Headers_HeaderOperations{}
+		}
+		if destination.Headers.Response.Add == nil {
+			destination.Headers.Response.Add = map[string]string{}
+		}
+		setCookie := name + "=" + value + "; Path=" + cookie.path
+		if cookie.age != nil && cookie.age.Seconds > 0 {
+			setCookie += "; Max-Age=" + strconv.FormatInt(cookie.age.Seconds, 10)
+		}
+		destination.Headers.Response.Add["Set-Cookie"] = setCookie
+
+		// The sticky route keeps the fallback clusters of the destination.
+		stickyRoute := route.DeepCopy()
+		stickyRoute.Name = route.Name + "-sticky-" + value
+		stickyRoute.Route = []*networking.HTTPRouteDestination{destination.DeepCopy()}
+		for _, match := range stickyRoute.Match {
+			if match.Headers == nil {
+				match.Headers = map[string]*networking.StringMatch{}
+			}
+			match.Headers[cookieHeader] = &networking.StringMatch{
+				MatchType: &networking.StringMatch_Regex{
+					Regex: `^(.*?;\s*)?(` + regexp.QuoteMeta(name+"="+value) + `)(;.*)?$`,
+				},
+			}
+		}
+		stickyRoutes = append(stickyRoutes, stickyRoute)
+	}
+	return stickyRoutes
+}
+
+// stickyCanaryValue identifies the destination in cookie without exposing the service.
+func stickyCanaryValue(destination *networking.Destination) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(destination.Host))
+	if destination.Port != nil {
+		_, _ = h.Write([]byte(":" + strconv.FormatUint(uint64(destination.Port.Number), 10)))
+	}
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
 func needCanaryConfig(annotations Annotations) bool {
 	return annotations.HasASAP(enableCanary)
 }
+
+// parseIPv4Range accepts an ipv4 address or cidr, and returns the normalized cidr.
+func parseIPv4Range(raw string) (string, error) {
+	if !strings.Contains(raw, "/") {
+		raw += "/32"
+	}
+	_, ipNet, err := net.ParseCIDR(raw)
+	if err != nil {
+		return "", err
+	}
+	if ipNet.IP.To4() == nil {
+		return "", fmt.Errorf("only ipv4 is supported")
+	}
+	return ipNet.String(), nil
+}
+
+// sourceRangesRegex returns the regex matching the value of x-forwarded-for,
+// whose last address is within any of the cidrs.
+func sourceRangesRegex(cidrs []string) string {
+	var ranges []string
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		ranges = append(ranges, ipv4RangeRegex(ipNet))
+	}
+	return `(.*,\s*)?(` + strings.Join(ranges, "|") + `)`
+}
+
+// ipv4RangeRegex returns the regex matching the dotted ipv4 addresses within the cidr.
+func ipv4RangeRegex(ipNet *net.IPNet) string {
+	ones, _ := ipNet.Mask.Size()
+	ip := ipNet.IP.To4()
+	octets := make([]string, len(ip))
+	for i := range ip {
+		bits := ones - i*8
+		switch {
+		case bits >= 8:
+			octets[i] = strconv.Itoa(int(ip[i]))
+		case bits <= 0:
+			octets[i] = `\d+`
+		default:
+			lo := int(ip[i])
+			hi := lo | (1<<(8-bits) - 1)
+			octets[i] = "(" + strings.Join(numberRangeRegex(lo, hi), "|") + ")"
+		}
+	}
+	return strings.Join(octets, `\.`)
+}
+
+// numberRangeRegex returns the alternatives matching the decimal numbers between lo and hi.
+func numberRangeRegex(lo, hi int) []string {
+	var alternatives []string
+	for lower, upper := 0, 9; lower <= hi; lower, upper = upper+1, upper*10+9 {
+		from, to := lower, upper
+		if from < lo {
+			from = lo
+		}
+		if to > hi {
+			to = hi
+		}
+		if from > to {
+			continue
+		}
+		alternatives = append(alternatives, digitRangeRegex(strconv.Itoa(from), strconv.Itoa(to))...)
+	}
+	return alternatives
+}
+
+// digitRangeRegex returns the alternatives matching the numbers between from and to,
+// which must have the same count of digits.
+func digitRangeRegex(from, to string) []string {
+	if from == to {
+		return []string{from}
+	}
+	if len(from) == 1 {
+		return []string{digitClass(from[0], to[0])}
+	}
+
+	withPrefix := func(prefix byte, alternatives []string) []string {
+		for i := range alternatives {
+			alternatives[i] = string(prefix) + alternatives[i]
+		}
+		return alternatives
+	}
+	if from[0] == to[0] {
+		return withPrefix(from[0], digitRangeRegex(from[1:], to[1:]))
+	}
+
+	var alternatives []string
+	lowest, highest := strings.Repeat("0", len(from)-1), strings.Repeat("9", len(from)-1)
+	first, last := from[0], to[0]
+	if from[1:] != lowest {
+		alternatives = append(alternatives, withPrefix(from[0], digitRangeRegex(from[1:], highest))...)
+		first++
+	}
+	if to[1:] != highest {
+		last--
+	}
+	if first <= last {
+		alternatives = append(alternatives, digitClass(first, last)+strings.Repeat(`\d`, len(from)-1))
+	}
+	if to[1:] != highest {
+		alternatives = append(alternatives, withPrefix(to[0], digitRangeRegex(lowest, to[1:]))...)
+	}
+	return alternatives
+}
+
+func digitClass(from, to byte) string {
+	if from == to {
+		return string(from)
+	}
+	return "[" + string(from) + "-" + string(to) + "]"
+}
@@ -15,9 +15,13 @@
 package annotations
 
 import (
+	"fmt"
+	"net"
 	"reflect"
+	"regexp"
 	"testing"
 
+	"github.com/gogo/protobuf/types"
 	networking "istio.io/api/networking/v1alpha3"
 )
 
@@ -252,3 +256,293 @@ func TestApplyHeader(t *testing.T) {
 		t.Fatal("Should be equal")
 	}
 }
+
+func TestCanaryParse(t *testing.T) {
+	parser := canary{}
+
+	inputCases := []struct {
+		input     map[string]string
+		expect    *CanaryConfig
+		expectErr bool
+	}{
+		{},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(enableCanary):        "true",
+				buildNginxAnnotationKey(canaryByHeader):      "user",
+				buildNginxAnnotationKey(canaryByHeaderValue): "test",
+				buildNginxAnnotationKey(canaryWeight):        "20",
+			},
+			expect: &CanaryConfig{
+				Enabled:     true,
+				Header:      "user",
+				HeaderValue: "test",
+				Weight:      20,
+				WeightTotal: defaultCanaryWeightTotal,
+			},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(enableCanary):      "true",
+				buildNginxAnnotationKey(canaryByHeader):    "user",
+				buildMSEAnnotationKey(canaryByQuery):       "region",
+				buildMSEAnnotationKey(canaryByQueryValue):  "hz",
+				buildMSEAnnotationKey(canaryBySourceRange): "10.0.0.1, 192.168.0.0/16, 2001:db8::/32, abc",
+				buildMSEAnnotationKey(canaryPriority):      "10",
+			},
+			expect: &CanaryConfig{
+				Enabled:      true,
+				Header:       "user",
+				Query:        "region",
+				QueryValue:   "hz",
+				SourceRanges: []string{"10.0.0.1/32", "192.168.0.0/16"},
+				WeightTotal:  defaultCanaryWeightTotal,
+				Priority:     10,
+			},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(enableCanary):   "true",
+				buildNginxAnnotationKey(canaryByHeader): "Cookie",
+				buildNginxAnnotationKey(canaryByCookie): "beta",
+			},
+			expect: &CanaryConfig{
+				Enabled:     true,
+				Header:      "Cookie",
+				Cookie:      "beta",
+				WeightTotal: defaultCanaryWeightTotal,
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			config := &Ingress{}
+			err := parser.Parse(inputCase.input, config, nil)
+			if (err != nil) != inputCase.expectErr {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(inputCase.expect, config.Canary) {
+				t.Fatal("Should be equal")
+			}
+		})
+	}
+}
+
+func TestApplyHeaderWithConditions(t *testing.T) {
+	route := &networking.HTTPRoute{
+		Route: []*networking.HTTPRouteDestination{
+			{
+				Destination: &networking.Destination{
+					Host: "normal",
+					Port: &networking.PortSelector{
+						Number: 80,
+					},
+				},
+			},
+		},
+	}
+
+	canary := &networking.HTTPRoute{
+		Match: []*networking.HTTPMatchRequest{
+			{
+				Uri: &networking.StringMatch{
+					MatchType: &networking.StringMatch_Prefix{Prefix: "/"},
+				},
+			},
+		},
+		Route: []*networking.HTTPRouteDestination{
+			{
+				Destination: &networking.Destination{
+					Host: "canary",
+					Port: &networking.PortSelector{
+						Number: 80,
+					},
+				},
+			},
+		},
+	}
+
+	ApplyByHeader(canary, route, &Ingress{
+		Canary: &CanaryConfig{
+			Header:       "user",
+			Cookie:       "beta",
+			Query:        "region",
+			SourceRanges: []string{"10.0.0.0/8"},
+		},
+	})
+
+	expect := []*networking.HTTPMatchRequest{
+		{
+			Uri: &networking.StringMatch{
+				MatchType: &networking.StringMatch_Prefix{Prefix: "/"},
+			},
+			Headers: map[string]*networking.StringMatch{
+				"user": {
+					MatchType: &networking.StringMatch_Exact{Exact: "always"},
+				},
+				"cookie": {
+					MatchType: &networking.StringMatch_Regex{Regex: "^(.\\*?;)?(beta=always)(;.\\*)?$"},
+				},
+				"x-forwarded-for": {
+					MatchType: &networking.StringMatch_Regex{Regex: `(.*,\s*)?(10\.\d+\.\d+\.\d+)`},
+				},
+			},
+			QueryParams: map[string]*networking.StringMatch{
+				"region": {
+					MatchType: &networking.StringMatch_Exact{Exact: "always"},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(canary.Match, expect) {
+		t.Fatal("Should be equal")
+	}
+}
+
+func TestSourceRangesRegex(t *testing.T) {
+	re := regexp.MustCompile("^" + sourceRangesRegex([]string{"10.1.2.3/32", "172.16.0.0/12"}) + "$")
+	inputCases := []struct {
+		input  string
+		expect bool
+	}{
+		{
+			input:  "10.1.2.3",
+			expect: true,
+		},
+		{
+			input:  "1.1.1.1, 10.1.2.3",
+			expect: true,
+		},
+		{
+			input:  "10.1.2.3, 1.1.1.1",
+			expect: false,
+		},
+		{
+			input:  "10.1.2.30",
+			expect: false,
+		},
+		{
+			input:  "172.31.255.1",
+			expect: true,
+		},
+		{
+			input:  "172.32.0.1",
+			expect: false,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			if re.MatchString(inputCase.input) != inputCase.expect {
+				t.Fatalf("Should be %t for %s", inputCase.expect, inputCase.input)
+			}
+		})
+	}
+}
+
+func TestIPv4RangeRegex(t *testing.T) {
+	for _, base := range []string{"0.0.0.0", "100.64.200.99", "255.255.255.255"} {
+		for ones := 17; ones <= 32; ones++ {
+			_, ipNet, _ := net.ParseCIDR(fmt.Sprintf("%s/%d", base, ones))
+			re := regexp.MustCompile("^" + ipv4RangeRegex(ipNet) + "$")
+			ip := ipNet.IP.To4()
+			for third := 0; third < 256; third++ {
+				for _, fourth := range []int{0, 9, 10, 99, 100, 199, 200, 249, 250, 255, int(ip[3])} {
+					candidate := net.IPv4(ip[0], ip[1], byte(third), byte(fourth))
+					if re.MatchString(candidate.String()) != ipNet.Contains(candidate) {
+						t.Fatalf("Mismatch of %s within %s", candidate, ipNet)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestApplyStickyCanary(t *testing.T) {
+	newRoute := func() *networking.HTTPRoute {
+		return &networking.HTTPRoute{
+			Name: "route",
+			Match: []*networking.HTTPMatchRequest{
+				{
+					Uri: &networking.StringMatch{
+						MatchType: &networking.StringMatch_Prefix{Prefix: "/"},
+					},
+				},
+			},
+			Route: []*networking.HTTPRouteDestination{
+				{
+					Destination: &networking.Destination{
+						Host: "normal",
+						Port: &networking.PortSelector{
+							Number: 80,
+						},
+					},
+					Weight: 90,
+				},
+				{
+					Destination: &networking.Destination{
+						Host: "canary",
+						Port: &networking.PortSelector{
+							Number: 80,
+						},
+					},
+					Weight: 10,
+					FallbackClusters: []*networking.Destination{
+						{
+							Host: "normal",
+							Port: &networking.PortSelector{
+								Number: 80,
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	route := newRoute()
+	if ApplyStickyCanary(route, &Ingress{}) != nil || !reflect.DeepEqual(route, newRoute()) {
+		t.Fatal("Should not apply without sticky behavior")
+	}
+
+	stickyRoutes := ApplyStickyCanary(route, &Ingress{
+		LoadBalance: &LoadBalanceConfig{
+			cookie: &consistentHashByCookie{
+				name:         "test",
+				path:         "/",
+				age:          &types.Duration{Seconds: 100},
+				canarySticky: true,
+			},
+		},
+	})
+	if len(stickyRoutes) != 2 {
+		t.Fatalf("Should be 2 sticky routes, but actual is %d", len(stickyRoutes))
+	}
+
+	for idx, destination := range route.Route {
+		value := stickyCanaryValue(destination.Destination)
+		setCookie := "test-canary=" + value + "; Path=/; Max-Age=100"
+		if destination.Headers.Response.Add["Set-Cookie"] != setCookie {
+			t.Fatalf("Unexpected set cookie %s", destination.Headers.Response.Add["Set-Cookie"])
+		}
+
+		stickyRoute := stickyRoutes[idx]
+		if stickyRoute.Name != "route-sticky-"+value {
+			t.Fatalf("Unexpected route name %s", stickyRoute.Name)
+		}
+		if len(stickyRoute.Route) != 1 || !reflect.DeepEqual(stickyRoute.Route[0], destination) {
+			t.Fatal("Should route to the destination only")
+		}
+
+		re := regexp.MustCompile(stickyRoute.Match[0].Headers["cookie"].GetRegex())
+		if !re.MatchString("a=b; test-canary=" + value + "; c=d") {
+			t.Fatal("Should match the cookie")
+		}
+		if re.MatchString("test-canary=" + value + "0") {
+			t.Fatal("Should not match other value")
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gogo/protobuf/types"
+	networking "istio.io/api/networking/v1alpha3"
+
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	upstreamMaxConnections           = "upstream-max-connections"
+	upstreamMaxPendingRequests       = "upstream-max-pending-requests"
+	upstreamMaxRequests              = "upstream-max-requests"
+	upstreamMaxRequestsPerConnection = "upstream-max-requests-per-connection"
+	outlierConsecutive5xx            = "outlier-consecutive-5xx"
+	outlierInterval                  = "outlier-interval"
+	outlierBaseEjectionTime          = "outlier-base-ejection-time"
+	outlierMaxEjectionPercent        = "outlier-max-ejection-percent"
+)
+
+var (
+	_ Parser               = circuitBreaker{}
+	_ TrafficPolicyHandler = circuitBreaker{}
+)
+
+// CircuitBreakerConfig holds the connection pool limits and outlier detection of upstream,
+// and the zero value of each field means unset.
+type CircuitBreakerConfig struct {
+	MaxConnections           int32
+	MaxPendingRequests       int32
+	MaxRequests              int32
+	MaxRequestsPerConnection int32
+
+	Consecutive5xxErrors uint32
+	Interval             *types.Duration
+	BaseEjectionTime     *types.Duration
+	MaxEjectionPercent   int32
+}
+
+type circuitBreaker struct{}
+
+// Parse returns error if any circuit breaker annotation is invalid, and the circuit breaker is not applied in this case.
+func (c circuitBreaker) Parse(annotations Annotations, config *Ingress, _ *GlobalContext) error {
+	if !needCircuitBreakerConfig(annotations) {
+		return nil
+	}
+
+	circuitBreakerConfig, err := parseCircuitBreakerConfig(annotations)
+	if err != nil {
+		IngressLog.Errorf("Circuit breaker within ingress %s/%s is invalid, %v", config.Namespace, config.Name, err)
+		return fmt.Errorf("invalid circuit breaker within ingress %s/%s: %v", config.Namespace, config.Name, err)
+	}
+
+	config.CircuitBreaker = circuitBreakerConfig
+	return nil
+}
+
+func parseCircuitBreakerConfig(annotations Annotations) (*CircuitBreakerConfig, error) {
+	circuitBreakerConfig := &CircuitBreakerConfig{}
+
+	limits := []struct {
+		key   string
+		value *int32
+	}{
+		{upstreamMaxConnections, &circuitBreakerConfig.MaxConnections},
+		{upstreamMaxPendingRequests, &circuitBreakerConfig.MaxPendingRequests},
+		{upstreamMaxRequests, &circuitBreakerConfig.MaxRequests},
+		{upstreamMaxRequestsPerConnection, &circuitBreakerConfig.MaxRequestsPerConnection},
+	}
+	for _, limit := range limits {
+		raw, err := annotations.ParseStringForMSE(limit.key)
+		if err != nil {
+			continue
+		}
+		value, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("%s %s is invalid", limit.key, raw)
+		}
+		*limit.value = int32(value)
+	}
+
+	if raw, err := annotations.ParseStringForMSE(outlierConsecutive5xx); err == nil {
+		value, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil || value == 0 {
+			return nil, fmt.Errorf("%s %s is invalid", outlierConsecutive5xx, raw)
+		}
+		circuitBreakerConfig.Consecutive5xxErrors = uint32(value)
+	}
+
+	durations := []struct {
+		key   string
+		value **types.Duration
+	}{
+		{outlierInterval, &circuitBreakerConfig.Interval},
+		{outlierBaseEjectionTime, &circuitBreakerConfig.BaseEjectionTime},
+	}
+	for _, duration := range durations {
+		raw, err := annotations.ParseStringForMSE(duration.key)
+		if err != nil {
+			continue
+		}
+		value, err := parseDuration(raw)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("%s %s is invalid", duration.key, raw)
+		}
+		*duration.value = types.DurationProto(value)
+	}
+
+	if raw, err := annotations.ParseStringForMSE(outlierMaxEjectionPercent); err == nil {
+		value, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || value <= 0 || value > 100 {
+			return nil, fmt.Errorf("%s %s is invalid", outlierMaxEjectionPercent, raw)
+		}
+		circuitBreakerConfig.MaxEjectionPercent = int32(value)
+	}
+
+	if circuitBreakerConfig.Consecutive5xxErrors == 0 && (circuitBreakerConfig.Interval != nil ||
+		circuitBreakerConfig.BaseEjectionTime != nil || circuitBreakerConfig.MaxEjectionPercent > 0) {
+		return nil, fmt.Errorf("outlier detection requires %s", outlierConsecutive5xx)
+	}
+
+	return circuitBreakerConfig, nil
+}
+
+func (c circuitBreaker) ApplyTrafficPolicy(trafficPolicy *networking.TrafficPolicy_PortTrafficPolicy, config *Ingress) {
+	circuitBreakerConfig := config.CircuitBreaker
+	if circuitBreakerConfig == nil {
+		return
+	}
+
+	if circuitBreakerConfig.MaxConnections > 0 {
+		tcpConnectionPool(trafficPolicy).MaxConnections = circuitBreakerConfig.MaxConnections
+	}
+	if circuitBreakerConfig.MaxPendingRequests > 0 {
+		httpConnectionPool(trafficPolicy).Http1MaxPendingRequests = circuitBreakerConfig.MaxPendingRequests
+	}
+	if circuitBreakerConfig.MaxRequests > 0 {
+		httpConnectionPool(trafficPolicy).Http2MaxRequests = circuitBreakerConfig.MaxRequests
+	}
+	if circuitBreakerConfig.MaxRequestsPerConnection > 0 {
+		httpConnectionPool(trafficPolicy).MaxRequestsPerConnection = circuitBreakerConfig.MaxRequestsPerConnection
+	}
+
+	if circuitBreakerConfig.Consecutive5xxErrors > 0 {
+		trafficPolicy.OutlierDetection = &networking.OutlierDetection{
+			Consecutive_5XxErrors: &types.UInt32Value{Value: circuitBreakerConfig.Consecutive5xxErrors},
+			Interval:              circuitBreakerConfig.Interval,
+			BaseEjectionTime:      circuitBreakerConfig.BaseEjectionTime,
+			MaxEjectionPercent:    circuitBreakerConfig.MaxEjectionPercent,
+		}
+	}
+}
+
+// MergeCircuitBreaker merges the circuit breakers of ingresses sharing the same service, which
+// are ordered by creation time. Each field takes the value of the earliest ingress setting it,
+// so the result doesn't depend on the order in which the ingresses are listed.
+func MergeCircuitBreaker(configs ...*CircuitBreakerConfig) *CircuitBreakerConfig {
+	var merged *CircuitBreakerConfig
+	for _, config := range configs {
+		if config == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &CircuitBreakerConfig{}
+		}
+		if merged.MaxConnections == 0 {
+			merged.MaxConnections = config.MaxConnections
+		}
+		if merged.MaxPendingRequests == 0 {
+			merged.MaxPendingRequests = config.MaxPendingRequests
+		}
+		if merged.MaxRequests == 0 {
+			merged.MaxRequests = config.MaxRequests
+		}
+		if merged.MaxRequestsPerConnection == 0 {
+			merged.MaxRequestsPerConnection = config.MaxRequestsPerConnection
+		}
+		// The fields of outlier detection go together.
+		if merged.Consecutive5xxErrors == 0 {
+			merged.Consecutive5xxErrors = config.Consecutive5xxErrors
+			merged.Interval = config.Interval
+			merged.BaseEjectionTime = config.BaseEjectionTime
+			merged.MaxEjectionPercent = config.MaxEjectionPercent
+		}
+	}
+	return merged
+}
+
+func needCircuitBreakerConfig(annotations Annotations) bool {
+	return annotations.HasMSE(upstreamMaxConnections) ||
+		annotations.HasMSE(upstreamMaxPendingRequests) ||
+		annotations.HasMSE(upstreamMaxRequests) ||
+		annotations.HasMSE(upstreamMaxRequestsPerConnection) ||
+		annotations.HasMSE(outlierConsecutive5xx) ||
+		annotations.HasMSE(outlierInterval) ||
+		annotations.HasMSE(outlierBaseEjectionTime) ||
+		annotations.HasMSE(outlierMaxEjectionPercent)
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gogo/protobuf/types"
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+func TestCircuitBreakerParse(t *testing.T) {
+	circuitBreaker := circuitBreaker{}
+	inputCases := []struct {
+		input     map[string]string
+		expect    *CircuitBreakerConfig
+		expectErr bool
+	}{
+		{},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(upstreamMaxConnections):           "100",
+				buildMSEAnnotationKey(upstreamMaxPendingRequests):       "10",
+				buildMSEAnnotationKey(upstreamMaxRequestsPerConnection): "1",
+			},
+			expect: &CircuitBreakerConfig{
+				MaxConnections:           100,
+				MaxPendingRequests:       10,
+				MaxRequestsPerConnection: 1,
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(outlierConsecutive5xx):     "5",
+				buildMSEAnnotationKey(outlierInterval):           "10s",
+				buildMSEAnnotationKey(outlierBaseEjectionTime):   "30",
+				buildMSEAnnotationKey(outlierMaxEjectionPercent): "50",
+			},
+			expect: &CircuitBreakerConfig{
+				Consecutive5xxErrors: 5,
+				Interval:             &types.Duration{Seconds: 10},
+				BaseEjectionTime:     &types.Duration{Seconds: 30},
+				MaxEjectionPercent:   50,
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(upstreamMaxConnections): "0",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(outlierConsecutive5xx):     "5",
+				buildMSEAnnotationKey(outlierMaxEjectionPercent): "101",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(outlierInterval): "10s",
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			config := &Ingress{}
+			err := circuitBreaker.Parse(inputCase.input, config, nil)
+			if (err != nil) != inputCase.expectErr {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(inputCase.expect, config.CircuitBreaker) {
+				t.Fatal("Should be equal")
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerApplyTrafficPolicy(t *testing.T) {
+	circuitBreaker := circuitBreaker{}
+	inputCases := []struct {
+		config *Ingress
+		input  *networking.TrafficPolicy_PortTrafficPolicy
+		expect *networking.TrafficPolicy_PortTrafficPolicy
+	}{
+		{
+			config: &Ingress{},
+			input:  &networking.TrafficPolicy_PortTrafficPolicy{},
+			expect: &networking.TrafficPolicy_PortTrafficPolicy{},
+		},
+		{
+			config: &Ingress{
+				CircuitBreaker: &CircuitBreakerConfig{
+					MaxConnections:       100,
+					MaxRequests:          1000,
+					Consecutive5xxErrors: 5,
+					BaseEjectionTime:     &types.Duration{Seconds: 30},
+				},
+			},
+			input: &networking.TrafficPolicy_PortTrafficPolicy{
+				ConnectionPool: &networking.ConnectionPoolSettings{
+					Http: &networking.ConnectionPoolSettings_HTTPSettings{
+						H2UpgradePolicy: networking.ConnectionPoolSettings_HTTPSettings_UPGRADE,
+					},
+				},
+			},
+			expect: &networking.TrafficPolicy_PortTrafficPolicy{
+				ConnectionPool: &networking.ConnectionPoolSettings{
+					Tcp: &networking.ConnectionPoolSettings_TCPSettings{
+						MaxConnections: 100,
+					},
+					Http: &networking.ConnectionPoolSettings_HTTPSettings{
+						H2UpgradePolicy:  networking.ConnectionPoolSettings_HTTPSettings_UPGRADE,
+						Http2MaxRequests: 1000,
+					},
+				},
+				OutlierDetection: &networking.OutlierDetection{
+					Consecutive_5XxErrors: &types.UInt32Value{Value: 5},
+					BaseEjectionTime:      &types.Duration{Seconds: 30},
+				},
+			},
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			circuitBreaker.ApplyTrafficPolicy(inputCase.input, inputCase.config)
+			if !reflect.DeepEqual(inputCase.input, inputCase.expect) {
+				t.Fatal("Should be equal")
+			}
+		})
+	}
+}
+
+func TestMergeCircuitBreaker(t *testing.T) {
+	inputCases := []struct {
+		input  []*CircuitBreakerConfig
+		expect *CircuitBreakerConfig
+	}{
+		{
+			input: []*CircuitBreakerConfig{nil, nil},
+		},
+		{
+			input: []*CircuitBreakerConfig{
+				nil,
+				{
+					MaxConnections: 100,
+				},
+				{
+					MaxConnections:     200,
+					MaxPendingRequests: 10,
+				},
+			},
+			expect: &CircuitBreakerConfig{
+				MaxConnections:     100,
+				MaxPendingRequests: 10,
+			},
+		},
+		{
+			input: []*CircuitBreakerConfig{
+				{
+					MaxConnections: 100,
+				},
+				{
+					Consecutive5xxErrors: 5,
+					Interval:             &types.Duration{Seconds: 10},
+				},
+				{
+					Consecutive5xxErrors: 3,
+					MaxEjectionPercent:   50,
+				},
+			},
+			expect: &CircuitBreakerConfig{
+				MaxConnections:       100,
+				Consecutive5xxErrors: 5,
+				Interval:             &types.Duration{Seconds: 10},
+			},
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			if !reflect.DeepEqual(MergeCircuitBreaker(inputCase.input...), inputCase.expect) {
+				t.Fatal("Should be equal")
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	compression             = "compression"
+	compressionMinLength    = "compression-min-length"
+	compressionContentTypes = "compression-content-types"
+	compressionLevel        = "compression-level"
+
+	GzipCompression   = "gzip"
+	BrotliCompression = "br"
+)
+
+// compressionLevelRanges are the valid levels of each algorithm, which are the compression level of gzip
+// and the quality of brotli.
+var compressionLevelRanges = map[string][2]uint64{
+	GzipCompression:   {1, 9},
+	BrotliCompression: {0, 11},
+}
+
+var _ Parser = compressor{}
+
+// CompressionConfig holds the compression of responses, and the zero value of other fields
+// than algorithms means the default of envoy. The level is shared by all algorithms, so it must
+// be valid for each of them.
+type CompressionConfig struct {
+	// Algorithms are the content codings in order of preference, such as gzip and br.
+	Algorithms   []string
+	MinLength    uint32
+	ContentTypes []string
+	Level        *uint32
+}
+
+type compressor struct{}
+
+// Parse returns error if any compression annotation is invalid, and the responses are not compressed in this case.
+func (c compressor) Parse(annotations Annotations, config *Ingress, _ *GlobalContext) error {
+	if !needCompressionConfig(annotations) {
+		return nil
+	}
+
+	compressionConfig, err := parseCompressionConfig(annotations)
+	if err != nil {
+		IngressLog.Errorf("Compression within ingress %s/%s is invalid, %v", config.Namespace, config.Name, err)
+		return fmt.Errorf("invalid compression within ingress %s/%s: %v", config.Namespace, config.Name, err)
+	}
+
+	config.Compression = compressionConfig
+	return nil
+}
+
+func parseCompressionConfig(annotations Annotations) (*CompressionConfig, error) {
+	raw, err := annotations.ParseStringForMSE(compression)
+	if err != nil {
+		return nil, fmt.Errorf("%s is required", compression)
+	}
+	compressionConfig := &CompressionConfig{}
+	for _, algorithm := range splitBySeparator(strings.ToLower(raw), ",") {
+		if algorithm != GzipCompression && algorithm != BrotliCompression {
+			return nil, fmt.Errorf("%s %s is not supported", compression, algorithm)
+		}
+		if !containsString(compressionConfig.Algorithms, algorithm) {
+			compressionConfig.Algorithms = append(compressionConfig.Algorithms, algorithm)
+		}
+	}
+	if len(compressionConfig.Algorithms) == 0 {
+		return nil, fmt.Errorf("%s %s is invalid", compression, raw)
+	}
+
+	if raw, err := annotations.ParseStringForMSE(compressionMinLength); err == nil {
+		value, err := parseNginxSize(raw)
+		if err != nil || value > math.MaxUint32 {
+			return nil, fmt.Errorf("%s %s is invalid", compressionMinLength, raw)
+		}
+		compressionConfig.MinLength = uint32(value)
+	}
+
+	// The content types are separated by comma or space, like the gzip_types of nginx.
+	if raw, err := annotations.ParseStringForMSE(compressionContentTypes); err == nil {
+		compressionConfig.ContentTypes = strings.FieldsFunc(raw, func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	}
+
+	if raw, err := annotations.ParseStringForMSE(compressionLevel); err == nil {
+		value, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s is invalid", compressionLevel, raw)
+		}
+		for _, algorithm := range compressionConfig.Algorithms {
+			levelRange := compressionLevelRanges[algorithm]
+			if value < levelRange[0] || value > levelRange[1] {
+				return nil, fmt.Errorf("%s %s is invalid, which ranges from %d to %d for %s",
+					compressionLevel, raw, levelRange[0], levelRange[1], algorithm)
+			}
+		}
+		level := uint32(value)
+		compressionConfig.Level = &level
+	}
+
+	return compressionConfig, nil
+}
+
+func containsString(items []string, target string) bool {
+	for _, item := range items {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
+
+func needCompressionConfig(annotations Annotations) bool {
+	return annotations.HasMSE(compression) ||
+		annotations.HasMSE(compressionMinLength) ||
+		annotations.HasMSE(compressionContentTypes) ||
+		annotations.HasMSE(compressionLevel)
+}
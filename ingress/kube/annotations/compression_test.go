@@ -0,0 +1,136 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompressionParse(t *testing.T) {
+	compressor := compressor{}
+	levelZero := uint32(0)
+	levelFive := uint32(5)
+	levelEleven := uint32(11)
+	inputCases := []struct {
+		input     map[string]string
+		expect    *CompressionConfig
+		expectErr bool
+	}{
+		{},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(compression): "gzip",
+			},
+			expect: &CompressionConfig{
+				Algorithms: []string{GzipCompression},
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(compression):             "BR, gzip, br",
+				buildMSEAnnotationKey(compressionMinLength):    "1k",
+				buildMSEAnnotationKey(compressionContentTypes): "text/html, application/json text/css",
+				buildMSEAnnotationKey(compressionLevel):        "5",
+			},
+			expect: &CompressionConfig{
+				Algorithms:   []string{BrotliCompression, GzipCompression},
+				MinLength:    1024,
+				ContentTypes: []string{"text/html", "application/json", "text/css"},
+				Level:        &levelFive,
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(compressionLevel): "5",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(compression): "deflate",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(compression):          "gzip",
+				buildMSEAnnotationKey(compressionMinLength): "1.5k",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(compression):      "gzip",
+				buildMSEAnnotationKey(compressionLevel): "10",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(compression):      "gzip",
+				buildMSEAnnotationKey(compressionLevel): "0",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(compression):      "br",
+				buildMSEAnnotationKey(compressionLevel): "0",
+			},
+			expect: &CompressionConfig{
+				Algorithms: []string{BrotliCompression},
+				Level:      &levelZero,
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(compression):      "br",
+				buildMSEAnnotationKey(compressionLevel): "11",
+			},
+			expect: &CompressionConfig{
+				Algorithms: []string{BrotliCompression},
+				Level:      &levelEleven,
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(compression):      "br,gzip",
+				buildMSEAnnotationKey(compressionLevel): "11",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(compression):      "br",
+				buildMSEAnnotationKey(compressionLevel): "12",
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			config := &Ingress{}
+			err := compressor.Parse(inputCase.input, config, nil)
+			if (err != nil) != inputCase.expectErr {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(inputCase.expect, config.Compression) {
+				t.Fatalf("Should be %v, but actual is %v", inputCase.expect, config.Compression)
+			}
+		})
+	}
+}
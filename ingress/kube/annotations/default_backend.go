@@ -69,6 +69,11 @@ func (f fallback) Parse(annotations Annotations, config *Ingress, globalContext
 		fallBackConfig.DefaultBackend.Namespace = config.Namespace
 	}
 
+	// Subscribe service
+	if globalContext.WatchedServices != nil {
+		globalContext.WatchedServices.Insert(fallBackConfig.DefaultBackend.String())
+	}
+
 	serviceLister, exist := globalContext.ClusterServiceList[config.ClusterId]
 	if !exist {
 		IngressLog.Errorf("service lister of cluster %s doesn't exist", config.ClusterId)
@@ -0,0 +1,123 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	destinationKey = "destination"
+
+	totalWeight = 100
+)
+
+var _ Parser = destination{}
+
+// DestinationConfig routes to the services discovered from registries declared in McpBridge,
+// which overrides the backend of ingress.
+type DestinationConfig struct {
+	McpDestination []*networking.HTTPRouteDestination
+}
+
+type destination struct{}
+
+// Parse accepts the annotation value in format as below, the weight can be omitted if there is only one:
+//
+//	60% foo.static:80
+//	40% bar.default-group.public.nacos:8080
+func (d destination) Parse(annotations Annotations, config *Ingress, _ *GlobalContext) error {
+	if !needDestinationConfig(annotations) {
+		return nil
+	}
+
+	value, err := annotations.ParseStringForMSE(destinationKey)
+	if err != nil {
+		return nil
+	}
+
+	lines := splitBySeparator(value, "\n")
+	var destinations []*networking.HTTPRouteDestination
+	var weightSum int32
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		weight := int32(totalWeight)
+		address := line
+		switch len(fields) {
+		case 1:
+			if len(lines) > 1 {
+				IngressLog.Errorf("destination %s within ingress %s/%s requires weight", line, config.Namespace, config.Name)
+				return nil
+			}
+		case 2:
+			rawWeight, err := strconv.ParseInt(strings.TrimSuffix(fields[0], "%"), 10, 32)
+			if err != nil || !strings.HasSuffix(fields[0], "%") || rawWeight < 0 || rawWeight > totalWeight {
+				IngressLog.Errorf("destination %s within ingress %s/%s has invalid weight", line, config.Namespace, config.Name)
+				return nil
+			}
+			weight = int32(rawWeight)
+			address = fields[1]
+		default:
+			IngressLog.Errorf("destination %s within ingress %s/%s is invalid", line, config.Namespace, config.Name)
+			return nil
+		}
+
+		host, rawPort, err := net.SplitHostPort(address)
+		if err != nil {
+			IngressLog.Errorf("destination %s within ingress %s/%s is invalid, err %v", line, config.Namespace, config.Name, err)
+			return nil
+		}
+		port, err := strconv.ParseUint(rawPort, 10, 16)
+		if err != nil || port == 0 || host == "" {
+			IngressLog.Errorf("destination %s within ingress %s/%s has invalid port", line, config.Namespace, config.Name)
+			return nil
+		}
+
+		weightSum += weight
+		destinations = append(destinations, &networking.HTTPRouteDestination{
+			Destination: &networking.Destination{
+				Host: host,
+				Port: &networking.PortSelector{
+					Number: uint32(port),
+				},
+			},
+			Weight: weight,
+		})
+	}
+
+	if len(destinations) == 0 {
+		return nil
+	}
+	if weightSum != totalWeight {
+		IngressLog.Errorf("The sum of destination weight within ingress %s/%s should be %d, but actual is %d",
+			config.Namespace, config.Name, totalWeight, weightSum)
+		return nil
+	}
+
+	config.Destination = &DestinationConfig{
+		McpDestination: destinations,
+	}
+	return nil
+}
+
+func needDestinationConfig(annotations Annotations) bool {
+	return annotations.HasMSE(destinationKey)
+}
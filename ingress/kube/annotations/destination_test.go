@@ -0,0 +1,106 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"reflect"
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+func TestDestinationParse(t *testing.T) {
+	parser := destination{}
+	inputCases := []struct {
+		input  map[string]string
+		expect *DestinationConfig
+	}{
+		{},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(destinationKey): "foo.static:80",
+			},
+			expect: &DestinationConfig{
+				McpDestination: []*networking.HTTPRouteDestination{
+					{
+						Destination: &networking.Destination{
+							Host: "foo.static",
+							Port: &networking.PortSelector{Number: 80},
+						},
+						Weight: 100,
+					},
+				},
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(destinationKey): "60% foo.static:80\n 40%  bar.dns:8080\n",
+			},
+			expect: &DestinationConfig{
+				McpDestination: []*networking.HTTPRouteDestination{
+					{
+						Destination: &networking.Destination{
+							Host: "foo.static",
+							Port: &networking.PortSelector{Number: 80},
+						},
+						Weight: 60,
+					},
+					{
+						Destination: &networking.Destination{
+							Host: "bar.dns",
+							Port: &networking.PortSelector{Number: 8080},
+						},
+						Weight: 40,
+					},
+				},
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(destinationKey): "foo.static:80\nbar.dns:8080",
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(destinationKey): "60% foo.static:80\n30% bar.dns:8080",
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(destinationKey): "60 foo.static:80\n40% bar.dns:8080",
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(destinationKey): "foo.static",
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(destinationKey): "foo.static:0",
+			},
+		},
+	}
+
+	for _, c := range inputCases {
+		t.Run("", func(t *testing.T) {
+			config := &Ingress{}
+			_ = parser.Parse(c.input, config, nil)
+			if !reflect.DeepEqual(c.expect, config.Destination) {
+				t.Fatalf("Should be equal.")
+			}
+		})
+	}
+}
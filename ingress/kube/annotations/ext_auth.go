@@ -0,0 +1,218 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"github.com/alibaba/higress/ingress/kube/util"
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	authURL                 = "auth-url"
+	authMethod              = "auth-method"
+	authSignin              = "auth-signin"
+	authSigninRedirectParam = "auth-signin-redirect-param"
+	authResponseHeaders     = "auth-response-headers"
+	authRequestHeaders      = "auth-request-headers"
+	authTimeout             = "auth-timeout"
+
+	defaultAuthTimeout             = 5 * time.Second
+	defaultAuthSigninRedirectParam = "rd"
+)
+
+var (
+	_ Parser       = extAuth{}
+	_ RouteHandler = extAuth{}
+)
+
+// authMethods are the methods of auth requests accepted by auth-method.
+var authMethods = toSet([]string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions,
+})
+
+type ExtAuthConfig struct {
+	// The raw url of auth service
+	URL string
+	// The host and port of auth service, and the path prefix of auth requests.
+	ServiceHost string
+	ServicePort uint32
+	// Whether the auth service is called over tls.
+	ServiceTLS bool
+	PathPrefix string
+	// The method of auth requests, the method of the original request is used if empty.
+	Method  string
+	Timeout time.Duration
+	// The headers of request forwarded to auth service.
+	RequestHeaders []string
+	// The headers of auth response copied to the upstream request.
+	ResponseHeaders []string
+	// Redirect to the sign-in url when auth service returns 401.
+	SigninURL           string
+	SigninRedirectParam string
+	// The auth annotations are invalid, so all requests are rejected rather than served without auth.
+	Invalid bool
+}
+
+type extAuth struct{}
+
+func (e extAuth) Parse(annotations Annotations, config *Ingress, _ *GlobalContext) error {
+	if !needExtAuthConfig(annotations) {
+		return nil
+	}
+
+	rawURL, err := annotations.ParseStringASAP(authURL)
+	if err != nil {
+		return rejectExtAuth(config, fmt.Errorf("%s is required", authURL))
+	}
+	serviceURL, err := parseServiceURL(rawURL, config.Namespace)
+	if err != nil {
+		return rejectExtAuth(config, fmt.Errorf("auth url %s is invalid", rawURL))
+	}
+
+	extAuthConfig := &ExtAuthConfig{
+		URL:                 rawURL,
+		ServiceHost:         serviceURL.host,
+		ServicePort:         serviceURL.port,
+		ServiceTLS:          serviceURL.tls,
+		PathPrefix:          strings.TrimSuffix(serviceURL.path, "/"),
+		Timeout:             defaultAuthTimeout,
+		SigninRedirectParam: defaultAuthSigninRedirectParam,
+	}
+
+	if method, err := annotations.ParseStringASAP(authMethod); err == nil {
+		method = strings.ToUpper(method)
+		if !authMethods.Contains(method) {
+			return rejectExtAuth(config, fmt.Errorf("auth method %s is invalid", method))
+		}
+		extAuthConfig.Method = method
+	}
+
+	if rawTimeout, err := annotations.ParseStringForMSE(authTimeout); err == nil {
+		timeout, err := parseDuration(rawTimeout)
+		if err != nil || timeout <= 0 {
+			return rejectExtAuth(config, fmt.Errorf("auth timeout %s is invalid", rawTimeout))
+		}
+		extAuthConfig.Timeout = timeout
+	}
+
+	if headers, err := annotations.ParseStringForMSE(authRequestHeaders); err == nil {
+		extAuthConfig.RequestHeaders = splitBySeparator(headers, ",")
+	}
+	if headers, err := annotations.ParseStringASAP(authResponseHeaders); err == nil {
+		extAuthConfig.ResponseHeaders = splitBySeparator(headers, ",")
+	}
+
+	if signin, err := annotations.ParseStringASAP(authSignin); err == nil {
+		signinURL, err := url.Parse(signin)
+		if err != nil || signinURL.Host == "" {
+			return rejectExtAuth(config, fmt.Errorf("auth signin url %s is invalid", signin))
+		}
+		extAuthConfig.SigninURL = signin
+	}
+	if param, err := annotations.ParseStringASAP(authSigninRedirectParam); err == nil {
+		extAuthConfig.SigninRedirectParam = param
+	}
+
+	config.ExtAuth = extAuthConfig
+	return nil
+}
+
+// ApplyRoute rejects all requests of route if the auth annotations are invalid.
+func (e extAuth) ApplyRoute(route *networking.HTTPRoute, config *Ingress) {
+	if config.ExtAuth != nil && config.ExtAuth.Invalid {
+		rejectRoute(route)
+	}
+}
+
+// rejectExtAuth marks the ext auth config of ingress invalid, since serving the routes without auth on invalid
+// annotations is worse than rejecting all requests.
+func rejectExtAuth(config *Ingress, err error) error {
+	IngressLog.Errorf("Ext auth within ingress %s/%s is invalid, %v", config.Namespace, config.Name, err)
+	config.ExtAuth = &ExtAuthConfig{Invalid: true}
+	return fmt.Errorf("invalid ext auth within ingress %s/%s: %v", config.Namespace, config.Name, err)
+}
+
+// authServiceHost completes the short name of kubernetes service into the service FQDN.
+func authServiceHost(host, namespace string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if !strings.Contains(host, ".") {
+		return util.CreateServiceFQDN(namespace, host)
+	}
+	if strings.HasSuffix(host, ".svc") {
+		return host + "." + util.DefaultDomainSuffix
+	}
+	return host
+}
+
+// serviceURL is the url of a service called by envoy filters directly.
+type serviceURL struct {
+	host string
+	port uint32
+	path string
+	tls  bool
+}
+
+// parseServiceURL returns the service host, port and path of a http or https url.
+func parseServiceURL(raw, namespace string) (*serviceURL, error) {
+	parsedURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if parsedURL.Host == "" || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		return nil, fmt.Errorf("invalid url %s", raw)
+	}
+
+	result := &serviceURL{
+		host: authServiceHost(parsedURL.Hostname(), namespace),
+		path: parsedURL.Path,
+		tls:  parsedURL.Scheme == "https",
+	}
+	if rawPort := parsedURL.Port(); rawPort != "" {
+		number, err := strconv.ParseUint(rawPort, 10, 16)
+		if err != nil || number == 0 {
+			return nil, fmt.Errorf("invalid port of url %s", raw)
+		}
+		result.port = uint32(number)
+	} else if result.tls {
+		result.port = 443
+	} else {
+		result.port = 80
+	}
+	return result, nil
+}
+
+// needExtAuthConfig also returns true if auth-url is missing but the other auth annotations are set, so that
+// the routes are rejected rather than served without auth.
+func needExtAuthConfig(annotations Annotations) bool {
+	return annotations.HasASAP(authURL) ||
+		annotations.HasASAP(authMethod) ||
+		annotations.HasASAP(authSignin) ||
+		annotations.HasASAP(authSigninRedirectParam) ||
+		annotations.HasASAP(authResponseHeaders) ||
+		annotations.HasMSE(authRequestHeaders) ||
+		annotations.HasMSE(authTimeout)
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+func TestExtAuthParse(t *testing.T) {
+	extAuth := extAuth{}
+	inputCases := []struct {
+		input  map[string]string
+		expect *ExtAuthConfig
+	}{
+		{},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(authURL): "auth.default",
+			},
+			expect: &ExtAuthConfig{Invalid: true},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(authSignin): "https://login.example.com/signin",
+			},
+			expect: &ExtAuthConfig{Invalid: true},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(authURL):    "http://auth/verify/",
+				buildNginxAnnotationKey(authMethod): "CONNECT",
+			},
+			expect: &ExtAuthConfig{Invalid: true},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(authURL):   "http://auth/verify/",
+				buildMSEAnnotationKey(authTimeout): "-1s",
+			},
+			expect: &ExtAuthConfig{Invalid: true},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(authURL): "http://auth/verify/",
+			},
+			expect: &ExtAuthConfig{
+				URL:                 "http://auth/verify/",
+				ServiceHost:         "auth.foo.svc.cluster.local",
+				ServicePort:         80,
+				PathPrefix:          "/verify",
+				Timeout:             defaultAuthTimeout,
+				SigninRedirectParam: defaultAuthSigninRedirectParam,
+			},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(authURL):             "https://auth.bar.svc:8443",
+				buildNginxAnnotationKey(authMethod):          "post",
+				buildNginxAnnotationKey(authResponseHeaders): "X-User, X-Email",
+				buildNginxAnnotationKey(authSignin):          "https://login.example.com/signin",
+				buildMSEAnnotationKey(authRequestHeaders):    "Cookie,X-Token",
+				buildMSEAnnotationKey(authTimeout):           "500ms",
+			},
+			expect: &ExtAuthConfig{
+				URL:                 "https://auth.bar.svc:8443",
+				ServiceHost:         "auth.bar.svc.cluster.local",
+				ServicePort:         8443,
+				ServiceTLS:          true,
+				Method:              "POST",
+				Timeout:             500 * time.Millisecond,
+				RequestHeaders:      []string{"Cookie", "X-Token"},
+				ResponseHeaders:     []string{"X-User", "X-Email"},
+				SigninURL:           "https://login.example.com/signin",
+				SigninRedirectParam: defaultAuthSigninRedirectParam,
+			},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(authURL):                 "https://auth.example.com/check",
+				buildNginxAnnotationKey(authSignin):              "https://login.example.com/signin?app=foo",
+				buildNginxAnnotationKey(authSigninRedirectParam): "from",
+				buildMSEAnnotationKey(authTimeout):               "3",
+			},
+			expect: &ExtAuthConfig{
+				URL:                 "https://auth.example.com/check",
+				ServiceHost:         "auth.example.com",
+				ServicePort:         443,
+				ServiceTLS:          true,
+				PathPrefix:          "/check",
+				Timeout:             3 * time.Second,
+				SigninURL:           "https://login.example.com/signin?app=foo",
+				SigninRedirectParam: "from",
+			},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(authURL):    "https://auth.example.com/check",
+				buildNginxAnnotationKey(authSignin): "login",
+			},
+			expect: &ExtAuthConfig{Invalid: true},
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			config := &Ingress{
+				Meta: Meta{
+					Namespace: "foo",
+				},
+			}
+			err := extAuth.Parse(inputCase.input, config, nil)
+			if !reflect.DeepEqual(inputCase.expect, config.ExtAuth) {
+				t.Fatalf("Should be equal.")
+			}
+			if invalid := inputCase.expect != nil && inputCase.expect.Invalid; invalid != (err != nil) {
+				t.Fatalf("Unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestExtAuthApplyRoute(t *testing.T) {
+	extAuth := extAuth{}
+	inputCases := []struct {
+		config *Ingress
+		expect *networking.HTTPRoute
+	}{
+		{
+			config: &Ingress{},
+			expect: &networking.HTTPRoute{},
+		},
+		{
+			config: &Ingress{
+				ExtAuth: &ExtAuthConfig{URL: "http://auth/verify"},
+			},
+			expect: &networking.HTTPRoute{},
+		},
+		{
+			config: &Ingress{
+				ExtAuth: &ExtAuthConfig{Invalid: true},
+			},
+			expect: &networking.HTTPRoute{
+				Fault: &networking.HTTPFaultInjection{
+					Abort: &networking.HTTPFaultInjection_Abort{
+						ErrorType: &networking.HTTPFaultInjection_Abort_HttpStatus{
+							HttpStatus: 503,
+						},
+						Percentage: &networking.Percent{
+							Value: 100,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			route := &networking.HTTPRoute{}
+			extAuth.ApplyRoute(route, inputCase.config)
+			if !reflect.DeepEqual(inputCase.expect, route) {
+				t.Fatalf("Should be equal.")
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+const (
+	externalCACertificates = "external-ca-certificates"
+)
+
+var _ Parser = externalTLS{}
+
+// ExternalTLSConfig verifies the services outside the mesh called over tls by the auth annotations,
+// e.g. auth-url, jwt-jwks-uri and oidc-token-endpoint.
+type ExternalTLSConfig struct {
+	// The path of the ca certificates on gateway, which replaces the system roots.
+	CACertificates string
+}
+
+type externalTLS struct{}
+
+func (e externalTLS) Parse(annotations Annotations, config *Ingress, _ *GlobalContext) error {
+	if !needExternalTLSConfig(annotations) {
+		return nil
+	}
+
+	caCertificates, _ := annotations.ParseStringForMSE(externalCACertificates)
+	if !filepath.IsAbs(caCertificates) {
+		return fmt.Errorf("invalid external tls within ingress %s/%s: ca certificates %s is not an absolute path",
+			config.Namespace, config.Name, caCertificates)
+	}
+
+	config.ExternalTLS = &ExternalTLSConfig{
+		CACertificates: caCertificates,
+	}
+	return nil
+}
+
+func needExternalTLSConfig(annotations Annotations) bool {
+	return annotations.HasMSE(externalCACertificates)
+}
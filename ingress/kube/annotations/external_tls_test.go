@@ -0,0 +1,58 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExternalTLSParse(t *testing.T) {
+	externalTLS := externalTLS{}
+	inputCases := []struct {
+		input  map[string]string
+		expect *ExternalTLSConfig
+		err    bool
+	}{
+		{},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(externalCACertificates): "/etc/certs/ca.crt",
+			},
+			expect: &ExternalTLSConfig{
+				CACertificates: "/etc/certs/ca.crt",
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(externalCACertificates): "ca.crt",
+			},
+			err: true,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			config := &Ingress{}
+			err := externalTLS.Parse(inputCase.input, config, nil)
+			if inputCase.err != (err != nil) {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(inputCase.expect, config.ExternalTLS) {
+				t.Fatalf("Should be equal.")
+			}
+		})
+	}
+}
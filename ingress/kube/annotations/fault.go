@@ -0,0 +1,227 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/types"
+	networking "istio.io/api/networking/v1alpha3"
+
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	faultDelay           = "fault-delay"
+	faultDelayPercentage = "fault-delay-percentage"
+	faultAbortHTTPStatus = "fault-abort-http-status"
+	faultAbortGrpcStatus = "fault-abort-grpc-status"
+	faultAbortPercentage = "fault-abort-percentage"
+)
+
+var (
+	_ Parser       = fault{}
+	_ RouteHandler = fault{}
+
+	grpcStatusNames = []string{
+		"OK",
+		"CANCELLED",
+		"UNKNOWN",
+		"INVALID_ARGUMENT",
+		"DEADLINE_EXCEEDED",
+		"NOT_FOUND",
+		"ALREADY_EXISTS",
+		"PERMISSION_DENIED",
+		"RESOURCE_EXHAUSTED",
+		"FAILED_PRECONDITION",
+		"ABORTED",
+		"OUT_OF_RANGE",
+		"UNIMPLEMENTED",
+		"INTERNAL",
+		"UNAVAILABLE",
+		"DATA_LOSS",
+		"UNAUTHENTICATED",
+	}
+
+	// grpcAbortHTTPStatus is the http status of abort for each grpc status, since istio only translates the http
+	// abort into envoy route. Envoy replies the grpc requests aborted with the grpc status mapped from the http
+	// status, so only the grpc status mapped from some http status can be injected.
+	grpcAbortHTTPStatus = map[string]int32{
+		"UNKNOWN":           500,
+		"INTERNAL":          400,
+		"UNAUTHENTICATED":   401,
+		"PERMISSION_DENIED": 403,
+		"UNIMPLEMENTED":     404,
+		"UNAVAILABLE":       503,
+	}
+)
+
+type FaultConfig struct {
+	Delay           *types.Duration
+	DelayPercentage *float64
+	// The grpc status of abort is converted into the http status.
+	AbortHTTPStatus int32
+	AbortPercentage *float64
+}
+
+type fault struct{}
+
+// Parse returns error if any fault annotation is invalid, and no fault is injected in this case.
+func (f fault) Parse(annotations Annotations, config *Ingress, _ *GlobalContext) error {
+	if !needFaultConfig(annotations) {
+		return nil
+	}
+
+	faultConfig, err := parseFaultConfig(annotations)
+	if err != nil {
+		IngressLog.Errorf("Fault injection within ingress %s/%s is invalid, %v", config.Namespace, config.Name, err)
+		return fmt.Errorf("invalid fault injection within ingress %s/%s: %v", config.Namespace, config.Name, err)
+	}
+
+	config.Fault = faultConfig
+	return nil
+}
+
+func parseFaultConfig(annotations Annotations) (*FaultConfig, error) {
+	faultConfig := &FaultConfig{}
+
+	if rawDelay, err := annotations.ParseStringForMSE(faultDelay); err == nil {
+		delay, err := parseDuration(rawDelay)
+		if err != nil || delay <= 0 {
+			return nil, fmt.Errorf("delay %s is invalid", rawDelay)
+		}
+		faultConfig.Delay = types.DurationProto(delay)
+	}
+	delayPercentage, err := parsePercentage(annotations, faultDelayPercentage)
+	if err != nil {
+		return nil, err
+	}
+	if delayPercentage != nil && faultConfig.Delay == nil {
+		return nil, fmt.Errorf("delay percentage requires delay")
+	}
+	faultConfig.DelayPercentage = delayPercentage
+
+	if annotations.HasMSE(faultAbortHTTPStatus) && annotations.HasMSE(faultAbortGrpcStatus) {
+		return nil, fmt.Errorf("only one of abort http status and grpc status can be set")
+	}
+	if rawStatus, err := annotations.ParseStringForMSE(faultAbortHTTPStatus); err == nil {
+		status, err := strconv.ParseInt(rawStatus, 10, 32)
+		if err != nil || status < 200 || status >= 600 {
+			return nil, fmt.Errorf("abort http status %s is invalid", rawStatus)
+		}
+		faultConfig.AbortHTTPStatus = int32(status)
+	}
+	if rawStatus, err := annotations.ParseStringForMSE(faultAbortGrpcStatus); err == nil {
+		status, err := parseGrpcStatus(rawStatus)
+		if err != nil {
+			return nil, err
+		}
+		httpStatus, ok := grpcAbortHTTPStatus[status]
+		if !ok {
+			return nil, fmt.Errorf("abort grpc status %s is not supported", rawStatus)
+		}
+		faultConfig.AbortHTTPStatus = httpStatus
+	}
+	abortPercentage, err := parsePercentage(annotations, faultAbortPercentage)
+	if err != nil {
+		return nil, err
+	}
+	if abortPercentage != nil && faultConfig.AbortHTTPStatus == 0 {
+		return nil, fmt.Errorf("abort percentage requires abort status")
+	}
+	faultConfig.AbortPercentage = abortPercentage
+
+	return faultConfig, nil
+}
+
+func parsePercentage(annotations Annotations, key string) (*float64, error) {
+	raw, err := annotations.ParseStringForMSE(key)
+	if err != nil {
+		return nil, nil
+	}
+	percentage, err := strconv.ParseFloat(raw, 64)
+	if err != nil || percentage < 0 || percentage > 100 {
+		return nil, fmt.Errorf("%s %s is invalid", key, raw)
+	}
+	return &percentage, nil
+}
+
+// parseGrpcStatus accepts the code or the name of grpc status, such as 14 and UNAVAILABLE.
+func parseGrpcStatus(raw string) (string, error) {
+	if code, err := strconv.Atoi(raw); err == nil {
+		if code < 0 || code >= len(grpcStatusNames) {
+			return "", fmt.Errorf("abort grpc status %s is invalid", raw)
+		}
+		return grpcStatusNames[code], nil
+	}
+	name := strings.ToUpper(raw)
+	for _, status := range grpcStatusNames {
+		if status == name {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("abort grpc status %s is invalid", raw)
+}
+
+func (f fault) ApplyRoute(route *networking.HTTPRoute, config *Ingress) {
+	faultConfig := config.Fault
+	if faultConfig == nil {
+		return
+	}
+
+	faultInjection := &networking.HTTPFaultInjection{}
+	if faultConfig.Delay != nil {
+		faultInjection.Delay = &networking.HTTPFaultInjection_Delay{
+			HttpDelayType: &networking.HTTPFaultInjection_Delay_FixedDelay{
+				FixedDelay: faultConfig.Delay,
+			},
+			Percentage: toPercent(faultConfig.DelayPercentage),
+		}
+	}
+	if faultConfig.AbortHTTPStatus != 0 {
+		faultInjection.Abort = &networking.HTTPFaultInjection_Abort{
+			ErrorType: &networking.HTTPFaultInjection_Abort_HttpStatus{
+				HttpStatus: faultConfig.AbortHTTPStatus,
+			},
+			Percentage: toPercent(faultConfig.AbortPercentage),
+		}
+	}
+	if faultInjection.Delay == nil && faultInjection.Abort == nil {
+		return
+	}
+	route.Fault = faultInjection
+}
+
+// toPercent returns 100 percent if the percentage is not set, since the missing one may be treated as zero.
+func toPercent(percentage *float64) *networking.Percent {
+	if percentage == nil {
+		return &networking.Percent{
+			Value: 100,
+		}
+	}
+	return &networking.Percent{
+		Value: *percentage,
+	}
+}
+
+func needFaultConfig(annotations Annotations) bool {
+	return annotations.HasMSE(faultDelay) ||
+		annotations.HasMSE(faultDelayPercentage) ||
+		annotations.HasMSE(faultAbortHTTPStatus) ||
+		annotations.HasMSE(faultAbortGrpcStatus) ||
+		annotations.HasMSE(faultAbortPercentage)
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gogo/protobuf/types"
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+func TestFaultParse(t *testing.T) {
+	fault := fault{}
+	ten := 10.0
+	half := 50.0
+	inputCases := []struct {
+		input     map[string]string
+		expect    *FaultConfig
+		expectErr bool
+	}{
+		{},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(faultDelay):           "500ms",
+				buildMSEAnnotationKey(faultDelayPercentage): "10",
+			},
+			expect: &FaultConfig{
+				Delay:           &types.Duration{Nanos: 500000000},
+				DelayPercentage: &ten,
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(faultDelay):           "2",
+				buildMSEAnnotationKey(faultAbortHTTPStatus): "503",
+				buildMSEAnnotationKey(faultAbortPercentage): "50",
+			},
+			expect: &FaultConfig{
+				Delay:           &types.Duration{Seconds: 2},
+				AbortHTTPStatus: 503,
+				AbortPercentage: &half,
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(faultAbortGrpcStatus): "14",
+			},
+			expect: &FaultConfig{
+				AbortHTTPStatus: 503,
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(faultAbortGrpcStatus): "permission_denied",
+			},
+			expect: &FaultConfig{
+				AbortHTTPStatus: 403,
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(faultAbortGrpcStatus): "RESOURCE_EXHAUSTED",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(faultDelay): "abc",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(faultDelayPercentage): "10",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(faultDelay):           "1s",
+				buildMSEAnnotationKey(faultDelayPercentage): "101",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(faultAbortHTTPStatus): "700",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(faultAbortHTTPStatus): "503",
+				buildMSEAnnotationKey(faultAbortGrpcStatus): "UNAVAILABLE",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(faultAbortGrpcStatus): "17",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(faultAbortPercentage): "50",
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			config := &Ingress{}
+			err := fault.Parse(inputCase.input, config, nil)
+			if (err != nil) != inputCase.expectErr {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(inputCase.expect, config.Fault) {
+				t.Fatal("Should be equal")
+			}
+		})
+	}
+}
+
+func TestFaultApplyRoute(t *testing.T) {
+	fault := fault{}
+	ten := 10.0
+	inputCases := []struct {
+		config *Ingress
+		input  *networking.HTTPRoute
+		expect *networking.HTTPRoute
+	}{
+		{
+			config: &Ingress{},
+			input:  &networking.HTTPRoute{},
+			expect: &networking.HTTPRoute{},
+		},
+		{
+			config: &Ingress{
+				Fault: &FaultConfig{
+					Delay:           &types.Duration{Seconds: 2},
+					DelayPercentage: &ten,
+					AbortHTTPStatus: 503,
+				},
+			},
+			input: &networking.HTTPRoute{},
+			expect: &networking.HTTPRoute{
+				Fault: &networking.HTTPFaultInjection{
+					Delay: &networking.HTTPFaultInjection_Delay{
+						HttpDelayType: &networking.HTTPFaultInjection_Delay_FixedDelay{
+							FixedDelay: &types.Duration{Seconds: 2},
+						},
+						Percentage: &networking.Percent{Value: 10},
+					},
+					Abort: &networking.HTTPFaultInjection_Abort{
+						ErrorType: &networking.HTTPFaultInjection_Abort_HttpStatus{
+							HttpStatus: 503,
+						},
+						Percentage: &networking.Percent{Value: 100},
+					},
+				},
+			},
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			fault.ApplyRoute(inputCase.input, inputCase.config)
+			if !reflect.DeepEqual(inputCase.input, inputCase.expect) {
+				t.Fatal("Should be equal")
+			}
+		})
+	}
+}
+
+// TestFaultAbortGrpcStatus checks that the grpc status annotation ends up as the http abort of route, which is the
+// only abort translated into envoy route by istio.
+func TestFaultAbortGrpcStatus(t *testing.T) {
+	fault := fault{}
+	config := &Ingress{}
+	err := fault.Parse(map[string]string{
+		buildMSEAnnotationKey(faultAbortGrpcStatus): "UNAVAILABLE",
+		buildMSEAnnotationKey(faultAbortPercentage): "10",
+	}, config, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	route := &networking.HTTPRoute{}
+	fault.ApplyRoute(route, config)
+	expect := &networking.HTTPRoute{
+		Fault: &networking.HTTPFaultInjection{
+			Abort: &networking.HTTPFaultInjection_Abort{
+				ErrorType: &networking.HTTPFaultInjection_Abort_HttpStatus{
+					HttpStatus: 503,
+				},
+				Percentage: &networking.Percent{Value: 10},
+			},
+		},
+	}
+	if !reflect.DeepEqual(expect, route) {
+		t.Fatal("Should be equal")
+	}
+}
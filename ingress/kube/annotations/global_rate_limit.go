@@ -0,0 +1,146 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	globalLimitRPM     = "global-limit-rpm"
+	globalLimitRPS     = "global-limit-rps"
+	globalLimitBy      = "global-limit-by"
+	globalLimitService = "global-limit-service"
+
+	defaultRateLimitServicePort = 8081
+
+	RateLimitUnitSecond = "second"
+	RateLimitUnitMinute = "minute"
+
+	RateLimitByRemoteAddr = "remote_addr"
+	RateLimitByHeader     = "header"
+	RateLimitByPath       = "path"
+	RateLimitByConsumer   = "consumer"
+
+	// The consumer is the subject of jwt verified by the jwt annotations.
+	consumerIndicator = "$consumer"
+	consumerClaim     = "sub"
+)
+
+var _ Parser = globalRateLimit{}
+
+type RateLimitKey struct {
+	// One of remote_addr, header, path and consumer.
+	Type string
+	// The request header, or the jwt claim of consumer.
+	Name string
+}
+
+type GlobalRateLimitConfig struct {
+	// The host and port of rate limit service which implements the envoy rls protocol.
+	ServiceHost string
+	ServicePort uint32
+	// The requests allowed per unit of time, shared by all gateway instances.
+	RequestsPerUnit uint32
+	Unit            string
+	// Requests are counted separately by the values of keys, and by route only if no keys.
+	Keys []RateLimitKey
+}
+
+type globalRateLimit struct{}
+
+func (g globalRateLimit) Parse(annotations Annotations, config *Ingress, _ *GlobalContext) error {
+	if !needGlobalRateLimitConfig(annotations) {
+		return nil
+	}
+
+	globalConfig := &GlobalRateLimitConfig{}
+	if rpm, err := annotations.ParseUint32ForMSE(globalLimitRPM); err == nil {
+		globalConfig.RequestsPerUnit = rpm
+		globalConfig.Unit = RateLimitUnitMinute
+	} else if rps, err := annotations.ParseUint32ForMSE(globalLimitRPS); err == nil {
+		globalConfig.RequestsPerUnit = rps
+		globalConfig.Unit = RateLimitUnitSecond
+	} else {
+		IngressLog.Errorf("Global rate limit within ingress %s/%s is invalid", config.Namespace, config.Name)
+		return nil
+	}
+
+	service, err := annotations.ParseStringForMSE(globalLimitService)
+	if err != nil {
+		IngressLog.Errorf("Global rate limit service within ingress %s/%s is missing", config.Namespace, config.Name)
+		return nil
+	}
+	globalConfig.ServiceHost, globalConfig.ServicePort, err = parseServiceAddress(service, config.Namespace, defaultRateLimitServicePort)
+	if err != nil {
+		IngressLog.Errorf("Global rate limit service %s within ingress %s/%s is invalid", service, config.Namespace, config.Name)
+		return nil
+	}
+
+	if by, err := annotations.ParseStringForMSE(globalLimitBy); err == nil {
+		for _, raw := range splitBySeparator(by, ",") {
+			key, err := parseRateLimitKey(raw)
+			if err != nil {
+				IngressLog.Errorf("Global rate limit key %s within ingress %s/%s is invalid", raw, config.Namespace, config.Name)
+				return nil
+			}
+			globalConfig.Keys = append(globalConfig.Keys, key)
+		}
+	}
+
+	config.GlobalRateLimit = globalConfig
+	return nil
+}
+
+// parseRateLimitKey accepts the variables such as $remote_addr, $request_uri, $http_x_user and $consumer.
+func parseRateLimitKey(raw string) (RateLimitKey, error) {
+	switch {
+	case raw == "$remote_addr":
+		return RateLimitKey{Type: RateLimitByRemoteAddr}, nil
+	case raw == "$request_uri":
+		return RateLimitKey{Type: RateLimitByPath}, nil
+	case raw == consumerIndicator:
+		return RateLimitKey{Type: RateLimitByConsumer, Name: consumerClaim}, nil
+	case strings.HasPrefix(raw, headerIndicator) && raw != headerIndicator:
+		return RateLimitKey{Type: RateLimitByHeader, Name: strings.TrimPrefix(raw, headerIndicator)}, nil
+	}
+	return RateLimitKey{}, fmt.Errorf("unsupported rate limit key %s", raw)
+}
+
+// parseServiceAddress returns the service host and port of address such as svc, svc.ns:8080 and 10.0.0.1.
+func parseServiceAddress(address, namespace string, defaultPort uint32) (string, uint32, error) {
+	host, port := address, defaultPort
+	if h, p, err := net.SplitHostPort(address); err == nil {
+		number, err := strconv.ParseUint(p, 10, 16)
+		if err != nil || number == 0 {
+			return "", 0, fmt.Errorf("invalid port of address %s", address)
+		}
+		host, port = h, uint32(number)
+	}
+	if host == "" || strings.ContainsAny(host, "/:") {
+		return "", 0, fmt.Errorf("invalid address %s", address)
+	}
+	return authServiceHost(host, namespace), port, nil
+}
+
+func needGlobalRateLimitConfig(annotations Annotations) bool {
+	return annotations.HasMSE(globalLimitRPM) ||
+		annotations.HasMSE(globalLimitRPS)
+}
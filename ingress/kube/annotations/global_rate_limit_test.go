@@ -0,0 +1,106 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGlobalRateLimitParse(t *testing.T) {
+	globalRateLimit := globalRateLimit{}
+	inputCases := []struct {
+		input  map[string]string
+		expect *GlobalRateLimitConfig
+	}{
+		{},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(globalLimitRPM): "100",
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(globalLimitRPM):     "100",
+				buildMSEAnnotationKey(globalLimitService): "ratelimit",
+			},
+			expect: &GlobalRateLimitConfig{
+				ServiceHost:     "ratelimit.default.svc.cluster.local",
+				ServicePort:     8081,
+				RequestsPerUnit: 100,
+				Unit:            RateLimitUnitMinute,
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(globalLimitRPM):     "100",
+				buildMSEAnnotationKey(globalLimitRPS):     "10",
+				buildMSEAnnotationKey(globalLimitService): "ratelimit.higress-system:9091",
+				buildMSEAnnotationKey(globalLimitBy):      "$remote_addr, $http_x-user-id, $request_uri, $consumer",
+			},
+			expect: &GlobalRateLimitConfig{
+				ServiceHost:     "ratelimit.higress-system",
+				ServicePort:     9091,
+				RequestsPerUnit: 100,
+				Unit:            RateLimitUnitMinute,
+				Keys: []RateLimitKey{
+					{Type: RateLimitByRemoteAddr},
+					{Type: RateLimitByHeader, Name: "x-user-id"},
+					{Type: RateLimitByPath},
+					{Type: RateLimitByConsumer, Name: "sub"},
+				},
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(globalLimitRPS):     "10",
+				buildMSEAnnotationKey(globalLimitService): "10.0.0.1",
+			},
+			expect: &GlobalRateLimitConfig{
+				ServiceHost:     "10.0.0.1",
+				ServicePort:     8081,
+				RequestsPerUnit: 10,
+				Unit:            RateLimitUnitSecond,
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(globalLimitRPS):     "10",
+				buildMSEAnnotationKey(globalLimitService): "ratelimit:abc",
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(globalLimitRPS):     "10",
+				buildMSEAnnotationKey(globalLimitService): "ratelimit",
+				buildMSEAnnotationKey(globalLimitBy):      "$host",
+			},
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			config := &Ingress{
+				Meta: Meta{
+					Namespace: "default",
+				},
+			}
+			_ = globalRateLimit.Parse(inputCase.input, config, nil)
+			if !reflect.DeepEqual(inputCase.expect, config.GlobalRateLimit) {
+				t.Fatalf("Should be equal.")
+			}
+		})
+	}
+}
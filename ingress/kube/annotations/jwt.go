@@ -0,0 +1,205 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"fmt"
+	"strings"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"github.com/alibaba/higress/ingress/kube/util"
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	jwtIssuer         = "jwt-issuer"
+	jwtAudiences      = "jwt-audiences"
+	jwtJwksSecret     = "jwt-jwks-secret"
+	jwtJwksURI        = "jwt-jwks-uri"
+	jwtClaimToHeaders = "jwt-claim-to-headers"
+	jwtOptional       = "jwt-optional"
+
+	jwksSecretKey = "jwks"
+
+	JwtAuthnFilterName = "envoy.filters.http.jwt_authn"
+	// JwtPayloadMetadataKey is the key of verified jwt payload within the dynamic metadata of jwt authn filter.
+	JwtPayloadMetadataKey = "jwt_payload"
+)
+
+var (
+	_ Parser       = jwt{}
+	_ RouteHandler = jwt{}
+)
+
+type ClaimToHeader struct {
+	Claim  string
+	Header string
+}
+
+type JwtConfig struct {
+	Issuer    string
+	Audiences []string
+	// The jwks is inlined if it comes from secret.
+	Jwks       string
+	JwksSecret util.ClusterNamespacedName
+	// The raw uri of remote jwks, and the host and port of the service serving it.
+	JwksURI         string
+	JwksServiceHost string
+	JwksServicePort uint32
+	JwksServiceTLS  bool
+	// The claims of verified jwt forwarded to upstream as request headers.
+	ClaimToHeaders []ClaimToHeader
+	// Requests without jwt are allowed if optional, but invalid ones are still rejected.
+	Optional bool
+	// The jwt annotations are invalid, so all requests are rejected rather than left unauthenticated.
+	Invalid bool
+}
+
+type jwt struct{}
+
+func (j jwt) Parse(annotations Annotations, config *Ingress, globalContext *GlobalContext) error {
+	if !needJwtConfig(annotations) {
+		return nil
+	}
+
+	issuer, err := annotations.ParseStringForMSE(jwtIssuer)
+	if err != nil || issuer == "" {
+		IngressLog.Errorf("Jwt issuer within ingress %s/%s is invalid", config.Namespace, config.Name)
+		return rejectJwt(config)
+	}
+	jwtConfig := &JwtConfig{
+		Issuer: issuer,
+	}
+	if audiences, err := annotations.ParseStringForMSE(jwtAudiences); err == nil {
+		jwtConfig.Audiences = splitBySeparator(audiences, ",")
+	}
+	jwtConfig.Optional, _ = annotations.ParseBoolForMSE(jwtOptional)
+
+	if rawClaims, err := annotations.ParseStringForMSE(jwtClaimToHeaders); err == nil {
+		claimToHeaders, err := parseClaimToHeaders(rawClaims)
+		if err != nil {
+			IngressLog.Errorf("Jwt claim to headers within ingress %s/%s is invalid, err %v", config.Namespace, config.Name, err)
+			return rejectJwt(config)
+		}
+		jwtConfig.ClaimToHeaders = claimToHeaders
+	}
+
+	if secretName, err := annotations.ParseStringForMSE(jwtJwksSecret); err == nil {
+		namespaced := util.SplitNamespacedName(secretName)
+		if namespaced.Name == "" {
+			IngressLog.Errorf("Jwks secret name within ingress %s/%s is invalid", config.Namespace, config.Name)
+			return rejectJwt(config)
+		}
+		if namespaced.Namespace == "" {
+			namespaced.Namespace = config.Namespace
+		}
+		configKey := util.ClusterNamespacedName{
+			NamespacedName: namespaced,
+			ClusterId:      config.ClusterId,
+		}
+		jwtConfig.JwksSecret = configKey
+
+		// Subscribe secret, so the rotation of jwks will trigger a push.
+		globalContext.WatchedSecrets.Insert(configKey.String())
+
+		secretLister, exist := globalContext.ClusterSecretLister[config.ClusterId]
+		if !exist {
+			IngressLog.Errorf("secret lister of cluster %s doesn't exist", config.ClusterId)
+			return rejectJwt(config)
+		}
+		jwksSecret, err := secretLister.Secrets(namespaced.Namespace).Get(namespaced.Name)
+		if err != nil {
+			IngressLog.Errorf("Secret %s within ingress %s/%s is not found",
+				namespaced.String(), config.Namespace, config.Name)
+			return rejectJwt(config)
+		}
+		jwks, exist := jwksSecret.Data[jwksSecretKey]
+		if !exist || len(jwks) == 0 {
+			IngressLog.Errorf("Secret %s within ingress %s/%s must have the jwks key in data",
+				namespaced.String(), config.Namespace, config.Name)
+			return rejectJwt(config)
+		}
+		jwtConfig.Jwks = string(jwks)
+	} else if rawURI, err := annotations.ParseStringForMSE(jwtJwksURI); err == nil {
+		jwksURL, err := parseServiceURL(rawURI, config.Namespace)
+		if err != nil {
+			IngressLog.Errorf("Jwks uri %s within ingress %s/%s is invalid", rawURI, config.Namespace, config.Name)
+			return rejectJwt(config)
+		}
+		jwtConfig.JwksURI = rawURI
+		jwtConfig.JwksServiceHost = jwksURL.host
+		jwtConfig.JwksServicePort = jwksURL.port
+		jwtConfig.JwksServiceTLS = jwksURL.tls
+	} else {
+		IngressLog.Errorf("Jwks secret or uri is required within ingress %s/%s", config.Namespace, config.Name)
+		return rejectJwt(config)
+	}
+
+	config.Jwt = jwtConfig
+	return nil
+}
+
+// ApplyRoute forwards the claims through the dynamic metadata written by jwt authn filter.
+// The headers from client are removed first, since they are kept when the claim is absent.
+func (j jwt) ApplyRoute(route *networking.HTTPRoute, config *Ingress) {
+	jwtConfig := config.Jwt
+	if jwtConfig == nil || len(jwtConfig.ClaimToHeaders) == 0 {
+		return
+	}
+
+	if route.Headers == nil {
+		route.Headers = &networking.Headers{}
+	}
+	if route.Headers.Request == nil {
+		route.Headers.Request = &networking.Headers_HeaderOperations{}
+	}
+	request := route.Headers.Request
+	if request.Set == nil {
+		request.Set = map[string]string{}
+	}
+	for _, claimToHeader := range jwtConfig.ClaimToHeaders {
+		request.Remove = append(request.Remove, claimToHeader.Header)
+		request.Set[claimToHeader.Header] = fmt.Sprintf("%%DYNAMIC_METADATA(%s:%s:%s)%%",
+			JwtAuthnFilterName, JwtPayloadMetadataKey, strings.ReplaceAll(claimToHeader.Claim, ".", ":"))
+	}
+}
+
+// parseClaimToHeaders parses rules like sub:x-user-id,profile.email:x-email, where nested claims are joined by dot.
+func parseClaimToHeaders(raw string) ([]ClaimToHeader, error) {
+	var result []ClaimToHeader
+	for _, item := range splitBySeparator(raw, ",") {
+		parts := strings.Split(item, ":")
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid claim to header %s", item)
+		}
+		result = append(result, ClaimToHeader{
+			Claim:  strings.TrimSpace(parts[0]),
+			Header: strings.ToLower(strings.TrimSpace(parts[1])),
+		})
+	}
+	return result, nil
+}
+
+// rejectJwt marks the jwt config of ingress invalid, since leaving the routes open on invalid annotations
+// is worse than rejecting all requests.
+func rejectJwt(config *Ingress) error {
+	config.Jwt = &JwtConfig{Invalid: true}
+	return nil
+}
+
+func needJwtConfig(annotations Annotations) bool {
+	return annotations.HasMSE(jwtIssuer)
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"reflect"
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alibaba/higress/ingress/kube/util"
+)
+
+func TestJwtParse(t *testing.T) {
+	jwt := jwt{}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "jwks",
+			Namespace: "foo",
+		},
+		Data: map[string][]byte{
+			"jwks": []byte(`{"keys":[]}`),
+		},
+	}
+	inputCases := []struct {
+		input         map[string]string
+		expect        *JwtConfig
+		watchedSecret string
+	}{
+		{},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(jwtIssuer): "https://issuer.example.com",
+			},
+			expect: &JwtConfig{Invalid: true},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(jwtIssuer):         "https://issuer.example.com",
+				buildMSEAnnotationKey(jwtAudiences):      "a, b",
+				buildMSEAnnotationKey(jwtJwksSecret):     "foo/jwks",
+				buildMSEAnnotationKey(jwtClaimToHeaders): "sub:X-User-Id,profile.email:x-email",
+				buildMSEAnnotationKey(jwtOptional):       "true",
+			},
+			expect: &JwtConfig{
+				Issuer:    "https://issuer.example.com",
+				Audiences: []string{"a", "b"},
+				Jwks:      `{"keys":[]}`,
+				JwksSecret: util.ClusterNamespacedName{
+					NamespacedName: model.NamespacedName{
+						Namespace: "foo",
+						Name:      "jwks",
+					},
+					ClusterId: "cluster",
+				},
+				ClaimToHeaders: []ClaimToHeader{
+					{Claim: "sub", Header: "x-user-id"},
+					{Claim: "profile.email", Header: "x-email"},
+				},
+				Optional: true,
+			},
+			watchedSecret: "cluster/foo/jwks",
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(jwtIssuer):  "https://issuer.example.com",
+				buildMSEAnnotationKey(jwtJwksURI): "http://jwks/.well-known/jwks.json",
+			},
+			expect: &JwtConfig{
+				Issuer:          "https://issuer.example.com",
+				JwksURI:         "http://jwks/.well-known/jwks.json",
+				JwksServiceHost: "jwks.default.svc.cluster.local",
+				JwksServicePort: 80,
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(jwtIssuer):         "https://issuer.example.com",
+				buildMSEAnnotationKey(jwtJwksURI):        "http://jwks/.well-known/jwks.json",
+				buildMSEAnnotationKey(jwtClaimToHeaders): "sub",
+			},
+			expect: &JwtConfig{Invalid: true},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(jwtIssuer):  "https://issuer.example.com",
+				buildMSEAnnotationKey(jwtJwksURI): "https://issuer.example.com/jwks.json",
+			},
+			expect: &JwtConfig{
+				Issuer:          "https://issuer.example.com",
+				JwksURI:         "https://issuer.example.com/jwks.json",
+				JwksServiceHost: "issuer.example.com",
+				JwksServicePort: 443,
+				JwksServiceTLS:  true,
+			},
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			config := &Ingress{
+				Meta: Meta{
+					Namespace: "default",
+					ClusterId: "cluster",
+				},
+			}
+
+			globalContext, cancel := initGlobalContext(secret)
+			defer cancel()
+
+			_ = jwt.Parse(inputCase.input, config, globalContext)
+			if !reflect.DeepEqual(inputCase.expect, config.Jwt) {
+				t.Fatalf("Should be equal.")
+			}
+
+			if inputCase.watchedSecret != "" {
+				if !globalContext.WatchedSecrets.Contains(inputCase.watchedSecret) {
+					t.Fatalf("Should watch secret %s", inputCase.watchedSecret)
+				}
+			}
+		})
+	}
+}
+
+func TestJwtApplyRoute(t *testing.T) {
+	jwt := jwt{}
+	inputCases := []struct {
+		config *Ingress
+		input  *networking.HTTPRoute
+		expect *networking.HTTPRoute
+	}{
+		{
+			config: &Ingress{},
+			input:  &networking.HTTPRoute{},
+			expect: &networking.HTTPRoute{},
+		},
+		{
+			config: &Ingress{
+				Jwt: &JwtConfig{
+					ClaimToHeaders: []ClaimToHeader{
+						{Claim: "profile.email", Header: "x-email"},
+					},
+				},
+			},
+			input: &networking.HTTPRoute{
+				Headers: &networking.Headers{
+					Request: &networking.Headers_HeaderOperations{
+						Remove: []string{"x-foo"},
+					},
+				},
+			},
+			expect: &networking.HTTPRoute{
+				Headers: &networking.Headers{
+					Request: &networking.Headers_HeaderOperations{
+						Set: map[string]string{
+							"x-email": "%DYNAMIC_METADATA(envoy.filters.http.jwt_authn:jwt_payload:profile:email)%",
+						},
+						Remove: []string{"x-foo", "x-email"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			jwt.ApplyRoute(inputCase.input, inputCase.config)
+			if !reflect.DeepEqual(inputCase.input, inputCase.expect) {
+				t.Fatalf("Should be equal.")
+			}
+		})
+	}
+}
@@ -28,7 +28,8 @@ const (
 	affinity = "affinity"
 	// affinityMode in mse ingress always be balanced
 	affinityMode = "affinity-mode"
-	// affinityCanaryBehavior in mse ingress always be legacy
+	// affinityCanaryBehavior in mse ingress is legacy by default, and sticky pins the users to
+	// the canary or normal service chosen by weight at the first time.
 	affinityCanaryBehavior = "affinity-canary-behavior"
 	sessionCookieName      = "session-cookie-name"
 	sessionCookiePath      = "session-cookie-path"
@@ -42,6 +43,8 @@ const (
 
 	defaultAffinityCookieName = "INGRESSCOOKIE"
 	defaultAffinityCookiePath = "/"
+
+	stickyCanaryBehavior = "sticky"
 )
 
 var (
@@ -61,9 +64,10 @@ type consistentHashByOther struct {
 }
 
 type consistentHashByCookie struct {
-	name string
-	path string
-	age  *types.Duration
+	name         string
+	path         string
+	age          *types.Duration
+	canarySticky bool
 }
 
 type LoadBalanceConfig struct {
@@ -108,23 +112,15 @@ func (l loadBalance) Parse(annotations Annotations, config *Ingress, _ *GlobalCo
 				Seconds: int64(age),
 			}
 		}
+		if behavior, err := annotations.ParseStringASAP(affinityCanaryBehavior); err == nil {
+			loadBalanceConfig.cookie.canarySticky = behavior == stickyCanaryBehavior
+		}
 	} else if isOtherAffinity(annotations) {
-		if key, err := annotations.ParseStringASAP(upstreamHashBy); err == nil &&
-			strings.HasPrefix(key, varIndicator) {
-			value, exist := headersMapping[key]
-			if exist {
+		if key, err := annotations.ParseStringASAP(upstreamHashBy); err == nil {
+			if header, queryParam := parseVariable(key); header != "" || queryParam != "" {
 				loadBalanceConfig.other = &consistentHashByOther{
-					header: value,
-				}
-			} else {
-				if strings.HasPrefix(key, headerIndicator) {
-					loadBalanceConfig.other = &consistentHashByOther{
-						header: strings.TrimPrefix(key, headerIndicator),
-					}
-				} else if strings.HasPrefix(key, queryParamIndicator) {
-					loadBalanceConfig.other = &consistentHashByOther{
-						queryParam: strings.TrimPrefix(key, queryParamIndicator),
-					}
+					header:     header,
+					queryParam: queryParam,
 				}
 			}
 		}
@@ -194,6 +190,24 @@ func (l loadBalance) ApplyTrafficPolicy(trafficPolicy *networking.TrafficPolicy_
 	}
 }
 
+// parseVariable returns the request header or query parameter referred by the nginx variable,
+// such as $remote_addr, $http_x_user and $arg_id.
+func parseVariable(key string) (header string, queryParam string) {
+	if !strings.HasPrefix(key, varIndicator) {
+		return "", ""
+	}
+	if value, exist := headersMapping[key]; exist {
+		return value, ""
+	}
+	if strings.HasPrefix(key, headerIndicator) {
+		return strings.TrimPrefix(key, headerIndicator), ""
+	}
+	if strings.HasPrefix(key, queryParamIndicator) {
+		return "", strings.TrimPrefix(key, queryParamIndicator)
+	}
+	return "", ""
+}
+
 func isCookieAffinity(annotations Annotations) bool {
 	return annotations.HasASAP(affinity) ||
 		annotations.HasASAP(sessionCookieName) ||
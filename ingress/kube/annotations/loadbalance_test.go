@@ -77,6 +77,20 @@ func TestLoadBalanceParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(affinity):               "cookie",
+				buildNginxAnnotationKey(affinityCanaryBehavior): "sticky",
+			},
+			expect: &LoadBalanceConfig{
+				cookie: &consistentHashByCookie{
+					name:         defaultAffinityCookieName,
+					path:         defaultAffinityCookiePath,
+					age:          &types.Duration{},
+					canarySticky: true,
+				},
+			},
+		},
 		{
 			input: map[string]string{
 				buildNginxAnnotationKey(upstreamHashBy): "$request_uri",
@@ -15,16 +15,31 @@
 package annotations
 
 import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
 	"github.com/gogo/protobuf/types"
 
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/mseingress"
+
+	. "github.com/alibaba/higress/ingress/log"
 )
 
 const (
 	limitRPM             = "route-limit-rpm"
 	limitRPS             = "route-limit-rps"
 	limitBurstMultiplier = "route-limit-burst-multiplier"
+	// Requests are counted by the buckets of their key values. Envoy only supports the buckets of known values,
+	// so route-limit-by requires route-limit-by-values listing the limit of each value, such as
+	// "tenant-a:100, tenant-b:10". Requests of the other values share the bucket of route. For $remote_addr,
+	// the values are the addresses of clients, which are matched against the trusted address of downstream.
+	limitBy         = "route-limit-by"
+	limitByValues   = "route-limit-by-values"
+	limitStatusCode = "route-limit-status-code"
+	limitBody       = "route-limit-body"
 
 	defaultBurstMultiplier = 5
 	defaultStatusCode      = 503
@@ -43,10 +58,43 @@ var (
 	}
 )
 
-type localRateLimitConfig struct {
+type LocalRateLimitConfig struct {
 	TokensPerFill uint32
 	MaxTokens     uint32
 	FillInterval  *types.Duration
+	// The status code of limited requests, and 503 is used if zero.
+	StatusCode uint32
+	// The response body of limited requests, and the default one of envoy is used if empty.
+	Body string
+	// Requests are limited by the bucket of their key value, and by the bucket of route if no bucket matches.
+	Key *LocalRateLimitKey
+}
+
+type LocalRateLimitKey struct {
+	// Only one of remote address, header and query parameter is set.
+	RemoteAddress bool
+	Header        string
+	QueryParam    string
+	Buckets       []LocalRateLimitBucket
+}
+
+type LocalRateLimitBucket struct {
+	Value         string
+	TokensPerFill uint32
+	MaxTokens     uint32
+}
+
+func (l *LocalRateLimitConfig) GetStatusCode() uint32 {
+	if l.StatusCode == 0 {
+		return defaultStatusCode
+	}
+	return l.StatusCode
+}
+
+// NeedEnvoyFilter returns true if the local rate limit can't be applied by the route filter,
+// since it doesn't support the key and the response body.
+func (l *LocalRateLimitConfig) NeedEnvoyFilter() bool {
+	return l.Key != nil || l.Body != ""
 }
 
 type localRateLimit struct{}
@@ -56,9 +104,9 @@ func (l localRateLimit) Parse(annotations Annotations, config *Ingress, _ *Globa
 		return nil
 	}
 
-	var local *localRateLimitConfig
+	var local *LocalRateLimitConfig
 	defer func() {
-		config.localRateLimit = local
+		config.LocalRateLimit = local
 	}()
 
 	var multiplier uint32 = defaultBurstMultiplier
@@ -67,25 +115,90 @@ func (l localRateLimit) Parse(annotations Annotations, config *Ingress, _ *Globa
 	}
 
 	if rpm, err := annotations.ParseUint32ForMSE(limitRPM); err == nil {
-		local = &localRateLimitConfig{
+		local = &LocalRateLimitConfig{
 			MaxTokens:     rpm * multiplier,
 			TokensPerFill: rpm,
 			FillInterval:  minute,
 		}
 	} else if rps, err := annotations.ParseUint32ForMSE(limitRPS); err == nil {
-		local = &localRateLimitConfig{
+		local = &LocalRateLimitConfig{
 			MaxTokens:     rps * multiplier,
 			TokensPerFill: rps,
 			FillInterval:  second,
 		}
+	} else {
+		return nil
+	}
+
+	if code, err := annotations.ParseUint32ForMSE(limitStatusCode); err == nil {
+		if code >= 400 && code < 600 {
+			local.StatusCode = code
+		} else {
+			IngressLog.Errorf("Local rate limit status code %d within ingress %s/%s is invalid", code, config.Namespace, config.Name)
+		}
+	}
+	if body, err := annotations.ParseStringForMSE(limitBody); err == nil {
+		local.Body = body
+	}
+
+	if by, err := annotations.ParseStringForMSE(limitBy); err == nil {
+		// The key is rejected rather than silently shared by all values, and only the limit of route applies.
+		key, err := parseLocalRateLimitKey(by, annotations, multiplier)
+		if err != nil {
+			return fmt.Errorf("invalid local rate limit key %s within ingress %s/%s: %v", by, config.Namespace, config.Name, err)
+		}
+		local.Key = key
 	}
 
 	return nil
 }
 
+// parseLocalRateLimitKey parses the key of buckets and the limit of each key value, such as "tenant-a:100, tenant-b:10".
+// The limit has the same unit as the limit of route.
+func parseLocalRateLimitKey(by string, annotations Annotations, multiplier uint32) (*LocalRateLimitKey, error) {
+	key := &LocalRateLimitKey{}
+	if by == "$remote_addr" {
+		// The header mapped from $remote_addr may be sent by clients, so the trusted address is used instead.
+		key.RemoteAddress = true
+	} else {
+		key.Header, key.QueryParam = parseVariable(by)
+		if key.Header == "" && key.QueryParam == "" {
+			return nil, fmt.Errorf("unsupported variable")
+		}
+	}
+
+	values, err := annotations.ParseStringForMSE(limitByValues)
+	if err != nil {
+		return nil, fmt.Errorf("missing the limit of key values")
+	}
+	for _, item := range splitBySeparator(values, ",") {
+		idx := strings.LastIndex(item, ":")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid limit %s", item)
+		}
+		limit, err := strconv.ParseUint(strings.TrimSpace(item[idx+1:]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit %s", item)
+		}
+		value := strings.TrimSpace(item[:idx])
+		if key.RemoteAddress && net.ParseIP(value) == nil {
+			return nil, fmt.Errorf("invalid client address %s", value)
+		}
+		key.Buckets = append(key.Buckets, LocalRateLimitBucket{
+			Value:         value,
+			TokensPerFill: uint32(limit),
+			MaxTokens:     uint32(limit) * multiplier,
+		})
+	}
+	if len(key.Buckets) == 0 {
+		return nil, fmt.Errorf("missing the limit of key values")
+	}
+	return key, nil
+}
+
 func (l localRateLimit) ApplyRoute(route *networking.HTTPRoute, config *Ingress) {
-	localRateLimitConfig := config.localRateLimit
-	if localRateLimitConfig == nil {
+	localRateLimitConfig := config.LocalRateLimit
+	if localRateLimitConfig == nil || localRateLimitConfig.NeedEnvoyFilter() {
 		return
 	}
 
@@ -98,7 +211,7 @@ func (l localRateLimit) ApplyRoute(route *networking.HTTPRoute, config *Ingress)
 					TokensPefFill: localRateLimitConfig.TokensPerFill,
 					FillInterval:  localRateLimitConfig.FillInterval,
 				},
-				StatusCode: defaultStatusCode,
+				StatusCode: localRateLimitConfig.GetStatusCode(),
 			},
 		},
 	})
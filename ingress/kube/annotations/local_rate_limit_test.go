@@ -25,15 +25,16 @@ import (
 func TestLocalRateLimitParse(t *testing.T) {
 	localRateLimit := localRateLimit{}
 	inputCases := []struct {
-		input  map[string]string
-		expect *localRateLimitConfig
+		input     map[string]string
+		expect    *LocalRateLimitConfig
+		expectErr bool
 	}{
 		{},
 		{
 			input: map[string]string{
 				buildMSEAnnotationKey(limitRPM): "2",
 			},
-			expect: &localRateLimitConfig{
+			expect: &LocalRateLimitConfig{
 				MaxTokens:     10,
 				TokensPerFill: 2,
 				FillInterval:  minute,
@@ -45,7 +46,7 @@ func TestLocalRateLimitParse(t *testing.T) {
 				buildMSEAnnotationKey(limitRPS):             "3",
 				buildMSEAnnotationKey(limitBurstMultiplier): "10",
 			},
-			expect: &localRateLimitConfig{
+			expect: &LocalRateLimitConfig{
 				MaxTokens:     20,
 				TokensPerFill: 2,
 				FillInterval:  minute,
@@ -56,19 +57,154 @@ func TestLocalRateLimitParse(t *testing.T) {
 				buildMSEAnnotationKey(limitRPS):             "3",
 				buildMSEAnnotationKey(limitBurstMultiplier): "10",
 			},
-			expect: &localRateLimitConfig{
+			expect: &LocalRateLimitConfig{
 				MaxTokens:     30,
 				TokensPerFill: 3,
 				FillInterval:  second,
 			},
 		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(limitRPS):        "3",
+				buildMSEAnnotationKey(limitStatusCode): "429",
+				buildMSEAnnotationKey(limitBody):       "too many requests",
+			},
+			expect: &LocalRateLimitConfig{
+				MaxTokens:     15,
+				TokensPerFill: 3,
+				FillInterval:  second,
+				StatusCode:    429,
+				Body:          "too many requests",
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(limitRPS):        "3",
+				buildMSEAnnotationKey(limitStatusCode): "200",
+			},
+			expect: &LocalRateLimitConfig{
+				MaxTokens:     15,
+				TokensPerFill: 3,
+				FillInterval:  second,
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(limitRPM):      "10",
+				buildMSEAnnotationKey(limitBy):       "$http_x-api-key",
+				buildMSEAnnotationKey(limitByValues): "key-a:100, key-b:1",
+			},
+			expect: &LocalRateLimitConfig{
+				MaxTokens:     50,
+				TokensPerFill: 10,
+				FillInterval:  minute,
+				Key: &LocalRateLimitKey{
+					Header: "x-api-key",
+					Buckets: []LocalRateLimitBucket{
+						{Value: "key-a", TokensPerFill: 100, MaxTokens: 500},
+						{Value: "key-b", TokensPerFill: 1, MaxTokens: 5},
+					},
+				},
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(limitRPM):             "10",
+				buildMSEAnnotationKey(limitBurstMultiplier): "1",
+				buildMSEAnnotationKey(limitBy):              "$remote_addr",
+				buildMSEAnnotationKey(limitByValues):        "10.0.0.1:1",
+			},
+			expect: &LocalRateLimitConfig{
+				MaxTokens:     10,
+				TokensPerFill: 10,
+				FillInterval:  minute,
+				Key: &LocalRateLimitKey{
+					RemoteAddress: true,
+					Buckets: []LocalRateLimitBucket{
+						{Value: "10.0.0.1", TokensPerFill: 1, MaxTokens: 1},
+					},
+				},
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(limitRPM):      "10",
+				buildMSEAnnotationKey(limitBy):       "$remote_addr",
+				buildMSEAnnotationKey(limitByValues): "client-a:1",
+			},
+			expect: &LocalRateLimitConfig{
+				MaxTokens:     50,
+				TokensPerFill: 10,
+				FillInterval:  minute,
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(limitRPM):      "10",
+				buildMSEAnnotationKey(limitBy):       "$cookie_user",
+				buildMSEAnnotationKey(limitByValues): "a:1",
+			},
+			expect: &LocalRateLimitConfig{
+				MaxTokens:     50,
+				TokensPerFill: 10,
+				FillInterval:  minute,
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(limitRPM):      "10",
+				buildMSEAnnotationKey(limitBy):       "$arg_tenant",
+				buildMSEAnnotationKey(limitByValues): "a:1",
+			},
+			expect: &LocalRateLimitConfig{
+				MaxTokens:     50,
+				TokensPerFill: 10,
+				FillInterval:  minute,
+				Key: &LocalRateLimitKey{
+					QueryParam: "tenant",
+					Buckets: []LocalRateLimitBucket{
+						{Value: "a", TokensPerFill: 1, MaxTokens: 5},
+					},
+				},
+			},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(limitRPM):      "10",
+				buildMSEAnnotationKey(limitBy):       "$arg_tenant",
+				buildMSEAnnotationKey(limitByValues): "a",
+			},
+			expect: &LocalRateLimitConfig{
+				MaxTokens:     50,
+				TokensPerFill: 10,
+				FillInterval:  minute,
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(limitRPM): "10",
+				buildMSEAnnotationKey(limitBy):  "$arg_tenant",
+			},
+			expect: &LocalRateLimitConfig{
+				MaxTokens:     50,
+				TokensPerFill: 10,
+				FillInterval:  minute,
+			},
+			expectErr: true,
+		},
 	}
 
 	for _, inputCase := range inputCases {
 		t.Run("", func(t *testing.T) {
 			config := &Ingress{}
-			_ = localRateLimit.Parse(inputCase.input, config, nil)
-			if !reflect.DeepEqual(inputCase.expect, config.localRateLimit) {
+			err := localRateLimit.Parse(inputCase.input, config, nil)
+			if inputCase.expectErr != (err != nil) {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(inputCase.expect, config.LocalRateLimit) {
 				t.Fatal("Should be equal")
 			}
 		})
@@ -89,7 +225,7 @@ func TestLocalRateLimitApplyRoute(t *testing.T) {
 		},
 		{
 			config: &Ingress{
-				localRateLimit: &localRateLimitConfig{
+				LocalRateLimit: &LocalRateLimitConfig{
 					MaxTokens:     60,
 					TokensPerFill: 20,
 					FillInterval:  second,
@@ -114,6 +250,46 @@ func TestLocalRateLimitApplyRoute(t *testing.T) {
 				},
 			},
 		},
+		{
+			config: &Ingress{
+				LocalRateLimit: &LocalRateLimitConfig{
+					MaxTokens:     60,
+					TokensPerFill: 20,
+					FillInterval:  second,
+					StatusCode:    429,
+				},
+			},
+			input: &networking.HTTPRoute{},
+			expect: &networking.HTTPRoute{
+				RouteHTTPFilters: []*networking.HTTPFilter{
+					{
+						Name: mseingress.LocalRateLimit,
+						Filter: &networking.HTTPFilter_LocalRateLimit{
+							LocalRateLimit: &networking.LocalRateLimit{
+								TokenBucket: &networking.TokenBucket{
+									MaxTokens:     60,
+									TokensPefFill: 20,
+									FillInterval:  second,
+								},
+								StatusCode: 429,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			config: &Ingress{
+				LocalRateLimit: &LocalRateLimitConfig{
+					MaxTokens:     60,
+					TokensPerFill: 20,
+					FillInterval:  second,
+					Body:          "too many requests",
+				},
+			},
+			input:  &networking.HTTPRoute{},
+			expect: &networking.HTTPRoute{},
+		},
 	}
 
 	for _, inputCase := range inputCases {
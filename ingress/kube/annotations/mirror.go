@@ -0,0 +1,155 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+
+	"github.com/alibaba/higress/ingress/kube/util"
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	mirrorTarget     = "mirror-target"
+	mirrorPercentage = "mirror-percentage"
+)
+
+var (
+	_ Parser       = mirror{}
+	_ RouteHandler = mirror{}
+)
+
+type MirrorConfig struct {
+	ServiceName model.NamespacedName
+	Port        uint32
+	// The percentage of requests mirrored, and all requests are mirrored if nil.
+	Percentage *float64
+}
+
+type mirror struct{}
+
+// Parse accepts the mirror target such as app, app:8080 and namespace/app:8080. The first port of service is used
+// if port is omitted, and the namespace of ingress is used if namespace is omitted. It returns error if any mirror
+// annotation is invalid, and no request is mirrored in this case.
+func (m mirror) Parse(annotations Annotations, config *Ingress, globalContext *GlobalContext) error {
+	if !needMirrorConfig(annotations) {
+		return nil
+	}
+
+	mirrorConfig, err := parseMirrorConfig(annotations, config, globalContext)
+	if err != nil {
+		IngressLog.Errorf("Mirror within ingress %s/%s is invalid, %v", config.Namespace, config.Name, err)
+		return fmt.Errorf("invalid mirror within ingress %s/%s: %v", config.Namespace, config.Name, err)
+	}
+
+	config.Mirror = mirrorConfig
+	return nil
+}
+
+func parseMirrorConfig(annotations Annotations, config *Ingress, globalContext *GlobalContext) (*MirrorConfig, error) {
+	target, err := annotations.ParseStringASAP(mirrorTarget)
+	if err != nil {
+		return nil, err
+	}
+
+	var port uint64
+	if host, rawPort, err := net.SplitHostPort(target); err == nil {
+		port, err = strconv.ParseUint(rawPort, 10, 16)
+		if err != nil || port == 0 {
+			return nil, fmt.Errorf("%s %s has invalid port", mirrorTarget, target)
+		}
+		target = host
+	}
+
+	mirrorConfig := &MirrorConfig{
+		ServiceName: util.SplitNamespacedName(target),
+		Port:        uint32(port),
+	}
+	if mirrorConfig.ServiceName.Name == "" {
+		return nil, fmt.Errorf("%s %s is invalid", mirrorTarget, target)
+	}
+	if mirrorConfig.ServiceName.Namespace == "" {
+		mirrorConfig.ServiceName.Namespace = config.Namespace
+	}
+
+	// Subscribe service
+	if globalContext.WatchedServices != nil {
+		globalContext.WatchedServices.Insert(mirrorConfig.ServiceName.String())
+	}
+
+	serviceLister, exist := globalContext.ClusterServiceList[config.ClusterId]
+	if !exist {
+		return nil, fmt.Errorf("service lister of cluster %s doesn't exist", config.ClusterId)
+	}
+	mirrorSvc, err := serviceLister.Services(mirrorConfig.ServiceName.Namespace).Get(mirrorConfig.ServiceName.Name)
+	if err != nil {
+		return nil, fmt.Errorf("mirror service %s is not found", mirrorConfig.ServiceName)
+	}
+	if mirrorConfig.Port == 0 {
+		if len(mirrorSvc.Spec.Ports) == 0 {
+			return nil, fmt.Errorf("mirror service %s doesn't have ports", mirrorConfig.ServiceName)
+		}
+		mirrorConfig.Port = uint32(mirrorSvc.Spec.Ports[0].Port)
+	} else {
+		var found bool
+		for _, port := range mirrorSvc.Spec.Ports {
+			if uint32(port.Port) == mirrorConfig.Port {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("mirror service %s doesn't have port %d", mirrorConfig.ServiceName, mirrorConfig.Port)
+		}
+	}
+
+	if rawPercentage, err := annotations.ParseStringForMSE(mirrorPercentage); err == nil {
+		percentage, err := strconv.ParseFloat(rawPercentage, 64)
+		if err != nil || percentage < 0 || percentage > 100 {
+			return nil, fmt.Errorf("%s %s is invalid", mirrorPercentage, rawPercentage)
+		}
+		mirrorConfig.Percentage = &percentage
+	}
+
+	return mirrorConfig, nil
+}
+
+func (m mirror) ApplyRoute(route *networking.HTTPRoute, config *Ingress) {
+	mirrorConfig := config.Mirror
+	if mirrorConfig == nil {
+		return
+	}
+
+	route.Mirror = &networking.Destination{
+		Host: util.CreateServiceFQDN(mirrorConfig.ServiceName.Namespace, mirrorConfig.ServiceName.Name),
+		Port: &networking.PortSelector{
+			Number: mirrorConfig.Port,
+		},
+	}
+	if mirrorConfig.Percentage != nil {
+		route.MirrorPercentage = &networking.Percent{
+			Value: *mirrorConfig.Percentage,
+		}
+	}
+}
+
+func needMirrorConfig(annotations Annotations) bool {
+	return annotations.HasASAP(mirrorTarget)
+}
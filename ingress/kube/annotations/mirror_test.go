@@ -0,0 +1,183 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"reflect"
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestMirrorParse(t *testing.T) {
+	mirror := mirror{}
+	half := 50.0
+	inputCases := []struct {
+		input  map[string]string
+		expect *MirrorConfig
+		err    bool
+	}{
+		{},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(mirrorTarget): "app",
+			},
+			expect: &MirrorConfig{
+				ServiceName: model.NamespacedName{
+					Namespace: "test",
+					Name:      "app",
+				},
+				Port: 80,
+			},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(mirrorTarget):   "test/app:80",
+				buildMSEAnnotationKey(mirrorPercentage): "50",
+			},
+			expect: &MirrorConfig{
+				ServiceName: model.NamespacedName{
+					Namespace: "test",
+					Name:      "app",
+				},
+				Port:       80,
+				Percentage: &half,
+			},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(mirrorTarget):   "test/app",
+				buildMSEAnnotationKey(mirrorPercentage): "150",
+			},
+			err: true,
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(mirrorTarget):   "test/app",
+				buildMSEAnnotationKey(mirrorPercentage): "5%",
+			},
+			err: true,
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(mirrorTarget): "test/app:8080",
+			},
+			err: true,
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(mirrorTarget): "foo/app",
+			},
+			err: true,
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(mirrorTarget): "test/unknown",
+			},
+			err: true,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			config := &Ingress{
+				Meta: Meta{
+					Namespace: "test",
+					ClusterId: "cluster",
+				},
+			}
+			globalContext, cancel := initGlobalContextForService()
+			defer cancel()
+
+			err := mirror.Parse(inputCase.input, config, globalContext)
+			if inputCase.err != (err != nil) {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(inputCase.expect, config.Mirror) {
+				t.Fatal("Should be equal")
+			}
+		})
+	}
+}
+
+func TestMirrorApplyRoute(t *testing.T) {
+	mirror := mirror{}
+	half := 50.0
+	inputCases := []struct {
+		config *Ingress
+		input  *networking.HTTPRoute
+		expect *networking.HTTPRoute
+	}{
+		{
+			config: &Ingress{},
+			input:  &networking.HTTPRoute{},
+			expect: &networking.HTTPRoute{},
+		},
+		{
+			config: &Ingress{
+				Mirror: &MirrorConfig{
+					ServiceName: model.NamespacedName{
+						Namespace: "test",
+						Name:      "app",
+					},
+					Port: 80,
+				},
+			},
+			input: &networking.HTTPRoute{},
+			expect: &networking.HTTPRoute{
+				Mirror: &networking.Destination{
+					Host: "app.test.svc.cluster.local",
+					Port: &networking.PortSelector{
+						Number: 80,
+					},
+				},
+			},
+		},
+		{
+			config: &Ingress{
+				Mirror: &MirrorConfig{
+					ServiceName: model.NamespacedName{
+						Namespace: "test",
+						Name:      "app",
+					},
+					Port:       80,
+					Percentage: &half,
+				},
+			},
+			input: &networking.HTTPRoute{},
+			expect: &networking.HTTPRoute{
+				Mirror: &networking.Destination{
+					Host: "app.test.svc.cluster.local",
+					Port: &networking.PortSelector{
+						Number: 80,
+					},
+				},
+				MirrorPercentage: &networking.Percent{
+					Value: 50,
+				},
+			},
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			mirror.ApplyRoute(inputCase.input, inputCase.config)
+			if !reflect.DeepEqual(inputCase.input, inputCase.expect) {
+				t.Fatal("Should be equal")
+			}
+		})
+	}
+}
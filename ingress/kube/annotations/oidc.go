@@ -0,0 +1,176 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"fmt"
+	"strings"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"github.com/alibaba/higress/ingress/kube/util"
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	oidcIssuer                = "oidc-issuer"
+	oidcClientId              = "oidc-client-id"
+	oidcClientSecret          = "oidc-client-secret"
+	oidcAuthorizationEndpoint = "oidc-authorization-endpoint"
+	oidcTokenEndpoint         = "oidc-token-endpoint"
+	oidcRedirectPath          = "oidc-redirect-path"
+	oidcScopes                = "oidc-scopes"
+	oidcCookieDomain          = "oidc-cookie-domain"
+
+	defaultOidcAuthorizationPath = "/authorize"
+	defaultOidcTokenPath         = "/token"
+	defaultOidcRedirectPath      = "/oauth2/callback"
+	defaultOidcScope             = "openid"
+
+	// OidcClientSecretKey is the key of client secret within the secret referred by oidc-client-secret.
+	OidcClientSecretKey = "client-secret"
+)
+
+var (
+	_ Parser       = oidc{}
+	_ RouteHandler = oidc{}
+)
+
+type OidcConfig struct {
+	Issuer   string
+	ClientId string
+	// The secret holding the client secret, which is delivered to gateways as a generic secret by sds.
+	ClientSecret          util.ClusterNamespacedName
+	AuthorizationEndpoint string
+	// The token endpoint, and the host, port and tls of the service serving it.
+	TokenEndpoint    string
+	TokenServiceHost string
+	TokenServicePort uint32
+	TokenServiceTLS  bool
+	RedirectPath     string
+	Scopes           []string
+	CookieDomain     string
+	// The oidc annotations are invalid, so all requests are rejected rather than served without login.
+	Invalid bool
+}
+
+type oidc struct{}
+
+func (o oidc) Parse(annotations Annotations, config *Ingress, globalContext *GlobalContext) error {
+	if !needOidcConfig(annotations) {
+		return nil
+	}
+
+	issuer, _ := annotations.ParseStringForMSE(oidcIssuer)
+	issuer = strings.TrimSuffix(issuer, "/")
+	clientId, _ := annotations.ParseStringForMSE(oidcClientId)
+	if issuer == "" || clientId == "" {
+		return rejectOidc(config, fmt.Errorf("%s and %s are required", oidcIssuer, oidcClientId))
+	}
+
+	oidcConfig := &OidcConfig{
+		Issuer:                issuer,
+		ClientId:              clientId,
+		AuthorizationEndpoint: issuer + defaultOidcAuthorizationPath,
+		TokenEndpoint:         issuer + defaultOidcTokenPath,
+		RedirectPath:          defaultOidcRedirectPath,
+		Scopes:                []string{defaultOidcScope},
+	}
+	if endpoint, err := annotations.ParseStringForMSE(oidcAuthorizationEndpoint); err == nil {
+		oidcConfig.AuthorizationEndpoint = oidcEndpoint(issuer, endpoint)
+	}
+	if endpoint, err := annotations.ParseStringForMSE(oidcTokenEndpoint); err == nil {
+		oidcConfig.TokenEndpoint = oidcEndpoint(issuer, endpoint)
+	}
+	tokenURL, err := parseServiceURL(oidcConfig.TokenEndpoint, config.Namespace)
+	if err != nil {
+		return rejectOidc(config, fmt.Errorf("token endpoint %s is invalid", oidcConfig.TokenEndpoint))
+	}
+	oidcConfig.TokenServiceHost = tokenURL.host
+	oidcConfig.TokenServicePort = tokenURL.port
+	oidcConfig.TokenServiceTLS = tokenURL.tls
+
+	if path, err := annotations.ParseStringForMSE(oidcRedirectPath); err == nil {
+		if !strings.HasPrefix(path, "/") {
+			return rejectOidc(config, fmt.Errorf("redirect path %s is invalid", path))
+		}
+		oidcConfig.RedirectPath = path
+	}
+	if scopes, err := annotations.ParseStringForMSE(oidcScopes); err == nil {
+		oidcConfig.Scopes = splitBySeparator(scopes, ",")
+	}
+	oidcConfig.CookieDomain, _ = annotations.ParseStringForMSE(oidcCookieDomain)
+
+	secretName, _ := annotations.ParseStringForMSE(oidcClientSecret)
+	namespaced := util.SplitNamespacedName(secretName)
+	if namespaced.Name == "" {
+		return rejectOidc(config, fmt.Errorf("%s is required", oidcClientSecret))
+	}
+	if namespaced.Namespace == "" {
+		namespaced.Namespace = config.Namespace
+	}
+	configKey := util.ClusterNamespacedName{
+		NamespacedName: namespaced,
+		ClusterId:      config.ClusterId,
+	}
+
+	// Subscribe secret, so the ingress is converted again once the secret is created or rotated.
+	globalContext.WatchedSecrets.Insert(configKey.String())
+
+	secretLister, exist := globalContext.ClusterSecretLister[config.ClusterId]
+	if !exist {
+		return rejectOidc(config, fmt.Errorf("secret lister of cluster %s doesn't exist", config.ClusterId))
+	}
+	secret, err := secretLister.Secrets(namespaced.Namespace).Get(namespaced.Name)
+	if err != nil {
+		return rejectOidc(config, fmt.Errorf("secret %s is not found", namespaced.String()))
+	}
+	if len(secret.Data[OidcClientSecretKey]) == 0 {
+		return rejectOidc(config, fmt.Errorf("secret %s doesn't have the %s", namespaced.String(), OidcClientSecretKey))
+	}
+	oidcConfig.ClientSecret = configKey
+
+	config.Oidc = oidcConfig
+	return nil
+}
+
+// ApplyRoute rejects all requests of route if the oidc annotations are invalid.
+func (o oidc) ApplyRoute(route *networking.HTTPRoute, config *Ingress) {
+	if config.Oidc != nil && config.Oidc.Invalid {
+		rejectRoute(route)
+	}
+}
+
+// rejectOidc marks the oidc config of ingress invalid, since serving the routes without login on invalid
+// annotations is worse than rejecting all requests.
+func rejectOidc(config *Ingress, err error) error {
+	IngressLog.Errorf("Oidc within ingress %s/%s is invalid, %v", config.Namespace, config.Name, err)
+	config.Oidc = &OidcConfig{Invalid: true}
+	return fmt.Errorf("invalid oidc within ingress %s/%s: %v", config.Namespace, config.Name, err)
+}
+
+// oidcEndpoint accepts an absolute url, or a path relative to the issuer.
+func oidcEndpoint(issuer, endpoint string) string {
+	if strings.HasPrefix(endpoint, "/") {
+		return issuer + endpoint
+	}
+	return endpoint
+}
+
+func needOidcConfig(annotations Annotations) bool {
+	return annotations.HasMSE(oidcIssuer) ||
+		annotations.HasMSE(oidcClientId) ||
+		annotations.HasMSE(oidcClientSecret)
+}
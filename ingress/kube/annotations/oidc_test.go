@@ -0,0 +1,211 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"reflect"
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alibaba/higress/ingress/kube/util"
+)
+
+func TestOidcParse(t *testing.T) {
+	oidc := oidc{}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "client",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			OidcClientSecretKey: []byte("secret"),
+		},
+	}
+	clientSecret := util.ClusterNamespacedName{
+		NamespacedName: model.NamespacedName{
+			Namespace: "default",
+			Name:      "client",
+		},
+		ClusterId: "cluster",
+	}
+	inputCases := []struct {
+		input         map[string]string
+		expect        *OidcConfig
+		watchedSecret string
+	}{
+		{},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(oidcIssuer):   "https://idp.example.com",
+				buildMSEAnnotationKey(oidcClientId): "dashboard",
+			},
+			expect: &OidcConfig{Invalid: true},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(oidcIssuer):       "https://idp.example.com",
+				buildMSEAnnotationKey(oidcClientSecret): "client",
+			},
+			expect: &OidcConfig{Invalid: true},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(oidcIssuer):       "https://idp.example.com",
+				buildMSEAnnotationKey(oidcClientId):     "dashboard",
+				buildMSEAnnotationKey(oidcClientSecret): "client",
+				buildMSEAnnotationKey(oidcRedirectPath): "callback",
+			},
+			expect: &OidcConfig{Invalid: true},
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(oidcIssuer):       "https://idp.example.com/",
+				buildMSEAnnotationKey(oidcClientId):     "dashboard",
+				buildMSEAnnotationKey(oidcClientSecret): "client",
+			},
+			expect: &OidcConfig{
+				Issuer:                "https://idp.example.com",
+				ClientId:              "dashboard",
+				ClientSecret:          clientSecret,
+				AuthorizationEndpoint: "https://idp.example.com/authorize",
+				TokenEndpoint:         "https://idp.example.com/token",
+				TokenServiceHost:      "idp.example.com",
+				TokenServicePort:      443,
+				TokenServiceTLS:       true,
+				RedirectPath:          "/oauth2/callback",
+				Scopes:                []string{"openid"},
+			},
+			watchedSecret: "cluster/default/client",
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(oidcIssuer):                "https://idp.example.com",
+				buildMSEAnnotationKey(oidcClientId):              "dashboard",
+				buildMSEAnnotationKey(oidcClientSecret):          "default/client",
+				buildMSEAnnotationKey(oidcAuthorizationEndpoint): "/protocol/openid-connect/auth",
+				buildMSEAnnotationKey(oidcTokenEndpoint):         "http://keycloak:8080/protocol/openid-connect/token",
+				buildMSEAnnotationKey(oidcRedirectPath):          "/callback",
+				buildMSEAnnotationKey(oidcScopes):                "openid, email",
+				buildMSEAnnotationKey(oidcCookieDomain):          "example.com",
+			},
+			expect: &OidcConfig{
+				Issuer:                "https://idp.example.com",
+				ClientId:              "dashboard",
+				ClientSecret:          clientSecret,
+				AuthorizationEndpoint: "https://idp.example.com/protocol/openid-connect/auth",
+				TokenEndpoint:         "http://keycloak:8080/protocol/openid-connect/token",
+				TokenServiceHost:      "keycloak.default.svc.cluster.local",
+				TokenServicePort:      8080,
+				RedirectPath:          "/callback",
+				Scopes:                []string{"openid", "email"},
+				CookieDomain:          "example.com",
+			},
+			watchedSecret: "cluster/default/client",
+		},
+		{
+			input: map[string]string{
+				buildMSEAnnotationKey(oidcIssuer):       "https://idp.example.com",
+				buildMSEAnnotationKey(oidcClientId):     "dashboard",
+				buildMSEAnnotationKey(oidcClientSecret): "missing",
+			},
+			expect:        &OidcConfig{Invalid: true},
+			watchedSecret: "cluster/default/missing",
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			config := &Ingress{
+				Meta: Meta{
+					Namespace:    "default",
+					ClusterId:    "cluster",
+					RawClusterId: "cluster__",
+				},
+			}
+
+			globalContext, cancel := initGlobalContext(secret)
+			defer cancel()
+
+			err := oidc.Parse(inputCase.input, config, globalContext)
+			if !reflect.DeepEqual(inputCase.expect, config.Oidc) {
+				t.Fatalf("Should be equal.")
+			}
+			if invalid := inputCase.expect != nil && inputCase.expect.Invalid; invalid != (err != nil) {
+				t.Fatalf("Unexpected error %v", err)
+			}
+
+			if inputCase.watchedSecret != "" {
+				if !globalContext.WatchedSecrets.Contains(inputCase.watchedSecret) {
+					t.Fatalf("Should watch secret %s", inputCase.watchedSecret)
+				}
+			}
+		})
+	}
+}
+
+func TestOidcApplyRoute(t *testing.T) {
+	oidc := oidc{}
+	inputCases := []struct {
+		config *Ingress
+		input  *networking.HTTPRoute
+		expect *networking.HTTPRoute
+	}{
+		{
+			config: &Ingress{},
+			input:  &networking.HTTPRoute{},
+			expect: &networking.HTTPRoute{},
+		},
+		{
+			config: &Ingress{
+				Oidc: &OidcConfig{
+					Issuer: "https://idp.example.com",
+				},
+			},
+			input:  &networking.HTTPRoute{},
+			expect: &networking.HTTPRoute{},
+		},
+		{
+			config: &Ingress{
+				Oidc: &OidcConfig{Invalid: true},
+			},
+			input: &networking.HTTPRoute{},
+			expect: &networking.HTTPRoute{
+				Fault: &networking.HTTPFaultInjection{
+					Abort: &networking.HTTPFaultInjection_Abort{
+						ErrorType: &networking.HTTPFaultInjection_Abort_HttpStatus{
+							HttpStatus: 503,
+						},
+						Percentage: &networking.Percent{
+							Value: 100,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			oidc.ApplyRoute(inputCase.input, inputCase.config)
+			if !reflect.DeepEqual(inputCase.input, inputCase.expect) {
+				t.Fatalf("Should be equal.")
+			}
+		})
+	}
+}
@@ -15,48 +15,126 @@
 package annotations
 
 import (
-	"github.com/gogo/protobuf/types"
+	"fmt"
+	"time"
 
+	"github.com/gogo/protobuf/types"
 	networking "istio.io/api/networking/v1alpha3"
+
+	. "github.com/alibaba/higress/ingress/log"
 )
 
-const timeoutAnnotation = "timeout"
+const (
+	timeoutAnnotation   = "timeout"
+	proxyConnectTimeout = "proxy-connect-timeout"
+	proxySendTimeout    = "proxy-send-timeout"
+	proxyReadTimeout    = "proxy-read-timeout"
+)
 
 var (
-	_ Parser       = timeout{}
-	_ RouteHandler = timeout{}
+	_ Parser               = timeout{}
+	_ RouteHandler         = timeout{}
+	_ TrafficPolicyHandler = timeout{}
 )
 
+// TimeoutConfig holds the timeouts in nginx units. The time is the timeout of the whole request,
+// the connect timeout applies to upstream connections, and the read and send timeouts limit the
+// idle time of the request stream, as envoy doesn't distinguish between reading and sending.
 type TimeoutConfig struct {
-	time *types.Duration
+	time           *types.Duration
+	connectTimeout *types.Duration
+	sendTimeout    *types.Duration
+	readTimeout    *types.Duration
 }
 
 type timeout struct{}
 
+// Parse returns error if any timeout annotation is invalid, and the timeouts are not applied in this case.
 func (t timeout) Parse(annotations Annotations, config *Ingress, _ *GlobalContext) error {
 	if !needTimeoutConfig(annotations) {
 		return nil
 	}
 
-	if time, err := annotations.ParseIntForMSE(timeoutAnnotation); err == nil {
-		config.Timeout = &TimeoutConfig{
-			time: &types.Duration{
-				Seconds: int64(time),
-			},
+	timeoutConfig := &TimeoutConfig{}
+	timeouts := []struct {
+		key   string
+		parse func(string) (string, error)
+		value **types.Duration
+	}{
+		{timeoutAnnotation, annotations.ParseStringForMSE, &timeoutConfig.time},
+		{proxyConnectTimeout, annotations.ParseStringASAP, &timeoutConfig.connectTimeout},
+		{proxySendTimeout, annotations.ParseStringASAP, &timeoutConfig.sendTimeout},
+		{proxyReadTimeout, annotations.ParseStringASAP, &timeoutConfig.readTimeout},
+	}
+	var found bool
+	for _, item := range timeouts {
+		raw, err := item.parse(item.key)
+		if err != nil {
+			continue
+		}
+		value, err := parseDuration(raw)
+		if err != nil {
+			IngressLog.Errorf("Timeout %s within ingress %s/%s is invalid, %v", item.key, config.Namespace, config.Name, err)
+			return fmt.Errorf("invalid %s within ingress %s/%s: %v", item.key, config.Namespace, config.Name, err)
 		}
+		*item.value = types.DurationProto(value)
+		found = true
+	}
+
+	if found {
+		config.Timeout = timeoutConfig
 	}
 	return nil
 }
 
 func (t timeout) ApplyRoute(route *networking.HTTPRoute, config *Ingress) {
 	timeout := config.Timeout
-	if timeout == nil || timeout.time == nil || timeout.time.Seconds == 0 {
+	if timeout == nil || isZeroDuration(timeout.time) {
 		return
 	}
 
 	route.Timeout = timeout.time
 }
 
+func (t timeout) ApplyTrafficPolicy(trafficPolicy *networking.TrafficPolicy_PortTrafficPolicy, config *Ingress) {
+	timeout := config.Timeout
+	if timeout == nil || isZeroDuration(timeout.connectTimeout) {
+		return
+	}
+
+	tcpConnectionPool(trafficPolicy).ConnectTimeout = timeout.connectTimeout
+}
+
+// NeedConnectTimeout returns whether the connect timeout should be applied on the traffic policy.
+func (t *TimeoutConfig) NeedConnectTimeout() bool {
+	return t != nil && !isZeroDuration(t.connectTimeout)
+}
+
+// IdleTimeout returns the longer one of read and send timeouts, and zero means not set.
+func (t *TimeoutConfig) IdleTimeout() time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	var idleTimeout time.Duration
+	for _, value := range []*types.Duration{t.readTimeout, t.sendTimeout} {
+		if value == nil {
+			continue
+		}
+		if d, err := types.DurationFromProto(value); err == nil && d > idleTimeout {
+			idleTimeout = d
+		}
+	}
+	return idleTimeout
+}
+
+func isZeroDuration(d *types.Duration) bool {
+	return d == nil || (d.Seconds == 0 && d.Nanos == 0)
+}
+
 func needTimeoutConfig(annotations Annotations) bool {
-	return annotations.HasMSE(timeoutAnnotation)
+	return annotations.HasMSE(timeoutAnnotation) ||
+		annotations.HasASAP(proxyConnectTimeout) ||
+		annotations.HasASAP(proxySendTimeout) ||
+		annotations.HasASAP(proxyReadTimeout)
 }
@@ -17,6 +17,7 @@ package annotations
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/gogo/protobuf/types"
 	networking "istio.io/api/networking/v1alpha3"
@@ -25,8 +26,9 @@ import (
 func TestTimeoutParse(t *testing.T) {
 	timeout := timeout{}
 	inputCases := []struct {
-		input  map[string]string
-		expect *TimeoutConfig
+		input     map[string]string
+		expect    *TimeoutConfig
+		expectErr bool
 	}{
 		{},
 		{
@@ -52,12 +54,50 @@ func TestTimeoutParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			input: map[string]string{
+				MSEAnnotationsPrefix + "/" + timeoutAnnotation: "1m30s",
+			},
+			expect: &TimeoutConfig{
+				time: &types.Duration{
+					Seconds: 90,
+				},
+			},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(proxyConnectTimeout): "500ms",
+				buildNginxAnnotationKey(proxySendTimeout):    "60",
+				buildMSEAnnotationKey(proxyReadTimeout):      "2m",
+			},
+			expect: &TimeoutConfig{
+				connectTimeout: &types.Duration{
+					Nanos: 500000000,
+				},
+				sendTimeout: &types.Duration{
+					Seconds: 60,
+				},
+				readTimeout: &types.Duration{
+					Seconds: 120,
+				},
+			},
+		},
+		{
+			input: map[string]string{
+				MSEAnnotationsPrefix + "/" + timeoutAnnotation: "10",
+				buildNginxAnnotationKey(proxyReadTimeout):      "10x",
+			},
+			expectErr: true,
+		},
 	}
 
 	for _, c := range inputCases {
 		t.Run("", func(t *testing.T) {
 			config := &Ingress{}
-			_ = timeout.Parse(c.input, config, nil)
+			err := timeout.Parse(c.input, config, nil)
+			if (err != nil) != c.expectErr {
+				t.Fatalf("Unexpected error %v", err)
+			}
 			if !reflect.DeepEqual(c.expect, config.Timeout) {
 				t.Fatalf("Should be equal.")
 			}
@@ -119,3 +159,109 @@ func TestTimeoutApplyRoute(t *testing.T) {
 		})
 	}
 }
+
+func TestTimeoutApplyTrafficPolicy(t *testing.T) {
+	timeout := timeout{}
+	inputCases := []struct {
+		config *Ingress
+		input  *networking.TrafficPolicy_PortTrafficPolicy
+		expect *networking.TrafficPolicy_PortTrafficPolicy
+	}{
+		{
+			config: &Ingress{},
+			input:  &networking.TrafficPolicy_PortTrafficPolicy{},
+			expect: &networking.TrafficPolicy_PortTrafficPolicy{},
+		},
+		{
+			config: &Ingress{
+				Timeout: &TimeoutConfig{
+					readTimeout: &types.Duration{
+						Seconds: 10,
+					},
+				},
+			},
+			input:  &networking.TrafficPolicy_PortTrafficPolicy{},
+			expect: &networking.TrafficPolicy_PortTrafficPolicy{},
+		},
+		{
+			config: &Ingress{
+				Timeout: &TimeoutConfig{
+					connectTimeout: &types.Duration{
+						Seconds: 5,
+					},
+				},
+			},
+			input: &networking.TrafficPolicy_PortTrafficPolicy{
+				ConnectionPool: &networking.ConnectionPoolSettings{
+					Http: &networking.ConnectionPoolSettings_HTTPSettings{
+						H2UpgradePolicy: networking.ConnectionPoolSettings_HTTPSettings_UPGRADE,
+					},
+				},
+			},
+			expect: &networking.TrafficPolicy_PortTrafficPolicy{
+				ConnectionPool: &networking.ConnectionPoolSettings{
+					Tcp: &networking.ConnectionPoolSettings_TCPSettings{
+						ConnectTimeout: &types.Duration{
+							Seconds: 5,
+						},
+					},
+					Http: &networking.ConnectionPoolSettings_HTTPSettings{
+						H2UpgradePolicy: networking.ConnectionPoolSettings_HTTPSettings_UPGRADE,
+					},
+				},
+			},
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			timeout.ApplyTrafficPolicy(inputCase.input, inputCase.config)
+			if !reflect.DeepEqual(inputCase.input, inputCase.expect) {
+				t.Fatalf("Should be equal")
+			}
+		})
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	inputCases := []struct {
+		input  *TimeoutConfig
+		expect time.Duration
+	}{
+		{},
+		{
+			input: &TimeoutConfig{
+				time: &types.Duration{
+					Seconds: 10,
+				},
+			},
+		},
+		{
+			input: &TimeoutConfig{
+				readTimeout: &types.Duration{
+					Seconds: 10,
+				},
+			},
+			expect: 10 * time.Second,
+		},
+		{
+			input: &TimeoutConfig{
+				readTimeout: &types.Duration{
+					Seconds: 10,
+				},
+				sendTimeout: &types.Duration{
+					Seconds: 30,
+				},
+			},
+			expect: 30 * time.Second,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			if actual := inputCase.input.IdleTimeout(); actual != inputCase.expect {
+				t.Fatalf("Should be %s, but actual is %s", inputCase.expect, actual)
+			}
+		})
+	}
+}
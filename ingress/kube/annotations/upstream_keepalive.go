@@ -0,0 +1,121 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gogo/protobuf/types"
+	networking "istio.io/api/networking/v1alpha3"
+
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	upstreamKeepaliveConnections = "upstream-keepalive-connections"
+	upstreamKeepaliveTimeout     = "upstream-keepalive-timeout"
+	upstreamKeepaliveRequests    = "upstream-keepalive-requests"
+)
+
+var (
+	_ Parser               = upstreamKeepalive{}
+	_ TrafficPolicyHandler = upstreamKeepalive{}
+)
+
+// UpstreamKeepaliveConfig holds the keepalive of upstream connections. Envoy has no limit on the
+// number of idle connections, so the connections only matter when it is zero, which disables keepalive.
+type UpstreamKeepaliveConfig struct {
+	disabled    bool
+	timeout     *types.Duration
+	maxRequests int32
+}
+
+type upstreamKeepalive struct{}
+
+// Parse returns error if any keepalive annotation is invalid, and the keepalive is not applied in this case.
+func (u upstreamKeepalive) Parse(annotations Annotations, config *Ingress, _ *GlobalContext) error {
+	if !needUpstreamKeepaliveConfig(annotations) {
+		return nil
+	}
+
+	keepaliveConfig, err := parseUpstreamKeepaliveConfig(annotations)
+	if err != nil {
+		IngressLog.Errorf("Upstream keepalive within ingress %s/%s is invalid, %v", config.Namespace, config.Name, err)
+		return fmt.Errorf("invalid upstream keepalive within ingress %s/%s: %v", config.Namespace, config.Name, err)
+	}
+
+	config.UpstreamKeepalive = keepaliveConfig
+	return nil
+}
+
+func parseUpstreamKeepaliveConfig(annotations Annotations) (*UpstreamKeepaliveConfig, error) {
+	keepaliveConfig := &UpstreamKeepaliveConfig{}
+
+	if raw, err := annotations.ParseStringASAP(upstreamKeepaliveConnections); err == nil {
+		value, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("%s %s is invalid", upstreamKeepaliveConnections, raw)
+		}
+		keepaliveConfig.disabled = value == 0
+	}
+
+	if raw, err := annotations.ParseStringASAP(upstreamKeepaliveTimeout); err == nil {
+		value, err := parseDuration(raw)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("%s %s is invalid", upstreamKeepaliveTimeout, raw)
+		}
+		keepaliveConfig.timeout = types.DurationProto(value)
+	}
+
+	if raw, err := annotations.ParseStringASAP(upstreamKeepaliveRequests); err == nil {
+		value, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("%s %s is invalid", upstreamKeepaliveRequests, raw)
+		}
+		keepaliveConfig.maxRequests = int32(value)
+	}
+
+	return keepaliveConfig, nil
+}
+
+func (u upstreamKeepalive) ApplyTrafficPolicy(trafficPolicy *networking.TrafficPolicy_PortTrafficPolicy, config *Ingress) {
+	keepaliveConfig := config.UpstreamKeepalive
+	if keepaliveConfig == nil || !keepaliveConfig.needConnectionPool() {
+		return
+	}
+
+	http := httpConnectionPool(trafficPolicy)
+	if keepaliveConfig.disabled {
+		http.MaxRequestsPerConnection = 1
+		return
+	}
+	if keepaliveConfig.timeout != nil {
+		http.IdleTimeout = keepaliveConfig.timeout
+	}
+	if keepaliveConfig.maxRequests > 0 {
+		http.MaxRequestsPerConnection = keepaliveConfig.maxRequests
+	}
+}
+
+func (u *UpstreamKeepaliveConfig) needConnectionPool() bool {
+	return u.disabled || u.timeout != nil || u.maxRequests > 0
+}
+
+func needUpstreamKeepaliveConfig(annotations Annotations) bool {
+	return annotations.HasASAP(upstreamKeepaliveConnections) ||
+		annotations.HasASAP(upstreamKeepaliveTimeout) ||
+		annotations.HasASAP(upstreamKeepaliveRequests)
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gogo/protobuf/types"
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+func TestUpstreamKeepaliveParse(t *testing.T) {
+	upstreamKeepalive := upstreamKeepalive{}
+	inputCases := []struct {
+		input     map[string]string
+		expect    *UpstreamKeepaliveConfig
+		expectErr bool
+	}{
+		{},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(upstreamKeepaliveConnections): "0",
+			},
+			expect: &UpstreamKeepaliveConfig{
+				disabled: true,
+			},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(upstreamKeepaliveConnections): "320",
+				buildNginxAnnotationKey(upstreamKeepaliveTimeout):     "60s",
+				buildMSEAnnotationKey(upstreamKeepaliveRequests):      "10000",
+			},
+			expect: &UpstreamKeepaliveConfig{
+				timeout: &types.Duration{
+					Seconds: 60,
+				},
+				maxRequests: 10000,
+			},
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(upstreamKeepaliveConnections): "-1",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(upstreamKeepaliveTimeout): "0",
+			},
+			expectErr: true,
+		},
+		{
+			input: map[string]string{
+				buildNginxAnnotationKey(upstreamKeepaliveRequests): "abc",
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			config := &Ingress{}
+			err := upstreamKeepalive.Parse(inputCase.input, config, nil)
+			if (err != nil) != inputCase.expectErr {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(inputCase.expect, config.UpstreamKeepalive) {
+				t.Fatalf("Should be equal")
+			}
+		})
+	}
+}
+
+func TestUpstreamKeepaliveApplyTrafficPolicy(t *testing.T) {
+	upstreamKeepalive := upstreamKeepalive{}
+	inputCases := []struct {
+		config *Ingress
+		input  *networking.TrafficPolicy_PortTrafficPolicy
+		expect *networking.TrafficPolicy_PortTrafficPolicy
+	}{
+		{
+			config: &Ingress{},
+			input:  &networking.TrafficPolicy_PortTrafficPolicy{},
+			expect: &networking.TrafficPolicy_PortTrafficPolicy{},
+		},
+		{
+			config: &Ingress{
+				UpstreamKeepalive: &UpstreamKeepaliveConfig{},
+			},
+			input:  &networking.TrafficPolicy_PortTrafficPolicy{},
+			expect: &networking.TrafficPolicy_PortTrafficPolicy{},
+		},
+		{
+			config: &Ingress{
+				UpstreamKeepalive: &UpstreamKeepaliveConfig{
+					disabled: true,
+					timeout: &types.Duration{
+						Seconds: 60,
+					},
+				},
+			},
+			input: &networking.TrafficPolicy_PortTrafficPolicy{},
+			expect: &networking.TrafficPolicy_PortTrafficPolicy{
+				ConnectionPool: &networking.ConnectionPoolSettings{
+					Http: &networking.ConnectionPoolSettings_HTTPSettings{
+						MaxRequestsPerConnection: 1,
+					},
+				},
+			},
+		},
+		{
+			config: &Ingress{
+				UpstreamKeepalive: &UpstreamKeepaliveConfig{
+					timeout: &types.Duration{
+						Seconds: 60,
+					},
+					maxRequests: 100,
+				},
+			},
+			input: &networking.TrafficPolicy_PortTrafficPolicy{
+				ConnectionPool: &networking.ConnectionPoolSettings{
+					Http: &networking.ConnectionPoolSettings_HTTPSettings{
+						H2UpgradePolicy: networking.ConnectionPoolSettings_HTTPSettings_UPGRADE,
+					},
+				},
+			},
+			expect: &networking.TrafficPolicy_PortTrafficPolicy{
+				ConnectionPool: &networking.ConnectionPoolSettings{
+					Http: &networking.ConnectionPoolSettings_HTTPSettings{
+						H2UpgradePolicy: networking.ConnectionPoolSettings_HTTPSettings_UPGRADE,
+						IdleTimeout: &types.Duration{
+							Seconds: 60,
+						},
+						MaxRequestsPerConnection: 100,
+					},
+				},
+			},
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			upstreamKeepalive.ApplyTrafficPolicy(inputCase.input, inputCase.config)
+			if !reflect.DeepEqual(inputCase.input, inputCase.expect) {
+				t.Fatalf("Should be equal")
+			}
+		})
+	}
+}
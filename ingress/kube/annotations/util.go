@@ -15,13 +15,43 @@
 package annotations
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
+	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/model/credentials"
 	"istio.io/istio/pilot/pkg/util/sets"
 )
 
+var (
+	nginxTimeRegex     = regexp.MustCompile(`^(\d+(ms|s|m|h|d|w|M|y))+$`)
+	nginxTimeItemRegex = regexp.MustCompile(`(\d+)(ms|s|m|h|d|w|M|y)`)
+
+	nginxTimeUnits = map[string]time.Duration{
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+		"w":  7 * 24 * time.Hour,
+		"M":  30 * 24 * time.Hour,
+		"y":  365 * 24 * time.Hour,
+	}
+
+	nginxSizeRegex = regexp.MustCompile(`^(\d+)([kKmMgG]?)$`)
+
+	nginxSizeUnits = map[string]uint64{
+		"":  1,
+		"k": 1 << 10,
+		"m": 1 << 20,
+		"g": 1 << 30,
+	}
+)
+
 func extraSecret(name string) model.NamespacedName {
 	result := model.NamespacedName{}
 	res := strings.TrimPrefix(name, credentials.KubernetesIngressSecretTypeURI)
@@ -52,3 +82,79 @@ func splitBySeparator(content, separator string) []string {
 func toSet(slice []string) sets.Set {
 	return sets.NewSet(slice...)
 }
+
+// rejectRoute responds 503 to all requests of route, which is used when the annotations protecting the route
+// are invalid.
+func rejectRoute(route *networking.HTTPRoute) {
+	route.Fault = &networking.HTTPFaultInjection{
+		Abort: &networking.HTTPFaultInjection_Abort{
+			ErrorType: &networking.HTTPFaultInjection_Abort_HttpStatus{
+				HttpStatus: 503,
+			},
+			Percentage: &networking.Percent{
+				Value: 100,
+			},
+		},
+	}
+}
+
+// tcpConnectionPool returns the tcp settings of the connection pool of traffic policy, and creates them if absent,
+// so that the connection pool settings applied by other handlers, such as h2 upgrade policy, are kept.
+func tcpConnectionPool(trafficPolicy *networking.TrafficPolicy_PortTrafficPolicy) *networking.ConnectionPoolSettings_TCPSettings {
+	if trafficPolicy.ConnectionPool == nil {
+		trafficPolicy.ConnectionPool = &networking.ConnectionPoolSettings{}
+	}
+	if trafficPolicy.ConnectionPool.Tcp == nil {
+		trafficPolicy.ConnectionPool.Tcp = &networking.ConnectionPoolSettings_TCPSettings{}
+	}
+	return trafficPolicy.ConnectionPool.Tcp
+}
+
+// httpConnectionPool is the same as tcpConnectionPool, but returns the http settings.
+func httpConnectionPool(trafficPolicy *networking.TrafficPolicy_PortTrafficPolicy) *networking.ConnectionPoolSettings_HTTPSettings {
+	if trafficPolicy.ConnectionPool == nil {
+		trafficPolicy.ConnectionPool = &networking.ConnectionPoolSettings{}
+	}
+	if trafficPolicy.ConnectionPool.Http == nil {
+		trafficPolicy.ConnectionPool.Http = &networking.ConnectionPoolSettings_HTTPSettings{}
+	}
+	return trafficPolicy.ConnectionPool.Http
+}
+
+// parseDuration accepts the time in nginx units, such as 500ms, 60s and 1h 30m, the number without unit
+// as seconds, and the duration of go, such as 1.5s.
+func parseDuration(raw string) (time.Duration, error) {
+	compact := strings.Join(strings.Fields(raw), "")
+	if seconds, err := strconv.ParseInt(compact, 10, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	if !nginxTimeRegex.MatchString(compact) {
+		if d, err := time.ParseDuration(compact); err == nil && d >= 0 {
+			return d, nil
+		}
+		return 0, fmt.Errorf("invalid time %q", raw)
+	}
+
+	var total time.Duration
+	for _, item := range nginxTimeItemRegex.FindAllStringSubmatch(compact, -1) {
+		value, err := strconv.ParseInt(item[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %q", raw)
+		}
+		total += time.Duration(value) * nginxTimeUnits[item[2]]
+	}
+	return total, nil
+}
+
+// parseNginxSize accepts the size in nginx units, such as 512k, 8m and 1g, and the number without unit is bytes.
+func parseNginxSize(raw string) (uint64, error) {
+	items := nginxSizeRegex.FindStringSubmatch(strings.TrimSpace(raw))
+	if items == nil {
+		return 0, fmt.Errorf("invalid size %q", raw)
+	}
+	value, err := strconv.ParseUint(items[1], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", raw)
+	}
+	return value * nginxSizeUnits[strings.ToLower(items[2])], nil
+}
@@ -17,6 +17,7 @@ package annotations
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"istio.io/istio/pilot/pkg/model"
 )
@@ -51,3 +52,117 @@ func TestExtraSecret(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDuration(t *testing.T) {
+	inputCases := []struct {
+		input     string
+		expect    time.Duration
+		expectErr bool
+	}{
+		{
+			input:     "",
+			expectErr: true,
+		},
+		{
+			input:  "10",
+			expect: 10 * time.Second,
+		},
+		{
+			input:  "500ms",
+			expect: 500 * time.Millisecond,
+		},
+		{
+			input:  "1h 30m",
+			expect: 90 * time.Minute,
+		},
+		{
+			input:  "1m30s",
+			expect: 90 * time.Second,
+		},
+		{
+			input:  "1d",
+			expect: 24 * time.Hour,
+		},
+		{
+			input:  "1.5s",
+			expect: 1500 * time.Millisecond,
+		},
+		{
+			input:     "10x",
+			expectErr: true,
+		},
+		{
+			input:     "-1",
+			expectErr: true,
+		},
+		{
+			input:     "-1s",
+			expectErr: true,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			actual, err := parseDuration(inputCase.input)
+			if (err != nil) != inputCase.expectErr {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if actual != inputCase.expect {
+				t.Fatalf("Should be %s, but actual is %s", inputCase.expect, actual)
+			}
+		})
+	}
+}
+
+func TestParseNginxSize(t *testing.T) {
+	inputCases := []struct {
+		input     string
+		expect    uint64
+		expectErr bool
+	}{
+		{
+			input:     "",
+			expectErr: true,
+		},
+		{
+			input:  "0",
+			expect: 0,
+		},
+		{
+			input:  "1024",
+			expect: 1024,
+		},
+		{
+			input:  "512k",
+			expect: 512 * 1024,
+		},
+		{
+			input:  "8M",
+			expect: 8 * 1024 * 1024,
+		},
+		{
+			input:  "1g",
+			expect: 1024 * 1024 * 1024,
+		},
+		{
+			input:     "1.5m",
+			expectErr: true,
+		},
+		{
+			input:     "10mb",
+			expectErr: true,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			actual, err := parseNginxSize(inputCase.input)
+			if (err != nil) != inputCase.expectErr {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if actual != inputCase.expect {
+				t.Fatalf("Should be %d, but actual is %d", inputCase.expect, actual)
+			}
+		})
+	}
+}
@@ -60,15 +60,17 @@ func (w *WrapperGateway) IsHTTPS() bool {
 }
 
 type WrapperHTTPRoute struct {
-	HTTPRoute        *networking.HTTPRoute
-	WrapperConfig    *WrapperConfig
-	RawClusterId     string
-	ClusterId        string
-	ClusterName      string
-	Host             string
-	OriginPath       string
-	OriginPathType   PathType
-	WeightTotal      int32
+	HTTPRoute      *networking.HTTPRoute
+	WrapperConfig  *WrapperConfig
+	RawClusterId   string
+	ClusterId      string
+	ClusterName    string
+	Host           string
+	OriginPath     string
+	OriginPathType PathType
+	WeightTotal    int32
+	// CanaryWeight is the sum of weights of the canaries merged into the route.
+	CanaryWeight     int32
 	IsDefaultBackend bool
 }
 
@@ -94,6 +96,59 @@ type WrapperVirtualService struct {
 type WrapperTrafficPolicy struct {
 	TrafficPolicy *networking.TrafficPolicy_PortTrafficPolicy
 	WrapperConfig *WrapperConfig
+	// SharedWrapperConfigs are the later ingresses using the same service key.
+	SharedWrapperConfigs []*WrapperConfig
+}
+
+// AddSharedWrapperConfig records the ingress using the same service key as the traffic policy.
+func (w *WrapperTrafficPolicy) AddSharedWrapperConfig(wrapper *WrapperConfig) {
+	if w.WrapperConfig == wrapper {
+		return
+	}
+	for _, shared := range w.SharedWrapperConfigs {
+		if shared == wrapper {
+			return
+		}
+	}
+	w.SharedWrapperConfigs = append(w.SharedWrapperConfigs, wrapper)
+}
+
+// AddMirrorTrafficPolicy gives the mirror service of ingress its own traffic policy entry, which shares
+// the traffic policy of ingress. It is called after the backends of all ingresses are converted, so an
+// entry created for a backend is never taken over by an ingress only mirroring to it.
+func AddMirrorTrafficPolicy(convertOptions *ConvertOptions, wrapper *WrapperConfig) {
+	mirror := wrapper.AnnotationsConfig.Mirror
+	if mirror == nil {
+		return
+	}
+
+	serviceKey := ServiceKey{
+		Namespace: mirror.ServiceName.Namespace,
+		Name:      mirror.ServiceName.Name,
+		Port:      int32(mirror.Port),
+	}
+	trafficPolicy, exist := convertOptions.Service2TrafficPolicy[serviceKey]
+	if !exist {
+		convertOptions.Service2TrafficPolicy[serviceKey] = &WrapperTrafficPolicy{
+			TrafficPolicy: &networking.TrafficPolicy_PortTrafficPolicy{
+				Port: &networking.PortSelector{
+					Number: mirror.Port,
+				},
+			},
+			WrapperConfig: wrapper,
+		}
+		return
+	}
+
+	if !wrapper.AnnotationsConfig.NeedTrafficPolicy() {
+		return
+	}
+	// An ingress without traffic policy only reserves the entry.
+	if !trafficPolicy.WrapperConfig.AnnotationsConfig.NeedTrafficPolicy() {
+		trafficPolicy.WrapperConfig = wrapper
+		return
+	}
+	trafficPolicy.AddSharedWrapperConfig(wrapper)
 }
 
 type WrapperDestinationRule struct {
@@ -123,6 +178,10 @@ type IngressController interface {
 
 	ConvertTrafficPolicy(convertOptions *ConvertOptions, wrapper *WrapperConfig) error
 
+	// SetIngressErrors replaces the translation errors of ingresses within the cluster, which are
+	// reported to users by the status syncer.
+	SetIngressErrors(errors []IngressError)
+
 	// Run until a signal is received
 	Run(stop <-chan struct{})
 
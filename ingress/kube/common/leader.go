@@ -0,0 +1,94 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	// statusLeaderElectionID is the prefix of leases electing the replica which writes the status and the
+	// error annotation of ingresses, there is one lease for each cluster.
+	statusLeaderElectionID = "higress-status-leader"
+
+	leaseDuration = 30 * time.Second
+	renewDeadline = 15 * time.Second
+	retryPeriod   = 5 * time.Second
+)
+
+// RunAsStatusLeader runs f while the replica leads the status syncers of the cluster, so that the replicas don't
+// write the same ingresses with different views. The leader is elected again once the leadership is lost, until
+// stop is closed. The stop channel passed to f is closed when the leadership is lost.
+func RunAsStatusLeader(client kubernetes.Interface, namespace, clusterId string, stop <-chan struct{}, f func(stop <-chan struct{})) {
+	identity, err := os.Hostname()
+	if err != nil {
+		IngressLog.Errorf("Get the identity of status leader election within cluster %s fail, err %v", clusterId, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	config := leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      CreateConvertedName(statusLeaderElectionID, strings.ToLower(clusterId)),
+			},
+			Client: client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity: identity,
+			},
+		},
+		LeaseDuration: leaseDuration,
+		RenewDeadline: renewDeadline,
+		RetryPeriod:   retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				IngressLog.Infof("Start leading the status syncer within cluster %s", clusterId)
+				f(ctx.Done())
+			},
+			OnStoppedLeading: func() {
+				IngressLog.Infof("Stop leading the status syncer within cluster %s", clusterId)
+			},
+		},
+		ReleaseOnCancel: true,
+	}
+	for {
+		leaderelection.RunOrDie(ctx, config)
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+	}
+}
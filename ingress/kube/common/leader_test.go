@@ -0,0 +1,55 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunAsStatusLeader(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	stop := make(chan struct{})
+	leading := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		RunAsStatusLeader(client, "higress-system", "Kubernetes", stop, func(stop <-chan struct{}) {
+			close(leading)
+			<-stop
+		})
+		close(done)
+	}()
+
+	select {
+	case <-leading:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Should lead the status syncer")
+	}
+	if _, err := client.CoordinationV1().Leases("higress-system").Get(context.TODO(),
+		"higress-status-leader-kubernetes", metav1.GetOptions{}); err != nil {
+		t.Fatalf("Should hold the lease of cluster, err %v", err)
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Should stop once the stop channel is closed")
+	}
+}
@@ -34,6 +34,12 @@ const (
 	DuplicatedTls Event = "duplicated-tls"
 
 	PortNameResolveError Event = "port-name-resolve-error"
+
+	InvalidAnnotation Event = "invalid-annotation"
+
+	DuplicatedCanary Event = "duplicated-canary"
+
+	InvalidCanaryWeight Event = "invalid-canary-weight"
 )
 
 var (
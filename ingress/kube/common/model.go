@@ -42,6 +42,8 @@ const (
 
 	HostAnnotation = prefixAnnotation + "host"
 
+	RegistryTypeAnnotation = prefixAnnotation + "registry-type"
+
 	// PrefixMatchRegex optionally matches "/..." at the end of a path.
 	// regex taken from https://github.com/projectcontour/contour/blob/2b3376449bedfea7b8cea5fbade99fb64009c0f6/internal/envoy/v3/route.go#L59
 	PrefixMatchRegex = `((\/).*)?`
@@ -66,6 +68,12 @@ const (
 	AppKey            = "app"
 	AppValue          = "higress-gateway"
 	SvcHostNameSuffix = ".multiplenic"
+
+	// EventComponent is the source component of events recorded by the controller.
+	EventComponent = "higress-controller"
+
+	// TranslationErrorsAnnotation records the translation errors of the ingress, if the status annotation is enabled.
+	TranslationErrorsAnnotation = "higress.io/translation-errors"
 )
 
 var (
@@ -78,6 +86,7 @@ var (
 		collections.IstioNetworkingV1Alpha3Gateways,
 		collections.IstioNetworkingV1Alpha3Destinationrules,
 		collections.IstioNetworkingV1Alpha3Envoyfilters,
+		collections.IstioNetworkingV1Alpha3Serviceentries,
 	)
 
 	clusterPrefix    string
@@ -92,15 +101,20 @@ func init() {
 }
 
 type Options struct {
-	Enable               bool
-	ClusterId            string
-	IngressClass         string
-	WatchNamespace       string
-	RawClusterId         string
-	EnableStatus         bool
-	SystemNamespace      string
-	GatewaySelectorKey   string
-	GatewaySelectorValue string
+	Enable                bool
+	ClusterId             string
+	IngressClass          string
+	WatchNamespace        string
+	RawClusterId          string
+	EnableStatus          bool
+	SystemNamespace       string
+	GatewaySelectorKey    string
+	GatewaySelectorValue  string
+	KeepStaleWhenEmpty    bool
+	EnableGatewayAPI      bool
+	EnableErrorAnnotation bool
+	// The cluster of controller, through which gateways fetch the generic secrets by sds.
+	SdsCluster string
 }
 
 type BasicAuthRules struct {
@@ -114,11 +128,40 @@ type Rule struct {
 	Encrypted   bool     `json:"encrypted"`
 }
 
+// DigestAuthRules is the configuration of the digest auth filter.
+// Nonces are signed by the nonce key together with the client address, so that any gateway replica can
+// verify them until they expire, while the other clients can't replay them.
+// All requests of the rules are rejected if the nonce key is empty.
+type DigestAuthRules struct {
+	Rules       []*DigestRule
+	NonceKey    string
+	NonceMaxAge int64
+}
+
+type DigestRule struct {
+	Realm      string
+	MatchRoute []string
+	// Credentials are in the format of user:HA1.
+	Credentials []string
+}
+
+// IngressError is the reason why a part of the ingress is dropped during translation.
+type IngressError struct {
+	ClusterId string
+	Namespace string
+	Name      string
+	Host      string
+	Event     Event
+	Message   string
+}
+
 type IngressDomainCache struct {
 	// host as key
 	Valid map[string]*IngressDomainBuilder
 
 	Invalid []model.IngressDomain
+
+	Errors []IngressError
 }
 
 func NewIngressDomainCache() *IngressDomainCache {
@@ -140,6 +183,20 @@ func (i *IngressDomainCache) Extract() model.IngressDomainCollection {
 	}
 }
 
+// AddInvalid records the invalid domain and the error of ingress defining it.
+func (i *IngressDomainCache) AddInvalid(builder *IngressDomainBuilder) {
+	domain := builder.Build()
+	i.Invalid = append(i.Invalid, domain)
+	i.Errors = append(i.Errors, IngressError{
+		ClusterId: builder.ClusterId,
+		Namespace: builder.Ingress.Namespace,
+		Name:      builder.Ingress.Name,
+		Host:      builder.Host,
+		Event:     builder.Event,
+		Message:   domain.Error,
+	})
+}
+
 type ConvertOptions struct {
 	HostWithRule2Ingress map[string]*config.Config
 
@@ -202,6 +259,7 @@ func CreateOptions(clusterId cluster.ID) Options {
 type IngressRouteCache struct {
 	routes  map[string]*IngressRouteBuilder
 	invalid []model.IngressRoute
+	errors  []IngressError
 }
 
 func NewIngressRouteCache() *IngressRouteCache {
@@ -251,7 +309,16 @@ func (i *IngressRouteCache) NewAndAdd(route *WrapperHTTPRoute) {
 func (i *IngressRouteCache) Add(builder *IngressRouteBuilder) {
 	if builder.Event != Normal {
 		builder.RouteName = "invalid-route"
-		i.invalid = append(i.invalid, builder.Build())
+		route := builder.Build()
+		i.invalid = append(i.invalid, route)
+		i.errors = append(i.errors, IngressError{
+			ClusterId: builder.ClusterId,
+			Namespace: builder.Ingress.Namespace,
+			Name:      builder.Ingress.Name,
+			Host:      builder.Host,
+			Event:     builder.Event,
+			Message:   route.Error,
+		})
 		return
 	}
 
@@ -291,6 +358,11 @@ func (i *IngressRouteCache) Extract() model.IngressRouteCollection {
 	}
 }
 
+// Errors returns the errors of ingresses whose routes are invalid.
+func (i *IngressRouteCache) Errors() []IngressError {
+	return i.errors
+}
+
 type IngressRouteBuilder struct {
 	ClusterId   string
 	RouteName   string
@@ -335,6 +407,25 @@ func (i *IngressRouteBuilder) Build() model.IngressRoute {
 			i.Ingress.Name,
 			i.ClusterId,
 		)
+	case DuplicatedCanary:
+		preClusterId := GetClusterId(i.PreIngress.Annotations)
+		errorMsg = fmt.Sprintf("canary of host %s and path %s in ingress %s/%s within cluster %s has the same match as the canary in ingress %s/%s within cluster %s",
+			i.Host,
+			i.Path,
+			i.Ingress.Namespace,
+			i.Ingress.Name,
+			i.ClusterId,
+			i.PreIngress.Namespace,
+			i.PreIngress.Name,
+			preClusterId)
+	case InvalidCanaryWeight:
+		errorMsg = fmt.Sprintf("canary weight of host %s and path %s in ingress %s/%s within cluster %s exceeds the weight total left by other canaries, or the weight total differs from theirs",
+			i.Host,
+			i.Path,
+			i.Ingress.Namespace,
+			i.Ingress.Name,
+			i.ClusterId,
+		)
 	}
 
 	ingressRoute := model.IngressRoute{
@@ -21,13 +21,16 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/gogo/protobuf/proto"
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/kube"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/version"
 
+	"github.com/alibaba/higress/ingress/kube/annotations"
 	. "github.com/alibaba/higress/ingress/log"
 )
 
@@ -198,6 +201,46 @@ func SortHTTPRoutes(routes []*WrapperHTTPRoute) {
 	})
 }
 
+// SortCanaryIngresses sorts the canary ingresses by priority in descending order,
+// and the canaries with the same priority keep their original order.
+func SortCanaryIngresses(configs []*WrapperConfig) {
+	sort.SliceStable(configs, func(i, j int) bool {
+		return configs[i].AnnotationsConfig.Canary.Priority > configs[j].AnnotationsConfig.Canary.Priority
+	})
+}
+
+// FindDuplicatedCanary returns the canary route within routes which has the same match as the canary,
+// so that one of them could never be reached.
+func FindDuplicatedCanary(canary *WrapperHTTPRoute, routes []*WrapperHTTPRoute) *WrapperHTTPRoute {
+	for _, route := range routes {
+		if !route.WrapperConfig.AnnotationsConfig.IsCanary() || route.OriginPath != canary.OriginPath ||
+			route.OriginPathType != canary.OriginPathType || len(route.HTTPRoute.Match) != len(canary.HTTPRoute.Match) {
+			continue
+		}
+
+		duplicated := true
+		for idx, match := range route.HTTPRoute.Match {
+			if !proto.Equal(match, canary.HTTPRoute.Match[idx]) {
+				duplicated = false
+				break
+			}
+		}
+		if duplicated {
+			return route
+		}
+	}
+	return nil
+}
+
+// CanaryWeightAvailable returns whether the weight canary can be merged into the route.
+// All weight canaries of the route must share the same weight total, and their weights can't exceed it.
+func CanaryWeightAvailable(route *WrapperHTTPRoute, canary *annotations.CanaryConfig) bool {
+	if route.WeightTotal != 0 && route.WeightTotal != int32(canary.WeightTotal) {
+		return false
+	}
+	return route.CanaryWeight+int32(canary.Weight) <= int32(canary.WeightTotal)
+}
+
 func constructRouteName(route *WrapperHTTPRoute) string {
 	var builder strings.Builder
 	// host-pathType-path
@@ -312,6 +355,22 @@ func ConvertBackendService(routeDestination *networking.HTTPRouteDestination) mo
 	}
 }
 
+// ConvertMcpDestination copies the destinations which refer to the services discovered from registries.
+func ConvertMcpDestination(config *annotations.DestinationConfig, builder *IngressRouteBuilder) []*networking.HTTPRouteDestination {
+	var routeDestinations []*networking.HTTPRouteDestination
+	var serviceList []model.BackendService
+	for _, routeDestination := range config.McpDestination {
+		routeDestinations = append(routeDestinations, routeDestination.DeepCopy())
+		serviceList = append(serviceList, model.BackendService{
+			Name:   routeDestination.Destination.Host,
+			Port:   routeDestination.Destination.Port.Number,
+			Weight: routeDestination.Weight,
+		})
+	}
+	builder.ServiceList = serviceList
+	return routeDestinations
+}
+
 func getLoadBalancerIp(svc *v1.Service) []string {
 	var out []string
 
@@ -363,3 +422,34 @@ func GetLbStatusList(svcList []*v1.Service) []v1.LoadBalancerIngress {
 	sort.SliceStable(lbi, SortLbIngressList(lbi))
 	return lbi
 }
+
+// EventReason converts the event into the reason of kubernetes event, e.g. duplicated-route to DuplicatedRoute.
+func EventReason(event Event) string {
+	parts := strings.Split(string(event), "-")
+	for idx, part := range parts {
+		if part == "" {
+			continue
+		}
+		parts[idx] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return strings.Join(parts, "")
+}
+
+// GroupIngressErrors groups the errors by namespace/name of ingress, keeping the order within each ingress.
+func GroupIngressErrors(errors []IngressError) map[types.NamespacedName][]IngressError {
+	out := map[types.NamespacedName][]IngressError{}
+	for _, err := range errors {
+		key := types.NamespacedName{Namespace: err.Namespace, Name: err.Name}
+		out[key] = append(out[key], err)
+	}
+	return out
+}
+
+// FormatIngressErrors joins the messages of errors, one line for each error.
+func FormatIngressErrors(errors []IngressError) string {
+	messages := make([]string, 0, len(errors))
+	for _, err := range errors {
+		messages = append(messages, EventReason(err.Event)+": "+err.Message)
+	}
+	return strings.Join(messages, "\n")
+}
@@ -15,12 +15,14 @@
 package common
 
 import (
+	"reflect"
 	"testing"
 
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pkg/config"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/alibaba/higress/ingress/kube/annotations"
 )
@@ -471,3 +473,218 @@ func TestSortRoutes(t *testing.T) {
 		t.Fatal("should be test-3")
 	}
 }
+
+func TestEventReason(t *testing.T) {
+	testCases := []struct {
+		input  Event
+		expect string
+	}{
+		{
+			input:  DuplicatedRoute,
+			expect: "DuplicatedRoute",
+		},
+		{
+			input:  PortNameResolveError,
+			expect: "PortNameResolveError",
+		},
+		{
+			input:  Normal,
+			expect: "Normal",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run("", func(t *testing.T) {
+			if EventReason(testCase.input) != testCase.expect {
+				t.Fatalf("Should be %s, but actual is %s", testCase.expect, EventReason(testCase.input))
+			}
+		})
+	}
+}
+
+func TestIngressRouteCacheErrors(t *testing.T) {
+	ingress := &config.Config{
+		Meta: config.Meta{
+			Name:      "foo",
+			Namespace: "bar",
+		},
+	}
+	cache := NewIngressRouteCache()
+	cache.Add(&IngressRouteBuilder{
+		ClusterId: "cluster1",
+		RouteName: "valid",
+		Host:      "test.com",
+		Path:      "/a",
+		Event:     Normal,
+		Ingress:   ingress,
+	})
+	cache.Add(&IngressRouteBuilder{
+		ClusterId: "cluster1",
+		RouteName: "invalid",
+		Host:      "test.com",
+		Path:      "/b",
+		Event:     InvalidBackendService,
+		Ingress:   ingress,
+	})
+
+	expect := []IngressError{
+		{
+			ClusterId: "cluster1",
+			Namespace: "bar",
+			Name:      "foo",
+			Host:      "test.com",
+			Event:     InvalidBackendService,
+			Message:   "backend service of host test.com and path /b is invalid defined in ingress bar/foo within cluster cluster1",
+		},
+	}
+	if !reflect.DeepEqual(cache.Errors(), expect) {
+		t.Fatalf("Should be equal.")
+	}
+
+	grouped := GroupIngressErrors(append(cache.Errors(), IngressError{
+		Namespace: "bar",
+		Name:      "foo",
+		Event:     DuplicatedRoute,
+		Message:   "duplicated",
+	}))
+	if len(grouped) != 1 {
+		t.Fatalf("Should be grouped into one ingress.")
+	}
+	message := FormatIngressErrors(grouped[types.NamespacedName{Namespace: "bar", Name: "foo"}])
+	if message != "InvalidBackendService: "+expect[0].Message+"\nDuplicatedRoute: duplicated" {
+		t.Fatalf("Unexpected message %s", message)
+	}
+}
+
+func TestSortCanaryIngresses(t *testing.T) {
+	newCanary := func(name string, priority int) *WrapperConfig {
+		return &WrapperConfig{
+			Config: &config.Config{
+				Meta: config.Meta{
+					Name: name,
+				},
+			},
+			AnnotationsConfig: &annotations.Ingress{
+				Canary: &annotations.CanaryConfig{
+					Enabled:  true,
+					Priority: priority,
+				},
+			},
+		}
+	}
+
+	input := []*WrapperConfig{
+		newCanary("a", 0),
+		newCanary("b", 10),
+		newCanary("c", 0),
+		newCanary("d", 5),
+	}
+	SortCanaryIngresses(input)
+
+	var actual []string
+	for _, cfg := range input {
+		actual = append(actual, cfg.Config.Name)
+	}
+	if !reflect.DeepEqual(actual, []string{"b", "d", "a", "c"}) {
+		t.Fatalf("Unexpected order %v", actual)
+	}
+}
+
+func TestFindDuplicatedCanary(t *testing.T) {
+	newRoute := func(canary bool, header string) *WrapperHTTPRoute {
+		return &WrapperHTTPRoute{
+			WrapperConfig: &WrapperConfig{
+				Config: &config.Config{},
+				AnnotationsConfig: &annotations.Ingress{
+					Canary: &annotations.CanaryConfig{
+						Enabled: canary,
+					},
+				},
+			},
+			OriginPathType: Prefix,
+			OriginPath:     "/test",
+			HTTPRoute: &networking.HTTPRoute{
+				Match: []*networking.HTTPMatchRequest{
+					{
+						Headers: map[string]*networking.StringMatch{
+							header: {
+								MatchType: &networking.StringMatch_Exact{
+									Exact: "always",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	canaryV2 := newRoute(true, "v2")
+	normal := newRoute(false, "v3")
+	routes := []*WrapperHTTPRoute{canaryV2, normal}
+
+	if FindDuplicatedCanary(newRoute(true, "v3"), routes) != nil {
+		t.Fatal("Should not be duplicated with the normal route")
+	}
+	if FindDuplicatedCanary(newRoute(true, "v2"), routes) != canaryV2 {
+		t.Fatal("Should be duplicated with the canary v2")
+	}
+}
+
+func TestCanaryWeightAvailable(t *testing.T) {
+	testCases := []struct {
+		route  *WrapperHTTPRoute
+		canary *annotations.CanaryConfig
+		expect bool
+	}{
+		{
+			route: &WrapperHTTPRoute{},
+			canary: &annotations.CanaryConfig{
+				Weight:      10,
+				WeightTotal: 100,
+			},
+			expect: true,
+		},
+		{
+			route: &WrapperHTTPRoute{
+				WeightTotal:  100,
+				CanaryWeight: 90,
+			},
+			canary: &annotations.CanaryConfig{
+				Weight:      10,
+				WeightTotal: 100,
+			},
+			expect: true,
+		},
+		{
+			route: &WrapperHTTPRoute{
+				WeightTotal:  100,
+				CanaryWeight: 95,
+			},
+			canary: &annotations.CanaryConfig{
+				Weight:      10,
+				WeightTotal: 100,
+			},
+			expect: false,
+		},
+		{
+			route: &WrapperHTTPRoute{
+				WeightTotal:  100,
+				CanaryWeight: 10,
+			},
+			canary: &annotations.CanaryConfig{
+				Weight:      10,
+				WeightTotal: 1000,
+			},
+			expect: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run("", func(t *testing.T) {
+			if CanaryWeightAvailable(testCase.route, testCase.canary) != testCase.expect {
+				t.Fatalf("Should be %t", testCase.expect)
+			}
+		})
+	}
+}
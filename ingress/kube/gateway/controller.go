@@ -0,0 +1,328 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/gvk"
+	kubeclient "istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/controllers"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	listers "sigs.k8s.io/gateway-api/pkg/client/listers/gateway/apis/v1alpha2"
+
+	"github.com/alibaba/higress/ingress/kube/common"
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const convertedNamePrefix = "istio-autogenerated-k8s-gateway"
+
+var httpRouteResource = gatewayv1alpha2.SchemeGroupVersion.WithResource("httproutes")
+
+// Controller watches the resources of Kubernetes Gateway API, and converts them into
+// the same istio gateways and virtual services as ingresses.
+//
+// The gateway-api v0.4.0 pinned by istio 1.12 only serves v1alpha2, which predates the
+// URLRewrite filter and ReferenceGrant. HTTPRoutes are watched as unstructured objects, so that
+// the config of URLRewrite filter served by the v0.5.0 CRDs is kept, and cross-namespace
+// references are checked against ReferencePolicy, the former name of ReferenceGrant, which will be
+// supported once gateway-api is upgraded together with istio.
+type Controller interface {
+	// RegisterEventHandler adds a handler to receive config update events for a
+	// configuration type
+	RegisterEventHandler(kind config.GroupVersionKind, handler model.EventHandler)
+
+	// List returns the converted istio configs of the kind. Only gateways and virtual services
+	// are converted, since v1alpha2 has no policy of backends which could be translated into
+	// destination rules.
+	List(kind config.GroupVersionKind) []config.Config
+
+	// Run until a signal is received
+	Run(stop <-chan struct{})
+
+	SetWatchErrorHandler(func(r *cache.Reflector, err error)) error
+
+	// HasSynced returns true after initial cache synchronization is complete
+	HasSynced() bool
+}
+
+var _ Controller = &controller{}
+
+type controller struct {
+	queue                  workqueue.RateLimitingInterface
+	virtualServiceHandlers []model.EventHandler
+	gatewayHandlers        []model.EventHandler
+
+	options common.Options
+
+	gatewayClassInformer    cache.SharedIndexInformer
+	gatewayClassLister      listers.GatewayClassLister
+	gatewayInformer         cache.SharedIndexInformer
+	gatewayLister           listers.GatewayLister
+	httpRouteInformer       cache.SharedIndexInformer
+	httpRouteLister         cache.GenericLister
+	referencePolicyInformer cache.SharedIndexInformer
+	referencePolicyLister   listers.ReferencePolicyLister
+}
+
+// NewController creates a new Gateway API controller
+func NewController(client kubeclient.Client, options common.Options) Controller {
+	q := workqueue.NewRateLimitingQueue(workqueue.DefaultItemBasedRateLimiter())
+
+	informers := client.GatewayAPIInformer().Gateway().V1alpha2()
+	gatewayClasses := informers.GatewayClasses()
+	gateways := informers.Gateways()
+	// The v0.4.0 types drop the config of URLRewrite filter, see fromUnstructuredHTTPRoute.
+	httpRoutes := dynamicinformer.NewFilteredDynamicInformer(client.Dynamic(), httpRouteResource,
+		metav1.NamespaceAll, 0, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil)
+	referencePolicies := informers.ReferencePolicies()
+
+	c := &controller{
+		queue:                   q,
+		options:                 options,
+		gatewayClassInformer:    gatewayClasses.Informer(),
+		gatewayClassLister:      gatewayClasses.Lister(),
+		gatewayInformer:         gateways.Informer(),
+		gatewayLister:           gateways.Lister(),
+		httpRouteInformer:       httpRoutes.Informer(),
+		httpRouteLister:         httpRoutes.Lister(),
+		referencePolicyInformer: referencePolicies.Informer(),
+		referencePolicyLister:   referencePolicies.Lister(),
+	}
+
+	// Any change of these resources may affect the whole converted result,
+	// so we don't care which one changes and just push.
+	handler := controllers.LatestVersionHandlerFuncs(controllers.EnqueueForSelf(q))
+	c.gatewayClassInformer.AddEventHandler(handler)
+	c.gatewayInformer.AddEventHandler(handler)
+	c.httpRouteInformer.AddEventHandler(handler)
+	c.referencePolicyInformer.AddEventHandler(handler)
+
+	return c
+}
+
+func (c *controller) RegisterEventHandler(kind config.GroupVersionKind, f model.EventHandler) {
+	switch kind {
+	case gvk.VirtualService:
+		c.virtualServiceHandlers = append(c.virtualServiceHandlers, f)
+	case gvk.Gateway:
+		c.gatewayHandlers = append(c.gatewayHandlers, f)
+	}
+}
+
+func (c *controller) Run(stop <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	// Unlike the typed informers, the dynamic one isn't started by the kube client.
+	go c.httpRouteInformer.Run(stop)
+
+	if !cache.WaitForCacheSync(stop, c.HasSynced) {
+		IngressLog.Errorf("Failed to sync gateway controller cache for cluster %s", c.options.ClusterId)
+		return
+	}
+	go wait.Until(c.worker, time.Second, stop)
+	<-stop
+}
+
+func (c *controller) worker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *controller) processNextWorkItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+	IngressLog.Debugf("gateway api resource %s push to queue", key)
+	c.onEvent(key.(types.NamespacedName))
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *controller) onEvent(namespacedName types.NamespacedName) {
+	push := func(kind config.GroupVersionKind, handlers []model.EventHandler) {
+		metadata := config.Meta{
+			Name:             namespacedName.Name + "-gateway-api",
+			Namespace:        namespacedName.Namespace,
+			GroupVersionKind: kind,
+			// Set this label so that we do not compare configs and just push.
+			Labels: map[string]string{constants.AlwaysPushLabel: "true"},
+		}
+		for _, f := range handlers {
+			f(config.Config{Meta: metadata}, config.Config{Meta: metadata}, model.EventUpdate)
+		}
+	}
+
+	push(gvk.Gateway, c.gatewayHandlers)
+	push(gvk.VirtualService, c.virtualServiceHandlers)
+}
+
+func (c *controller) SetWatchErrorHandler(handler func(r *cache.Reflector, err error)) error {
+	var errs error
+	for _, informer := range []cache.SharedIndexInformer{c.gatewayClassInformer, c.gatewayInformer,
+		c.httpRouteInformer, c.referencePolicyInformer} {
+		if err := informer.SetWatchErrorHandler(handler); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (c *controller) HasSynced() bool {
+	return c.gatewayClassInformer.HasSynced() && c.gatewayInformer.HasSynced() &&
+		c.httpRouteInformer.HasSynced() && c.referencePolicyInformer.HasSynced()
+}
+
+func (c *controller) List(kind config.GroupVersionKind) []config.Config {
+	if kind != gvk.Gateway && kind != gvk.VirtualService {
+		return nil
+	}
+
+	input := &ConvertInput{
+		RawClusterId: c.options.RawClusterId,
+	}
+	if c.options.GatewaySelectorKey != "" {
+		input.Selector = map[string]string{c.options.GatewaySelectorKey: c.options.GatewaySelectorValue}
+	}
+	var err error
+	if input.GatewayClasses, err = c.gatewayClassLister.List(labels.Everything()); err != nil {
+		IngressLog.Errorf("List gateway classes fail in cluster %s, err %v", c.options.ClusterId, err)
+		return nil
+	}
+	if input.Gateways, err = c.gatewayLister.List(labels.Everything()); err != nil {
+		IngressLog.Errorf("List gateways fail in cluster %s, err %v", c.options.ClusterId, err)
+		return nil
+	}
+	httpRoutes, err := c.httpRouteLister.List(labels.Everything())
+	if err != nil {
+		IngressLog.Errorf("List httproutes fail in cluster %s, err %v", c.options.ClusterId, err)
+		return nil
+	}
+	input.URLRewrites = map[URLRewriteKey]*HTTPURLRewriteFilter{}
+	for _, obj := range httpRoutes {
+		route, err := fromUnstructuredHTTPRoute(obj, input.URLRewrites)
+		if err != nil {
+			IngressLog.Errorf("Convert httproute fail in cluster %s, err %v", c.options.ClusterId, err)
+			continue
+		}
+		input.HTTPRoutes = append(input.HTTPRoutes, route)
+	}
+	if input.ReferencePolicies, err = c.referencePolicyLister.List(labels.Everything()); err != nil {
+		IngressLog.Errorf("List reference policies fail in cluster %s, err %v", c.options.ClusterId, err)
+		return nil
+	}
+
+	output := Convert(input)
+	var out []config.Config
+	switch kind {
+	case gvk.Gateway:
+		for _, gateway := range output.Gateways {
+			out = append(out, config.Config{
+				Meta: config.Meta{
+					GroupVersionKind: gvk.Gateway,
+					Name:             convertedGatewayName(gateway.Source),
+					Namespace:        c.options.SystemNamespace,
+					Annotations: map[string]string{
+						common.ClusterIdAnnotation: c.options.ClusterId,
+					},
+				},
+				Spec: gateway.Gateway,
+			})
+		}
+	case gvk.VirtualService:
+		for _, vs := range output.VirtualServices {
+			virtualService := vs.VirtualService
+			for _, parent := range vs.Parents {
+				virtualService.Gateways = append(virtualService.Gateways, c.options.SystemNamespace+"/"+convertedGatewayName(parent))
+			}
+			out = append(out, config.Config{
+				Meta: config.Meta{
+					GroupVersionKind: gvk.VirtualService,
+					Name:             common.CreateConvertedName(convertedNamePrefix, common.CleanHost(vs.Host)),
+					Namespace:        c.options.SystemNamespace,
+					Annotations: map[string]string{
+						common.ClusterIdAnnotation: c.options.ClusterId,
+					},
+				},
+				Spec: virtualService,
+			})
+		}
+	}
+	return out
+}
+
+func convertedGatewayName(gateway types.NamespacedName) string {
+	return common.CreateConvertedName(convertedNamePrefix, gateway.Namespace, gateway.Name)
+}
+
+// fromUnstructuredHTTPRoute converts the unstructured httproute into the v0.4.0 type, and collects the configs of
+// URLRewrite filters into urlRewrites, which are dropped by the conversion.
+func fromUnstructuredHTTPRoute(obj runtime.Object, urlRewrites map[URLRewriteKey]*HTTPURLRewriteFilter) (*gatewayv1alpha2.HTTPRoute, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, kerrors.NewBadRequest("httproute object is not unstructured")
+	}
+
+	route := &gatewayv1alpha2.HTTPRoute{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), route); err != nil {
+		return nil, err
+	}
+
+	rules, _, _ := unstructured.NestedSlice(u.Object, "spec", "rules")
+	for ruleIdx, rule := range rules {
+		ruleObj, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		filters, _, _ := unstructured.NestedSlice(ruleObj, "filters")
+		for _, filter := range filters {
+			filterObj, ok := filter.(map[string]interface{})
+			if !ok || filterObj["type"] != string(httpRouteFilterURLRewrite) {
+				continue
+			}
+			config, _, _ := unstructured.NestedMap(filterObj, "urlRewrite")
+			if config == nil {
+				continue
+			}
+			urlRewrite := &HTTPURLRewriteFilter{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(config, urlRewrite); err != nil {
+				return nil, err
+			}
+			urlRewrites[URLRewriteKey{
+				Route: types.NamespacedName{Namespace: route.Namespace, Name: route.Name},
+				Rule:  ruleIdx,
+			}] = urlRewrite
+		}
+	}
+	return route, nil
+}
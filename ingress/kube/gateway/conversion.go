@@ -0,0 +1,794 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model/credentials"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	"github.com/alibaba/higress/ingress/kube/util"
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+const (
+	// ControllerName is the controller name of GatewayClass handled by higress.
+	ControllerName = "higress.io/gateway-controller"
+
+	gatewayKind   = "Gateway"
+	httpRouteKind = "HTTPRoute"
+	serviceKind   = "Service"
+	secretKind    = "Secret"
+
+	defaultWeight = 1
+	totalWeight   = 100
+
+	// httpRouteFilterURLRewrite is introduced by gateway-api v0.5.0, the filter is kept
+	// by the v0.4.0 types without the config, see HTTPURLRewriteFilter.
+	httpRouteFilterURLRewrite gatewayv1alpha2.HTTPRouteFilterType = "URLRewrite"
+
+	fullPathHTTPPathModifier    = "ReplaceFullPath"
+	prefixMatchHTTPPathModifier = "ReplacePrefixMatch"
+
+	// invalidRuleStatus is responded by the rules which can't work as expected,
+	// e.g. all backends are invalid or some filters are unsupported.
+	invalidRuleStatus = 500
+)
+
+// HTTPURLRewriteFilter is the config of URLRewrite filter in gateway-api v0.5.0. It is dropped
+// by the v0.4.0 types, so the controller reads it from the httproutes as unstructured objects.
+type HTTPURLRewriteFilter struct {
+	Hostname *string           `json:"hostname,omitempty"`
+	Path     *HTTPPathModifier `json:"path,omitempty"`
+}
+
+// HTTPPathModifier rewrites either the full path or the matched prefix of path.
+type HTTPPathModifier struct {
+	Type               string  `json:"type"`
+	ReplaceFullPath    *string `json:"replaceFullPath,omitempty"`
+	ReplacePrefixMatch *string `json:"replacePrefixMatch,omitempty"`
+}
+
+// URLRewriteKey locates the rule of httproute which the URLRewrite filter belongs to.
+type URLRewriteKey struct {
+	Route types.NamespacedName
+	Rule  int
+}
+
+// ConvertInput is the snapshot of Gateway API resources to be converted.
+type ConvertInput struct {
+	GatewayClasses    []*gatewayv1alpha2.GatewayClass
+	Gateways          []*gatewayv1alpha2.Gateway
+	HTTPRoutes        []*gatewayv1alpha2.HTTPRoute
+	ReferencePolicies []*gatewayv1alpha2.ReferencePolicy
+	// The configs of URLRewrite filters, which are absent from HTTPRoutes.
+	URLRewrites map[URLRewriteKey]*HTTPURLRewriteFilter
+
+	// Used to build the credential name of tls secrets.
+	RawClusterId string
+	// The selector of istio gateways.
+	Selector map[string]string
+}
+
+type ConvertedGateway struct {
+	// The namespace/name of Gateway API gateway
+	Source  types.NamespacedName
+	Gateway *networking.Gateway
+}
+
+type ConvertedVirtualService struct {
+	Host string
+	// The Gateway API gateways which the routes attach to
+	Parents        []types.NamespacedName
+	VirtualService *networking.VirtualService
+}
+
+type ConvertOutput struct {
+	Gateways        []*ConvertedGateway
+	VirtualServices []*ConvertedVirtualService
+}
+
+type listener struct {
+	gatewayv1alpha2.Listener
+	gateway *gatewayv1alpha2.Gateway
+}
+
+type routeWithPriority struct {
+	route *networking.HTTPRoute
+
+	exactPath    bool
+	pathLength   int
+	hasMethod    bool
+	headerCount  int
+	queryCount   int
+	creationTime time.Time
+	source       string
+}
+
+type converter struct {
+	input *ConvertInput
+	// key: namespace/name of gateway
+	listeners map[types.NamespacedName][]*listener
+}
+
+// Convert translates the Gateway API resources into istio gateways and virtual services.
+// Gateways are converted one by one, and routes are aggregated into one virtual service per host.
+func Convert(input *ConvertInput) *ConvertOutput {
+	c := &converter{
+		input:     input,
+		listeners: map[types.NamespacedName][]*listener{},
+	}
+
+	output := &ConvertOutput{}
+	output.Gateways = c.convertGateways()
+	output.VirtualServices = c.convertHTTPRoutes()
+	return output
+}
+
+func (c *converter) convertGateways() []*ConvertedGateway {
+	classes := map[string]struct{}{}
+	for _, class := range c.input.GatewayClasses {
+		if string(class.Spec.ControllerName) == ControllerName {
+			classes[class.Name] = struct{}{}
+		}
+	}
+
+	gateways := make([]*gatewayv1alpha2.Gateway, 0, len(c.input.Gateways))
+	for _, gateway := range c.input.Gateways {
+		if _, exist := classes[string(gateway.Spec.GatewayClassName)]; exist {
+			gateways = append(gateways, gateway)
+		}
+	}
+	sort.SliceStable(gateways, func(i, j int) bool {
+		if gateways[i].Namespace != gateways[j].Namespace {
+			return gateways[i].Namespace < gateways[j].Namespace
+		}
+		return gateways[i].Name < gateways[j].Name
+	})
+
+	var out []*ConvertedGateway
+	for _, gateway := range gateways {
+		source := types.NamespacedName{Namespace: gateway.Namespace, Name: gateway.Name}
+		istioGateway := &networking.Gateway{
+			Selector: c.input.Selector,
+		}
+		for idx := range gateway.Spec.Listeners {
+			l := gateway.Spec.Listeners[idx]
+			server := c.convertListener(gateway, l)
+			if server == nil {
+				continue
+			}
+			istioGateway.Servers = append(istioGateway.Servers, server)
+			c.listeners[source] = append(c.listeners[source], &listener{
+				Listener: l,
+				gateway:  gateway,
+			})
+		}
+		if len(istioGateway.Servers) == 0 {
+			IngressLog.Warnf("gateway %s has no valid listeners", source)
+			continue
+		}
+		out = append(out, &ConvertedGateway{
+			Source:  source,
+			Gateway: istioGateway,
+		})
+	}
+	return out
+}
+
+func (c *converter) convertListener(gateway *gatewayv1alpha2.Gateway, l gatewayv1alpha2.Listener) *networking.Server {
+	host := "*"
+	if l.Hostname != nil && *l.Hostname != "" {
+		host = string(*l.Hostname)
+	}
+	server := &networking.Server{
+		Port: &networking.Port{
+			Number:   uint32(l.Port),
+			Protocol: string(l.Protocol),
+			Name:     strings.Join([]string{strings.ToLower(string(l.Protocol)), strconv.Itoa(int(l.Port)), gateway.Namespace, gateway.Name, string(l.Name)}, "-"),
+		},
+		Hosts: []string{host},
+	}
+
+	switch l.Protocol {
+	case gatewayv1alpha2.HTTPProtocolType:
+		return server
+	case gatewayv1alpha2.HTTPSProtocolType:
+		if l.TLS == nil || (l.TLS.Mode != nil && *l.TLS.Mode != gatewayv1alpha2.TLSModeTerminate) {
+			IngressLog.Warnf("listener %s of gateway %s/%s only supports terminating tls", l.Name, gateway.Namespace, gateway.Name)
+			return nil
+		}
+		secret, ok := c.certificateRef(gateway, l.TLS)
+		if !ok {
+			IngressLog.Warnf("listener %s of gateway %s/%s has invalid certificate ref", l.Name, gateway.Namespace, gateway.Name)
+			return nil
+		}
+		server.Tls = &networking.ServerTLSSettings{
+			Mode:           networking.ServerTLSSettings_SIMPLE,
+			CredentialName: credentials.ToKubernetesIngressResource(c.input.RawClusterId, secret.Namespace, secret.Name),
+		}
+		return server
+	default:
+		IngressLog.Warnf("protocol %s of listener %s within gateway %s/%s is not supported", l.Protocol, l.Name, gateway.Namespace, gateway.Name)
+		return nil
+	}
+}
+
+func (c *converter) certificateRef(gateway *gatewayv1alpha2.Gateway, tls *gatewayv1alpha2.GatewayTLSConfig) (types.NamespacedName, bool) {
+	if len(tls.CertificateRefs) == 0 || tls.CertificateRefs[0] == nil {
+		return types.NamespacedName{}, false
+	}
+	ref := tls.CertificateRefs[0]
+	if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != secretKind) {
+		return types.NamespacedName{}, false
+	}
+
+	secret := types.NamespacedName{Namespace: gateway.Namespace, Name: string(ref.Name)}
+	if ref.Namespace != nil && *ref.Namespace != "" {
+		secret.Namespace = string(*ref.Namespace)
+	}
+	if secret.Namespace != gateway.Namespace &&
+		!c.referenceAllowed(gatewayv1alpha2.GroupName, gatewayKind, gateway.Namespace, "", secretKind, secret) {
+		return types.NamespacedName{}, false
+	}
+	return secret, true
+}
+
+// referenceAllowed checks whether there is a ReferencePolicy within the namespace of target, which allows the reference.
+func (c *converter) referenceAllowed(fromGroup, fromKind, fromNamespace, toGroup, toKind string, to types.NamespacedName) bool {
+	for _, policy := range c.input.ReferencePolicies {
+		if policy.Namespace != to.Namespace {
+			continue
+		}
+		var fromMatched bool
+		for _, from := range policy.Spec.From {
+			if string(from.Group) == fromGroup && string(from.Kind) == fromKind && string(from.Namespace) == fromNamespace {
+				fromMatched = true
+				break
+			}
+		}
+		if !fromMatched {
+			continue
+		}
+		for _, target := range policy.Spec.To {
+			if string(target.Group) == toGroup && string(target.Kind) == toKind &&
+				(target.Name == nil || *target.Name == "" || string(*target.Name) == to.Name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *converter) convertHTTPRoutes() []*ConvertedVirtualService {
+	routes := make([]*gatewayv1alpha2.HTTPRoute, len(c.input.HTTPRoutes))
+	copy(routes, c.input.HTTPRoutes)
+	sort.SliceStable(routes, func(i, j int) bool {
+		if !routes[i].CreationTimestamp.Equal(&routes[j].CreationTimestamp) {
+			return routes[i].CreationTimestamp.Before(&routes[j].CreationTimestamp)
+		}
+		if routes[i].Namespace != routes[j].Namespace {
+			return routes[i].Namespace < routes[j].Namespace
+		}
+		return routes[i].Name < routes[j].Name
+	})
+
+	// key: host
+	hostRoutes := map[string][]*routeWithPriority{}
+	hostParents := map[string]map[types.NamespacedName]struct{}{}
+	for _, route := range routes {
+		hosts := c.attachedHosts(route)
+		if len(hosts) == 0 {
+			continue
+		}
+		httpRoutes := c.convertHTTPRoute(route)
+		for host, parents := range hosts {
+			for _, httpRoute := range httpRoutes {
+				copied := *httpRoute
+				copied.route = httpRoute.route.DeepCopy()
+				hostRoutes[host] = append(hostRoutes[host], &copied)
+			}
+			if hostParents[host] == nil {
+				hostParents[host] = map[types.NamespacedName]struct{}{}
+			}
+			for parent := range parents {
+				hostParents[host][parent] = struct{}{}
+			}
+		}
+	}
+
+	hosts := make([]string, 0, len(hostRoutes))
+	for host := range hostRoutes {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var out []*ConvertedVirtualService
+	for _, host := range hosts {
+		httpRoutes := hostRoutes[host]
+		if len(httpRoutes) == 0 {
+			continue
+		}
+		sortRoutes(httpRoutes)
+
+		vs := &networking.VirtualService{
+			Hosts: []string{host},
+		}
+		for _, httpRoute := range httpRoutes {
+			vs.Http = append(vs.Http, httpRoute.route)
+		}
+
+		var parents []types.NamespacedName
+		for parent := range hostParents[host] {
+			parents = append(parents, parent)
+		}
+		sort.Slice(parents, func(i, j int) bool {
+			return parents[i].String() < parents[j].String()
+		})
+
+		out = append(out, &ConvertedVirtualService{
+			Host:           host,
+			Parents:        parents,
+			VirtualService: vs,
+		})
+	}
+	return out
+}
+
+// attachedHosts returns the hosts of route accepted by the listeners, and the gateways of these listeners.
+func (c *converter) attachedHosts(route *gatewayv1alpha2.HTTPRoute) map[string]map[types.NamespacedName]struct{} {
+	hosts := map[string]map[types.NamespacedName]struct{}{}
+	for _, parentRef := range route.Spec.ParentRefs {
+		if (parentRef.Group != nil && string(*parentRef.Group) != gatewayv1alpha2.GroupName) ||
+			(parentRef.Kind != nil && string(*parentRef.Kind) != gatewayKind) {
+			continue
+		}
+		parent := types.NamespacedName{Namespace: route.Namespace, Name: string(parentRef.Name)}
+		if parentRef.Namespace != nil && *parentRef.Namespace != "" {
+			parent.Namespace = string(*parentRef.Namespace)
+		}
+
+		for _, l := range c.listeners[parent] {
+			if parentRef.SectionName != nil && *parentRef.SectionName != l.Name {
+				continue
+			}
+			if !routeAllowed(l, route) {
+				IngressLog.Warnf("httproute %s/%s is not allowed by listener %s of gateway %s", route.Namespace, route.Name, l.Name, parent)
+				continue
+			}
+			for _, host := range intersectHostnames(l.Hostname, route.Spec.Hostnames) {
+				if hosts[host] == nil {
+					hosts[host] = map[types.NamespacedName]struct{}{}
+				}
+				hosts[host][parent] = struct{}{}
+			}
+		}
+	}
+	return hosts
+}
+
+func routeAllowed(l *listener, route *gatewayv1alpha2.HTTPRoute) bool {
+	allowedRoutes := l.AllowedRoutes
+	if allowedRoutes != nil && len(allowedRoutes.Kinds) > 0 {
+		var kindAllowed bool
+		for _, kind := range allowedRoutes.Kinds {
+			if (kind.Group == nil || string(*kind.Group) == gatewayv1alpha2.GroupName) && kind.Kind == httpRouteKind {
+				kindAllowed = true
+				break
+			}
+		}
+		if !kindAllowed {
+			return false
+		}
+	}
+
+	from := gatewayv1alpha2.NamespacesFromSame
+	if allowedRoutes != nil && allowedRoutes.Namespaces != nil && allowedRoutes.Namespaces.From != nil {
+		from = *allowedRoutes.Namespaces.From
+	}
+	switch from {
+	case gatewayv1alpha2.NamespacesFromAll:
+		return true
+	case gatewayv1alpha2.NamespacesFromSame:
+		return route.Namespace == l.gateway.Namespace
+	default:
+		// Selecting namespaces by label is not supported yet.
+		return false
+	}
+}
+
+// intersectHostnames returns the hostnames matched by both listener and route.
+func intersectHostnames(listenerHostname *gatewayv1alpha2.Hostname, routeHostnames []gatewayv1alpha2.Hostname) []string {
+	var listenerHost string
+	if listenerHostname != nil {
+		listenerHost = string(*listenerHostname)
+	}
+	if len(routeHostnames) == 0 {
+		if listenerHost == "" {
+			return []string{"*"}
+		}
+		return []string{listenerHost}
+	}
+
+	var hosts []string
+	for _, hostname := range routeHostnames {
+		routeHost := string(hostname)
+		switch {
+		case listenerHost == "" || listenerHost == routeHost:
+			hosts = append(hosts, routeHost)
+		case strings.HasPrefix(listenerHost, "*.") && strings.HasSuffix(routeHost, listenerHost[1:]):
+			hosts = append(hosts, routeHost)
+		case strings.HasPrefix(routeHost, "*.") && strings.HasSuffix(listenerHost, routeHost[1:]):
+			hosts = append(hosts, listenerHost)
+		}
+	}
+	return hosts
+}
+
+// convertHTTPRoute generates one istio route for each match of rules, so that routes can be sorted by the precedence of matches.
+func (c *converter) convertHTTPRoute(route *gatewayv1alpha2.HTTPRoute) []*routeWithPriority {
+	var out []*routeWithPriority
+	for ruleIdx, rule := range route.Spec.Rules {
+		urlRewrite := c.input.URLRewrites[URLRewriteKey{
+			Route: types.NamespacedName{Namespace: route.Namespace, Name: route.Name},
+			Rule:  ruleIdx,
+		}]
+		// The rule still takes the traffic matched even if it is invalid, and responds 500
+		// as Gateway API requires, rather than leaving it to the rules with lower precedence.
+		template := &networking.HTTPRoute{}
+		if !c.applyFilters(route, rule.Filters, urlRewrite, template) {
+			template = invalidRoute()
+		} else if template.Redirect == nil {
+			destinations := c.convertBackendRefs(route, rule.BackendRefs)
+			if len(destinations) == 0 {
+				IngressLog.Warnf("rule %d of httproute %s/%s has no valid backends", ruleIdx, route.Namespace, route.Name)
+				template = invalidRoute()
+			} else {
+				template.Route = destinations
+			}
+		}
+
+		matches := rule.Matches
+		if len(matches) == 0 {
+			matches = []gatewayv1alpha2.HTTPRouteMatch{{}}
+		}
+		for matchIdx, match := range matches {
+			httpRoute := template.DeepCopy()
+			if httpRoute.DirectResponse == nil && urlRewrite != nil && urlRewrite.Path != nil {
+				if err := applyPathModifier(urlRewrite.Path, match, httpRoute); err != nil {
+					IngressLog.Errorf("URLRewrite filter of rule %d within httproute %s/%s is invalid, err %v",
+						ruleIdx, route.Namespace, route.Name, err)
+					httpRoute = invalidRoute()
+				}
+			}
+			httpRoute.Name = fmt.Sprintf("%s-%s-%d-%d", route.Namespace, route.Name, ruleIdx, matchIdx)
+			httpRoute.Match = convertMatch(match)
+
+			priority := &routeWithPriority{
+				route:        httpRoute,
+				hasMethod:    match.Method != nil,
+				headerCount:  len(match.Headers),
+				queryCount:   len(match.QueryParams),
+				creationTime: route.CreationTimestamp.Time,
+				source:       route.Namespace + "/" + route.Name,
+			}
+			pathType, pathValue := pathMatch(match)
+			priority.exactPath = pathType == gatewayv1alpha2.PathMatchExact
+			priority.pathLength = len(pathValue)
+			out = append(out, priority)
+		}
+	}
+	return out
+}
+
+func pathMatch(match gatewayv1alpha2.HTTPRouteMatch) (gatewayv1alpha2.PathMatchType, string) {
+	pathType := gatewayv1alpha2.PathMatchPathPrefix
+	pathValue := "/"
+	if match.Path != nil {
+		if match.Path.Type != nil {
+			pathType = *match.Path.Type
+		}
+		if match.Path.Value != nil {
+			pathValue = *match.Path.Value
+		}
+	}
+	return pathType, pathValue
+}
+
+func convertMatch(match gatewayv1alpha2.HTTPRouteMatch) []*networking.HTTPMatchRequest {
+	request := &networking.HTTPMatchRequest{}
+	for _, header := range match.Headers {
+		if request.Headers == nil {
+			request.Headers = map[string]*networking.StringMatch{}
+		}
+		regex := header.Type != nil && *header.Type == gatewayv1alpha2.HeaderMatchRegularExpression
+		request.Headers[strings.ToLower(string(header.Name))] = stringMatch(header.Value, regex)
+	}
+	for _, query := range match.QueryParams {
+		if request.QueryParams == nil {
+			request.QueryParams = map[string]*networking.StringMatch{}
+		}
+		regex := query.Type != nil && *query.Type == gatewayv1alpha2.QueryParamMatchRegularExpression
+		request.QueryParams[query.Name] = stringMatch(query.Value, regex)
+	}
+	if match.Method != nil {
+		request.Method = stringMatch(string(*match.Method), false)
+	}
+
+	pathType, pathValue := pathMatch(match)
+	switch pathType {
+	case gatewayv1alpha2.PathMatchExact:
+		request.Uri = stringMatch(pathValue, false)
+	case gatewayv1alpha2.PathMatchRegularExpression:
+		request.Uri = stringMatch(pathValue, true)
+	default:
+		// Prefix of Gateway API matches by path elements, so /foo should match /foo and /foo/bar, but not /foobar.
+		if pathValue == "/" {
+			request.Uri = &networking.StringMatch{
+				MatchType: &networking.StringMatch_Prefix{Prefix: "/"},
+			}
+			break
+		}
+		pathValue = strings.TrimSuffix(pathValue, "/")
+		prefixRequest := request.DeepCopy()
+		request.Uri = stringMatch(pathValue, false)
+		prefixRequest.Uri = &networking.StringMatch{
+			MatchType: &networking.StringMatch_Prefix{Prefix: pathValue + "/"},
+		}
+		return []*networking.HTTPMatchRequest{request, prefixRequest}
+	}
+	return []*networking.HTTPMatchRequest{request}
+}
+
+func stringMatch(value string, regex bool) *networking.StringMatch {
+	if regex {
+		return &networking.StringMatch{
+			MatchType: &networking.StringMatch_Regex{Regex: value},
+		}
+	}
+	return &networking.StringMatch{
+		MatchType: &networking.StringMatch_Exact{Exact: value},
+	}
+}
+
+// applyFilters returns false if there are unsupported filters, since the rule can't work as expected.
+// The path of URLRewrite filter depends on the match, which is applied by applyPathModifier.
+func (c *converter) applyFilters(route *gatewayv1alpha2.HTTPRoute, filters []gatewayv1alpha2.HTTPRouteFilter,
+	urlRewrite *HTTPURLRewriteFilter, httpRoute *networking.HTTPRoute) bool {
+	for _, filter := range filters {
+		switch filter.Type {
+		case gatewayv1alpha2.HTTPRouteFilterRequestHeaderModifier:
+			if filter.RequestHeaderModifier == nil {
+				continue
+			}
+			operations := &networking.Headers_HeaderOperations{
+				Remove: filter.RequestHeaderModifier.Remove,
+			}
+			for _, header := range filter.RequestHeaderModifier.Set {
+				if operations.Set == nil {
+					operations.Set = map[string]string{}
+				}
+				operations.Set[string(header.Name)] = header.Value
+			}
+			for _, header := range filter.RequestHeaderModifier.Add {
+				if operations.Add == nil {
+					operations.Add = map[string]string{}
+				}
+				operations.Add[string(header.Name)] = header.Value
+			}
+			httpRoute.Headers = &networking.Headers{
+				Request: operations,
+			}
+		case gatewayv1alpha2.HTTPRouteFilterRequestRedirect:
+			redirect := filter.RequestRedirect
+			if redirect == nil {
+				continue
+			}
+			httpRoute.Redirect = &networking.HTTPRedirect{
+				RedirectCode: 302,
+			}
+			if redirect.Scheme != nil {
+				httpRoute.Redirect.Scheme = *redirect.Scheme
+			}
+			if redirect.Hostname != nil {
+				httpRoute.Redirect.Authority = string(*redirect.Hostname)
+			}
+			if redirect.Port != nil {
+				httpRoute.Redirect.RedirectPort = &networking.HTTPRedirect_Port{Port: uint32(*redirect.Port)}
+			}
+			if redirect.StatusCode != nil {
+				httpRoute.Redirect.RedirectCode = uint32(*redirect.StatusCode)
+			}
+		case gatewayv1alpha2.HTTPRouteFilterRequestMirror:
+			if filter.RequestMirror == nil {
+				continue
+			}
+			destination, ok := c.convertBackendRef(route, filter.RequestMirror.BackendRef)
+			if !ok {
+				IngressLog.Warnf("mirror backend of httproute %s/%s is invalid", route.Namespace, route.Name)
+				continue
+			}
+			httpRoute.Mirror = destination
+		case httpRouteFilterURLRewrite:
+			if urlRewrite == nil {
+				IngressLog.Errorf("filter %s within httproute %s/%s has no config", filter.Type, route.Namespace, route.Name)
+				return false
+			}
+			if urlRewrite.Hostname != nil {
+				if httpRoute.Rewrite == nil {
+					httpRoute.Rewrite = &networking.HTTPRewrite{}
+				}
+				httpRoute.Rewrite.Authority = *urlRewrite.Hostname
+			}
+		default:
+			IngressLog.Errorf("filter %s within httproute %s/%s is not supported", filter.Type, route.Namespace, route.Name)
+			return false
+		}
+	}
+	return true
+}
+
+// applyPathModifier rewrites the path of route by regex, so that the prefix is replaced by path elements,
+// which is the same as the prefix match.
+func applyPathModifier(modifier *HTTPPathModifier, match gatewayv1alpha2.HTTPRouteMatch, httpRoute *networking.HTTPRoute) error {
+	var uriRegex *networking.RegexMatchAndSubstitute
+	switch modifier.Type {
+	case fullPathHTTPPathModifier:
+		if modifier.ReplaceFullPath == nil {
+			return fmt.Errorf("replaceFullPath is required by %s", modifier.Type)
+		}
+		uriRegex = &networking.RegexMatchAndSubstitute{
+			Pattern:      "^.*$",
+			Substitution: *modifier.ReplaceFullPath,
+		}
+	case prefixMatchHTTPPathModifier:
+		if modifier.ReplacePrefixMatch == nil {
+			return fmt.Errorf("replacePrefixMatch is required by %s", modifier.Type)
+		}
+		pathType, pathValue := pathMatch(match)
+		if pathType != gatewayv1alpha2.PathMatchPathPrefix {
+			return fmt.Errorf("%s only works with path prefix match", modifier.Type)
+		}
+		matchPrefix := regexp.QuoteMeta(strings.TrimSuffix(pathValue, "/"))
+		replacement := strings.TrimSuffix(*modifier.ReplacePrefixMatch, "/")
+		if replacement == "" {
+			// Replace the prefix with "/", e.g. /foo/bar becomes /bar, and /foo becomes /.
+			uriRegex = &networking.RegexMatchAndSubstitute{
+				Pattern:      "^" + matchPrefix + "/*(.*)$",
+				Substitution: "/\\1",
+			}
+		} else {
+			uriRegex = &networking.RegexMatchAndSubstitute{
+				Pattern:      "^" + matchPrefix + "(/.*)?$",
+				Substitution: replacement + "\\1",
+			}
+		}
+	default:
+		return fmt.Errorf("path modifier %s is not supported", modifier.Type)
+	}
+	if httpRoute.Rewrite == nil {
+		httpRoute.Rewrite = &networking.HTTPRewrite{}
+	}
+	httpRoute.Rewrite.UriRegex = uriRegex
+	return nil
+}
+
+// invalidRoute responds 500 directly instead of routing to any backend.
+func invalidRoute() *networking.HTTPRoute {
+	return &networking.HTTPRoute{
+		DirectResponse: &networking.HTTPDirectResponse{
+			ResponseCode: invalidRuleStatus,
+		},
+	}
+}
+
+func (c *converter) convertBackendRefs(route *gatewayv1alpha2.HTTPRoute, backendRefs []gatewayv1alpha2.HTTPBackendRef) []*networking.HTTPRouteDestination {
+	var destinations []*networking.HTTPRouteDestination
+	var weightSum int32
+	for _, backendRef := range backendRefs {
+		weight := int32(defaultWeight)
+		if backendRef.Weight != nil {
+			weight = *backendRef.Weight
+		}
+		if weight <= 0 {
+			continue
+		}
+		destination, ok := c.convertBackendRef(route, backendRef.BackendObjectReference)
+		if !ok {
+			IngressLog.Warnf("backend %s of httproute %s/%s is invalid", backendRef.Name, route.Namespace, route.Name)
+			continue
+		}
+		weightSum += weight
+		destinations = append(destinations, &networking.HTTPRouteDestination{
+			Destination: destination,
+			Weight:      weight,
+		})
+	}
+	if len(destinations) == 0 {
+		return nil
+	}
+
+	// Istio requires the sum of weight to be 100, the remainder goes to the first destination.
+	var sum int32
+	for idx, destination := range destinations {
+		if idx == 0 {
+			continue
+		}
+		destination.Weight = int32(int64(destination.Weight) * totalWeight / int64(weightSum))
+		sum += destination.Weight
+	}
+	destinations[0].Weight = totalWeight - sum
+	return destinations
+}
+
+func (c *converter) convertBackendRef(route *gatewayv1alpha2.HTTPRoute, ref gatewayv1alpha2.BackendObjectReference) (*networking.Destination, bool) {
+	if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != serviceKind) {
+		return nil, false
+	}
+	// Port is required when referring to a service.
+	if ref.Port == nil {
+		return nil, false
+	}
+
+	service := types.NamespacedName{Namespace: route.Namespace, Name: string(ref.Name)}
+	if ref.Namespace != nil && *ref.Namespace != "" {
+		service.Namespace = string(*ref.Namespace)
+	}
+	if service.Namespace != route.Namespace &&
+		!c.referenceAllowed(gatewayv1alpha2.GroupName, httpRouteKind, route.Namespace, "", serviceKind, service) {
+		return nil, false
+	}
+
+	return &networking.Destination{
+		Host: util.CreateServiceFQDN(service.Namespace, service.Name),
+		Port: &networking.PortSelector{
+			Number: uint32(*ref.Port),
+		},
+	}, true
+}
+
+// sortRoutes sorts routes by the precedence defined by Gateway API, routes with more specific matches come first.
+func sortRoutes(routes []*routeWithPriority) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+		if a.exactPath != b.exactPath {
+			return a.exactPath
+		}
+		if a.pathLength != b.pathLength {
+			return a.pathLength > b.pathLength
+		}
+		if a.hasMethod != b.hasMethod {
+			return a.hasMethod
+		}
+		if a.headerCount != b.headerCount {
+			return a.headerCount > b.headerCount
+		}
+		if a.queryCount != b.queryCount {
+			return a.queryCount > b.queryCount
+		}
+		if !a.creationTime.Equal(b.creationTime) {
+			return a.creationTime.Before(b.creationTime)
+		}
+		return a.source < b.source
+	})
+}
@@ -0,0 +1,814 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func newGatewayClass(name, controller string) *gatewayv1alpha2.GatewayClass {
+	return &gatewayv1alpha2.GatewayClass{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: gatewayv1alpha2.GatewayClassSpec{
+			ControllerName: gatewayv1alpha2.GatewayController(controller),
+		},
+	}
+}
+
+func newGateway(namespace, name, class string, listeners ...gatewayv1alpha2.Listener) *gatewayv1alpha2.Gateway {
+	return &gatewayv1alpha2.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: gatewayv1alpha2.GatewaySpec{
+			GatewayClassName: gatewayv1alpha2.ObjectName(class),
+			Listeners:        listeners,
+		},
+	}
+}
+
+func httpListener(name string, hostname string) gatewayv1alpha2.Listener {
+	l := gatewayv1alpha2.Listener{
+		Name:     gatewayv1alpha2.SectionName(name),
+		Port:     80,
+		Protocol: gatewayv1alpha2.HTTPProtocolType,
+	}
+	if hostname != "" {
+		h := gatewayv1alpha2.Hostname(hostname)
+		l.Hostname = &h
+	}
+	return l
+}
+
+func httpsListener(name string, secretNamespace, secretName string) gatewayv1alpha2.Listener {
+	l := gatewayv1alpha2.Listener{
+		Name:     gatewayv1alpha2.SectionName(name),
+		Port:     443,
+		Protocol: gatewayv1alpha2.HTTPSProtocolType,
+		TLS: &gatewayv1alpha2.GatewayTLSConfig{
+			CertificateRefs: []*gatewayv1alpha2.SecretObjectReference{
+				{
+					Name: gatewayv1alpha2.ObjectName(secretName),
+				},
+			},
+		},
+	}
+	if secretNamespace != "" {
+		ns := gatewayv1alpha2.Namespace(secretNamespace)
+		l.TLS.CertificateRefs[0].Namespace = &ns
+	}
+	return l
+}
+
+func newHTTPRoute(namespace, name string, created time.Time, parent string, hostnames []string, rules ...gatewayv1alpha2.HTTPRouteRule) *gatewayv1alpha2.HTTPRoute {
+	route := &gatewayv1alpha2.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         namespace,
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Spec: gatewayv1alpha2.HTTPRouteSpec{
+			CommonRouteSpec: gatewayv1alpha2.CommonRouteSpec{
+				ParentRefs: []gatewayv1alpha2.ParentRef{
+					{Name: gatewayv1alpha2.ObjectName(parent)},
+				},
+			},
+			Rules: rules,
+		},
+	}
+	for _, hostname := range hostnames {
+		route.Spec.Hostnames = append(route.Spec.Hostnames, gatewayv1alpha2.Hostname(hostname))
+	}
+	return route
+}
+
+func backendRef(namespace, name string, port int32, weight *int32) gatewayv1alpha2.HTTPBackendRef {
+	p := gatewayv1alpha2.PortNumber(port)
+	ref := gatewayv1alpha2.HTTPBackendRef{
+		BackendRef: gatewayv1alpha2.BackendRef{
+			BackendObjectReference: gatewayv1alpha2.BackendObjectReference{
+				Name: gatewayv1alpha2.ObjectName(name),
+				Port: &p,
+			},
+			Weight: weight,
+		},
+	}
+	if namespace != "" {
+		ns := gatewayv1alpha2.Namespace(namespace)
+		ref.Namespace = &ns
+	}
+	return ref
+}
+
+func pathPrefix(value string) *gatewayv1alpha2.HTTPPathMatch {
+	pathType := gatewayv1alpha2.PathMatchPathPrefix
+	return &gatewayv1alpha2.HTTPPathMatch{Type: &pathType, Value: strPtr(value)}
+}
+
+func pathExact(value string) *gatewayv1alpha2.HTTPPathMatch {
+	pathType := gatewayv1alpha2.PathMatchExact
+	return &gatewayv1alpha2.HTTPPathMatch{Type: &pathType, Value: strPtr(value)}
+}
+
+func exact(value string) *networking.StringMatch {
+	return &networking.StringMatch{MatchType: &networking.StringMatch_Exact{Exact: value}}
+}
+
+func prefix(value string) *networking.StringMatch {
+	return &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: value}}
+}
+
+func regex(value string) *networking.StringMatch {
+	return &networking.StringMatch{MatchType: &networking.StringMatch_Regex{Regex: value}}
+}
+
+func destination(host string, port uint32) *networking.Destination {
+	return &networking.Destination{
+		Host: host,
+		Port: &networking.PortSelector{Number: port},
+	}
+}
+
+func TestConvertGateways(t *testing.T) {
+	classes := []*gatewayv1alpha2.GatewayClass{
+		newGatewayClass("higress", ControllerName),
+		newGatewayClass("other", "example.com/other-controller"),
+	}
+
+	testCases := []struct {
+		name     string
+		gateways []*gatewayv1alpha2.Gateway
+		policies []*gatewayv1alpha2.ReferencePolicy
+		expect   []*ConvertedGateway
+	}{
+		{
+			name: "other class",
+			gateways: []*gatewayv1alpha2.Gateway{
+				newGateway("default", "gw", "other", httpListener("http", "")),
+			},
+		},
+		{
+			name: "http and https",
+			gateways: []*gatewayv1alpha2.Gateway{
+				newGateway("default", "gw", "higress",
+					httpListener("http", "*.example.com"),
+					httpsListener("https", "", "cert")),
+			},
+			expect: []*ConvertedGateway{
+				{
+					Source: types.NamespacedName{Namespace: "default", Name: "gw"},
+					Gateway: &networking.Gateway{
+						Selector: map[string]string{"higress": "gateway"},
+						Servers: []*networking.Server{
+							{
+								Port: &networking.Port{
+									Number:   80,
+									Protocol: "HTTP",
+									Name:     "http-80-default-gw-http",
+								},
+								Hosts: []string{"*.example.com"},
+							},
+							{
+								Port: &networking.Port{
+									Number:   443,
+									Protocol: "HTTPS",
+									Name:     "https-443-default-gw-https",
+								},
+								Hosts: []string{"*"},
+								Tls: &networking.ServerTLSSettings{
+									Mode:           networking.ServerTLSSettings_SIMPLE,
+									CredentialName: "kubernetes-ingress://cluster/default/cert",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "cross namespace certificate without reference policy",
+			gateways: []*gatewayv1alpha2.Gateway{
+				newGateway("default", "gw", "higress", httpsListener("https", "certs", "cert")),
+			},
+		},
+		{
+			name: "cross namespace certificate with reference policy",
+			gateways: []*gatewayv1alpha2.Gateway{
+				newGateway("default", "gw", "higress", httpsListener("https", "certs", "cert")),
+			},
+			policies: []*gatewayv1alpha2.ReferencePolicy{
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "certs", Name: "allow-gateways"},
+					Spec: gatewayv1alpha2.ReferencePolicySpec{
+						From: []gatewayv1alpha2.ReferencePolicyFrom{
+							{Group: gatewayv1alpha2.GroupName, Kind: "Gateway", Namespace: "default"},
+						},
+						To: []gatewayv1alpha2.ReferencePolicyTo{
+							{Group: "", Kind: "Secret"},
+						},
+					},
+				},
+			},
+			expect: []*ConvertedGateway{
+				{
+					Source: types.NamespacedName{Namespace: "default", Name: "gw"},
+					Gateway: &networking.Gateway{
+						Selector: map[string]string{"higress": "gateway"},
+						Servers: []*networking.Server{
+							{
+								Port: &networking.Port{
+									Number:   443,
+									Protocol: "HTTPS",
+									Name:     "https-443-default-gw-https",
+								},
+								Hosts: []string{"*"},
+								Tls: &networking.ServerTLSSettings{
+									Mode:           networking.ServerTLSSettings_SIMPLE,
+									CredentialName: "kubernetes-ingress://cluster/certs/cert",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			output := Convert(&ConvertInput{
+				GatewayClasses:    classes,
+				Gateways:          testCase.gateways,
+				ReferencePolicies: testCase.policies,
+				RawClusterId:      "cluster",
+				Selector:          map[string]string{"higress": "gateway"},
+			})
+			if !reflect.DeepEqual(output.Gateways, testCase.expect) {
+				t.Fatalf("Should be equal.")
+			}
+		})
+	}
+}
+
+func TestConvertHTTPRoutes(t *testing.T) {
+	classes := []*gatewayv1alpha2.GatewayClass{newGatewayClass("higress", ControllerName)}
+	gateways := []*gatewayv1alpha2.Gateway{
+		newGateway("default", "gw", "higress", httpListener("http", "*.example.com")),
+	}
+	now := time.Now()
+	parents := []types.NamespacedName{{Namespace: "default", Name: "gw"}}
+	methodGet := gatewayv1alpha2.HTTPMethodGet
+	headerRegex := gatewayv1alpha2.HeaderMatchRegularExpression
+	weight80, weight20 := int32(80), int32(20)
+	weight1, weight2 := int32(1), int32(2)
+
+	testCases := []struct {
+		name        string
+		routes      []*gatewayv1alpha2.HTTPRoute
+		policies    []*gatewayv1alpha2.ReferencePolicy
+		urlRewrites map[URLRewriteKey]*HTTPURLRewriteFilter
+		expect      []*ConvertedVirtualService
+	}{
+		{
+			name: "hostname doesn't match listener",
+			routes: []*gatewayv1alpha2.HTTPRoute{
+				newHTTPRoute("default", "route", now, "gw", []string{"foo.test.com"},
+					gatewayv1alpha2.HTTPRouteRule{
+						BackendRefs: []gatewayv1alpha2.HTTPBackendRef{backendRef("", "svc", 80, nil)},
+					}),
+			},
+		},
+		{
+			name: "route in other namespace is not allowed",
+			routes: []*gatewayv1alpha2.HTTPRoute{
+				newHTTPRoute("other", "route", now, "gw", nil,
+					gatewayv1alpha2.HTTPRouteRule{
+						BackendRefs: []gatewayv1alpha2.HTTPBackendRef{backendRef("", "svc", 80, nil)},
+					}),
+			},
+		},
+		{
+			name: "prefix, header, query and method matches",
+			routes: []*gatewayv1alpha2.HTTPRoute{
+				newHTTPRoute("default", "route", now, "gw", []string{"foo.example.com"},
+					gatewayv1alpha2.HTTPRouteRule{
+						Matches: []gatewayv1alpha2.HTTPRouteMatch{
+							{
+								Path: pathPrefix("/api/"),
+								Headers: []gatewayv1alpha2.HTTPHeaderMatch{
+									{Name: "X-Version", Value: "v1"},
+									{Type: &headerRegex, Name: "X-User", Value: "a.*"},
+								},
+								QueryParams: []gatewayv1alpha2.HTTPQueryParamMatch{
+									{Name: "debug", Value: "true"},
+								},
+								Method: &methodGet,
+							},
+						},
+						BackendRefs: []gatewayv1alpha2.HTTPBackendRef{backendRef("", "svc", 80, nil)},
+					}),
+			},
+			expect: []*ConvertedVirtualService{
+				{
+					Host:    "foo.example.com",
+					Parents: parents,
+					VirtualService: &networking.VirtualService{
+						Hosts: []string{"foo.example.com"},
+						Http: []*networking.HTTPRoute{
+							{
+								Name: "default-route-0-0",
+								Match: []*networking.HTTPMatchRequest{
+									{
+										Uri:         exact("/api"),
+										Headers:     map[string]*networking.StringMatch{"x-version": exact("v1"), "x-user": regex("a.*")},
+										QueryParams: map[string]*networking.StringMatch{"debug": exact("true")},
+										Method:      exact("GET"),
+									},
+									{
+										Uri:         prefix("/api/"),
+										Headers:     map[string]*networking.StringMatch{"x-version": exact("v1"), "x-user": regex("a.*")},
+										QueryParams: map[string]*networking.StringMatch{"debug": exact("true")},
+										Method:      exact("GET"),
+									},
+								},
+								Route: []*networking.HTTPRouteDestination{
+									{Destination: destination("svc.default.svc.cluster.local", 80), Weight: 100},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "weighted backends and cross namespace backend",
+			routes: []*gatewayv1alpha2.HTTPRoute{
+				newHTTPRoute("default", "route", now, "gw", []string{"foo.example.com"},
+					gatewayv1alpha2.HTTPRouteRule{
+						BackendRefs: []gatewayv1alpha2.HTTPBackendRef{
+							backendRef("", "v1", 80, &weight1),
+							backendRef("", "v2", 80, &weight2),
+							backendRef("other", "v3", 80, nil),
+						},
+					}),
+			},
+			expect: []*ConvertedVirtualService{
+				{
+					Host:    "foo.example.com",
+					Parents: parents,
+					VirtualService: &networking.VirtualService{
+						Hosts: []string{"foo.example.com"},
+						Http: []*networking.HTTPRoute{
+							{
+								Name:  "default-route-0-0",
+								Match: []*networking.HTTPMatchRequest{{Uri: prefix("/")}},
+								Route: []*networking.HTTPRouteDestination{
+									{Destination: destination("v1.default.svc.cluster.local", 80), Weight: 34},
+									{Destination: destination("v2.default.svc.cluster.local", 80), Weight: 66},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "cross namespace backend with reference policy",
+			routes: []*gatewayv1alpha2.HTTPRoute{
+				newHTTPRoute("default", "route", now, "gw", []string{"foo.example.com"},
+					gatewayv1alpha2.HTTPRouteRule{
+						BackendRefs: []gatewayv1alpha2.HTTPBackendRef{
+							backendRef("", "v1", 80, &weight80),
+							backendRef("other", "v2", 8080, &weight20),
+						},
+					}),
+			},
+			policies: []*gatewayv1alpha2.ReferencePolicy{
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "allow-routes"},
+					Spec: gatewayv1alpha2.ReferencePolicySpec{
+						From: []gatewayv1alpha2.ReferencePolicyFrom{
+							{Group: gatewayv1alpha2.GroupName, Kind: "HTTPRoute", Namespace: "default"},
+						},
+						To: []gatewayv1alpha2.ReferencePolicyTo{
+							{Group: "", Kind: "Service"},
+						},
+					},
+				},
+			},
+			expect: []*ConvertedVirtualService{
+				{
+					Host:    "foo.example.com",
+					Parents: parents,
+					VirtualService: &networking.VirtualService{
+						Hosts: []string{"foo.example.com"},
+						Http: []*networking.HTTPRoute{
+							{
+								Name:  "default-route-0-0",
+								Match: []*networking.HTTPMatchRequest{{Uri: prefix("/")}},
+								Route: []*networking.HTTPRouteDestination{
+									{Destination: destination("v1.default.svc.cluster.local", 80), Weight: 80},
+									{Destination: destination("v2.other.svc.cluster.local", 8080), Weight: 20},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "header modifier and redirect filters, unsupported filters respond 500",
+			routes: []*gatewayv1alpha2.HTTPRoute{
+				newHTTPRoute("default", "route", now, "gw", []string{"foo.example.com"},
+					gatewayv1alpha2.HTTPRouteRule{
+						Matches: []gatewayv1alpha2.HTTPRouteMatch{{Path: pathExact("/old")}},
+						Filters: []gatewayv1alpha2.HTTPRouteFilter{
+							{
+								Type: gatewayv1alpha2.HTTPRouteFilterRequestRedirect,
+								RequestRedirect: &gatewayv1alpha2.HTTPRequestRedirectFilter{
+									Scheme: strPtr("https"),
+								},
+							},
+						},
+					},
+					gatewayv1alpha2.HTTPRouteRule{
+						Filters: []gatewayv1alpha2.HTTPRouteFilter{
+							{
+								Type: gatewayv1alpha2.HTTPRouteFilterRequestHeaderModifier,
+								RequestHeaderModifier: &gatewayv1alpha2.HTTPRequestHeaderFilter{
+									Set:    []gatewayv1alpha2.HTTPHeader{{Name: "X-Set", Value: "a"}},
+									Add:    []gatewayv1alpha2.HTTPHeader{{Name: "X-Add", Value: "b"}},
+									Remove: []string{"X-Remove"},
+								},
+							},
+						},
+						BackendRefs: []gatewayv1alpha2.HTTPBackendRef{backendRef("", "svc", 80, nil)},
+					},
+					gatewayv1alpha2.HTTPRouteRule{
+						Matches: []gatewayv1alpha2.HTTPRouteMatch{{Path: pathPrefix("/ext")}},
+						Filters: []gatewayv1alpha2.HTTPRouteFilter{
+							{Type: gatewayv1alpha2.HTTPRouteFilterExtensionRef},
+						},
+						BackendRefs: []gatewayv1alpha2.HTTPBackendRef{backendRef("", "svc", 80, nil)},
+					},
+					gatewayv1alpha2.HTTPRouteRule{
+						Matches: []gatewayv1alpha2.HTTPRouteMatch{{Path: pathPrefix("/rewrite")}},
+						Filters: []gatewayv1alpha2.HTTPRouteFilter{
+							{Type: httpRouteFilterURLRewrite},
+						},
+						BackendRefs: []gatewayv1alpha2.HTTPBackendRef{backendRef("", "svc", 80, nil)},
+					}),
+			},
+			expect: []*ConvertedVirtualService{
+				{
+					Host:    "foo.example.com",
+					Parents: parents,
+					VirtualService: &networking.VirtualService{
+						Hosts: []string{"foo.example.com"},
+						Http: []*networking.HTTPRoute{
+							{
+								Name:  "default-route-0-0",
+								Match: []*networking.HTTPMatchRequest{{Uri: exact("/old")}},
+								Redirect: &networking.HTTPRedirect{
+									Scheme:       "https",
+									RedirectCode: 302,
+								},
+							},
+							{
+								Name:           "default-route-3-0",
+								Match:          []*networking.HTTPMatchRequest{{Uri: exact("/rewrite")}, {Uri: prefix("/rewrite/")}},
+								DirectResponse: &networking.HTTPDirectResponse{ResponseCode: 500},
+							},
+							{
+								Name:           "default-route-2-0",
+								Match:          []*networking.HTTPMatchRequest{{Uri: exact("/ext")}, {Uri: prefix("/ext/")}},
+								DirectResponse: &networking.HTTPDirectResponse{ResponseCode: 500},
+							},
+							{
+								Name:  "default-route-1-0",
+								Match: []*networking.HTTPMatchRequest{{Uri: prefix("/")}},
+								Headers: &networking.Headers{
+									Request: &networking.Headers_HeaderOperations{
+										Set:    map[string]string{"X-Set": "a"},
+										Add:    map[string]string{"X-Add": "b"},
+										Remove: []string{"X-Remove"},
+									},
+								},
+								Route: []*networking.HTTPRouteDestination{
+									{Destination: destination("svc.default.svc.cluster.local", 80), Weight: 100},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "rule without valid backends responds 500",
+			routes: []*gatewayv1alpha2.HTTPRoute{
+				newHTTPRoute("default", "route", now, "gw", []string{"foo.example.com"},
+					gatewayv1alpha2.HTTPRouteRule{
+						Matches:     []gatewayv1alpha2.HTTPRouteMatch{{Path: pathPrefix("/other")}},
+						BackendRefs: []gatewayv1alpha2.HTTPBackendRef{backendRef("other", "svc", 80, nil)},
+					},
+					gatewayv1alpha2.HTTPRouteRule{
+						BackendRefs: []gatewayv1alpha2.HTTPBackendRef{backendRef("", "svc", 80, nil)},
+					}),
+			},
+			expect: []*ConvertedVirtualService{
+				{
+					Host:    "foo.example.com",
+					Parents: parents,
+					VirtualService: &networking.VirtualService{
+						Hosts: []string{"foo.example.com"},
+						Http: []*networking.HTTPRoute{
+							{
+								Name:           "default-route-0-0",
+								Match:          []*networking.HTTPMatchRequest{{Uri: exact("/other")}, {Uri: prefix("/other/")}},
+								DirectResponse: &networking.HTTPDirectResponse{ResponseCode: 500},
+							},
+							{
+								Name:  "default-route-1-0",
+								Match: []*networking.HTTPMatchRequest{{Uri: prefix("/")}},
+								Route: []*networking.HTTPRouteDestination{
+									{Destination: destination("svc.default.svc.cluster.local", 80), Weight: 100},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "url rewrite filters",
+			routes: []*gatewayv1alpha2.HTTPRoute{
+				newHTTPRoute("default", "route", now, "gw", []string{"foo.example.com"},
+					gatewayv1alpha2.HTTPRouteRule{
+						Matches:     []gatewayv1alpha2.HTTPRouteMatch{{Path: pathPrefix("/api")}},
+						Filters:     []gatewayv1alpha2.HTTPRouteFilter{{Type: httpRouteFilterURLRewrite}},
+						BackendRefs: []gatewayv1alpha2.HTTPBackendRef{backendRef("", "svc", 80, nil)},
+					},
+					gatewayv1alpha2.HTTPRouteRule{
+						Matches: []gatewayv1alpha2.HTTPRouteMatch{
+							{Path: pathExact("/old")},
+							{Path: pathPrefix("/legacy")},
+						},
+						Filters:     []gatewayv1alpha2.HTTPRouteFilter{{Type: httpRouteFilterURLRewrite}},
+						BackendRefs: []gatewayv1alpha2.HTTPBackendRef{backendRef("", "svc", 80, nil)},
+					}),
+			},
+			urlRewrites: map[URLRewriteKey]*HTTPURLRewriteFilter{
+				{Route: types.NamespacedName{Namespace: "default", Name: "route"}, Rule: 0}: {
+					Hostname: strPtr("bar.example.com"),
+					Path:     &HTTPPathModifier{Type: prefixMatchHTTPPathModifier, ReplacePrefixMatch: strPtr("/v2")},
+				},
+				{Route: types.NamespacedName{Namespace: "default", Name: "route"}, Rule: 1}: {
+					Path: &HTTPPathModifier{Type: prefixMatchHTTPPathModifier, ReplacePrefixMatch: strPtr("/new")},
+				},
+			},
+			expect: []*ConvertedVirtualService{
+				{
+					Host:    "foo.example.com",
+					Parents: parents,
+					VirtualService: &networking.VirtualService{
+						Hosts: []string{"foo.example.com"},
+						Http: []*networking.HTTPRoute{
+							{
+								Name:           "default-route-1-0",
+								Match:          []*networking.HTTPMatchRequest{{Uri: exact("/old")}},
+								DirectResponse: &networking.HTTPDirectResponse{ResponseCode: 500},
+							},
+							{
+								Name:  "default-route-1-1",
+								Match: []*networking.HTTPMatchRequest{{Uri: exact("/legacy")}, {Uri: prefix("/legacy/")}},
+								Rewrite: &networking.HTTPRewrite{
+									UriRegex: &networking.RegexMatchAndSubstitute{Pattern: "^/legacy(/.*)?$", Substitution: "/new\\1"},
+								},
+								Route: []*networking.HTTPRouteDestination{
+									{Destination: destination("svc.default.svc.cluster.local", 80), Weight: 100},
+								},
+							},
+							{
+								Name:  "default-route-0-0",
+								Match: []*networking.HTTPMatchRequest{{Uri: exact("/api")}, {Uri: prefix("/api/")}},
+								Rewrite: &networking.HTTPRewrite{
+									Authority: "bar.example.com",
+									UriRegex:  &networking.RegexMatchAndSubstitute{Pattern: "^/api(/.*)?$", Substitution: "/v2\\1"},
+								},
+								Route: []*networking.HTTPRouteDestination{
+									{Destination: destination("svc.default.svc.cluster.local", 80), Weight: 100},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "routes sorted by precedence across httproutes",
+			routes: []*gatewayv1alpha2.HTTPRoute{
+				newHTTPRoute("default", "a", now, "gw", []string{"*.example.com"},
+					gatewayv1alpha2.HTTPRouteRule{
+						Matches:     []gatewayv1alpha2.HTTPRouteMatch{{Path: pathPrefix("/foo")}},
+						BackendRefs: []gatewayv1alpha2.HTTPBackendRef{backendRef("", "a", 80, nil)},
+					}),
+				newHTTPRoute("default", "b", now.Add(time.Second), "gw", []string{"*.example.com"},
+					gatewayv1alpha2.HTTPRouteRule{
+						Matches: []gatewayv1alpha2.HTTPRouteMatch{
+							{Path: pathPrefix("/foo"), Method: &methodGet},
+							{Path: pathExact("/")},
+						},
+						BackendRefs: []gatewayv1alpha2.HTTPBackendRef{backendRef("", "b", 80, nil)},
+					}),
+			},
+			expect: []*ConvertedVirtualService{
+				{
+					Host:    "*.example.com",
+					Parents: parents,
+					VirtualService: &networking.VirtualService{
+						Hosts: []string{"*.example.com"},
+						Http: []*networking.HTTPRoute{
+							{
+								Name:  "default-b-0-1",
+								Match: []*networking.HTTPMatchRequest{{Uri: exact("/")}},
+								Route: []*networking.HTTPRouteDestination{
+									{Destination: destination("b.default.svc.cluster.local", 80), Weight: 100},
+								},
+							},
+							{
+								Name: "default-b-0-0",
+								Match: []*networking.HTTPMatchRequest{
+									{Uri: exact("/foo"), Method: exact("GET")},
+									{Uri: prefix("/foo/"), Method: exact("GET")},
+								},
+								Route: []*networking.HTTPRouteDestination{
+									{Destination: destination("b.default.svc.cluster.local", 80), Weight: 100},
+								},
+							},
+							{
+								Name: "default-a-0-0",
+								Match: []*networking.HTTPMatchRequest{
+									{Uri: exact("/foo")},
+									{Uri: prefix("/foo/")},
+								},
+								Route: []*networking.HTTPRouteDestination{
+									{Destination: destination("a.default.svc.cluster.local", 80), Weight: 100},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			output := Convert(&ConvertInput{
+				GatewayClasses:    classes,
+				Gateways:          gateways,
+				HTTPRoutes:        testCase.routes,
+				ReferencePolicies: testCase.policies,
+				URLRewrites:       testCase.urlRewrites,
+			})
+			if !reflect.DeepEqual(output.VirtualServices, testCase.expect) {
+				t.Fatalf("Should be equal.")
+			}
+		})
+	}
+}
+
+func TestApplyPathModifier(t *testing.T) {
+	testCases := []struct {
+		modifier *HTTPPathModifier
+		match    gatewayv1alpha2.HTTPRouteMatch
+		// key: the path of request, value: the path rewritten
+		paths map[string]string
+		err   bool
+	}{
+		{
+			modifier: &HTTPPathModifier{Type: fullPathHTTPPathModifier, ReplaceFullPath: strPtr("/new")},
+			match:    gatewayv1alpha2.HTTPRouteMatch{Path: pathPrefix("/old")},
+			paths:    map[string]string{"/old": "/new", "/old/foo": "/new"},
+		},
+		{
+			modifier: &HTTPPathModifier{Type: prefixMatchHTTPPathModifier, ReplacePrefixMatch: strPtr("/v2/")},
+			match:    gatewayv1alpha2.HTTPRouteMatch{Path: pathPrefix("/api/")},
+			paths:    map[string]string{"/api": "/v2", "/api/": "/v2/", "/api/foo": "/v2/foo"},
+		},
+		{
+			modifier: &HTTPPathModifier{Type: prefixMatchHTTPPathModifier, ReplacePrefixMatch: strPtr("/")},
+			match:    gatewayv1alpha2.HTTPRouteMatch{Path: pathPrefix("/api")},
+			paths:    map[string]string{"/api": "/", "/api/": "/", "/api/foo": "/foo"},
+		},
+		{
+			modifier: &HTTPPathModifier{Type: prefixMatchHTTPPathModifier, ReplacePrefixMatch: strPtr("/v2")},
+			match:    gatewayv1alpha2.HTTPRouteMatch{},
+			paths:    map[string]string{"/": "/v2/", "/foo": "/v2/foo"},
+		},
+		{
+			modifier: &HTTPPathModifier{Type: prefixMatchHTTPPathModifier, ReplacePrefixMatch: strPtr("/v2")},
+			match:    gatewayv1alpha2.HTTPRouteMatch{Path: pathExact("/api")},
+			err:      true,
+		},
+		{
+			modifier: &HTTPPathModifier{Type: fullPathHTTPPathModifier},
+			err:      true,
+		},
+		{
+			modifier: &HTTPPathModifier{Type: "Unknown"},
+			err:      true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run("", func(t *testing.T) {
+			httpRoute := &networking.HTTPRoute{}
+			err := applyPathModifier(testCase.modifier, testCase.match, httpRoute)
+			if testCase.err != (err != nil) {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if err != nil {
+				return
+			}
+			// Envoy refers to the capture group by \\1, while go by ${1}.
+			uriRegex := httpRoute.Rewrite.UriRegex
+			pattern := regexp.MustCompile(uriRegex.Pattern)
+			substitution := strings.ReplaceAll(uriRegex.Substitution, "\\1", "${1}")
+			for path, expect := range testCase.paths {
+				if actual := pattern.ReplaceAllString(path, substitution); actual != expect {
+					t.Fatalf("Path %s should be rewritten to %s, but got %s", path, expect, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestIntersectHostnames(t *testing.T) {
+	hostname := func(h string) *gatewayv1alpha2.Hostname {
+		out := gatewayv1alpha2.Hostname(h)
+		return &out
+	}
+	testCases := []struct {
+		listener *gatewayv1alpha2.Hostname
+		route    []gatewayv1alpha2.Hostname
+		expect   []string
+	}{
+		{
+			expect: []string{"*"},
+		},
+		{
+			listener: hostname("foo.com"),
+			expect:   []string{"foo.com"},
+		},
+		{
+			route:  []gatewayv1alpha2.Hostname{"foo.com", "bar.com"},
+			expect: []string{"foo.com", "bar.com"},
+		},
+		{
+			listener: hostname("*.foo.com"),
+			route:    []gatewayv1alpha2.Hostname{"a.foo.com", "bar.com", "*.foo.com"},
+			expect:   []string{"a.foo.com", "*.foo.com"},
+		},
+		{
+			listener: hostname("a.foo.com"),
+			route:    []gatewayv1alpha2.Hostname{"*.foo.com"},
+			expect:   []string{"a.foo.com"},
+		},
+		{
+			listener: hostname("a.foo.com"),
+			route:    []gatewayv1alpha2.Hostname{"b.foo.com"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run("", func(t *testing.T) {
+			if !reflect.DeepEqual(intersectHostnames(testCase.listener, testCase.route), testCase.expect) {
+				t.Fatalf("Should be equal.")
+			}
+		})
+	}
+}
@@ -68,6 +68,7 @@ type controller struct {
 	gatewayHandlers         []model.EventHandler
 	destinationRuleHandlers []model.EventHandler
 	envoyFilterHandlers     []model.EventHandler
+	serviceEntryHandlers    []model.EventHandler
 
 	options common.Options
 
@@ -117,15 +118,19 @@ func NewController(localKubeClient, client kubeclient.Client, options common.Opt
 	handler := controllers.LatestVersionHandlerFuncs(controllers.EnqueueForSelf(q))
 	c.ingressInformer.AddEventHandler(handler)
 
-	if options.EnableStatus {
-		c.statusSyncer = newStatusSyncer(localKubeClient, client, c, options.SystemNamespace)
-	} else {
+	// The status syncer always reports translation errors, and only updates the ip in status if enabled.
+	c.statusSyncer = newStatusSyncer(localKubeClient, client, c, options.SystemNamespace)
+	if !options.EnableStatus {
 		IngressLog.Infof("Disable status update for cluster %s", options.ClusterId)
 	}
 
 	return c
 }
 
+func (c *controller) SetIngressErrors(errors []common.IngressError) {
+	c.statusSyncer.setErrors(errors)
+}
+
 func (c *controller) ServiceLister() listerv1.ServiceLister {
 	return c.serviceLister
 }
@@ -135,9 +140,7 @@ func (c *controller) SecretLister() listerv1.SecretLister {
 }
 
 func (c *controller) Run(stop <-chan struct{}) {
-	if c.statusSyncer != nil {
-		go c.statusSyncer.run(stop)
-	}
+	go c.statusSyncer.run(stop)
 	go c.secretController.Run(stop)
 
 	defer utilruntime.HandleCrash()
@@ -233,6 +236,14 @@ func (c *controller) onEvent(namespacedName types.NamespacedName) error {
 		// Set this label so that we do not compare configs and just push.
 		Labels: map[string]string{constants.AlwaysPushLabel: "true"},
 	}
+	// External services referred by annotations are exported as service entries.
+	semetadata := config.Meta{
+		Name:             ing.Name + "-" + "serviceentry",
+		Namespace:        ing.Namespace,
+		GroupVersionKind: gvk.ServiceEntry,
+		// Set this label so that we do not compare configs and just push.
+		Labels: map[string]string{constants.AlwaysPushLabel: "true"},
+	}
 
 	for _, f := range c.destinationRuleHandlers {
 		f(config.Config{Meta: drmetadata}, config.Config{Meta: drmetadata}, event)
@@ -250,6 +261,10 @@ func (c *controller) onEvent(namespacedName types.NamespacedName) error {
 		f(config.Config{Meta: gatewaymetadata}, config.Config{Meta: gatewaymetadata}, event)
 	}
 
+	for _, f := range c.serviceEntryHandlers {
+		f(config.Config{Meta: semetadata}, config.Config{Meta: semetadata}, event)
+	}
+
 	return nil
 }
 
@@ -263,6 +278,8 @@ func (c *controller) RegisterEventHandler(kind config.GroupVersionKind, f model.
 		c.destinationRuleHandlers = append(c.destinationRuleHandlers, f)
 	case gvk.EnvoyFilter:
 		c.envoyFilterHandlers = append(c.envoyFilterHandlers, f)
+	case gvk.ServiceEntry:
+		c.serviceEntryHandlers = append(c.serviceEntryHandlers, f)
 	}
 }
 
@@ -314,6 +331,7 @@ func (c *controller) List() []config.Config {
 				Annotations:       common.CreateOrUpdateAnnotations(copiedConfig.Annotations, c.options),
 				Labels:            copiedConfig.Labels,
 				CreationTimestamp: copiedConfig.CreationTimestamp.Time,
+				ResourceVersion:   copiedConfig.ResourceVersion,
 			},
 			Spec: copiedConfig.Spec,
 		}
@@ -427,8 +445,7 @@ func (c *controller) ConvertGateway(convertOptions *common.ConvertOptions, wrapp
 		if wrapperGateway.IsHTTPS() {
 			domainBuilder.Event = common.DuplicatedTls
 			domainBuilder.PreIngress = preDomainBuilder.Ingress
-			convertOptions.IngressDomainCache.Invalid = append(convertOptions.IngressDomainCache.Invalid,
-				domainBuilder.Build())
+			convertOptions.IngressDomainCache.AddInvalid(domainBuilder)
 			continue
 		}
 
@@ -499,6 +516,7 @@ func (c *controller) ConvertHTTPRoute(convertOptions *common.ConvertOptions, wra
 			convertOptions.VirtualServices[rule.Host] = wrapperVS
 		} else {
 			wrapperVS.WrapperConfig.AnnotationsConfig.MergeHostIPAccessControlIfNotExist(wrapper.AnnotationsConfig.IPAccessControl)
+			wrapperVS.WrapperConfig.AnnotationsConfig.MergeHostBodySizeIfNotExist(wrapper.AnnotationsConfig.BodySize)
 		}
 
 		// Record the latest app root for per host.
@@ -573,7 +591,7 @@ func (c *controller) ConvertHTTPRoute(convertOptions *common.ConvertOptions, wra
 
 			// backend service check
 			var event common.Event
-			wrapperHttpRoute.HTTPRoute.Route, event = c.backendToRouteDestination(&httpPath.Backend, cfg.Namespace, ingressRouteBuilder)
+			wrapperHttpRoute.HTTPRoute.Route, event = c.backendToRouteDestination(&httpPath.Backend, cfg.Namespace, ingressRouteBuilder, wrapper.AnnotationsConfig.Destination)
 
 			if ingressRouteBuilder.Event != common.Normal {
 				event = ingressRouteBuilder.Event
@@ -754,7 +772,7 @@ func (c *controller) ApplyCanaryIngress(convertOptions *common.ConvertOptions, w
 			ingressRouteBuilder := convertOptions.IngressRouteCache.New(canary)
 			// backend service check
 			var event common.Event
-			canary.HTTPRoute.Route, event = c.backendToRouteDestination(&httpPath.Backend, cfg.Namespace, ingressRouteBuilder)
+			canary.HTTPRoute.Route, event = c.backendToRouteDestination(&httpPath.Backend, cfg.Namespace, ingressRouteBuilder, wrapper.AnnotationsConfig.Destination)
 			if event != common.Normal {
 				common.IncrementInvalidIngress(c.options.ClusterId, event)
 				ingressRouteBuilder.Event = event
@@ -766,38 +784,66 @@ func (c *controller) ApplyCanaryIngress(convertOptions *common.ConvertOptions, w
 			if byWeight {
 				canary.HTTPRoute.Route[0].Weight = int32(canaryConfig.Weight)
 			}
+			// The requests not matched by header fall through to the weighted canary,
+			// which is merged into the normal route with a copy of canary route.
+			weightCanary := canary.HTTPRoute
+			if byHeader && byWeight {
+				weightCanary = canary.HTTPRoute.DeepCopy()
+			}
 
 			pos := 0
 			var targetRoute *common.WrapperHTTPRoute
 			for _, route := range routes {
 				if isCanaryRoute(canary, route) {
 					targetRoute = route
-					// Header, Cookie
-					if byHeader {
-						IngressLog.Debug("Insert canary route by header")
-						annotations.ApplyByHeader(canary.HTTPRoute, route.HTTPRoute, canary.WrapperConfig.AnnotationsConfig)
-						canary.HTTPRoute.Name = common.GenerateUniqueRouteName(canary)
-					} else {
-						IngressLog.Debug("Merge canary route by weight")
-						if route.WeightTotal == 0 {
-							route.WeightTotal = int32(canaryConfig.WeightTotal)
-						}
-						annotations.ApplyByWeight(canary.HTTPRoute, route.HTTPRoute, canary.WrapperConfig.AnnotationsConfig)
-					}
-
 					break
 				}
 				pos += 1
 			}
 
-			IngressLog.Debugf("Canary route is %v", canary)
 			if targetRoute == nil {
+				IngressLog.Debugf("Canary route is %v", canary)
 				continue
 			}
 
+			if byWeight && !common.CanaryWeightAvailable(targetRoute, canaryConfig) {
+				IngressLog.Errorf("Canary weight of host %s and path %s in ingress %s/%s is invalid", rule.Host, path, cfg.Namespace, cfg.Name)
+				ingressRouteBuilder.Event = common.InvalidCanaryWeight
+				convertOptions.IngressRouteCache.Add(ingressRouteBuilder)
+				continue
+			}
+
+			// Header, Cookie, Query, Source range
+			if byHeader {
+				IngressLog.Debug("Insert canary route by header")
+				annotations.ApplyByHeader(canary.HTTPRoute, targetRoute.HTTPRoute, canary.WrapperConfig.AnnotationsConfig)
+				canary.HTTPRoute.Name = common.GenerateUniqueRouteName(canary)
+
+				if duplicated := common.FindDuplicatedCanary(canary, routes[:pos]); duplicated != nil {
+					IngressLog.Errorf("Canary of host %s and path %s in ingress %s/%s is duplicated", rule.Host, path, cfg.Namespace, cfg.Name)
+					ingressRouteBuilder.Event = common.DuplicatedCanary
+					ingressRouteBuilder.PreIngress = duplicated.WrapperConfig.Config
+					convertOptions.IngressRouteCache.Add(ingressRouteBuilder)
+					continue
+				}
+			}
+			if byWeight {
+				IngressLog.Debug("Merge canary route by weight")
+				if targetRoute.WeightTotal == 0 {
+					targetRoute.WeightTotal = int32(canaryConfig.WeightTotal)
+				}
+				targetRoute.CanaryWeight += int32(canaryConfig.Weight)
+				annotations.ApplyByWeight(weightCanary, targetRoute.HTTPRoute, canary.WrapperConfig.AnnotationsConfig)
+			}
+
+			IngressLog.Debugf("Canary route is %v", canary)
+
 			if byHeader {
 				// Inherit policy from normal route
 				canary.WrapperConfig.AnnotationsConfig.Auth = targetRoute.WrapperConfig.AnnotationsConfig.Auth
+				canary.WrapperConfig.AnnotationsConfig.ExtAuth = targetRoute.WrapperConfig.AnnotationsConfig.ExtAuth
+				canary.WrapperConfig.AnnotationsConfig.Jwt = targetRoute.WrapperConfig.AnnotationsConfig.Jwt
+				canary.WrapperConfig.AnnotationsConfig.Oidc = targetRoute.WrapperConfig.AnnotationsConfig.Oidc
 
 				routes = append(routes[:pos+1], routes[pos:]...)
 				routes[pos] = canary
@@ -806,7 +852,8 @@ func (c *controller) ApplyCanaryIngress(convertOptions *common.ConvertOptions, w
 				// Recreate route name.
 				ingressRouteBuilder.RouteName = common.GenerateUniqueRouteName(canary)
 				convertOptions.IngressRouteCache.Add(ingressRouteBuilder)
-			} else {
+			}
+			if byWeight {
 				convertOptions.IngressRouteCache.Update(targetRoute)
 			}
 		}
@@ -835,7 +882,7 @@ func (c *controller) ConvertTrafficPolicy(convertOptions *common.ConvertOptions,
 		if err != nil {
 			IngressLog.Errorf("ignore default service %s within ingress %s/%s", serviceKey.Name, cfg.Namespace, cfg.Name)
 		} else {
-			if _, exist := convertOptions.Service2TrafficPolicy[serviceKey]; !exist {
+			if trafficPolicy, exist := convertOptions.Service2TrafficPolicy[serviceKey]; !exist {
 				convertOptions.Service2TrafficPolicy[serviceKey] = &common.WrapperTrafficPolicy{
 					TrafficPolicy: &networking.TrafficPolicy_PortTrafficPolicy{
 						Port: &networking.PortSelector{
@@ -844,6 +891,8 @@ func (c *controller) ConvertTrafficPolicy(convertOptions *common.ConvertOptions,
 					},
 					WrapperConfig: wrapper,
 				}
+			} else {
+				trafficPolicy.AddSharedWrapperConfig(wrapper)
 			}
 		}
 	}
@@ -864,7 +913,8 @@ func (c *controller) ConvertTrafficPolicy(convertOptions *common.ConvertOptions,
 				continue
 			}
 
-			if _, exist := convertOptions.Service2TrafficPolicy[serviceKey]; exist {
+			if trafficPolicy, exist := convertOptions.Service2TrafficPolicy[serviceKey]; exist {
+				trafficPolicy.AddSharedWrapperConfig(wrapper)
 				continue
 			}
 
@@ -956,7 +1006,12 @@ func isCanaryRoute(canary, route *common.WrapperHTTPRoute) bool {
 }
 
 func (c *controller) backendToRouteDestination(backend *ingress.IngressBackend, namespace string,
-	builder *common.IngressRouteBuilder) ([]*networking.HTTPRouteDestination, common.Event) {
+	builder *common.IngressRouteBuilder, config *annotations.DestinationConfig) ([]*networking.HTTPRouteDestination, common.Event) {
+	// The destination annotation takes precedence over the backend, which is usually a resource backend in this case.
+	if config != nil && len(config.McpDestination) > 0 {
+		return common.ConvertMcpDestination(config, builder), common.Normal
+	}
+
 	if backend == nil {
 		return nil, common.InvalidBackendService
 	}
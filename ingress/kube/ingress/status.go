@@ -16,18 +16,25 @@ package ingress
 
 import (
 	"context"
+	"encoding/json"
 	"reflect"
 	"sort"
+	"sync"
 	"time"
 
 	kubelib "istio.io/istio/pkg/kube"
 	coreV1 "k8s.io/api/core/v1"
+	ingress "k8s.io/api/networking/v1beta1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	listerv1 "k8s.io/client-go/listers/core/v1"
 	ingresslister "k8s.io/client-go/listers/networking/v1beta1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/alibaba/higress/ingress/kube/common"
 	. "github.com/alibaba/higress/ingress/log"
@@ -37,6 +44,8 @@ import (
 type statusSyncer struct {
 	client     kubernetes.Interface
 	controller *controller
+	// The client of the cluster which the controller runs in, where the status leader is elected.
+	leaderClient kubernetes.Interface
 
 	watchedNamespace string
 
@@ -44,6 +53,13 @@ type statusSyncer struct {
 	ingressClassLister ingresslister.IngressClassLister
 	// search service in the mse vpc
 	serviceLister listerv1.ServiceLister
+
+	mutex         sync.Mutex
+	ingressErrors []common.IngressError
+	// Held by the sync loop of leader, so that a new loop waits for the one of lost leadership.
+	leading sync.Mutex
+	// key: namespace/name of ingress, value: the errors reported last time
+	reportedErrors map[types.NamespacedName]string
 }
 
 // newStatusSyncer creates a new instance
@@ -51,16 +67,32 @@ func newStatusSyncer(localKubeClient, client kubelib.Client, controller *control
 	return &statusSyncer{
 		client:             client,
 		controller:         controller,
+		leaderClient:       localKubeClient,
 		watchedNamespace:   namespace,
 		ingressLister:      client.KubeInformer().Networking().V1beta1().Ingresses().Lister(),
 		ingressClassLister: client.KubeInformer().Networking().V1beta1().IngressClasses().Lister(),
 		// search service in the mse vpc
-		serviceLister: localKubeClient.KubeInformer().Core().V1().Services().Lister(),
+		serviceLister:  localKubeClient.KubeInformer().Core().V1().Services().Lister(),
+		reportedErrors: map[types.NamespacedName]string{},
 	}
 }
 
 func (s *statusSyncer) run(stopCh <-chan struct{}) {
 	cache.WaitForCacheSync(stopCh, s.controller.HasSynced)
+	common.RunAsStatusLeader(s.leaderClient, s.controller.options.SystemNamespace, s.controller.options.ClusterId, stopCh, s.sync)
+}
+
+// sync updates the status and reports the errors of ingresses periodically, which only runs on the status leader.
+func (s *statusSyncer) sync(stopCh <-chan struct{}) {
+	s.leading.Lock()
+	defer s.leading.Unlock()
+	// Report the errors again, since they may have been changed by other leaders in the meantime.
+	s.reportedErrors = map[types.NamespacedName]string{}
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: s.client.CoreV1().Events("")})
+	defer broadcaster.Shutdown()
+	recorder := broadcaster.NewRecorder(scheme.Scheme, coreV1.EventSource{Component: common.EventComponent})
 
 	ticker := time.NewTicker(common.DefaultStatusUpdateInterval)
 	for {
@@ -69,13 +101,99 @@ func (s *statusSyncer) run(stopCh <-chan struct{}) {
 			ticker.Stop()
 			return
 		case <-ticker.C:
-			if err := s.runUpdateStatus(); err != nil {
-				IngressLog.Errorf("update status task fail, err %v", err)
+			if s.controller.options.EnableStatus {
+				if err := s.runUpdateStatus(); err != nil {
+					IngressLog.Errorf("update status task fail, err %v", err)
+				}
+			}
+			if err := s.reportErrors(recorder); err != nil {
+				IngressLog.Errorf("report ingress errors task fail, err %v", err)
 			}
 		}
 	}
 }
 
+func (s *statusSyncer) setErrors(errors []common.IngressError) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ingressErrors = errors
+}
+
+// reportErrors records the translation errors as warning events of ingresses. Events are only recorded
+// when the errors of ingress change, and the error annotation is kept up to date if enabled.
+func (s *statusSyncer) reportErrors(recorder record.EventRecorder) error {
+	s.mutex.Lock()
+	ingressErrors := common.GroupIngressErrors(s.ingressErrors)
+	s.mutex.Unlock()
+
+	for key, errors := range ingressErrors {
+		message := common.FormatIngressErrors(errors)
+		if s.reportedErrors[key] == message {
+			continue
+		}
+		ing, err := s.ingressLister.Ingresses(key.Namespace).Get(key.Name)
+		if err != nil {
+			IngressLog.Warnf("ingress %s within cluster %s is not found for reporting errors", key, s.controller.options.ClusterId)
+			continue
+		}
+		for _, ingressError := range errors {
+			recorder.Event(ing, coreV1.EventTypeWarning, common.EventReason(ingressError.Event), ingressError.Message)
+		}
+		s.reportedErrors[key] = message
+	}
+	for key := range s.reportedErrors {
+		if _, exist := ingressErrors[key]; !exist {
+			delete(s.reportedErrors, key)
+		}
+	}
+
+	if !s.controller.options.EnableErrorAnnotation {
+		return nil
+	}
+	ingressList, err := s.ingressLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, ing := range ingressList {
+		key := types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}
+		s.updateErrorAnnotation(ing, s.reportedErrors[key])
+	}
+	return nil
+}
+
+// updateErrorAnnotation sets the error annotation of ingress, and removes it if there are no errors.
+// The annotation is merged by patch, so that the concurrent changes of ingress are kept.
+func (s *statusSyncer) updateErrorAnnotation(ing *ingress.Ingress, message string) {
+	current, exist := ing.Annotations[common.TranslationErrorsAnnotation]
+	if (!exist && message == "") || (exist && current == message) {
+		return
+	}
+
+	// The annotation is removed by the null value of merge patch.
+	var value interface{}
+	if message != "" {
+		value = message
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				common.TranslationErrorsAnnotation: value,
+			},
+		},
+	})
+	if err != nil {
+		IngressLog.Errorf("error building the error annotation patch of ingress %s/%s: %v", ing.Namespace, ing.Name, err)
+		return
+	}
+	IngressLog.Infof("Update Ingress %v/%v within cluster %s error annotation",
+		ing.Namespace, ing.Name, s.controller.options.ClusterId)
+	_, err = s.client.NetworkingV1beta1().Ingresses(ing.Namespace).Patch(context.TODO(), ing.Name, types.MergePatchType, patch, metaV1.PatchOptions{})
+	if err != nil {
+		IngressLog.Warnf("error updating ingress %s/%s within cluster %s error annotation: %v",
+			ing.Namespace, ing.Name, s.controller.options.ClusterId, err)
+	}
+}
+
 func (s *statusSyncer) runUpdateStatus() error {
 	svcList, err := s.serviceLister.Services(s.watchedNamespace).List(common.SvcLabelSelector)
 	if err != nil {
@@ -15,11 +15,14 @@
 package ingressv1
 
 import (
+	"reflect"
 	"testing"
 
+	"istio.io/istio/pkg/config"
 	v1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/alibaba/higress/ingress/kube/annotations"
 	"github.com/alibaba/higress/ingress/kube/common"
 )
 
@@ -76,3 +79,206 @@ func TestShouldProcessIngressUpdate(t *testing.T) {
 		t.Fatal("should be true")
 	}
 }
+
+func TestApplyCanaryIngress(t *testing.T) {
+	c := controller{
+		options: common.Options{
+			ClusterId: "cluster",
+		},
+	}
+
+	pathType := v1.PathTypePrefix
+	newWrapper := func(name, service string, canary *annotations.CanaryConfig) *common.WrapperConfig {
+		return &common.WrapperConfig{
+			Config: &config.Config{
+				Meta: config.Meta{
+					Name:      name,
+					Namespace: "default",
+				},
+				Spec: v1.IngressSpec{
+					Rules: []v1.IngressRule{
+						{
+							Host: "test.com",
+							IngressRuleValue: v1.IngressRuleValue{
+								HTTP: &v1.HTTPIngressRuleValue{
+									Paths: []v1.HTTPIngressPath{
+										{
+											Path:     "/app",
+											PathType: &pathType,
+											Backend: v1.IngressBackend{
+												Service: &v1.IngressServiceBackend{
+													Name: service,
+													Port: v1.ServiceBackendPort{
+														Number: 80,
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			AnnotationsConfig: &annotations.Ingress{
+				Canary: canary,
+			},
+		}
+	}
+	canary := func(header string, weight, priority int) *annotations.CanaryConfig {
+		return &annotations.CanaryConfig{
+			Enabled:     true,
+			Header:      header,
+			Weight:      weight,
+			WeightTotal: 100,
+			Priority:    priority,
+		}
+	}
+
+	convertOptions := &common.ConvertOptions{
+		HostAndPath2Ingress: map[string]*config.Config{},
+		IngressRouteCache:   common.NewIngressRouteCache(),
+		VirtualServices:     map[string]*common.WrapperVirtualService{},
+		HTTPRoutes:          map[string][]*common.WrapperHTTPRoute{},
+	}
+	if err := c.ConvertHTTPRoute(convertOptions, newWrapper("normal", "normal", nil)); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	canaries := []*common.WrapperConfig{
+		newWrapper("weight-v2", "v2", canary("", 10, 0)),
+		newWrapper("header-low", "v3", canary("x-low", 0, 1)),
+		newWrapper("weight-v3", "v3", canary("", 5, 0)),
+		newWrapper("header-high", "v2", canary("x-high", 0, 10)),
+		newWrapper("weight-exceeded", "v4", canary("", 90, 0)),
+		newWrapper("header-duplicated", "v4", canary("x-low", 0, 0)),
+	}
+	common.SortCanaryIngresses(canaries)
+	for _, wrapper := range canaries {
+		if err := c.ApplyCanaryIngress(convertOptions, wrapper); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+	}
+
+	routes := convertOptions.HTTPRoutes["test.com"]
+	var ingresses []string
+	for _, route := range routes {
+		ingresses = append(ingresses, route.WrapperConfig.Config.Name)
+	}
+	if expect := []string{"header-high", "header-low", "normal"}; !reflect.DeepEqual(ingresses, expect) {
+		t.Fatalf("Unexpected route order %v, expect %v", ingresses, expect)
+	}
+
+	normal := routes[len(routes)-1]
+	var weights []int32
+	for _, destination := range normal.HTTPRoute.Route {
+		weights = append(weights, destination.Weight)
+	}
+	if expect := []int32{100, 10, 5}; !reflect.DeepEqual(weights, expect) {
+		t.Fatalf("Unexpected weights %v, expect %v", weights, expect)
+	}
+	if normal.WeightTotal != 100 || normal.CanaryWeight != 15 {
+		t.Fatalf("Unexpected weight total %d and canary weight %d", normal.WeightTotal, normal.CanaryWeight)
+	}
+
+	events := map[string]common.Event{}
+	for _, ingressError := range convertOptions.IngressRouteCache.Errors() {
+		events[ingressError.Name] = ingressError.Event
+	}
+	expectEvents := map[string]common.Event{
+		"weight-exceeded":   common.InvalidCanaryWeight,
+		"header-duplicated": common.DuplicatedCanary,
+	}
+	if !reflect.DeepEqual(events, expectEvents) {
+		t.Fatalf("Unexpected errors %v, expect %v", events, expectEvents)
+	}
+}
+
+func TestApplyCanaryIngressInheritsAuth(t *testing.T) {
+	c := controller{
+		options: common.Options{
+			ClusterId: "cluster",
+		},
+	}
+
+	pathType := v1.PathTypePrefix
+	newWrapper := func(name string, annotationsConfig *annotations.Ingress) *common.WrapperConfig {
+		return &common.WrapperConfig{
+			Config: &config.Config{
+				Meta: config.Meta{
+					Name:      name,
+					Namespace: "default",
+				},
+				Spec: v1.IngressSpec{
+					Rules: []v1.IngressRule{
+						{
+							Host: "test.com",
+							IngressRuleValue: v1.IngressRuleValue{
+								HTTP: &v1.HTTPIngressRuleValue{
+									Paths: []v1.HTTPIngressPath{
+										{
+											Path:     "/",
+											PathType: &pathType,
+											Backend: v1.IngressBackend{
+												Service: &v1.IngressServiceBackend{
+													Name: name,
+													Port: v1.ServiceBackendPort{
+														Number: 80,
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			AnnotationsConfig: annotationsConfig,
+		}
+	}
+
+	normal := &annotations.Ingress{
+		ExtAuth: &annotations.ExtAuthConfig{
+			URL: "http://auth.default.svc/verify",
+		},
+		Jwt:  &annotations.JwtConfig{},
+		Oidc: &annotations.OidcConfig{},
+	}
+	convertOptions := &common.ConvertOptions{
+		HostAndPath2Ingress: map[string]*config.Config{},
+		IngressRouteCache:   common.NewIngressRouteCache(),
+		VirtualServices:     map[string]*common.WrapperVirtualService{},
+		HTTPRoutes:          map[string][]*common.WrapperHTTPRoute{},
+	}
+	if err := c.ConvertHTTPRoute(convertOptions, newWrapper("normal", normal)); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	canary := &annotations.Ingress{
+		Canary: &annotations.CanaryConfig{
+			Enabled:     true,
+			Header:      "x-canary",
+			WeightTotal: 100,
+		},
+	}
+	if err := c.ApplyCanaryIngress(convertOptions, newWrapper("canary", canary)); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	routes := convertOptions.HTTPRoutes["test.com"]
+	if len(routes) != 2 || routes[0].WrapperConfig.Config.Name != "canary" {
+		t.Fatalf("Unexpected routes %v", routes)
+	}
+	inherited := routes[0].WrapperConfig.AnnotationsConfig
+	if inherited.ExtAuth != normal.ExtAuth {
+		t.Fatalf("Canary should inherit ext auth of normal route")
+	}
+	if inherited.Jwt != normal.Jwt {
+		t.Fatalf("Canary should inherit jwt of normal route")
+	}
+	if inherited.Oidc != normal.Oidc {
+		t.Fatalf("Canary should inherit oidc of normal route")
+	}
+}
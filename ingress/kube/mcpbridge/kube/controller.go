@@ -0,0 +1,166 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	kubeclient "istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/controllers"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/alibaba/higress/ingress/kube/common"
+	"github.com/alibaba/higress/ingress/kube/mcpbridge"
+	"github.com/alibaba/higress/ingress/kube/util"
+	. "github.com/alibaba/higress/ingress/log"
+)
+
+var _ mcpbridge.Controller = &controller{}
+
+type controller struct {
+	queue     workqueue.RateLimitingInterface
+	informer  cache.SharedIndexInformer
+	lister    cache.GenericNamespaceLister
+	handler   func(util.ClusterNamespacedName)
+	clusterId string
+}
+
+// NewController watches McpBridge objects within the system namespace only.
+func NewController(client kubeclient.Client, options common.Options) mcpbridge.Controller {
+	q := workqueue.NewRateLimitingQueue(workqueue.DefaultItemBasedRateLimiter())
+
+	genericInformer := dynamicinformer.NewFilteredDynamicInformer(client.Dynamic(), mcpbridge.GroupVersionResource,
+		options.SystemNamespace, 0, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil)
+	informer := genericInformer.Informer()
+
+	handler := controllers.LatestVersionHandlerFuncs(controllers.EnqueueForSelf(q))
+	informer.AddEventHandler(handler)
+
+	return &controller{
+		queue:     q,
+		informer:  informer,
+		lister:    genericInformer.Lister().ByNamespace(options.SystemNamespace),
+		clusterId: options.ClusterId,
+	}
+}
+
+func (c *controller) Informer() cache.SharedIndexInformer {
+	return c.informer
+}
+
+func (c *controller) AddEventHandler(f func(util.ClusterNamespacedName)) {
+	c.handler = f
+}
+
+func (c *controller) List() []*mcpbridge.McpBridge {
+	objs, err := c.lister.List(labels.Everything())
+	if err != nil {
+		IngressLog.Errorf("List mcpbridge fail, err %v", err)
+		return nil
+	}
+
+	var out []*mcpbridge.McpBridge
+	for _, obj := range objs {
+		bridge, err := convert(obj)
+		if err != nil {
+			IngressLog.Errorf("Convert mcpbridge fail, err %v", err)
+			continue
+		}
+		out = append(out, bridge)
+	}
+	return out
+}
+
+func (c *controller) Run(stop <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	go c.informer.Run(stop)
+
+	if !cache.WaitForCacheSync(stop, c.HasSynced) {
+		IngressLog.Errorf("Failed to sync mcpbridge controller cache")
+		return
+	}
+	go wait.Until(c.worker, time.Second, stop)
+	<-stop
+}
+
+func (c *controller) worker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *controller) processNextWorkItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+	namespacedName := key.(types.NamespacedName)
+	IngressLog.Debugf("mcpbridge %s push to queue", namespacedName)
+	if err := c.onEvent(namespacedName); err != nil {
+		IngressLog.Errorf("error processing mcpbridge item (%v) (retrying): %v", key, err)
+		c.queue.AddRateLimited(key)
+	} else {
+		c.queue.Forget(key)
+	}
+	return true
+}
+
+func (c *controller) onEvent(namespacedName types.NamespacedName) error {
+	_, err := c.lister.Get(namespacedName.Name)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	// Deletion also matters here, the handler is expected to list the remaining objects.
+	if c.handler != nil {
+		c.handler(util.ClusterNamespacedName{
+			NamespacedName: model.NamespacedName{
+				Namespace: namespacedName.Namespace,
+				Name:      namespacedName.Name,
+			},
+			ClusterId: c.clusterId,
+		})
+	}
+	return nil
+}
+
+func (c *controller) HasSynced() bool {
+	return c.informer.HasSynced()
+}
+
+func convert(obj runtime.Object) (*mcpbridge.McpBridge, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, kerrors.NewBadRequest("mcpbridge object is not unstructured")
+	}
+
+	bridge := &mcpbridge.McpBridge{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), bridge); err != nil {
+		return nil, err
+	}
+	return bridge, nil
+}
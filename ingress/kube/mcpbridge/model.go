@@ -0,0 +1,95 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcpbridge
+
+import (
+	"net"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/alibaba/higress/ingress/kube/util"
+)
+
+const (
+	Group   = "istio.aliyun.cloud.com"
+	Version = "v1"
+	Kind    = "McpBridge"
+
+	// DefaultMcpBridgeName is the name of the McpBridge object watched in the system namespace.
+	DefaultMcpBridgeName = "default"
+)
+
+var GroupVersionResource = schema.GroupVersionResource{
+	Group:    Group,
+	Version:  Version,
+	Resource: "mcpbridges",
+}
+
+// McpBridge is the schema for the mcpbridges API, see helm/higress/crds/mcp-bridge.yaml.
+type McpBridge struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec McpBridgeSpec `json:"spec,omitempty"`
+}
+
+type McpBridgeSpec struct {
+	Registries []*RegistryConfig `json:"registries,omitempty"`
+}
+
+type RegistryConfig struct {
+	Type   string `json:"type"`
+	Name   string `json:"name,omitempty"`
+	Domain string `json:"domain"`
+	Port   uint32 `json:"port"`
+
+	NacosAddressServer string   `json:"nacosAddressServer,omitempty"`
+	NacosAccessKey     string   `json:"nacosAccessKey,omitempty"`
+	NacosSecretKey     string   `json:"nacosScretKey,omitempty"`
+	NacosNamespaceId   string   `json:"nacosNamespaceId,omitempty"`
+	NacosNamespace     string   `json:"nacosNamespace,omitempty"`
+	NacosGroups        []string `json:"nacosGroups,omitempty"`
+	// NacosRefreshInterval is a duration in nanoseconds.
+	NacosRefreshInterval int64 `json:"nacosRefreshInterval,omitempty"`
+
+	ConsulNamespace string `json:"consulNamespace,omitempty"`
+
+	ZkServicesPath []string `json:"zkServicesPath,omitempty"`
+}
+
+// Key identifies a registry within all McpBridge objects.
+func (r *RegistryConfig) Key() string {
+	name := r.Name
+	if name == "" {
+		name = net.JoinHostPort(r.Domain, strconv.Itoa(int(r.Port)))
+	}
+	return r.Type + "/" + name
+}
+
+type Controller interface {
+	AddEventHandler(func(util.ClusterNamespacedName))
+
+	Run(stop <-chan struct{})
+
+	HasSynced() bool
+
+	// List returns all McpBridge objects within the watched namespace.
+	List() []*McpBridge
+
+	Informer() cache.SharedIndexInformer
+}
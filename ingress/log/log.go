@@ -17,3 +17,5 @@ package log
 import "istio.io/pkg/log"
 
 var IngressLog = log.RegisterScope("ingress", "Higress Ingress process.", 0)
+
+var RegistryLog = log.RegisterScope("registry", "Higress service registry process.", 0)
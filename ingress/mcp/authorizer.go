@@ -0,0 +1,108 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcp
+
+import (
+	"fmt"
+	"net"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+)
+
+// GatewayAuthorizer only allows the gateway pods to fetch the generic secrets served by SecretGenerator.
+// The grpc server is plaintext, so istio never authenticates the streams. Instead, the peer address of
+// the stream must be the ip of a running pod selected by the gateway selector.
+type GatewayAuthorizer struct {
+	// Pods lists the pods within the namespace of gateways.
+	Pods     listerv1.PodNamespaceLister
+	Selector labels.Selector
+}
+
+// StreamInterceptor rejects the streams requesting the secrets from the clients other than gateways.
+func (a *GatewayAuthorizer) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &authorizedStream{ServerStream: ss, authorizer: a})
+	}
+}
+
+// authorize returns an error unless the peer of stream is a running gateway pod.
+func (a *GatewayAuthorizer) authorize(ss grpc.ServerStream) error {
+	p, ok := peer.FromContext(ss.Context())
+	if !ok {
+		return status.Error(codes.PermissionDenied, "unknown peer")
+	}
+	ip := p.Addr.String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	pods, err := a.Pods.List(a.Selector)
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	for _, pod := range pods {
+		if pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+		for _, podIP := range pod.Status.PodIPs {
+			if podIP.IP == ip {
+				return nil
+			}
+		}
+		if pod.Status.PodIP == ip {
+			return nil
+		}
+	}
+	return status.Error(codes.PermissionDenied, fmt.Sprintf("%s is not a gateway", ip))
+}
+
+// authorizedStream authorizes the peer once the stream requests the secrets, so that the other resources, e.g.
+// the configs fetched by istiod, are still served as before.
+type authorizedStream struct {
+	grpc.ServerStream
+	authorizer *GatewayAuthorizer
+	authorized bool
+}
+
+func (s *authorizedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if s.authorized {
+		return nil
+	}
+	var typeURL string
+	switch req := m.(type) {
+	case *discovery.DiscoveryRequest:
+		typeURL = req.TypeUrl
+	case *discovery.DeltaDiscoveryRequest:
+		typeURL = req.TypeUrl
+	}
+	if typeURL != v3.SecretType {
+		return nil
+	}
+	if err := s.authorizer.authorize(s.ServerStream); err != nil {
+		return err
+	}
+	s.authorized = true
+	return nil
+}
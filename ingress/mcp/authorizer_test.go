@@ -0,0 +1,140 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcp
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	typeURL string
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeStream) RecvMsg(m interface{}) error {
+	m.(*discovery.DiscoveryRequest).TypeUrl = s.typeURL
+	return nil
+}
+
+func TestGatewayAuthorizer(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	pods := []*v1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "gateway",
+				Namespace: "higress-system",
+				Labels:    map[string]string{"higress": "higress-gateway"},
+			},
+			Status: v1.PodStatus{
+				Phase:  v1.PodRunning,
+				PodIP:  "10.0.0.1",
+				PodIPs: []v1.PodIP{{IP: "10.0.0.1"}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "controller",
+				Namespace: "higress-system",
+				Labels:    map[string]string{"higress": "higress-controller"},
+			},
+			Status: v1.PodStatus{
+				Phase: v1.PodRunning,
+				PodIP: "10.0.0.2",
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "stopped",
+				Namespace: "higress-system",
+				Labels:    map[string]string{"higress": "higress-gateway"},
+			},
+			Status: v1.PodStatus{
+				Phase: v1.PodSucceeded,
+				PodIP: "10.0.0.3",
+			},
+		},
+	}
+	for _, pod := range pods {
+		_ = indexer.Add(pod)
+	}
+	authorizer := &GatewayAuthorizer{
+		Pods:     listerv1.NewPodLister(indexer).Pods("higress-system"),
+		Selector: labels.SelectorFromSet(map[string]string{"higress": "higress-gateway"}),
+	}
+
+	inputCases := []struct {
+		ip      string
+		typeURL string
+		allowed bool
+	}{
+		{
+			ip:      "10.0.0.1",
+			typeURL: v3.SecretType,
+			allowed: true,
+		},
+		{
+			ip:      "10.0.0.2",
+			typeURL: v3.SecretType,
+		},
+		{
+			ip:      "10.0.0.3",
+			typeURL: v3.SecretType,
+		},
+		{
+			ip:      "10.0.0.4",
+			typeURL: v3.SecretType,
+		},
+		{
+			ip:      "10.0.0.4",
+			typeURL: "networking.istio.io/v1alpha3/VirtualService",
+			allowed: true,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			ctx := peer.NewContext(context.Background(), &peer.Peer{
+				Addr: &net.TCPAddr{IP: net.ParseIP(inputCase.ip), Port: 40000},
+			})
+			var stream grpc.ServerStream
+			handler := func(srv interface{}, ss grpc.ServerStream) error {
+				stream = ss
+				return nil
+			}
+			_ = authorizer.StreamInterceptor()(nil, &fakeStream{ctx: ctx, typeURL: inputCase.typeURL}, nil, handler)
+			err := stream.RecvMsg(&discovery.DiscoveryRequest{})
+			if inputCase.allowed != (err == nil) {
+				t.Fatalf("Unexpected error %v", err)
+			}
+		})
+	}
+}
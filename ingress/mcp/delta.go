@@ -0,0 +1,150 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcp
+
+import (
+	"crypto/md5"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/any"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// namedResource is a marshaled mcp resource with the name in its metadata.
+type namedResource struct {
+	name   string
+	digest [md5.Size]byte
+	body   *any.Any
+}
+
+func newNamedResource(name string, body *any.Any) namedResource {
+	return namedResource{
+		name:   name,
+		digest: md5.Sum(body.Value),
+		body:   body,
+	}
+}
+
+// marshaledResource is the marshaled result of a config, which is reused while the config is unchanged.
+type marshaledResource struct {
+	resourceVersion string
+	specDigest      [md5.Size]byte
+	createTime      time.Time
+	resource        namedResource
+}
+
+// unchanged returns whether the config is the same as the marshaled one. Configs converted from
+// ingresses have no resource version, so their specs are compared by the digest of content.
+func (m marshaledResource) unchanged(resourceVersion string, specDigest [md5.Size]byte, createTime time.Time) bool {
+	if !m.createTime.Equal(createTime) {
+		return false
+	}
+	if resourceVersion != "" {
+		return m.resourceVersion == resourceVersion
+	}
+	return m.resourceVersion == "" && m.specDigest == specDigest
+}
+
+// deltaCache records the digests of resources last sent to each proxy,
+// so that only the changed resources need to be sent in the next push.
+type deltaCache struct {
+	mutex sync.Mutex
+	// proxy id -> resource name -> digest
+	sent map[string]map[string][md5.Size]byte
+	// resource name -> marshaled resource, shared by all proxies
+	marshaled map[string]marshaledResource
+	// the push last marshaled and its resources, shared by all proxies within the push
+	push   *model.PushContext
+	pushed []namedResource
+}
+
+// evict drops the digests of the proxy, it is called when the proxy disconnects.
+func (d *deltaCache) evict(proxyID string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	delete(d.sent, proxyID)
+}
+
+// reset records the full set of resources sent to the proxy.
+func (d *deltaCache) reset(proxyID string, resources []namedResource) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.store(proxyID, resources)
+}
+
+// diff records the full set of resources and returns the changed resources and the names of
+// removed resources compared with the last push. The returned bool is false if the proxy has
+// never received resources, which means the whole set should be sent.
+func (d *deltaCache) diff(proxyID string, resources []namedResource) ([]*any.Any, []string, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	prev, exist := d.sent[proxyID]
+	d.store(proxyID, resources)
+	if !exist {
+		return nil, nil, false
+	}
+
+	var changed []*any.Any
+	current := make(map[string]struct{}, len(resources))
+	for _, resource := range resources {
+		current[resource.name] = struct{}{}
+		if digest, ok := prev[resource.name]; ok && digest == resource.digest {
+			continue
+		}
+		changed = append(changed, resource.body)
+	}
+
+	var removed []string
+	for name := range prev {
+		if _, ok := current[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	return changed, removed, true
+}
+
+// has returns whether the proxy has received resources before.
+func (d *deltaCache) has(proxyID string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	_, exist := d.sent[proxyID]
+	return exist
+}
+
+func (d *deltaCache) store(proxyID string, resources []namedResource) {
+	if d.sent == nil {
+		d.sent = make(map[string]map[string][md5.Size]byte)
+	}
+	digests := make(map[string][md5.Size]byte, len(resources))
+	for _, resource := range resources {
+		digests[resource.name] = resource.digest
+	}
+	d.sent[proxyID] = digests
+}
+
+func toAnys(resources []namedResource) []*any.Any {
+	result := make([]*any.Any, 0, len(resources))
+	for _, resource := range resources {
+		result = append(result, resource.body)
+	}
+	return result
+}
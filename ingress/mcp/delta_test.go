@@ -0,0 +1,200 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcp
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/any"
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+)
+
+func buildResource(name, value string) namedResource {
+	return newNamedResource(name, &any.Any{
+		TypeUrl: "type.googleapis.com/istio.mcp.v1alpha1.Resource",
+		Value:   []byte(value),
+	})
+}
+
+func TestDeltaCacheDiff(t *testing.T) {
+	cache := deltaCache{}
+	foo := buildResource("default/foo", "foo")
+	bar := buildResource("default/bar", "bar")
+	newBar := buildResource("default/bar", "new-bar")
+	baz := buildResource("default/baz", "baz")
+
+	if cache.has("gateway") {
+		t.Fatalf("Should not have any resources")
+	}
+	if _, _, ok := cache.diff("gateway", []namedResource{foo, bar}); ok {
+		t.Fatalf("Should push all resources for the first time")
+	}
+	if !cache.has("gateway") {
+		t.Fatalf("Should have resources")
+	}
+
+	inputCases := []struct {
+		input   []namedResource
+		changed []*any.Any
+		removed []string
+	}{
+		{
+			input: []namedResource{foo, bar},
+		},
+		{
+			input:   []namedResource{foo, newBar},
+			changed: []*any.Any{newBar.body},
+		},
+		{
+			input:   []namedResource{baz},
+			changed: []*any.Any{baz.body},
+			removed: []string{"default/bar", "default/foo"},
+		},
+		{
+			removed: []string{"default/baz"},
+		},
+	}
+
+	for _, c := range inputCases {
+		changed, removed, ok := cache.diff("gateway", c.input)
+		if !ok {
+			t.Fatalf("Should use delta")
+		}
+		if !reflect.DeepEqual(c.changed, changed) {
+			t.Fatalf("Should be equal, expect changed %v, actual %v", c.changed, changed)
+		}
+		if !reflect.DeepEqual(c.removed, removed) {
+			t.Fatalf("Should be equal, expect removed %v, actual %v", c.removed, removed)
+		}
+	}
+}
+
+func TestDeltaCacheReset(t *testing.T) {
+	cache := deltaCache{}
+	foo := buildResource("default/foo", "foo")
+	bar := buildResource("default/bar", "bar")
+
+	cache.reset("gateway", []namedResource{foo})
+	changed, removed, ok := cache.diff("gateway", []namedResource{foo, bar})
+	if !ok {
+		t.Fatalf("Should use delta")
+	}
+	if !reflect.DeepEqual([]*any.Any{bar.body}, changed) || len(removed) != 0 {
+		t.Fatalf("Should only push the new resource")
+	}
+
+	if _, _, ok = cache.diff("other-gateway", []namedResource{foo}); ok {
+		t.Fatalf("Should push all resources to another proxy")
+	}
+}
+
+func TestDeltaCacheEvict(t *testing.T) {
+	cache := deltaCache{}
+	foo := buildResource("default/foo", "foo")
+
+	cache.reset("gateway-1", []namedResource{foo})
+	cache.reset("gateway-2", []namedResource{foo})
+	cache.evict(proxyIDOfConnection("gateway-1-3"))
+	if cache.has("gateway-1") {
+		t.Fatalf("Should evict the disconnected proxy")
+	}
+	if !cache.has("gateway-2") {
+		t.Fatalf("Should keep other proxies")
+	}
+}
+
+func TestDeltaCacheMarshal(t *testing.T) {
+	cache := deltaCache{}
+	spec := &networking.Gateway{Servers: []*networking.Server{{Hosts: []string{"foo.com"}}}}
+	gateway := config.Config{
+		Meta: config.Meta{Name: "foo", Namespace: "default"},
+		Spec: spec,
+	}
+
+	first, err := cache.marshal([]config.Config{gateway})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := cache.marshal([]config.Config{gateway})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first[0].body != second[0].body {
+		t.Fatalf("Should reuse the marshaled resource of unchanged config")
+	}
+
+	gateway.Spec = &networking.Gateway{Servers: []*networking.Server{{Hosts: []string{"foo.com"}}}}
+	second, err = cache.marshal([]config.Config{gateway})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first[0].body != second[0].body {
+		t.Fatalf("Should reuse the marshaled resource of the config with the same content")
+	}
+
+	gateway.Spec.(*networking.Gateway).Servers[0].Hosts[0] = "bar.com"
+	third, err := cache.marshal([]config.Config{gateway})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third[0].digest == first[0].digest {
+		t.Fatalf("Should marshal the changed config again")
+	}
+
+	if _, err = cache.marshal(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.marshaled) != 0 {
+		t.Fatalf("Should drop the removed configs")
+	}
+}
+
+func TestDeltaCacheResources(t *testing.T) {
+	cache := deltaCache{}
+	var listed int
+	list := func() ([]config.Config, error) {
+		listed++
+		return []config.Config{
+			{
+				Meta: config.Meta{Name: "foo", Namespace: "default"},
+				Spec: &networking.ServiceEntry{Hosts: []string{"foo.com"}},
+			},
+		}, nil
+	}
+
+	push := &model.PushContext{}
+	for i := 0; i < 3; i++ {
+		resources, err := cache.resources(push, list)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resources) != 1 {
+			t.Fatalf("Unexpected resources %v", resources)
+		}
+	}
+	if listed != 1 {
+		t.Fatalf("Should list the configs once per push, but listed %d times", listed)
+	}
+
+	if _, err := cache.resources(&model.PushContext{}, list); err != nil {
+		t.Fatal(err)
+	}
+	if listed != 2 {
+		t.Fatalf("Should list the configs again for the new push")
+	}
+}
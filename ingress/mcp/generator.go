@@ -15,209 +15,321 @@
 package mcp
 
 import (
+	"crypto/md5"
+	"encoding/json"
 	"path"
+	"sort"
+	"time"
 
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/protobuf/types"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/any"
-	extensions "istio.io/api/extensions/v1alpha1"
 	mcp "istio.io/api/mcp/v1alpha1"
-	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
 )
 
 type VirtualServiceGenerator struct {
 	Server *xds.DiscoveryServer
+	deltaCache
 }
 
-func (c VirtualServiceGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
+func (c *VirtualServiceGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
 	updates *model.PushRequest) ([]*any.Any, model.XdsLogDetails, error) {
-	resources := make([]*any.Any, 0)
-	configs := push.AllVirtualServices
-	for _, config := range configs {
-		body, err := types.MarshalAny(config.Spec.(*networking.VirtualService))
-		if err != nil {
-			return nil, model.DefaultXdsLogDetails, err
-		}
-		createTime, err := types.TimestampProto(config.CreationTimestamp)
-		if err != nil {
-			return nil, model.DefaultXdsLogDetails, err
-		}
-		resource := &mcp.Resource{
-			Body: body,
-			Metadata: &mcp.Metadata{
-				Name:       path.Join(config.Namespace, config.Name),
-				CreateTime: createTime,
-			},
-		}
-		mcpAny, err := ptypes.MarshalAny(resource)
-		if err != nil {
-			return nil, model.DefaultXdsLogDetails, err
-		}
-		resources = append(resources, mcpAny)
-	}
-	return resources, model.DefaultXdsLogDetails, nil
+	return c.generate(proxy, push, pushConfigs(push.AllVirtualServices))
 }
 
-func (c VirtualServiceGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
+func (c *VirtualServiceGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
 	w *model.WatchedResource) ([]*any.Any, []string, model.XdsLogDetails, bool, error) {
-	// TODO: delta implement
-	return nil, nil, model.DefaultXdsLogDetails, false, nil
+	return c.generateDeltas(proxy, push, pushConfigs(push.AllVirtualServices), gvk.VirtualService, updates, w)
 }
 
 type DestinationRuleGenerator struct {
 	Server *xds.DiscoveryServer
+	deltaCache
 }
 
-func (c DestinationRuleGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
+func (c *DestinationRuleGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
 	updates *model.PushRequest) ([]*any.Any, model.XdsLogDetails, error) {
-	resources := make([]*any.Any, 0)
-	configs := push.AllDestinationRules
-	for _, config := range configs {
-		body, err := types.MarshalAny(config.Spec.(*networking.DestinationRule))
-		if err != nil {
-			return nil, model.DefaultXdsLogDetails, err
-		}
-		createTime, err := types.TimestampProto(config.CreationTimestamp)
-		if err != nil {
-			return nil, model.DefaultXdsLogDetails, err
-		}
-		resource := &mcp.Resource{
-			Body: body,
-			Metadata: &mcp.Metadata{
-				Name:       path.Join(config.Namespace, config.Name),
-				CreateTime: createTime,
-			},
-		}
-		mcpAny, err := ptypes.MarshalAny(resource)
-		if err != nil {
-			return nil, model.DefaultXdsLogDetails, err
-		}
-		resources = append(resources, mcpAny)
-	}
-	return resources, model.DefaultXdsLogDetails, nil
+	return c.generate(proxy, push, pushConfigs(push.AllDestinationRules))
 }
 
-func (c DestinationRuleGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
+func (c *DestinationRuleGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
 	w *model.WatchedResource) ([]*any.Any, []string, model.XdsLogDetails, bool, error) {
-	// TODO: delta implement
-	return nil, nil, model.DefaultXdsLogDetails, false, nil
+	return c.generateDeltas(proxy, push, pushConfigs(push.AllDestinationRules), gvk.DestinationRule, updates, w)
 }
 
 type EnvoyFilterGenerator struct {
 	Server *xds.DiscoveryServer
+	deltaCache
 }
 
-func (c EnvoyFilterGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
+func (c *EnvoyFilterGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
 	updates *model.PushRequest) ([]*any.Any, model.XdsLogDetails, error) {
-	resources := make([]*any.Any, 0)
-	configs := push.AllEnvoyFilters
-	for _, config := range configs {
-		body, err := types.MarshalAny(config.Spec.(*networking.EnvoyFilter))
-		if err != nil {
-			return nil, model.DefaultXdsLogDetails, err
-		}
-		createTime, err := types.TimestampProto(config.CreationTimestamp)
-		if err != nil {
-			return nil, model.DefaultXdsLogDetails, err
-		}
-		resource := &mcp.Resource{
-			Body: body,
-			Metadata: &mcp.Metadata{
-				Name:       path.Join(config.Namespace, config.Name),
-				CreateTime: createTime,
-			},
-		}
-		mcpAny, err := ptypes.MarshalAny(resource)
-		if err != nil {
-			return nil, model.DefaultXdsLogDetails, err
-		}
-		resources = append(resources, mcpAny)
-	}
-	return resources, model.DefaultXdsLogDetails, nil
+	return c.generate(proxy, push, pushConfigs(push.AllEnvoyFilters))
 }
 
-func (c EnvoyFilterGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
+func (c *EnvoyFilterGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
 	w *model.WatchedResource) ([]*any.Any, []string, model.XdsLogDetails, bool, error) {
-	// TODO: delta implement
-	return nil, nil, model.DefaultXdsLogDetails, false, nil
+	return c.generateDeltas(proxy, push, pushConfigs(push.AllEnvoyFilters), gvk.EnvoyFilter, updates, w)
 }
 
 type GatewayGenerator struct {
 	Server *xds.DiscoveryServer
+	deltaCache
 }
 
-func (c GatewayGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
+func (c *GatewayGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
 	updates *model.PushRequest) ([]*any.Any, model.XdsLogDetails, error) {
-	resources := make([]*any.Any, 0)
-	configs := push.AllGateways
-	for _, config := range configs {
-		body, err := types.MarshalAny(config.Spec.(*networking.Gateway))
-		if err != nil {
-			return nil, model.DefaultXdsLogDetails, err
+	return c.generate(proxy, push, pushConfigs(push.AllGateways))
+}
+
+func (c *GatewayGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
+	w *model.WatchedResource) ([]*any.Any, []string, model.XdsLogDetails, bool, error) {
+	return c.generateDeltas(proxy, push, pushConfigs(push.AllGateways), gvk.Gateway, updates, w)
+}
+
+type WasmpluginGenerator struct {
+	Server *xds.DiscoveryServer
+	deltaCache
+}
+
+func (c *WasmpluginGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
+	updates *model.PushRequest) ([]*any.Any, model.XdsLogDetails, error) {
+	return c.generate(proxy, push, pushConfigs(push.AllWasmplugins))
+}
+
+func (c *WasmpluginGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
+	w *model.WatchedResource) ([]*any.Any, []string, model.XdsLogDetails, bool, error) {
+	return c.generateDeltas(proxy, push, pushConfigs(push.AllWasmplugins), gvk.WasmPlugin, updates, w)
+}
+
+type ServiceEntryGenerator struct {
+	Server *xds.DiscoveryServer
+	deltaCache
+}
+
+func (c *ServiceEntryGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
+	updates *model.PushRequest) ([]*any.Any, model.XdsLogDetails, error) {
+	return c.generate(proxy, push, c.listServiceEntries)
+}
+
+func (c *ServiceEntryGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
+	w *model.WatchedResource) ([]*any.Any, []string, model.XdsLogDetails, bool, error) {
+	return c.generateDeltas(proxy, push, c.listServiceEntries, gvk.ServiceEntry, updates, w)
+}
+
+// listServiceEntries lists the service entries from the config store, since push context doesn't keep the raw ones.
+// The result is sorted like the other configs within push context, so that the order is stable between pushes.
+// It is only called once per push, see deltaCache.resources.
+func (c *ServiceEntryGenerator) listServiceEntries() ([]config.Config, error) {
+	configs, err := c.Server.Env.List(gvk.ServiceEntry, model.NamespaceAll)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(configs, func(i, j int) bool {
+		if !configs[i].CreationTimestamp.Equal(configs[j].CreationTimestamp) {
+			return configs[i].CreationTimestamp.Before(configs[j].CreationTimestamp)
 		}
-		createTime, err := types.TimestampProto(config.CreationTimestamp)
-		if err != nil {
-			return nil, model.DefaultXdsLogDetails, err
+		if configs[i].Namespace != configs[j].Namespace {
+			return configs[i].Namespace < configs[j].Namespace
 		}
-		resource := &mcp.Resource{
-			Body: body,
-			Metadata: &mcp.Metadata{
-				Name:       path.Join(config.Namespace, config.Name),
-				CreateTime: createTime,
-			},
+		return configs[i].Name < configs[j].Name
+	})
+	return configs, nil
+}
+
+// GenericSecretStore provides the generic secrets referred by the converted configs.
+type GenericSecretStore interface {
+	GetGenericSecret(name string) ([]byte, bool)
+}
+
+// SecretGenerator serves the generic secrets to gateways by sds, e.g. the client secrets of oidc, since istio sds
+// only serves tls certificates. The store only returns the secrets referred by the converted configs, so the other
+// secrets can't be fetched through it, and GatewayAuthorizer only allows the gateways to fetch them.
+type SecretGenerator struct {
+	Server *xds.DiscoveryServer
+	Store  GenericSecretStore
+}
+
+func (c *SecretGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
+	updates *model.PushRequest) ([]*any.Any, model.XdsLogDetails, error) {
+	if w == nil {
+		return nil, model.DefaultXdsLogDetails, nil
+	}
+	resources := make([]*any.Any, 0, len(w.ResourceNames))
+	for _, name := range w.ResourceNames {
+		data, exist := c.Store.GetGenericSecret(name)
+		if !exist {
+			continue
 		}
-		mcpAny, err := ptypes.MarshalAny(resource)
+		resource, err := ptypes.MarshalAny(&tlsv3.Secret{
+			Name: name,
+			Type: &tlsv3.Secret_GenericSecret{
+				GenericSecret: &tlsv3.GenericSecret{
+					Secret: &corev3.DataSource{
+						Specifier: &corev3.DataSource_InlineBytes{
+							InlineBytes: data,
+						},
+					},
+				},
+			},
+		})
 		if err != nil {
 			return nil, model.DefaultXdsLogDetails, err
 		}
-		resources = append(resources, mcpAny)
+		resources = append(resources, resource)
 	}
 	return resources, model.DefaultXdsLogDetails, nil
 }
 
-func (c GatewayGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
-	w *model.WatchedResource) ([]*any.Any, []string, model.XdsLogDetails, bool, error) {
-	// TODO: delta implement
-	return nil, nil, model.DefaultXdsLogDetails, false, nil
+// pushConfigs lists the configs kept by push context.
+func pushConfigs(configs []config.Config) func() ([]config.Config, error) {
+	return func() ([]config.Config, error) {
+		return configs, nil
+	}
 }
 
-type WasmpluginGenerator struct {
-	Server *xds.DiscoveryServer
+func (d *deltaCache) generate(proxy *model.Proxy, push *model.PushContext,
+	list func() ([]config.Config, error)) ([]*any.Any, model.XdsLogDetails, error) {
+	resources, err := d.resources(push, list)
+	if err != nil {
+		return nil, model.DefaultXdsLogDetails, err
+	}
+	d.reset(proxy.ID, resources)
+	return toAnys(resources), model.DefaultXdsLogDetails, nil
 }
 
-func (c WasmpluginGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
-	updates *model.PushRequest) ([]*any.Any, model.XdsLogDetails, error) {
-	resources := make([]*any.Any, 0)
-	configs := push.AllWasmplugins
-	for _, config := range configs {
-		body, err := types.MarshalAny(config.Spec.(*extensions.WasmPlugin))
-		if err != nil {
-			return nil, model.DefaultXdsLogDetails, err
+// generateDeltas only returns the resources changed since the last push to the proxy, and the names of removed ones.
+// The whole set is returned if the proxy has not received any resources yet within the current stream.
+func (d *deltaCache) generateDeltas(proxy *model.Proxy, push *model.PushContext, list func() ([]config.Config, error),
+	kind config.GroupVersionKind, updates *model.PushRequest, w *model.WatchedResource) ([]*any.Any, []string, model.XdsLogDetails, bool, error) {
+	newStream := w == nil || w.NonceSent == ""
+	if !newStream && !configsUpdated(updates, kind) && d.has(proxy.ID) {
+		return nil, nil, model.DefaultXdsLogDetails, true, nil
+	}
+
+	resources, err := d.resources(push, list)
+	if err != nil {
+		return nil, nil, model.DefaultXdsLogDetails, false, err
+	}
+	if newStream {
+		d.reset(proxy.ID, resources)
+		return toAnys(resources), nil, model.DefaultXdsLogDetails, false, nil
+	}
+	changed, removed, ok := d.diff(proxy.ID, resources)
+	if !ok {
+		return toAnys(resources), nil, model.DefaultXdsLogDetails, false, nil
+	}
+	return changed, removed, model.DefaultXdsLogDetails, true, nil
+}
+
+// configsUpdated returns whether the push may change the configs of this kind.
+// An empty ConfigsUpdated means all configs should be considered changed.
+func configsUpdated(updates *model.PushRequest, kind config.GroupVersionKind) bool {
+	if updates == nil || len(updates.ConfigsUpdated) == 0 {
+		return true
+	}
+	for key := range updates.ConfigsUpdated {
+		if key.Kind == kind {
+			return true
 		}
-		createTime, err := types.TimestampProto(config.CreationTimestamp)
-		if err != nil {
-			return nil, model.DefaultXdsLogDetails, err
+	}
+	return false
+}
+
+// resources returns the marshaled resources of the push. The configs are listed and marshaled only once per push,
+// and the result is shared by all proxies generated within the same push.
+func (d *deltaCache) resources(push *model.PushContext, list func() ([]config.Config, error)) ([]namedResource, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if push != nil && d.push == push {
+		return d.pushed, nil
+	}
+	configs, err := list()
+	if err != nil {
+		return nil, err
+	}
+	resources, err := d.marshal(configs)
+	if err != nil {
+		return nil, err
+	}
+	d.push = push
+	d.pushed = resources
+	return resources, nil
+}
+
+// marshal returns the marshaled resources of the configs. Only the configs changed since the last call are
+// marshaled again, and the cache of removed configs is dropped. The caller must hold the mutex.
+func (d *deltaCache) marshal(configs []config.Config) ([]namedResource, error) {
+	marshaled := make(map[string]marshaledResource, len(configs))
+	resources := make([]namedResource, 0, len(configs))
+	for _, cfg := range configs {
+		name := path.Join(cfg.Namespace, cfg.Name)
+		spec := cfg.Spec.(proto.Message)
+		var specDigest [md5.Size]byte
+		if cfg.ResourceVersion == "" {
+			var err error
+			if specDigest, err = digestSpec(spec); err != nil {
+				return nil, err
+			}
 		}
-		resource := &mcp.Resource{
-			Body: body,
-			Metadata: &mcp.Metadata{
-				Name:       path.Join(config.Namespace, config.Name),
-				CreateTime: createTime,
-			},
+		if cached, exist := d.marshaled[name]; exist && cached.unchanged(cfg.ResourceVersion, specDigest, cfg.CreationTimestamp) {
+			marshaled[name] = cached
+			resources = append(resources, cached.resource)
+			continue
 		}
-		mcpAny, err := ptypes.MarshalAny(resource)
+		resource, err := marshalResource(name, spec, cfg.CreationTimestamp)
 		if err != nil {
-			return nil, model.DefaultXdsLogDetails, err
+			return nil, err
 		}
-		resources = append(resources, mcpAny)
+		marshaled[name] = marshaledResource{
+			resourceVersion: cfg.ResourceVersion,
+			specDigest:      specDigest,
+			createTime:      cfg.CreationTimestamp,
+			resource:        resource,
+		}
+		resources = append(resources, resource)
 	}
-	return resources, model.DefaultXdsLogDetails, nil
+	d.marshaled = marshaled
+	return resources, nil
 }
 
-func (c WasmpluginGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
-	w *model.WatchedResource) ([]*any.Any, []string, model.XdsLogDetails, bool, error) {
-	// TODO: delta implement
-	return nil, nil, model.DefaultXdsLogDetails, false, nil
+// digestSpec hashes the content of spec. The spec is encoded by json rather than protobuf, since the protobuf
+// encoding doesn't sort the entries of map fields, which makes the digest of the same spec unstable.
+func digestSpec(spec proto.Message) ([md5.Size]byte, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return [md5.Size]byte{}, err
+	}
+	return md5.Sum(data), nil
+}
+
+func marshalResource(name string, spec proto.Message, creationTimestamp time.Time) (namedResource, error) {
+	body, err := types.MarshalAny(spec)
+	if err != nil {
+		return namedResource{}, err
+	}
+	createTime, err := types.TimestampProto(creationTimestamp)
+	if err != nil {
+		return namedResource{}, err
+	}
+	resource := &mcp.Resource{
+		Body: body,
+		Metadata: &mcp.Metadata{
+			Name:       name,
+			CreateTime: createTime,
+		},
+	}
+	mcpAny, err := ptypes.MarshalAny(resource)
+	if err != nil {
+		return namedResource{}, err
+	}
+	return newNamedResource(name, mcpAny), nil
 }
@@ -0,0 +1,57 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcp
+
+import (
+	"strings"
+
+	"istio.io/istio/pilot/pkg/xds"
+)
+
+// evictor is implemented by the generators which keep states per proxy.
+type evictor interface {
+	evict(proxyID string)
+}
+
+// DisconnectReporter is used as the status reporter of the discovery server, so that the per proxy
+// states kept by the mcp generators are dropped once the stream of the proxy is closed.
+type DisconnectReporter struct {
+	Server *xds.DiscoveryServer
+}
+
+var _ xds.DistributionStatusCache = &DisconnectReporter{}
+
+func (r *DisconnectReporter) RegisterEvent(string, xds.EventType, string) {}
+
+func (r *DisconnectReporter) RegisterDisconnect(conID string, _ []xds.EventType) {
+	proxyID := proxyIDOfConnection(conID)
+	for _, generator := range r.Server.McpGenerators {
+		if e, ok := generator.(evictor); ok {
+			e.evict(proxyID)
+		}
+	}
+}
+
+func (r *DisconnectReporter) QueryLastNonce(string, xds.EventType) string {
+	return ""
+}
+
+// proxyIDOfConnection trims the counter suffix from the connection id, which is formatted as <proxy id>-<counter>.
+func proxyIDOfConnection(conID string) string {
+	if idx := strings.LastIndex(conID, "-"); idx > 0 {
+		return conID[:idx]
+	}
+	return conID
+}
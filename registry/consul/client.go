@@ -0,0 +1,132 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	catalogServicesPath = "/v1/catalog/services"
+	healthServicePath   = "/v1/health/service/"
+
+	indexHeader = "X-Consul-Index"
+)
+
+type serviceEntry struct {
+	Node    *node    `json:"Node"`
+	Service *service `json:"Service"`
+}
+
+type node struct {
+	Node    string `json:"Node"`
+	Address string `json:"Address"`
+}
+
+type service struct {
+	ID      string            `json:"ID"`
+	Service string            `json:"Service"`
+	Tags    []string          `json:"Tags"`
+	Address string            `json:"Address"`
+	Port    uint32            `json:"Port"`
+	Meta    map[string]string `json:"Meta"`
+	Weights *weights          `json:"Weights"`
+}
+
+type weights struct {
+	Passing uint32 `json:"Passing"`
+	Warning uint32 `json:"Warning"`
+}
+
+// client issues blocking queries against consul http api.
+type client struct {
+	httpClient *http.Client
+	address    string
+	namespace  string
+	waitTime   time.Duration
+}
+
+func newClient(address, namespace string, waitTime time.Duration) *client {
+	return &client{
+		// Leave some time for consul to reply the blocking query.
+		httpClient: &http.Client{Timeout: waitTime + waitTime/16 + 5*time.Second},
+		address:    address,
+		namespace:  namespace,
+		waitTime:   waitTime,
+	}
+}
+
+// catalogServices returns all service names with their tags.
+func (c *client) catalogServices(ctx context.Context, index uint64) (map[string][]string, uint64, error) {
+	services := map[string][]string{}
+	newIndex, err := c.blockingQuery(ctx, catalogServicesPath, url.Values{}, index, &services)
+	return services, newIndex, err
+}
+
+// healthService only returns the instances whose checks are all passing.
+func (c *client) healthService(ctx context.Context, name string, index uint64) ([]*serviceEntry, uint64, error) {
+	var entries []*serviceEntry
+	params := url.Values{}
+	params.Set("passing", "true")
+	newIndex, err := c.blockingQuery(ctx, healthServicePath+url.PathEscape(name), params, index, &entries)
+	return entries, newIndex, err
+}
+
+func (c *client) blockingQuery(ctx context.Context, path string, params url.Values, index uint64, out interface{}) (uint64, error) {
+	if c.namespace != "" {
+		params.Set("ns", c.namespace)
+	}
+	if index > 0 {
+		params.Set("index", strconv.FormatUint(index, 10))
+		params.Set("wait", c.waitTime.String())
+	}
+	u := url.URL{
+		Scheme:   "http",
+		Host:     c.address,
+		Path:     path,
+		RawQuery: params.Encode(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("consul %s responses status code %d, body %s", c.address, resp.StatusCode, string(body))
+	}
+
+	newIndex, err := strconv.ParseUint(resp.Header.Get(indexHeader), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("consul %s responses invalid index %q", c.address, resp.Header.Get(indexHeader))
+	}
+	return newIndex, json.Unmarshal(body, out)
+}
@@ -0,0 +1,325 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"istio.io/api/networking/v1alpha3"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/alibaba/higress/ingress/kube/mcpbridge"
+	. "github.com/alibaba/higress/ingress/log"
+	"github.com/alibaba/higress/registry"
+	"github.com/alibaba/higress/registry/memory"
+)
+
+const (
+	DefaultConsulWaitTime      = 5 * time.Minute
+	DefaultConsulRetryInterval = 5 * time.Second
+
+	suffix = "consul"
+
+	// consulService is the catalog entry of consul server itself.
+	consulService = "consul"
+
+	protocolKey = "protocol"
+)
+
+type watcher struct {
+	registry.BaseWatcher
+	client             *client
+	cache              memory.Cache
+	suffix             string
+	retryInterval      time.Duration
+	keepStaleWhenEmpty bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex   sync.Mutex
+	healthy bool
+	// key: service name
+	serviceCancels map[string]context.CancelFunc
+}
+
+func NewWatcher(config *mcpbridge.RegistryConfig, cache memory.Cache, keepStaleWhenEmpty bool) (registry.Watcher, error) {
+	if config.Domain == "" {
+		return nil, errors.New("consul registry requires domain")
+	}
+
+	hostSuffix := suffix
+	if config.ConsulNamespace != "" {
+		hostSuffix = strings.ToLower(config.ConsulNamespace) + "." + suffix
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &watcher{
+		client: newClient(net.JoinHostPort(config.Domain, strconv.Itoa(int(config.Port))),
+			config.ConsulNamespace, DefaultConsulWaitTime),
+		cache:              cache,
+		suffix:             hostSuffix,
+		retryInterval:      DefaultConsulRetryInterval,
+		keepStaleWhenEmpty: keepStaleWhenEmpty,
+		ctx:                ctx,
+		cancel:             cancel,
+		serviceCancels:     make(map[string]context.CancelFunc),
+	}, nil
+}
+
+// Run watches the catalog with blocking query, and starts a blocking query for every service.
+func (w *watcher) Run() {
+	var index uint64
+	for {
+		services, newIndex, err := w.client.catalogServices(w.ctx, index)
+		if w.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			RegistryLog.Errorf("Query consul catalog services fail, err %v", err)
+			w.setHealthy(false)
+			if !w.sleep() {
+				return
+			}
+			continue
+		}
+		w.setHealthy(true)
+
+		if newIndex == index {
+			continue
+		}
+		// The index should be reset if it goes backwards, see consul blocking queries.
+		if newIndex < index {
+			index = 0
+		} else {
+			index = newIndex
+		}
+		w.syncServices(services)
+	}
+}
+
+func (w *watcher) Stop() {
+	w.cancel()
+}
+
+func (w *watcher) IsHealthy() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.healthy
+}
+
+func (w *watcher) GetRegistryType() string {
+	return registry.Consul.String()
+}
+
+func (w *watcher) setHealthy(healthy bool) {
+	w.mutex.Lock()
+	w.healthy = healthy
+	w.mutex.Unlock()
+}
+
+// sleep returns false if the watcher is stopped.
+func (w *watcher) sleep() bool {
+	select {
+	case <-time.After(w.retryInterval):
+		return true
+	case <-w.ctx.Done():
+		return false
+	}
+}
+
+func (w *watcher) syncServices(services map[string][]string) {
+	var changed bool
+
+	w.mutex.Lock()
+	for name := range services {
+		if name == consulService {
+			continue
+		}
+		if _, exist := w.serviceCancels[name]; exist {
+			continue
+		}
+		ctx, cancel := context.WithCancel(w.ctx)
+		w.serviceCancels[name] = cancel
+		go w.watchService(ctx, name)
+	}
+
+	for name, cancel := range w.serviceCancels {
+		if _, exist := services[name]; exist {
+			continue
+		}
+		cancel()
+		delete(w.serviceCancels, name)
+		host := w.generateHost(name)
+		if w.cache.GetServiceEntryWrapper(host) != nil {
+			w.cache.DeleteServiceEntryWrapper(host)
+			changed = true
+		}
+	}
+	w.mutex.Unlock()
+
+	if changed {
+		w.UpdateService()
+	}
+}
+
+func (w *watcher) watchService(ctx context.Context, name string) {
+	host := w.generateHost(name)
+	if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+		RegistryLog.Debugf("Skip consul service %s, invalid host %s", name, host)
+		return
+	}
+
+	var index uint64
+	for {
+		entries, newIndex, err := w.client.healthService(ctx, name, index)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			RegistryLog.Errorf("Query consul service %s fail, err %v", name, err)
+			select {
+			case <-time.After(w.retryInterval):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if newIndex == index {
+			continue
+		}
+		if newIndex < index {
+			index = 0
+		} else {
+			index = newIndex
+		}
+		w.updateService(ctx, host, entries)
+	}
+}
+
+func (w *watcher) updateService(ctx context.Context, host string, entries []*serviceEntry) {
+	w.mutex.Lock()
+	// The service may be removed during the query.
+	if ctx.Err() != nil {
+		w.mutex.Unlock()
+		return
+	}
+
+	var changed bool
+	old := w.cache.GetServiceEntryWrapper(host)
+	se := generateServiceEntry(host, entries)
+	switch {
+	case se == nil && old == nil:
+	case se == nil:
+		if !w.keepStaleWhenEmpty {
+			w.cache.DeleteServiceEntryWrapper(host)
+			changed = true
+		}
+	case old == nil || !proto.Equal(old.ServiceEntry, se):
+		w.cache.UpdateServiceEntryWrapper(host, &memory.ServiceEntryWrapper{
+			ServiceName:  host,
+			ServiceEntry: se,
+			Suffix:       w.suffix,
+			RegistryType: w.GetRegistryType(),
+		})
+		changed = true
+	}
+	w.mutex.Unlock()
+
+	if changed {
+		RegistryLog.Infof("Service %s of consul registry changed", host)
+		w.UpdateService()
+	}
+}
+
+// generateHost returns host in format: service.namespace.consul or service.consul
+func (w *watcher) generateHost(service string) string {
+	return strings.ToLower(service) + "." + w.suffix
+}
+
+// generateServiceEntry maps the passing instances into endpoints, returns nil if there is no one.
+func generateServiceEntry(host string, entries []*serviceEntry) *v1alpha3.ServiceEntry {
+	var valid []*serviceEntry
+	for _, entry := range entries {
+		if getAddress(entry) != "" && entry.Service.Port != 0 {
+			valid = append(valid, entry)
+		}
+	}
+	sort.SliceStable(valid, func(i, j int) bool {
+		if getAddress(valid[i]) != getAddress(valid[j]) {
+			return getAddress(valid[i]) < getAddress(valid[j])
+		}
+		return valid[i].Service.Port < valid[j].Service.Port
+	})
+
+	var endpoints []*v1alpha3.WorkloadEntry
+	var protocol string
+	var port uint32
+	for _, entry := range valid {
+		address := getAddress(entry)
+		if protocol == "" {
+			protocol = registry.ConvertProtocol(entry.Service.Meta[protocolKey])
+			port = entry.Service.Port
+		}
+		weight := uint32(1)
+		if entry.Service.Weights != nil && entry.Service.Weights.Passing > 0 {
+			weight = entry.Service.Weights.Passing
+		}
+		endpoints = append(endpoints, &v1alpha3.WorkloadEntry{
+			Address: address,
+			Ports:   map[string]uint32{protocol: entry.Service.Port},
+			Labels:  registry.FilterLabels(entry.Service.Meta),
+			Weight:  weight,
+		})
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	return &v1alpha3.ServiceEntry{
+		Hosts: []string{host},
+		Ports: []*v1alpha3.Port{{
+			Number:   port,
+			Name:     protocol,
+			Protocol: protocol,
+		}},
+		Location:   v1alpha3.ServiceEntry_MESH_INTERNAL,
+		Resolution: v1alpha3.ServiceEntry_STATIC,
+		Endpoints:  endpoints,
+	}
+}
+
+// getAddress prefers the service address, and falls back to the node address.
+func getAddress(entry *serviceEntry) string {
+	if entry.Service == nil {
+		return ""
+	}
+	if entry.Service.Address != "" {
+		return entry.Service.Address
+	}
+	if entry.Node != nil {
+		return entry.Node.Address
+	}
+	return ""
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"istio.io/api/networking/v1alpha3"
+
+	"github.com/alibaba/higress/ingress/kube/mcpbridge"
+	"github.com/alibaba/higress/registry/memory"
+)
+
+// fakeConsul imitates blocking queries: a request carrying the current index
+// is held until the data changes or the wait time elapses.
+type fakeConsul struct {
+	mutex     sync.Mutex
+	cond      *sync.Cond
+	index     uint64
+	services  map[string][]string
+	instances map[string][]*serviceEntry
+}
+
+func newFakeConsul() *fakeConsul {
+	f := &fakeConsul{
+		index:     1,
+		services:  map[string][]string{},
+		instances: map[string][]*serviceEntry{},
+	}
+	f.cond = sync.NewCond(&f.mutex)
+	return f
+}
+
+func (f *fakeConsul) update(fn func()) {
+	f.mutex.Lock()
+	fn()
+	f.index++
+	f.mutex.Unlock()
+	f.cond.Broadcast()
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if index == f.index {
+		timer := time.AfterFunc(50*time.Millisecond, f.cond.Broadcast)
+		f.cond.Wait()
+		timer.Stop()
+	}
+
+	var out interface{}
+	switch {
+	case r.URL.Path == catalogServicesPath:
+		out = f.services
+	case strings.HasPrefix(r.URL.Path, healthServicePath):
+		if r.URL.Query().Get("passing") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		out = f.instances[strings.TrimPrefix(r.URL.Path, healthServicePath)]
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set(indexHeader, strconv.FormatUint(f.index, 10))
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	fake := newFakeConsul()
+	fake.services = map[string][]string{
+		"consul": {},
+		"web":    {"v1"},
+	}
+	fake.instances["web"] = []*serviceEntry{
+		{
+			Node:    &node{Node: "vm-2", Address: "2.2.2.2"},
+			Service: &service{Service: "web", Port: 80, Meta: map[string]string{"version": "v1"}},
+		},
+		{
+			Node:    &node{Node: "vm-1", Address: "1.1.1.1"},
+			Service: &service{Service: "web", Address: "10.0.0.1", Port: 80, Weights: &weights{Passing: 3, Warning: 1}},
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	cache := memory.NewCache()
+	w, err := NewWatcher(&mcpbridge.RegistryConfig{
+		Type:   "consul",
+		Domain: host,
+		Port:   uint32(portNumber),
+	}, cache, false)
+	if err != nil {
+		t.Fatalf("create watcher fail, err %v", err)
+	}
+	w.(*watcher).retryInterval = 10 * time.Millisecond
+
+	var updated int32
+	w.AppendServiceUpdateHandler(func() {
+		atomic.AddInt32(&updated, 1)
+	})
+	go w.Run()
+	defer w.Stop()
+
+	waitFor(t, func() bool {
+		return cache.GetServiceEntryWrapper("web.consul") != nil
+	})
+	expect := &v1alpha3.ServiceEntry{
+		Hosts:      []string{"web.consul"},
+		Ports:      []*v1alpha3.Port{{Number: 80, Name: "HTTP", Protocol: "HTTP"}},
+		Location:   v1alpha3.ServiceEntry_MESH_INTERNAL,
+		Resolution: v1alpha3.ServiceEntry_STATIC,
+		Endpoints: []*v1alpha3.WorkloadEntry{
+			{Address: "10.0.0.1", Ports: map[string]uint32{"HTTP": 80}, Weight: 3},
+			{Address: "2.2.2.2", Ports: map[string]uint32{"HTTP": 80}, Weight: 1, Labels: map[string]string{"version": "v1"}},
+		},
+	}
+	if actual := cache.GetServiceEntryWrapper("web.consul").ServiceEntry; !reflect.DeepEqual(actual, expect) {
+		t.Fatalf("Should be equal, actual %v", actual)
+	}
+	if !w.IsHealthy() {
+		t.Fatal("should be healthy")
+	}
+
+	// Blocking queries return with the same index, nothing should be pushed.
+	time.Sleep(200 * time.Millisecond)
+	if atomic.LoadInt32(&updated) != 1 {
+		t.Fatalf("should be updated once, but actual is %d", atomic.LoadInt32(&updated))
+	}
+
+	fake.update(func() {
+		fake.services["api"] = []string{}
+		fake.instances["api"] = []*serviceEntry{
+			{
+				Node:    &node{Node: "vm-3", Address: "3.3.3.3"},
+				Service: &service{Service: "api", Port: 9090, Meta: map[string]string{"protocol": "grpc"}},
+			},
+		}
+	})
+	waitFor(t, func() bool {
+		return cache.GetServiceEntryWrapper("api.consul") != nil
+	})
+
+	fake.update(func() {
+		delete(fake.services, "web")
+	})
+	waitFor(t, func() bool {
+		return cache.GetServiceEntryWrapper("web.consul") == nil
+	})
+
+	// No passing instance any more.
+	fake.update(func() {
+		fake.instances["api"] = nil
+	})
+	waitFor(t, func() bool {
+		return len(cache.GetAllServiceEntryWrapper()) == 0
+	})
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package direct
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"istio.io/api/networking/v1alpha3"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/alibaba/higress/ingress/kube/mcpbridge"
+	"github.com/alibaba/higress/registry"
+	"github.com/alibaba/higress/registry/memory"
+)
+
+const defaultWeight = 1
+
+// watcher serves the static or dns registry whose service is fully declared within McpBridge,
+// so there is nothing to watch actually.
+type watcher struct {
+	registry.BaseWatcher
+	registryType string
+	sew          *memory.ServiceEntryWrapper
+	cache        memory.Cache
+	stop         chan struct{}
+	stopOnce     sync.Once
+}
+
+func NewWatcher(config *mcpbridge.RegistryConfig, cache memory.Cache) (registry.Watcher, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("%s registry requires name", config.Type)
+	}
+	if config.Port == 0 {
+		return nil, fmt.Errorf("%s registry requires port", config.Type)
+	}
+
+	host := strings.ToLower(config.Name) + "." + config.Type
+	if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid host %s, %s", host, strings.Join(errs, ","))
+	}
+
+	var se *v1alpha3.ServiceEntry
+	var err error
+	switch registry.ServiceRegistryType(config.Type) {
+	case registry.Static:
+		se, err = generateStaticServiceEntry(host, config.Domain, config.Port)
+	case registry.DNS:
+		se, err = generateDNSServiceEntry(host, config.Domain, config.Port)
+	default:
+		err = fmt.Errorf("unsupported registry type %s", config.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &watcher{
+		registryType: config.Type,
+		sew: &memory.ServiceEntryWrapper{
+			ServiceName:  host,
+			ServiceEntry: se,
+			Suffix:       config.Type,
+			RegistryType: config.Type,
+		},
+		cache: cache,
+		stop:  make(chan struct{}),
+	}, nil
+}
+
+func (w *watcher) Run() {
+	w.cache.UpdateServiceEntryWrapper(w.sew.ServiceName, w.sew)
+	w.UpdateService()
+	<-w.stop
+}
+
+func (w *watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+}
+
+func (w *watcher) IsHealthy() bool {
+	return true
+}
+
+func (w *watcher) GetRegistryType() string {
+	return w.registryType
+}
+
+// generateStaticServiceEntry parses domain in format: ip:port[:weight],ip:port[:weight]
+func generateStaticServiceEntry(host, domain string, port uint32) (*v1alpha3.ServiceEntry, error) {
+	var endpoints []*v1alpha3.WorkloadEntry
+	for _, item := range strings.Split(domain, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		endpoint, err := parseStaticEndpoint(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid static address %s, %v", item, err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("static registry requires at least one address")
+	}
+
+	return &v1alpha3.ServiceEntry{
+		Hosts: []string{host},
+		Ports: []*v1alpha3.Port{{
+			Number:   port,
+			Name:     registry.DefaultProtocol,
+			Protocol: registry.DefaultProtocol,
+		}},
+		Location:   v1alpha3.ServiceEntry_MESH_INTERNAL,
+		Resolution: v1alpha3.ServiceEntry_STATIC,
+		Endpoints:  endpoints,
+	}, nil
+}
+
+func parseStaticEndpoint(item string) (*v1alpha3.WorkloadEntry, error) {
+	weight := uint64(defaultWeight)
+	address, rawPort, err := net.SplitHostPort(item)
+	if err != nil {
+		// Try with weight suffix
+		idx := strings.LastIndex(item, ":")
+		if idx < 0 {
+			return nil, err
+		}
+		if weight, err = strconv.ParseUint(item[idx+1:], 10, 32); err != nil || weight == 0 {
+			return nil, errors.New("invalid weight")
+		}
+		if address, rawPort, err = net.SplitHostPort(item[:idx]); err != nil {
+			return nil, err
+		}
+	}
+	if net.ParseIP(address) == nil {
+		return nil, errors.New("invalid ip")
+	}
+	port, err := strconv.ParseUint(rawPort, 10, 16)
+	if err != nil || port == 0 {
+		return nil, errors.New("invalid port")
+	}
+
+	return &v1alpha3.WorkloadEntry{
+		Address: address,
+		Ports:   map[string]uint32{registry.DefaultProtocol: uint32(port)},
+		Weight:  uint32(weight),
+	}, nil
+}
+
+// generateDNSServiceEntry leaves the periodical resolution of domains to envoy.
+func generateDNSServiceEntry(host, domain string, port uint32) (*v1alpha3.ServiceEntry, error) {
+	var endpoints []*v1alpha3.WorkloadEntry
+	for _, item := range strings.Split(domain, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if errs := validation.IsDNS1123Subdomain(item); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid domain %s, %s", item, strings.Join(errs, ","))
+		}
+		endpoints = append(endpoints, &v1alpha3.WorkloadEntry{
+			Address: item,
+			Ports:   map[string]uint32{registry.DefaultProtocol: port},
+		})
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("dns registry requires at least one domain")
+	}
+
+	return &v1alpha3.ServiceEntry{
+		Hosts: []string{host},
+		Ports: []*v1alpha3.Port{{
+			Number:   port,
+			Name:     registry.DefaultProtocol,
+			Protocol: registry.DefaultProtocol,
+		}},
+		Location:   v1alpha3.ServiceEntry_MESH_EXTERNAL,
+		Resolution: v1alpha3.ServiceEntry_DNS,
+		Endpoints:  endpoints,
+	}, nil
+}
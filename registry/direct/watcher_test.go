@@ -0,0 +1,130 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package direct
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/api/networking/v1alpha3"
+
+	"github.com/alibaba/higress/ingress/kube/mcpbridge"
+	"github.com/alibaba/higress/registry/memory"
+)
+
+func TestNewWatcher(t *testing.T) {
+	inputCases := []struct {
+		input  *mcpbridge.RegistryConfig
+		expect *v1alpha3.ServiceEntry
+	}{
+		{
+			input: &mcpbridge.RegistryConfig{
+				Type:   "static",
+				Name:   "Foo",
+				Domain: "1.1.1.1:80, 2.2.2.2:8080:3,[::1]:80:2",
+				Port:   80,
+			},
+			expect: &v1alpha3.ServiceEntry{
+				Hosts:      []string{"foo.static"},
+				Ports:      []*v1alpha3.Port{{Number: 80, Name: "HTTP", Protocol: "HTTP"}},
+				Location:   v1alpha3.ServiceEntry_MESH_INTERNAL,
+				Resolution: v1alpha3.ServiceEntry_STATIC,
+				Endpoints: []*v1alpha3.WorkloadEntry{
+					{Address: "1.1.1.1", Ports: map[string]uint32{"HTTP": 80}, Weight: 1},
+					{Address: "2.2.2.2", Ports: map[string]uint32{"HTTP": 8080}, Weight: 3},
+					{Address: "::1", Ports: map[string]uint32{"HTTP": 80}, Weight: 2},
+				},
+			},
+		},
+		{
+			input: &mcpbridge.RegistryConfig{
+				Type:   "dns",
+				Name:   "bar",
+				Domain: "bar.example.com,backup.example.com",
+				Port:   443,
+			},
+			expect: &v1alpha3.ServiceEntry{
+				Hosts:      []string{"bar.dns"},
+				Ports:      []*v1alpha3.Port{{Number: 443, Name: "HTTP", Protocol: "HTTP"}},
+				Location:   v1alpha3.ServiceEntry_MESH_EXTERNAL,
+				Resolution: v1alpha3.ServiceEntry_DNS,
+				Endpoints: []*v1alpha3.WorkloadEntry{
+					{Address: "bar.example.com", Ports: map[string]uint32{"HTTP": 443}},
+					{Address: "backup.example.com", Ports: map[string]uint32{"HTTP": 443}},
+				},
+			},
+		},
+		{
+			input: &mcpbridge.RegistryConfig{
+				Type:   "static",
+				Domain: "1.1.1.1:80",
+				Port:   80,
+			},
+		},
+		{
+			input: &mcpbridge.RegistryConfig{
+				Type:   "static",
+				Name:   "foo",
+				Domain: "foo.com:80",
+				Port:   80,
+			},
+		},
+		{
+			input: &mcpbridge.RegistryConfig{
+				Type:   "static",
+				Name:   "foo",
+				Domain: "1.1.1.1:80:0",
+				Port:   80,
+			},
+		},
+		{
+			input: &mcpbridge.RegistryConfig{
+				Type:   "dns",
+				Name:   "foo",
+				Domain: "foo_bar.com",
+				Port:   80,
+			},
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			cache := memory.NewCache()
+			w, err := NewWatcher(inputCase.input, cache)
+			if inputCase.expect == nil {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err %v", err)
+			}
+
+			updated := make(chan struct{}, 1)
+			w.AppendServiceUpdateHandler(func() {
+				updated <- struct{}{}
+			})
+			go w.Run()
+			defer w.Stop()
+			<-updated
+
+			all := cache.GetAllServiceEntryWrapper()
+			if len(all) != 1 || !reflect.DeepEqual(all[0].ServiceEntry, inputCase.expect) {
+				t.Fatalf("Should be equal, actual %v", all)
+			}
+		})
+	}
+}
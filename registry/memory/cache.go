@@ -0,0 +1,115 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"istio.io/api/networking/v1alpha3"
+)
+
+type ServiceEntryWrapper struct {
+	ServiceName  string
+	ServiceEntry *v1alpha3.ServiceEntry
+	Suffix       string
+	RegistryType string
+	createTime   time.Time
+}
+
+func (sew *ServiceEntryWrapper) DeepCopy() *ServiceEntryWrapper {
+	return &ServiceEntryWrapper{
+		ServiceName:  sew.ServiceName,
+		ServiceEntry: sew.ServiceEntry.DeepCopy(),
+		Suffix:       sew.Suffix,
+		RegistryType: sew.RegistryType,
+		createTime:   sew.createTime,
+	}
+}
+
+func (sew *ServiceEntryWrapper) SetCreateTime(createTime time.Time) {
+	sew.createTime = createTime
+}
+
+func (sew *ServiceEntryWrapper) GetCreateTime() time.Time {
+	return sew.createTime
+}
+
+// Cache holds the service entries discovered from one registry, host as key.
+type Cache interface {
+	UpdateServiceEntryWrapper(service string, data *ServiceEntryWrapper)
+	DeleteServiceEntryWrapper(service string)
+	GetServiceEntryWrapper(service string) *ServiceEntryWrapper
+	GetAllServiceEntryWrapper() []*ServiceEntryWrapper
+}
+
+func NewCache() Cache {
+	return &store{
+		sew: make(map[string]*ServiceEntryWrapper),
+	}
+}
+
+type store struct {
+	mux sync.RWMutex
+	sew map[string]*ServiceEntryWrapper
+}
+
+// UpdateServiceEntryWrapper keeps the create time of existing service entry, so that
+// the converted config stays stable across updates.
+func (s *store) UpdateServiceEntryWrapper(service string, data *ServiceEntryWrapper) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if old, exist := s.sew[service]; exist {
+		data.SetCreateTime(old.GetCreateTime())
+	} else if data.GetCreateTime().IsZero() {
+		data.SetCreateTime(time.Now())
+	}
+
+	s.sew[service] = data
+}
+
+func (s *store) DeleteServiceEntryWrapper(service string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.sew, service)
+}
+
+func (s *store) GetServiceEntryWrapper(service string) *ServiceEntryWrapper {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	if sew, exist := s.sew[service]; exist {
+		return sew.DeepCopy()
+	}
+	return nil
+}
+
+// GetAllServiceEntryWrapper returns copies sorted by service name.
+func (s *store) GetAllServiceEntryWrapper() []*ServiceEntryWrapper {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	out := make([]*ServiceEntryWrapper, 0, len(s.sew))
+	for _, sew := range s.sew {
+		out = append(out, sew.DeepCopy())
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ServiceName < out[j].ServiceName
+	})
+	return out
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/api/networking/v1alpha3"
+)
+
+func TestUpdateServiceEntryWrapper(t *testing.T) {
+	cache := NewCache()
+	createTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	first := &ServiceEntryWrapper{
+		ServiceName: "foo.nacos",
+		ServiceEntry: &v1alpha3.ServiceEntry{
+			Hosts: []string{"foo.nacos"},
+		},
+	}
+	first.SetCreateTime(createTime)
+	cache.UpdateServiceEntryWrapper("foo.nacos", first)
+
+	second := &ServiceEntryWrapper{
+		ServiceName: "foo.nacos",
+		ServiceEntry: &v1alpha3.ServiceEntry{
+			Hosts:     []string{"foo.nacos"},
+			Endpoints: []*v1alpha3.WorkloadEntry{{Address: "1.1.1.1"}},
+		},
+	}
+	cache.UpdateServiceEntryWrapper("foo.nacos", second)
+
+	got := cache.GetServiceEntryWrapper("foo.nacos")
+	if got == nil {
+		t.Fatal("should exist")
+	}
+	if !got.GetCreateTime().Equal(createTime) {
+		t.Fatalf("create time should be kept, but actual is %v", got.GetCreateTime())
+	}
+	if !reflect.DeepEqual(got.ServiceEntry, second.ServiceEntry) {
+		t.Fatalf("Should be equal")
+	}
+
+	cache.DeleteServiceEntryWrapper("foo.nacos")
+	if cache.GetServiceEntryWrapper("foo.nacos") != nil {
+		t.Fatal("should be deleted")
+	}
+}
+
+func TestGetAllServiceEntryWrapper(t *testing.T) {
+	cache := NewCache()
+	for _, name := range []string{"c.nacos", "a.nacos", "b.nacos"} {
+		cache.UpdateServiceEntryWrapper(name, &ServiceEntryWrapper{
+			ServiceName: name,
+			ServiceEntry: &v1alpha3.ServiceEntry{
+				Hosts: []string{name},
+			},
+		})
+	}
+
+	var names []string
+	for _, sew := range cache.GetAllServiceEntryWrapper() {
+		if sew.GetCreateTime().IsZero() {
+			t.Fatal("create time should be set")
+		}
+		names = append(names, sew.ServiceName)
+	}
+	if !reflect.DeepEqual(names, []string{"a.nacos", "b.nacos", "c.nacos"}) {
+		t.Fatalf("Should be sorted, but actual is %v", names)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nacos
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAddressServerPort = "8080"
+	defaultServerPort        = "8848"
+	defaultPageSize          = 500
+
+	serviceListPath   = "/nacos/v1/ns/service/list"
+	instanceListPath  = "/nacos/v1/ns/instance/list"
+	addressServerPath = "/nacos/serverlist"
+)
+
+type serviceList struct {
+	Count int      `json:"count"`
+	Doms  []string `json:"doms"`
+}
+
+type instanceList struct {
+	Name  string      `json:"name"`
+	Hosts []*instance `json:"hosts"`
+}
+
+type instance struct {
+	InstanceId  string            `json:"instanceId"`
+	Ip          string            `json:"ip"`
+	Port        uint32            `json:"port"`
+	Weight      float64           `json:"weight"`
+	Healthy     bool              `json:"healthy"`
+	Enabled     bool              `json:"enabled"`
+	ClusterName string            `json:"clusterName"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+// client talks to nacos server with the open api over http.
+type client struct {
+	httpClient    *http.Client
+	addressServer string
+	namespaceId   string
+	accessKey     string
+	secretKey     string
+
+	mutex   sync.Mutex
+	servers []string
+	current int
+}
+
+func newClient(addressServer string, servers []string, namespaceId, accessKey, secretKey string, timeout time.Duration) *client {
+	return &client{
+		httpClient:    &http.Client{Timeout: timeout},
+		addressServer: addressServer,
+		namespaceId:   namespaceId,
+		accessKey:     accessKey,
+		secretKey:     secretKey,
+		servers:       servers,
+	}
+}
+
+// refreshServers fetches the server list from address server if configured.
+func (c *client) refreshServers() error {
+	if c.addressServer == "" {
+		return nil
+	}
+
+	addr := c.addressServer
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, defaultAddressServerPort)
+	}
+	resp, err := c.httpClient.Get("http://" + addr + addressServerPath)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("address server %s responses status code %d", addr, resp.StatusCode)
+	}
+
+	var servers []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		server := strings.TrimSpace(scanner.Text())
+		if server == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, defaultServerPort)
+		}
+		servers = append(servers, server)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		return fmt.Errorf("address server %s returns empty server list", addr)
+	}
+
+	c.mutex.Lock()
+	c.servers = servers
+	c.current = 0
+	c.mutex.Unlock()
+	return nil
+}
+
+func (c *client) listServices(group string) ([]string, error) {
+	var services []string
+	for pageNo := 1; ; pageNo++ {
+		params := url.Values{}
+		params.Set("pageNo", strconv.Itoa(pageNo))
+		params.Set("pageSize", strconv.Itoa(defaultPageSize))
+		params.Set("groupName", group)
+
+		list := &serviceList{}
+		if err := c.get(serviceListPath, params, list); err != nil {
+			return nil, err
+		}
+		services = append(services, list.Doms...)
+		if len(list.Doms) < defaultPageSize || len(services) >= list.Count {
+			return services, nil
+		}
+	}
+}
+
+func (c *client) listInstances(service, group string) ([]*instance, error) {
+	params := url.Values{}
+	params.Set("serviceName", service)
+	params.Set("groupName", group)
+	params.Set("healthyOnly", "false")
+
+	list := &instanceList{}
+	if err := c.get(instanceListPath, params, list); err != nil {
+		return nil, err
+	}
+	return list.Hosts, nil
+}
+
+// get tries all the servers in turn until one of them succeeds.
+func (c *client) get(path string, params url.Values, out interface{}) error {
+	if c.namespaceId != "" {
+		params.Set("namespaceId", c.namespaceId)
+	}
+	c.sign(params)
+
+	c.mutex.Lock()
+	servers := c.servers
+	current := c.current
+	c.mutex.Unlock()
+	if len(servers) == 0 {
+		return fmt.Errorf("no available nacos server")
+	}
+
+	var lastErr error
+	for i := 0; i < len(servers); i++ {
+		idx := (current + i) % len(servers)
+		if lastErr = c.doGet(servers[idx], path, params, out); lastErr == nil {
+			c.mutex.Lock()
+			c.current = idx
+			c.mutex.Unlock()
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func (c *client) doGet(server, path string, params url.Values, out interface{}) error {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     server,
+		Path:     path,
+		RawQuery: params.Encode(),
+	}
+	resp, err := c.httpClient.Get(u.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("nacos server %s responses status code %d, body %s", server, resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// sign injects the spas signature which is the same as what nacos sdk does.
+func (c *client) sign(params url.Values) {
+	if c.accessKey == "" || c.secretKey == "" {
+		return
+	}
+
+	signData := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+	if service := params.Get("serviceName"); service != "" {
+		if group := params.Get("groupName"); group != "" && !strings.Contains(service, "@@") {
+			signData = signData + "@@" + group + "@@" + service
+		} else {
+			signData = signData + "@@" + service
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(c.secretKey))
+	mac.Write([]byte(signData))
+	params.Set("ak", c.accessKey)
+	params.Set("data", signData)
+	params.Set("signature", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
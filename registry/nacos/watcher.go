@@ -0,0 +1,269 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nacos
+
+import (
+	"errors"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"istio.io/api/networking/v1alpha3"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/alibaba/higress/ingress/kube/mcpbridge"
+	. "github.com/alibaba/higress/ingress/log"
+	"github.com/alibaba/higress/registry"
+	"github.com/alibaba/higress/registry/memory"
+)
+
+const (
+	DefaultNacosGroup           = "DEFAULT_GROUP"
+	DefaultNacosNamespace       = "public"
+	DefaultNacosRefreshInterval = 30 * time.Second
+	DefaultNacosTimeout         = 5 * time.Second
+
+	suffix = "nacos"
+
+	protocolKey = "protocol"
+)
+
+type watcher struct {
+	registry.BaseWatcher
+	client             *client
+	cache              memory.Cache
+	groups             []string
+	suffix             string
+	refreshInterval    time.Duration
+	keepStaleWhenEmpty bool
+
+	mutex    sync.RWMutex
+	healthy  bool
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewWatcher(config *mcpbridge.RegistryConfig, cache memory.Cache, keepStaleWhenEmpty bool) (registry.Watcher, error) {
+	if config.Domain == "" && config.NacosAddressServer == "" {
+		return nil, errors.New("nacos registry requires domain or address server")
+	}
+
+	var servers []string
+	if config.Domain != "" {
+		servers = append(servers, net.JoinHostPort(config.Domain, strconv.Itoa(int(config.Port))))
+	}
+
+	groups := config.NacosGroups
+	if len(groups) == 0 {
+		groups = []string{DefaultNacosGroup}
+	}
+
+	namespace := config.NacosNamespace
+	if namespace == "" {
+		namespace = DefaultNacosNamespace
+	}
+
+	refreshInterval := time.Duration(config.NacosRefreshInterval)
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultNacosRefreshInterval
+	}
+
+	return &watcher{
+		client: newClient(config.NacosAddressServer, servers, config.NacosNamespaceId,
+			config.NacosAccessKey, config.NacosSecretKey, DefaultNacosTimeout),
+		cache:              cache,
+		groups:             groups,
+		suffix:             strings.Join([]string{normalize(namespace), suffix}, "."),
+		refreshInterval:    refreshInterval,
+		keepStaleWhenEmpty: keepStaleWhenEmpty,
+		stop:               make(chan struct{}),
+	}, nil
+}
+
+func (w *watcher) Run() {
+	ticker := time.NewTicker(w.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		w.refresh()
+		select {
+		case <-ticker.C:
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+}
+
+func (w *watcher) IsHealthy() bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.healthy
+}
+
+func (w *watcher) GetRegistryType() string {
+	return registry.Nacos.String()
+}
+
+func (w *watcher) setHealthy(healthy bool) {
+	w.mutex.Lock()
+	w.healthy = healthy
+	w.mutex.Unlock()
+}
+
+// refresh fetches all the services of configured groups, then syncs them into cache.
+// Nothing is removed from cache if the services can't be listed.
+func (w *watcher) refresh() {
+	if err := w.client.refreshServers(); err != nil {
+		RegistryLog.Errorf("Refresh nacos server list fail, err %v", err)
+	}
+
+	desired := make(map[string]*memory.ServiceEntryWrapper)
+	for _, group := range w.groups {
+		services, err := w.client.listServices(group)
+		if err != nil {
+			RegistryLog.Errorf("List nacos services of group %s fail, err %v", group, err)
+			w.setHealthy(false)
+			return
+		}
+
+		for _, service := range services {
+			host := w.generateHost(service, group)
+			if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+				RegistryLog.Debugf("Skip nacos service %s of group %s, invalid host %s", service, group, host)
+				continue
+			}
+
+			old := w.cache.GetServiceEntryWrapper(host)
+			instances, err := w.client.listInstances(service, group)
+			if err != nil {
+				RegistryLog.Errorf("List instances of nacos service %s of group %s fail, err %v", service, group, err)
+				// Keep the old one until next refresh.
+				if old != nil {
+					desired[host] = old
+				}
+				continue
+			}
+
+			se := generateServiceEntry(host, instances)
+			if se == nil {
+				if w.keepStaleWhenEmpty && old != nil {
+					desired[host] = old
+				}
+				continue
+			}
+
+			desired[host] = &memory.ServiceEntryWrapper{
+				ServiceName:  host,
+				ServiceEntry: se,
+				Suffix:       w.suffix,
+				RegistryType: w.GetRegistryType(),
+			}
+		}
+	}
+	w.setHealthy(true)
+
+	var changed bool
+	for _, sew := range w.cache.GetAllServiceEntryWrapper() {
+		if _, exist := desired[sew.ServiceName]; !exist {
+			w.cache.DeleteServiceEntryWrapper(sew.ServiceName)
+			changed = true
+		}
+	}
+	for host, sew := range desired {
+		old := w.cache.GetServiceEntryWrapper(host)
+		if old != nil && proto.Equal(old.ServiceEntry, sew.ServiceEntry) {
+			continue
+		}
+		w.cache.UpdateServiceEntryWrapper(host, sew)
+		changed = true
+	}
+
+	if changed {
+		RegistryLog.Infof("Services of nacos registry changed, number %d", len(desired))
+		w.UpdateService()
+	}
+}
+
+// generateHost returns host in format: service.group.namespace.nacos
+func (w *watcher) generateHost(service, group string) string {
+	return strings.Join([]string{normalize(service), normalize(group), w.suffix}, ".")
+}
+
+func normalize(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+// generateServiceEntry only keeps the enabled and healthy instances, returns nil if there is no one.
+func generateServiceEntry(host string, instances []*instance) *v1alpha3.ServiceEntry {
+	var endpoints []*v1alpha3.WorkloadEntry
+	var protocol string
+	var port uint32
+	sort.SliceStable(instances, func(i, j int) bool {
+		if instances[i].Ip != instances[j].Ip {
+			return instances[i].Ip < instances[j].Ip
+		}
+		return instances[i].Port < instances[j].Port
+	})
+	for _, ins := range instances {
+		if !ins.Enabled || !ins.Healthy || ins.Weight <= 0 {
+			continue
+		}
+		if protocol == "" {
+			protocol = registry.ConvertProtocol(ins.Metadata[protocolKey])
+			port = ins.Port
+		}
+		endpoints = append(endpoints, &v1alpha3.WorkloadEntry{
+			Address: ins.Ip,
+			Ports:   map[string]uint32{protocol: ins.Port},
+			Labels:  registry.FilterLabels(ins.Metadata),
+			Weight:  convertWeight(ins.Weight),
+		})
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	return &v1alpha3.ServiceEntry{
+		Hosts: []string{host},
+		Ports: []*v1alpha3.Port{{
+			Number:   port,
+			Name:     protocol,
+			Protocol: protocol,
+		}},
+		Location:   v1alpha3.ServiceEntry_MESH_INTERNAL,
+		Resolution: v1alpha3.ServiceEntry_STATIC,
+		Endpoints:  endpoints,
+	}
+}
+
+// convertWeight rounds the float weight of nacos, an instance with positive weight always gets traffic.
+func convertWeight(weight float64) uint32 {
+	w := uint32(math.Round(weight))
+	if w == 0 {
+		w = 1
+	}
+	return w
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nacos
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"istio.io/api/networking/v1alpha3"
+
+	"github.com/alibaba/higress/ingress/kube/mcpbridge"
+	"github.com/alibaba/higress/registry/memory"
+)
+
+// fakeNacos serves the nacos open api from memory, key of instances is group@@service.
+type fakeNacos struct {
+	mutex     sync.Mutex
+	namespace string
+	services  map[string][]string
+	instances map[string][]*instance
+}
+
+func (f *fakeNacos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	query := r.URL.Query()
+	if query.Get("namespaceId") != f.namespace {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var out interface{}
+	switch r.URL.Path {
+	case serviceListPath:
+		services := f.services[query.Get("groupName")]
+		out = &serviceList{Count: len(services), Doms: services}
+	case instanceListPath:
+		out = &instanceList{
+			Name:  query.Get("groupName") + "@@" + query.Get("serviceName"),
+			Hosts: f.instances[query.Get("groupName")+"@@"+query.Get("serviceName")],
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func newFakeNacosWatcher(t *testing.T, fake *fakeNacos, keepStaleWhenEmpty bool) (*watcher, memory.Cache, func()) {
+	server := httptest.NewServer(fake)
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	cache := memory.NewCache()
+	w, err := NewWatcher(&mcpbridge.RegistryConfig{
+		Type:             "nacos",
+		Domain:           host,
+		Port:             uint32(portNumber),
+		NacosNamespaceId: fake.namespace,
+		NacosNamespace:   "dev",
+		NacosGroups:      []string{"DEFAULT_GROUP", "test"},
+	}, cache, keepStaleWhenEmpty)
+	if err != nil {
+		t.Fatalf("create watcher fail, err %v", err)
+	}
+	return w.(*watcher), cache, server.Close
+}
+
+func TestWatcherRefresh(t *testing.T) {
+	fake := &fakeNacos{
+		namespace: "ns-1",
+		services: map[string][]string{
+			"DEFAULT_GROUP": {"foo"},
+			"test":          {"bar_svc", "providers:com.foo.Bar::"},
+		},
+		instances: map[string][]*instance{
+			"DEFAULT_GROUP@@foo": {
+				{Ip: "2.2.2.2", Port: 8080, Weight: 2, Healthy: true, Enabled: true, Metadata: map[string]string{"version": "v1"}},
+				{Ip: "1.1.1.1", Port: 8080, Weight: 1, Healthy: true, Enabled: true},
+				{Ip: "3.3.3.3", Port: 8080, Weight: 1, Healthy: false, Enabled: true},
+			},
+			"test@@bar_svc": {
+				{Ip: "4.4.4.4", Port: 9090, Weight: 0.5, Healthy: true, Enabled: true, Metadata: map[string]string{"protocol": "grpc"}},
+			},
+		},
+	}
+	w, cache, closeServer := newFakeNacosWatcher(t, fake, false)
+	defer closeServer()
+
+	updated := 0
+	w.AppendServiceUpdateHandler(func() {
+		updated++
+	})
+
+	w.refresh()
+	if !w.IsHealthy() {
+		t.Fatal("should be healthy")
+	}
+	if updated != 1 {
+		t.Fatalf("should be updated once, but actual is %d", updated)
+	}
+
+	foo := cache.GetServiceEntryWrapper("foo.default-group.dev.nacos")
+	if foo == nil {
+		t.Fatal("service foo should exist")
+	}
+	expectFoo := &v1alpha3.ServiceEntry{
+		Hosts:      []string{"foo.default-group.dev.nacos"},
+		Ports:      []*v1alpha3.Port{{Number: 8080, Name: "HTTP", Protocol: "HTTP"}},
+		Location:   v1alpha3.ServiceEntry_MESH_INTERNAL,
+		Resolution: v1alpha3.ServiceEntry_STATIC,
+		Endpoints: []*v1alpha3.WorkloadEntry{
+			{Address: "1.1.1.1", Ports: map[string]uint32{"HTTP": 8080}, Weight: 1},
+			{Address: "2.2.2.2", Ports: map[string]uint32{"HTTP": 8080}, Weight: 2, Labels: map[string]string{"version": "v1"}},
+		},
+	}
+	if !reflect.DeepEqual(foo.ServiceEntry, expectFoo) {
+		t.Fatalf("Should be equal, actual %v", foo.ServiceEntry)
+	}
+
+	bar := cache.GetServiceEntryWrapper("bar-svc.test.dev.nacos")
+	if bar == nil {
+		t.Fatal("service bar_svc should exist")
+	}
+	expectBar := &v1alpha3.ServiceEntry{
+		Hosts:      []string{"bar-svc.test.dev.nacos"},
+		Ports:      []*v1alpha3.Port{{Number: 9090, Name: "GRPC", Protocol: "GRPC"}},
+		Location:   v1alpha3.ServiceEntry_MESH_INTERNAL,
+		Resolution: v1alpha3.ServiceEntry_STATIC,
+		Endpoints: []*v1alpha3.WorkloadEntry{
+			{Address: "4.4.4.4", Ports: map[string]uint32{"GRPC": 9090}, Weight: 1, Labels: map[string]string{"protocol": "grpc"}},
+		},
+	}
+	if !reflect.DeepEqual(bar.ServiceEntry, expectBar) {
+		t.Fatalf("Should be equal, actual %v", bar.ServiceEntry)
+	}
+
+	if len(cache.GetAllServiceEntryWrapper()) != 2 {
+		t.Fatal("invalid service name should be skipped")
+	}
+
+	// Nothing changed, no push.
+	w.refresh()
+	if updated != 1 {
+		t.Fatalf("should not be updated, but actual is %d", updated)
+	}
+
+	// All instances of bar_svc are down.
+	fake.mutex.Lock()
+	fake.instances["test@@bar_svc"][0].Healthy = false
+	fake.mutex.Unlock()
+	w.refresh()
+	if updated != 2 {
+		t.Fatalf("should be updated twice, but actual is %d", updated)
+	}
+	if cache.GetServiceEntryWrapper("bar-svc.test.dev.nacos") != nil {
+		t.Fatal("service bar_svc should be deleted")
+	}
+}
+
+func TestWatcherKeepStaleWhenEmpty(t *testing.T) {
+	fake := &fakeNacos{
+		services: map[string][]string{
+			"DEFAULT_GROUP": {"foo"},
+		},
+		instances: map[string][]*instance{
+			"DEFAULT_GROUP@@foo": {
+				{Ip: "1.1.1.1", Port: 8080, Weight: 1, Healthy: true, Enabled: true},
+			},
+		},
+	}
+	w, cache, closeServer := newFakeNacosWatcher(t, fake, true)
+	defer closeServer()
+
+	w.refresh()
+	fake.mutex.Lock()
+	fake.instances["DEFAULT_GROUP@@foo"][0].Enabled = false
+	fake.mutex.Unlock()
+	w.refresh()
+
+	foo := cache.GetServiceEntryWrapper("foo.default-group.dev.nacos")
+	if foo == nil || len(foo.ServiceEntry.Endpoints) != 1 {
+		t.Fatal("stale service foo should be kept")
+	}
+
+	// Registry is unreachable, services should be kept.
+	closeServer()
+	w.refresh()
+	if w.IsHealthy() {
+		t.Fatal("should be unhealthy")
+	}
+	if cache.GetServiceEntryWrapper("foo.default-group.dev.nacos") == nil {
+		t.Fatal("service foo should be kept")
+	}
+}
+
+func TestSign(t *testing.T) {
+	c := newClient("", nil, "", "ak", "sk", DefaultNacosTimeout)
+	params := map[string][]string{
+		"serviceName": {"foo"},
+		"groupName":   {"DEFAULT_GROUP"},
+	}
+	c.sign(params)
+	if params["ak"][0] != "ak" || params["signature"][0] == "" {
+		t.Fatalf("should be signed, actual %v", params)
+	}
+}
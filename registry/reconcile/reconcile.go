@@ -0,0 +1,166 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/alibaba/higress/ingress/kube/mcpbridge"
+	. "github.com/alibaba/higress/ingress/log"
+	"github.com/alibaba/higress/registry"
+	"github.com/alibaba/higress/registry/consul"
+	"github.com/alibaba/higress/registry/direct"
+	"github.com/alibaba/higress/registry/memory"
+	"github.com/alibaba/higress/registry/nacos"
+	"github.com/alibaba/higress/registry/zookeeper"
+)
+
+type watcherEntry struct {
+	config  *mcpbridge.RegistryConfig
+	watcher registry.Watcher
+	cache   memory.Cache
+}
+
+// Reconciler runs a watcher for every registry declared in McpBridge objects.
+type Reconciler struct {
+	mutex         sync.RWMutex
+	watchers      map[string]*watcherEntry
+	serviceUpdate func()
+	// keepStaleWhenEmpty keeps the last known endpoints of a service once all of them are gone.
+	keepStaleWhenEmpty bool
+}
+
+func NewReconciler(serviceUpdate func(), keepStaleWhenEmpty bool) *Reconciler {
+	return &Reconciler{
+		watchers:           make(map[string]*watcherEntry),
+		serviceUpdate:      serviceUpdate,
+		keepStaleWhenEmpty: keepStaleWhenEmpty,
+	}
+}
+
+// Reconcile starts watchers for the new or changed registries and stops the ones no longer declared.
+func (r *Reconciler) Reconcile(bridges []*mcpbridge.McpBridge) {
+	toBe := make(map[string]*mcpbridge.RegistryConfig)
+	for _, bridge := range bridges {
+		if bridge == nil {
+			continue
+		}
+		for _, registryConfig := range bridge.Spec.Registries {
+			if registryConfig == nil {
+				continue
+			}
+			key := registryConfig.Key()
+			if _, exist := toBe[key]; exist {
+				RegistryLog.Warnf("Registry %s is declared more than once within mcpbridge, ignore the duplicated one", key)
+				continue
+			}
+			toBe[key] = registryConfig
+		}
+	}
+
+	r.mutex.Lock()
+	var changed bool
+	for key, entry := range r.watchers {
+		if config, exist := toBe[key]; exist && reflect.DeepEqual(config, entry.config) {
+			continue
+		}
+		RegistryLog.Infof("Stop watcher of registry %s", key)
+		entry.watcher.Stop()
+		delete(r.watchers, key)
+		changed = true
+	}
+
+	for key, config := range toBe {
+		if _, exist := r.watchers[key]; exist {
+			continue
+		}
+		cache := memory.NewCache()
+		watcher, err := r.generateWatcherFromRegistryConfig(config, cache)
+		if err != nil {
+			RegistryLog.Errorf("Create watcher of registry %s fail, err %v", key, err)
+			continue
+		}
+		watcher.AppendServiceUpdateHandler(r.serviceUpdate)
+		r.watchers[key] = &watcherEntry{
+			config:  config,
+			watcher: watcher,
+			cache:   cache,
+		}
+		RegistryLog.Infof("Start watcher of registry %s", key)
+		go watcher.Run()
+	}
+	r.mutex.Unlock()
+
+	// Services of the stopped watchers are gone.
+	if changed && r.serviceUpdate != nil {
+		r.serviceUpdate()
+	}
+}
+
+// GetAllServiceEntryWrapper aggregates services of all registries. When a host is discovered by
+// more than one registry, the registry with the smaller key wins.
+func (r *Reconciler) GetAllServiceEntryWrapper() []*memory.ServiceEntryWrapper {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	keys := make([]string, 0, len(r.watchers))
+	for key := range r.watchers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hosts := make(map[string]struct{})
+	var out []*memory.ServiceEntryWrapper
+	for _, key := range keys {
+		for _, sew := range r.watchers[key].cache.GetAllServiceEntryWrapper() {
+			if _, exist := hosts[sew.ServiceName]; exist {
+				RegistryLog.Warnf("Service %s of registry %s conflicts with other registry, ignore it", sew.ServiceName, key)
+				continue
+			}
+			hosts[sew.ServiceName] = struct{}{}
+			out = append(out, sew)
+		}
+	}
+	return out
+}
+
+// Stop stops all the running watchers.
+func (r *Reconciler) Stop() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, entry := range r.watchers {
+		entry.watcher.Stop()
+		delete(r.watchers, key)
+	}
+}
+
+func (r *Reconciler) generateWatcherFromRegistryConfig(config *mcpbridge.RegistryConfig, cache memory.Cache) (registry.Watcher, error) {
+	switch registry.ServiceRegistryType(config.Type) {
+	case registry.Nacos:
+		return nacos.NewWatcher(config, cache, r.keepStaleWhenEmpty)
+	case registry.Consul:
+		return consul.NewWatcher(config, cache, r.keepStaleWhenEmpty)
+	case registry.Zookeeper:
+		return zookeeper.NewWatcher(config, cache, r.keepStaleWhenEmpty)
+	case registry.Static, registry.DNS:
+		return direct.NewWatcher(config, cache)
+	default:
+		return nil, fmt.Errorf("unsupported registry type %s", config.Type)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alibaba/higress/ingress/kube/mcpbridge"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	var updated int32
+	r := NewReconciler(func() {
+		atomic.AddInt32(&updated, 1)
+	}, false)
+	defer r.Stop()
+
+	bridge := &mcpbridge.McpBridge{
+		Spec: mcpbridge.McpBridgeSpec{
+			Registries: []*mcpbridge.RegistryConfig{
+				{Type: "static", Name: "foo", Domain: "1.1.1.1:80", Port: 80},
+				{Type: "dns", Name: "bar", Domain: "bar.example.com", Port: 80},
+				{Type: "unknown", Name: "invalid", Domain: "1.1.1.1", Port: 80},
+			},
+		},
+	}
+	r.Reconcile([]*mcpbridge.McpBridge{bridge})
+	waitFor(t, func() bool {
+		return len(r.GetAllServiceEntryWrapper()) == 2
+	})
+	if atomic.LoadInt32(&updated) != 2 {
+		t.Fatalf("should be updated twice, actual %d", atomic.LoadInt32(&updated))
+	}
+
+	// Unchanged registries are kept as they are.
+	r.Reconcile([]*mcpbridge.McpBridge{bridge})
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&updated) != 2 {
+		t.Fatalf("should not be updated, actual %d", atomic.LoadInt32(&updated))
+	}
+
+	// Changed registry is restarted and the removed one is gone.
+	r.Reconcile([]*mcpbridge.McpBridge{{
+		Spec: mcpbridge.McpBridgeSpec{
+			Registries: []*mcpbridge.RegistryConfig{
+				{Type: "static", Name: "foo", Domain: "1.1.1.1:80,2.2.2.2:80", Port: 80},
+			},
+		},
+	}})
+	waitFor(t, func() bool {
+		all := r.GetAllServiceEntryWrapper()
+		return len(all) == 1 && all[0].ServiceName == "foo.static" && len(all[0].ServiceEntry.Endpoints) == 2
+	})
+
+	r.Reconcile(nil)
+	if len(r.GetAllServiceEntryWrapper()) != 0 {
+		t.Fatal("all services should be removed")
+	}
+}
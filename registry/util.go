@@ -0,0 +1,59 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const DefaultProtocol = "HTTP"
+
+var supportedProtocols = map[string]string{
+	"http":   "HTTP",
+	"https":  "HTTPS",
+	"http2":  "HTTP2",
+	"grpc":   "GRPC",
+	"tcp":    "TCP",
+	"tls":    "TLS",
+	"dubbo":  "TCP",
+	"tri":    "GRPC",
+	"triple": "GRPC",
+	"rest":   "HTTP",
+}
+
+// ConvertProtocol converts the protocol declared in registry into istio protocol, default is HTTP.
+func ConvertProtocol(protocol string) string {
+	if p, exist := supportedProtocols[strings.ToLower(protocol)]; exist {
+		return p
+	}
+	return DefaultProtocol
+}
+
+// FilterLabels drops the metadata which is not a valid kubernetes label.
+func FilterLabels(metadata map[string]string) map[string]string {
+	var labels map[string]string
+	for key, value := range metadata {
+		if len(validation.IsQualifiedName(key)) > 0 || len(validation.IsValidLabelValue(value)) > 0 {
+			continue
+		}
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[key] = value
+	}
+	return labels
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+type ServiceRegistryType string
+
+const (
+	Nacos     ServiceRegistryType = "nacos"
+	Consul    ServiceRegistryType = "consul"
+	Zookeeper ServiceRegistryType = "zookeeper"
+	Static    ServiceRegistryType = "static"
+	DNS       ServiceRegistryType = "dns"
+)
+
+func (srt ServiceRegistryType) String() string {
+	return string(srt)
+}
+
+type ServiceUpdateHandler func()
+
+// Watcher discovers services from one registry and keeps them in its cache.
+type Watcher interface {
+	// Run blocks until Stop is called.
+	Run()
+
+	Stop()
+
+	IsHealthy() bool
+
+	GetRegistryType() string
+
+	// AppendServiceUpdateHandler adds a handler which is called once the services of registry change.
+	AppendServiceUpdateHandler(f ServiceUpdateHandler)
+}
+
+type BaseWatcher struct {
+	handlers []ServiceUpdateHandler
+}
+
+func (w *BaseWatcher) Run()                    {}
+func (w *BaseWatcher) Stop()                   {}
+func (w *BaseWatcher) IsHealthy() bool         { return true }
+func (w *BaseWatcher) GetRegistryType() string { return "" }
+
+func (w *BaseWatcher) AppendServiceUpdateHandler(f ServiceUpdateHandler) {
+	w.handlers = append(w.handlers, f)
+}
+
+// UpdateService notifies all the handlers.
+func (w *BaseWatcher) UpdateService() {
+	for _, f := range w.handlers {
+		f()
+	}
+}
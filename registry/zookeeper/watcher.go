@@ -0,0 +1,480 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeper
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/gogo/protobuf/proto"
+	"istio.io/api/networking/v1alpha3"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/alibaba/higress/ingress/kube/mcpbridge"
+	. "github.com/alibaba/higress/ingress/log"
+	"github.com/alibaba/higress/registry"
+	"github.com/alibaba/higress/registry/memory"
+)
+
+const (
+	DefaultServicesPath       = "/dubbo"
+	DefaultSessionTimeout     = 30 * time.Second
+	DefaultZookeeperRetryTime = 5 * time.Second
+
+	suffix = "zookeeper"
+
+	providersNode = "providers"
+
+	defaultDubboWeight = 100
+)
+
+// Labels decoded from the parameters of dubbo provider url.
+var labelParams = []string{"application", "group", "version", "side", "release"}
+
+// conn is the subset of zk.Conn used by watcher, so that it can be replaced in tests.
+type conn interface {
+	ChildrenW(path string) ([]string, *zk.Stat, <-chan zk.Event, error)
+	Close()
+}
+
+type connectFunc func(servers []string, sessionTimeout time.Duration) (conn, <-chan zk.Event, error)
+
+func connect(servers []string, sessionTimeout time.Duration) (conn, <-chan zk.Event, error) {
+	return zk.Connect(servers, sessionTimeout, zk.WithLogger(zkLogger{}))
+}
+
+type zkLogger struct{}
+
+func (zkLogger) Printf(format string, args ...interface{}) {
+	RegistryLog.Debugf(format, args...)
+}
+
+type watcher struct {
+	registry.BaseWatcher
+	servers            []string
+	servicesPaths      []string
+	cache              memory.Cache
+	connect            connectFunc
+	sessionTimeout     time.Duration
+	retryInterval      time.Duration
+	keepStaleWhenEmpty bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex   sync.Mutex
+	healthy bool
+	// Hosts discovered under every services path, which survive across sessions.
+	// key: services path
+	pathHosts map[string]map[string]struct{}
+}
+
+func NewWatcher(config *mcpbridge.RegistryConfig, cache memory.Cache, keepStaleWhenEmpty bool) (registry.Watcher, error) {
+	if config.Domain == "" {
+		return nil, errors.New("zookeeper registry requires domain")
+	}
+
+	var servers []string
+	for _, domain := range strings.Split(config.Domain, ",") {
+		domain = strings.TrimSpace(domain)
+		if domain == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(domain); err != nil {
+			domain = net.JoinHostPort(domain, strconv.Itoa(int(config.Port)))
+		}
+		servers = append(servers, domain)
+	}
+
+	servicesPaths := config.ZkServicesPath
+	if len(servicesPaths) == 0 {
+		servicesPaths = []string{DefaultServicesPath}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &watcher{
+		servers:            servers,
+		servicesPaths:      servicesPaths,
+		cache:              cache,
+		connect:            connect,
+		sessionTimeout:     DefaultSessionTimeout,
+		retryInterval:      DefaultZookeeperRetryTime,
+		keepStaleWhenEmpty: keepStaleWhenEmpty,
+		ctx:                ctx,
+		cancel:             cancel,
+		pathHosts:          make(map[string]map[string]struct{}),
+	}, nil
+}
+
+// Run keeps a session with zookeeper, and establishes a new one once the session expires,
+// since all the watches are gone along with the session.
+func (w *watcher) Run() {
+	for {
+		c, events, err := w.connect(w.servers, w.sessionTimeout)
+		if err != nil {
+			RegistryLog.Errorf("Connect to zookeeper %v fail, err %v", w.servers, err)
+			w.setHealthy(false)
+			if !w.sleep(w.ctx) {
+				return
+			}
+			continue
+		}
+
+		w.runSession(c, events)
+		c.Close()
+		if w.ctx.Err() != nil {
+			return
+		}
+		RegistryLog.Warnf("Session of zookeeper %v expired, reconnect", w.servers)
+	}
+}
+
+func (w *watcher) Stop() {
+	w.cancel()
+}
+
+func (w *watcher) IsHealthy() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.healthy
+}
+
+func (w *watcher) GetRegistryType() string {
+	return registry.Zookeeper.String()
+}
+
+func (w *watcher) setHealthy(healthy bool) {
+	w.mutex.Lock()
+	w.healthy = healthy
+	w.mutex.Unlock()
+}
+
+// sleep returns false if the context is done.
+func (w *watcher) sleep(ctx context.Context) bool {
+	select {
+	case <-time.After(w.retryInterval):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// runSession returns when the session expires or the watcher is stopped.
+func (w *watcher) runSession(c conn, events <-chan zk.Event) {
+	ctx, cancel := context.WithCancel(w.ctx)
+	defer cancel()
+
+	s := &session{
+		watcher:    w,
+		conn:       c,
+		ctx:        ctx,
+		interfaces: make(map[string]context.CancelFunc),
+	}
+	for _, servicesPath := range w.servicesPaths {
+		go s.watchServicesPath(servicesPath)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			switch event.State {
+			case zk.StateHasSession:
+				w.setHealthy(true)
+			case zk.StateDisconnected:
+				w.setHealthy(false)
+			case zk.StateExpired:
+				w.setHealthy(false)
+				return
+			}
+		}
+	}
+}
+
+type session struct {
+	*watcher
+	conn conn
+	ctx  context.Context
+
+	mutex sync.Mutex
+	// key: interface path
+	interfaces map[string]context.CancelFunc
+}
+
+func (s *session) watchServicesPath(servicesPath string) {
+	for {
+		children, _, ch, err := s.conn.ChildrenW(servicesPath)
+		if s.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			RegistryLog.Errorf("Watch zookeeper path %s fail, err %v", servicesPath, err)
+			if !s.sleep(s.ctx) {
+				return
+			}
+			continue
+		}
+
+		s.syncInterfaces(servicesPath, children)
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ch:
+		}
+	}
+}
+
+func (s *session) syncInterfaces(servicesPath string, interfaces []string) {
+	desired := make(map[string]struct{})
+
+	s.mutex.Lock()
+	for _, iface := range interfaces {
+		host := generateHost(iface)
+		if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+			RegistryLog.Debugf("Skip zookeeper interface %s, invalid host %s", iface, host)
+			continue
+		}
+		desired[host] = struct{}{}
+
+		interfacePath := path.Join(servicesPath, iface)
+		if _, exist := s.interfaces[interfacePath]; exist {
+			continue
+		}
+		ctx, cancel := context.WithCancel(s.ctx)
+		s.interfaces[interfacePath] = cancel
+		go s.watchProviders(ctx, interfacePath, host)
+	}
+	for interfacePath, cancel := range s.interfaces {
+		if path.Dir(interfacePath) != servicesPath {
+			continue
+		}
+		if _, exist := desired[generateHost(path.Base(interfacePath))]; !exist {
+			cancel()
+			delete(s.interfaces, interfacePath)
+		}
+	}
+	s.mutex.Unlock()
+
+	var changed bool
+	s.watcher.mutex.Lock()
+	for host := range s.pathHosts[servicesPath] {
+		if _, exist := desired[host]; !exist && s.cache.GetServiceEntryWrapper(host) != nil {
+			s.cache.DeleteServiceEntryWrapper(host)
+			changed = true
+		}
+	}
+	s.pathHosts[servicesPath] = desired
+	s.watcher.mutex.Unlock()
+
+	if changed {
+		s.UpdateService()
+	}
+}
+
+func (s *session) watchProviders(ctx context.Context, interfacePath, host string) {
+	providersPath := path.Join(interfacePath, providersNode)
+	for {
+		providers, _, ch, err := s.conn.ChildrenW(providersPath)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			if err != zk.ErrNoNode {
+				RegistryLog.Errorf("Watch zookeeper path %s fail, err %v", providersPath, err)
+			}
+			if !s.sleep(ctx) {
+				return
+			}
+			continue
+		}
+
+		s.updateService(ctx, host, providers)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+		}
+	}
+}
+
+func (s *session) updateService(ctx context.Context, host string, providers []string) {
+	s.watcher.mutex.Lock()
+	// The interface may be removed during the query.
+	if ctx.Err() != nil {
+		s.watcher.mutex.Unlock()
+		return
+	}
+
+	var changed bool
+	old := s.cache.GetServiceEntryWrapper(host)
+	se := generateServiceEntry(host, providers)
+	switch {
+	case se == nil && old == nil:
+	case se == nil:
+		if !s.keepStaleWhenEmpty {
+			s.cache.DeleteServiceEntryWrapper(host)
+			changed = true
+		}
+	case old == nil || !proto.Equal(old.ServiceEntry, se):
+		s.cache.UpdateServiceEntryWrapper(host, &memory.ServiceEntryWrapper{
+			ServiceName:  host,
+			ServiceEntry: se,
+			Suffix:       suffix,
+			RegistryType: s.GetRegistryType(),
+		})
+		changed = true
+	}
+	s.watcher.mutex.Unlock()
+
+	if changed {
+		RegistryLog.Infof("Service %s of zookeeper registry changed", host)
+		s.UpdateService()
+	}
+}
+
+// generateHost returns host in format: interface.zookeeper, e.g. com.foo.demoservice.zookeeper
+func generateHost(iface string) string {
+	return strings.ToLower(iface) + "." + suffix
+}
+
+type provider struct {
+	protocol string
+	address  string
+	port     uint32
+	weight   uint32
+	labels   map[string]string
+}
+
+// parseProvider decodes the dubbo provider url, e.g.
+// dubbo%3A%2F%2F192.168.1.1%3A20880%2Fcom.foo.DemoService%3Fversion%3D1.0.0%26weight%3D100
+func parseProvider(raw string) (*provider, error) {
+	decoded, err := url.QueryUnescape(raw)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(decoded)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Hostname() == "" {
+		return nil, errors.New("missing protocol or host")
+	}
+	port, err := strconv.ParseUint(u.Port(), 10, 32)
+	if err != nil || port == 0 {
+		return nil, errors.New("invalid port")
+	}
+
+	params := u.Query()
+	if params.Get("enabled") == "false" || params.Get("disabled") == "true" {
+		return nil, nil
+	}
+
+	weight := uint32(defaultDubboWeight)
+	if rawWeight := params.Get("weight"); rawWeight != "" {
+		w, err := strconv.ParseUint(rawWeight, 10, 32)
+		if err != nil {
+			return nil, errors.New("invalid weight")
+		}
+		if w == 0 {
+			return nil, nil
+		}
+		weight = uint32(w)
+	}
+
+	metadata := map[string]string{}
+	for _, key := range labelParams {
+		if value := params.Get(key); value != "" {
+			metadata[key] = value
+		}
+	}
+
+	return &provider{
+		protocol: u.Scheme,
+		address:  u.Hostname(),
+		port:     uint32(port),
+		weight:   weight,
+		labels:   registry.FilterLabels(metadata),
+	}, nil
+}
+
+// generateServiceEntry returns nil if there is no available provider. Providers of other
+// protocols than the first one are dropped, since a service entry has only one port here.
+func generateServiceEntry(host string, rawProviders []string) *v1alpha3.ServiceEntry {
+	var providers []*provider
+	for _, raw := range rawProviders {
+		p, err := parseProvider(raw)
+		if err != nil {
+			RegistryLog.Debugf("Skip invalid provider %s of %s, err %v", raw, host, err)
+			continue
+		}
+		if p != nil {
+			providers = append(providers, p)
+		}
+	}
+	if len(providers) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(providers, func(i, j int) bool {
+		if providers[i].address != providers[j].address {
+			return providers[i].address < providers[j].address
+		}
+		return providers[i].port < providers[j].port
+	})
+
+	first := providers[0]
+	protocol := registry.ConvertProtocol(first.protocol)
+	var endpoints []*v1alpha3.WorkloadEntry
+	for _, p := range providers {
+		if p.protocol != first.protocol {
+			RegistryLog.Debugf("Skip provider %s:%d of %s, protocol %s differs from %s",
+				p.address, p.port, host, p.protocol, first.protocol)
+			continue
+		}
+		endpoints = append(endpoints, &v1alpha3.WorkloadEntry{
+			Address: p.address,
+			Ports:   map[string]uint32{protocol: p.port},
+			Labels:  p.labels,
+			Weight:  p.weight,
+		})
+	}
+
+	return &v1alpha3.ServiceEntry{
+		Hosts: []string{host},
+		Ports: []*v1alpha3.Port{{
+			Number:   first.port,
+			Name:     protocol,
+			Protocol: protocol,
+		}},
+		Location:   v1alpha3.ServiceEntry_MESH_INTERNAL,
+		Resolution: v1alpha3.ServiceEntry_STATIC,
+		Endpoints:  endpoints,
+	}
+}
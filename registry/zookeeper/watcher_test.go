@@ -0,0 +1,236 @@
+// Copyright (c) 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeper
+
+import (
+	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"istio.io/api/networking/v1alpha3"
+
+	"github.com/alibaba/higress/ingress/kube/mcpbridge"
+	"github.com/alibaba/higress/registry/memory"
+)
+
+// fakeZookeeper keeps the children of every path in memory and fires the watches on change.
+type fakeZookeeper struct {
+	mutex    sync.Mutex
+	children map[string][]string
+	watches  map[string][]chan zk.Event
+	sessions []chan zk.Event
+}
+
+func newFakeZookeeper() *fakeZookeeper {
+	return &fakeZookeeper{
+		children: map[string][]string{},
+		watches:  map[string][]chan zk.Event{},
+	}
+}
+
+func (f *fakeZookeeper) connect(servers []string, sessionTimeout time.Duration) (conn, <-chan zk.Event, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	events := make(chan zk.Event, 1)
+	events <- zk.Event{Type: zk.EventSession, State: zk.StateHasSession}
+	f.sessions = append(f.sessions, events)
+	return &fakeConn{fake: f}, events, nil
+}
+
+func (f *fakeZookeeper) set(path string, children ...string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.children[path] = children
+	for _, ch := range f.watches[path] {
+		ch <- zk.Event{Type: zk.EventNodeChildrenChanged, Path: path}
+	}
+	delete(f.watches, path)
+}
+
+// expire drops all the watches like what zookeeper does when session expires.
+func (f *fakeZookeeper) expire() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.watches = map[string][]chan zk.Event{}
+	f.sessions[len(f.sessions)-1] <- zk.Event{Type: zk.EventSession, State: zk.StateExpired}
+}
+
+func (f *fakeZookeeper) sessionNumber() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.sessions)
+}
+
+type fakeConn struct {
+	fake *fakeZookeeper
+}
+
+func (c *fakeConn) ChildrenW(path string) ([]string, *zk.Stat, <-chan zk.Event, error) {
+	c.fake.mutex.Lock()
+	defer c.fake.mutex.Unlock()
+	children, exist := c.fake.children[path]
+	if !exist {
+		return nil, nil, nil, zk.ErrNoNode
+	}
+	ch := make(chan zk.Event, 1)
+	c.fake.watches[path] = append(c.fake.watches[path], ch)
+	return append([]string{}, children...), &zk.Stat{}, ch, nil
+}
+
+func (c *fakeConn) Close() {}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestParseProvider(t *testing.T) {
+	inputCases := []struct {
+		input  string
+		expect *provider
+		err    bool
+	}{
+		{
+			input: url.QueryEscape("dubbo://192.168.1.1:20880/com.foo.DemoService?application=demo&interface=com.foo.DemoService&version=1.0.0&group=g1&weight=50&methods=sayHello"),
+			expect: &provider{
+				protocol: "dubbo",
+				address:  "192.168.1.1",
+				port:     20880,
+				weight:   50,
+				labels: map[string]string{
+					"application": "demo",
+					"version":     "1.0.0",
+					"group":       "g1",
+				},
+			},
+		},
+		{
+			input: url.QueryEscape("tri://192.168.1.2:50051/com.foo.DemoService"),
+			expect: &provider{
+				protocol: "tri",
+				address:  "192.168.1.2",
+				port:     50051,
+				weight:   defaultDubboWeight,
+			},
+		},
+		{
+			input: url.QueryEscape("dubbo://192.168.1.1:20880/com.foo.DemoService?enabled=false"),
+		},
+		{
+			input: url.QueryEscape("dubbo://192.168.1.1/com.foo.DemoService"),
+			err:   true,
+		},
+		{
+			input: "%zz",
+			err:   true,
+		},
+	}
+
+	for _, inputCase := range inputCases {
+		t.Run("", func(t *testing.T) {
+			p, err := parseProvider(inputCase.input)
+			if (err != nil) != inputCase.err {
+				t.Fatalf("unexpected err %v", err)
+			}
+			if !reflect.DeepEqual(p, inputCase.expect) {
+				t.Fatalf("Should be equal, actual %v", p)
+			}
+		})
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	fake := newFakeZookeeper()
+	fake.set("/dubbo", "com.foo.DemoService")
+	fake.set("/dubbo/com.foo.DemoService/providers",
+		url.QueryEscape("dubbo://10.0.0.2:20880/com.foo.DemoService?version=1.0.0"),
+		url.QueryEscape("dubbo://10.0.0.1:20880/com.foo.DemoService?version=2.0.0&weight=200"),
+	)
+
+	cache := memory.NewCache()
+	w, err := NewWatcher(&mcpbridge.RegistryConfig{
+		Type:   "zookeeper",
+		Domain: "127.0.0.1",
+		Port:   2181,
+	}, cache, false)
+	if err != nil {
+		t.Fatalf("create watcher fail, err %v", err)
+	}
+	zkWatcher := w.(*watcher)
+	zkWatcher.connect = fake.connect
+	zkWatcher.retryInterval = 10 * time.Millisecond
+
+	var updated int32
+	w.AppendServiceUpdateHandler(func() {
+		atomic.AddInt32(&updated, 1)
+	})
+	go w.Run()
+	defer w.Stop()
+
+	host := "com.foo.demoservice.zookeeper"
+	waitFor(t, func() bool {
+		return cache.GetServiceEntryWrapper(host) != nil
+	})
+	expect := &v1alpha3.ServiceEntry{
+		Hosts:      []string{host},
+		Ports:      []*v1alpha3.Port{{Number: 20880, Name: "TCP", Protocol: "TCP"}},
+		Location:   v1alpha3.ServiceEntry_MESH_INTERNAL,
+		Resolution: v1alpha3.ServiceEntry_STATIC,
+		Endpoints: []*v1alpha3.WorkloadEntry{
+			{Address: "10.0.0.1", Ports: map[string]uint32{"TCP": 20880}, Weight: 200, Labels: map[string]string{"version": "2.0.0"}},
+			{Address: "10.0.0.2", Ports: map[string]uint32{"TCP": 20880}, Weight: 100, Labels: map[string]string{"version": "1.0.0"}},
+		},
+	}
+	if actual := cache.GetServiceEntryWrapper(host).ServiceEntry; !reflect.DeepEqual(actual, expect) {
+		t.Fatalf("Should be equal, actual %v", actual)
+	}
+	waitFor(t, func() bool {
+		return w.IsHealthy()
+	})
+
+	// A provider goes offline.
+	fake.set("/dubbo/com.foo.DemoService/providers",
+		url.QueryEscape("dubbo://10.0.0.2:20880/com.foo.DemoService?version=1.0.0"))
+	waitFor(t, func() bool {
+		sew := cache.GetServiceEntryWrapper(host)
+		return sew != nil && len(sew.ServiceEntry.Endpoints) == 1
+	})
+
+	// Watches are lost along with the expired session, the watcher should reconnect and
+	// pick up the changes made in the meantime.
+	fake.expire()
+	waitFor(t, func() bool {
+		return fake.sessionNumber() == 2
+	})
+	fake.set("/dubbo", "com.foo.OtherService")
+	fake.set("/dubbo/com.foo.OtherService/providers",
+		url.QueryEscape("tri://10.0.0.3:50051/com.foo.OtherService"))
+	waitFor(t, func() bool {
+		return cache.GetServiceEntryWrapper(host) == nil &&
+			cache.GetServiceEntryWrapper("com.foo.otherservice.zookeeper") != nil
+	})
+	if atomic.LoadInt32(&updated) < 4 {
+		t.Fatalf("should be updated at least 4 times, actual %d", atomic.LoadInt32(&updated))
+	}
+}